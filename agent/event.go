@@ -0,0 +1,265 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package agent
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dtn7/cboring"
+	"github.com/dtn7/dtn7-go/bundle"
+)
+
+// BundleEventType describes a step within a bundle's lifecycle.
+type BundleEventType uint64
+
+const (
+	// BundleReceived is emitted for each bundle received from a CLA.
+	BundleReceived BundleEventType = 0
+
+	// BundleForwarded is emitted for each CLA a bundle was successfully sent to.
+	BundleForwarded BundleEventType = 1
+
+	// BundleDelivered is emitted after a bundle was delivered to a local ApplicationAgent.
+	BundleDelivered BundleEventType = 2
+
+	// BundleContraindicated is emitted after a bundle was marked for contraindication.
+	BundleContraindicated BundleEventType = 3
+
+	// BundleDeleted is emitted after a bundle was marked for deletion.
+	BundleDeleted BundleEventType = 4
+)
+
+func (bet BundleEventType) String() string {
+	switch bet {
+	case BundleReceived:
+		return "received"
+	case BundleForwarded:
+		return "forwarded"
+	case BundleDelivered:
+		return "delivered"
+	case BundleContraindicated:
+		return "contraindicated"
+	case BundleDeleted:
+		return "deleted"
+	default:
+		return fmt.Sprintf("unknown (%d)", uint64(bet))
+	}
+}
+
+// BundleEvent is emitted by the Core for a step within a bundle's lifecycle.
+type BundleEvent struct {
+	Type BundleEventType
+
+	// Bundle identifies the bundle, including its source node.
+	Bundle bundle.BundleID
+	// Destination of the bundle.
+	Destination bundle.EndpointID
+
+	// Peer is the node a bundle was received from or forwarded to, if known. Otherwise it is the zero EndpointID.
+	Peer bundle.EndpointID
+	// Cla is the address of the CLA a bundle was received from or forwarded to, if available.
+	Cla string
+	// Reason is a BundleDeleted event's status report reason.
+	Reason bundle.StatusReportReason
+
+	Time time.Time
+}
+
+func (be BundleEvent) String() string {
+	return fmt.Sprintf("%v %v", be.Type, be.Bundle)
+}
+
+// MarshalCbor writes a BundleEvent's CBOR representation. Unknown endpoints are encoded as empty text strings.
+func (be *BundleEvent) MarshalCbor(w io.Writer) error {
+	if err := cboring.WriteArrayLength(7, w); err != nil {
+		return err
+	}
+
+	if err := cboring.WriteUInt(uint64(be.Type), w); err != nil {
+		return err
+	}
+
+	bidLen := uint64(2)
+	if be.Bundle.IsFragment {
+		bidLen = 4
+	}
+	if err := cboring.WriteArrayLength(bidLen, w); err != nil {
+		return err
+	} else if err := cboring.Marshal(&be.Bundle, w); err != nil {
+		return err
+	}
+
+	for _, eid := range []bundle.EndpointID{be.Destination, be.Peer} {
+		if err := cboring.WriteTextString(eventEndpointText(eid), w); err != nil {
+			return err
+		}
+	}
+
+	if err := cboring.WriteTextString(be.Cla, w); err != nil {
+		return err
+	}
+
+	if err := cboring.WriteUInt(uint64(be.Reason), w); err != nil {
+		return err
+	}
+
+	return cboring.WriteUInt(uint64(be.Time.UnixNano()), w)
+}
+
+// UnmarshalCbor creates a BundleEvent from its CBOR representation.
+func (be *BundleEvent) UnmarshalCbor(r io.Reader) error {
+	if n, err := cboring.ReadArrayLength(r); err != nil {
+		return err
+	} else if n != 7 {
+		return fmt.Errorf("expected CBOR array of 7 elements, not %d", n)
+	}
+
+	if n, err := cboring.ReadUInt(r); err != nil {
+		return err
+	} else {
+		be.Type = BundleEventType(n)
+	}
+
+	if n, err := cboring.ReadArrayLength(r); err != nil {
+		return err
+	} else if n != 2 && n != 4 {
+		return fmt.Errorf("expected CBOR array of 2 or 4 elements for the bundle ID, not %d", n)
+	} else {
+		be.Bundle.IsFragment = n == 4
+	}
+	if err := cboring.Unmarshal(&be.Bundle, r); err != nil {
+		return err
+	}
+
+	for _, eid := range []*bundle.EndpointID{&be.Destination, &be.Peer} {
+		if text, err := cboring.ReadTextString(r); err != nil {
+			return err
+		} else if *eid, err = eventEndpoint(text); err != nil {
+			return err
+		}
+	}
+
+	if cla, err := cboring.ReadTextString(r); err != nil {
+		return err
+	} else {
+		be.Cla = cla
+	}
+
+	if n, err := cboring.ReadUInt(r); err != nil {
+		return err
+	} else {
+		be.Reason = bundle.StatusReportReason(n)
+	}
+
+	if n, err := cboring.ReadUInt(r); err != nil {
+		return err
+	} else {
+		be.Time = time.Unix(0, int64(n))
+	}
+
+	return nil
+}
+
+// BundleEventFilter selects BundleEvents. Each empty field matches all events.
+type BundleEventFilter struct {
+	Types       []BundleEventType
+	Source      bundle.EndpointID
+	Destination bundle.EndpointID
+}
+
+// Matches checks if a BundleEvent is selected by this filter.
+func (bef BundleEventFilter) Matches(be BundleEvent) bool {
+	if bef.Source != (bundle.EndpointID{}) && bef.Source != be.Bundle.SourceNode {
+		return false
+	}
+
+	if bef.Destination != (bundle.EndpointID{}) && bef.Destination != be.Destination {
+		return false
+	}
+
+	if len(bef.Types) == 0 {
+		return true
+	}
+	for _, t := range bef.Types {
+		if t == be.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalCbor writes a BundleEventFilter's CBOR representation. Empty endpoints are encoded as empty text strings.
+func (bef *BundleEventFilter) MarshalCbor(w io.Writer) error {
+	if err := cboring.WriteArrayLength(3, w); err != nil {
+		return err
+	}
+
+	if err := cboring.WriteArrayLength(uint64(len(bef.Types)), w); err != nil {
+		return err
+	}
+	for _, t := range bef.Types {
+		if err := cboring.WriteUInt(uint64(t), w); err != nil {
+			return err
+		}
+	}
+
+	for _, eid := range []bundle.EndpointID{bef.Source, bef.Destination} {
+		if err := cboring.WriteTextString(eventEndpointText(eid), w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UnmarshalCbor creates a BundleEventFilter from its CBOR representation.
+func (bef *BundleEventFilter) UnmarshalCbor(r io.Reader) error {
+	if n, err := cboring.ReadArrayLength(r); err != nil {
+		return err
+	} else if n != 3 {
+		return fmt.Errorf("expected CBOR array of 3 elements, not %d", n)
+	}
+
+	if n, err := cboring.ReadArrayLength(r); err != nil {
+		return err
+	} else {
+		bef.Types = nil
+		for i := uint64(0); i < n; i++ {
+			if t, err := cboring.ReadUInt(r); err != nil {
+				return err
+			} else {
+				bef.Types = append(bef.Types, BundleEventType(t))
+			}
+		}
+	}
+
+	for _, eid := range []*bundle.EndpointID{&bef.Source, &bef.Destination} {
+		if text, err := cboring.ReadTextString(r); err != nil {
+			return err
+		} else if *eid, err = eventEndpoint(text); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// eventEndpointText returns an endpoint's string representation or an empty string for the zero EndpointID.
+func eventEndpointText(eid bundle.EndpointID) string {
+	if eid == (bundle.EndpointID{}) {
+		return ""
+	}
+	return eid.String()
+}
+
+// eventEndpoint parses an endpoint from eventEndpointText.
+func eventEndpoint(text string) (bundle.EndpointID, error) {
+	if text == "" {
+		return bundle.EndpointID{}, nil
+	}
+	return bundle.NewEndpointID(text)
+}
@@ -61,3 +61,27 @@ type ShutdownMessage struct{}
 func (sm ShutdownMessage) Recipients() []bundle.EndpointID {
 	return nil
 }
+
+// EventSubscribeMessage is sent from an ApplicationAgent to subscribe the BundleEvents for its endpoint.
+//
+// The subscription ends after no ApplicationAgent is registered for this endpoint anymore. Each ApplicationAgent
+// should filter the received EventMessages on its own, as they are addressed to all ApplicationAgents of the endpoint.
+type EventSubscribeMessage struct {
+	Sender bundle.EndpointID
+}
+
+// Recipients are the endpoint of the subscribing ApplicationAgent.
+func (esm EventSubscribeMessage) Recipients() []bundle.EndpointID {
+	return []bundle.EndpointID{esm.Sender}
+}
+
+// EventMessage passes a BundleEvent to the ApplicationAgents of a subscribed endpoint.
+type EventMessage struct {
+	Event     BundleEvent
+	Recipient bundle.EndpointID
+}
+
+// Recipients are the sender of the EventSubscribeMessage.
+func (em EventMessage) Recipients() []bundle.EndpointID {
+	return []bundle.EndpointID{em.Recipient}
+}
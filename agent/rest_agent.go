@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -49,8 +50,16 @@ import (
 //   //          {"blockNumber":1,"blockTypeCode":1,"blockControlFlags":null,"data":"S2hlbGxvIHdvcmxk"}
 //   //        ]
 //   //      }
-//   //    ]}
-//   // <- {"error":"","bundles":[]}
+//   //    ],"sequences":[1586874726000000000],"sequence":1586874726000000000}
+//   // <- {"error":"","bundles":[],"sequences":[],"sequence":1586874726000000000}
+//
+//   //    An optional timeout lets the request wait for new bundles, up to five minutes
+//   // -> {"uuid":"75be76e2-23fc-da0e-eeb8-4773f84a9d2f","timeout":"30s"}
+//
+//   //    Received bundles are kept until being acknowledged, POST to /ack
+//   //    Without persistence, the next fetch releases them, even without an acknowledgement
+//   // -> {"uuid":"75be76e2-23fc-da0e-eeb8-4773f84a9d2f","sequence":1586874726000000000}
+//   // <- {"error":""}
 //
 //   // 3. Create and dispatch a new bundle, POST to /build
 //   // -> {
@@ -69,6 +78,36 @@ import (
 //   // -> {"uuid":"75be76e2-23fc-da0e-eeb8-4773f84a9d2f"}
 //   // <- {"error":""}
 //
+// Alternatively, bundles can be received as Server-Sent Events by a GET request to /events/{uuid}. Each received
+// bundle is an event of the type "bundle", while bundle status reports are "status-report" events, described by the
+// RestStatusReport type. An event's ID is the bundle's sequence number, which should be acknowledged by /ack as well.
+// A reconnecting client sends its last received event's ID as the Last-Event-ID header. Otherwise, all unacknowledged
+// bundles will be sent again.
+//
+//   // GET /events/75be76e2-23fc-da0e-eeb8-4773f84a9d2f
+//   // <- id: 1586874726000000000
+//   //    event: bundle
+//   //    data: {"primaryBlock":{...},"canonicalBlocks":[...]}
+//
+// Binary payloads do not need to be encoded within JSON. A POST to /upload creates a bundle with the raw request body or
+// the "payload" field of a multipart form as its payload. The client's UUID and the bundle's parameters - destination,
+// source, report_to, lifetime, bundle_ctrl_flags, and hop_count_block - are passed as query parameters or as X-Dtn-
+// headers, e.g., X-Dtn-Report-To. A received bundle's payload can be downloaded by a GET request to
+// /download/{uuid}/{sequence}, or its CBOR encoding by appending the ?format=cbor query.
+//
+//   // POST to /upload?uuid=75be76e2-23fc-da0e-eeb8-4773f84a9d2f&destination=dtn://dst/&lifetime=1h
+//   // -> <binary payload>
+//   // <- {"error":"","bundle_id":"dtn://foo/bar-686833926000-0"}
+//
+//   // GET /download/75be76e2-23fc-da0e-eeb8-4773f84a9d2f/1586874726000000000
+//   // <- <binary payload>
+//
+// Requests for an unknown or unregistered UUID result in an "Invalid UUID" error.
+//
+// A RestAgent created by NewPersistentRestAgent keeps its registrations and unacknowledged bundles across restarts.
+// A RestAgent created by NewRestAgent releases fetched bundles with the next /fetch request, which implicitly
+// acknowledges them. Thus, clients without acknowledgements do not accumulate bundles, while a fetched bundle can
+// still be downloaded until the next fetch.
 type RestAgent struct {
 	router *mux.Router
 
@@ -77,7 +116,10 @@ type RestAgent struct {
 
 	// map UUIDs to EIDs and received bundles
 	clients sync.Map // uuid[string] -> bundle.EndpointID
-	mailbox sync.Map // uuid[string] -> []bundle.Bundle
+	mailbox sync.Map // uuid[string] -> *restMailbox
+
+	// store persists clients and mailboxes; might be nil
+	store *restAgentStore
 }
 
 // NewRestAgent creates a new RESTful Application Agent.
@@ -89,14 +131,70 @@ func NewRestAgent(router *mux.Router) (ra *RestAgent) {
 		sender:   make(chan Message),
 	}
 
+	ra.start()
+
+	return ra
+}
+
+// NewPersistentRestAgent creates a new RESTful Application Agent, persisting its registrations and mailboxes within
+// the given directory. Previously persisted registrations and mailboxes will be restored.
+func NewPersistentRestAgent(router *mux.Router, dir string) (ra *RestAgent, err error) {
+	store, err := newRestAgentStore(dir)
+	if err != nil {
+		return
+	}
+
+	clients, mailboxes, err := store.load()
+	if err != nil {
+		return
+	}
+
+	ra = &RestAgent{
+		router: router,
+
+		receiver: make(chan Message),
+		sender:   make(chan Message),
+
+		store: store,
+	}
+
+	for uuid, eid := range clients {
+		ra.clients.Store(uuid, eid)
+		ra.mailbox.Store(uuid, mailboxes[uuid])
+	}
+
+	log.WithFields(log.Fields{
+		"directory": dir,
+		"clients":   len(clients),
+	}).Info("REST Agent restored its persisted clients")
+
+	ra.start()
+
+	return
+}
+
+// start binds the HTTP handlers and starts the handler.
+func (ra *RestAgent) start() {
 	ra.router.HandleFunc("/register", ra.handleRegister).Methods(http.MethodPost)
 	ra.router.HandleFunc("/unregister", ra.handleUnregister).Methods(http.MethodPost)
 	ra.router.HandleFunc("/fetch", ra.handleFetch).Methods(http.MethodPost)
+	ra.router.HandleFunc("/ack", ra.handleAck).Methods(http.MethodPost)
 	ra.router.HandleFunc("/build", ra.handleBuild).Methods(http.MethodPost)
+	ra.router.HandleFunc("/events/{uuid}", ra.handleEvents).Methods(http.MethodGet)
+	ra.router.HandleFunc("/upload", ra.handleUpload).Methods(http.MethodPost)
+	ra.router.HandleFunc("/download/{uuid}/{sequence}", ra.handleDownload).Methods(http.MethodGet)
 
 	go ra.handler()
+}
 
-	return ra
+// clientMailbox returns the mailbox of a registered client, or false for an unknown UUID.
+func (ra *RestAgent) clientMailbox(uuid string) (*restMailbox, bool) {
+	if _, ok := ra.clients.Load(uuid); !ok {
+		return nil, false
+	}
+
+	mb, _ := ra.mailbox.LoadOrStore(uuid, new(restMailbox))
+	return mb.(*restMailbox), true
 }
 
 // handler checks the receiver channel and deals with inbounding messages.
@@ -125,23 +223,30 @@ func (ra *RestAgent) receiveBundleMessage(msg BundleMessage) {
 		if bagHasEndpoint(msg.Recipients(), v.(bundle.EndpointID)) {
 			uuids = append(uuids, k.(string))
 		}
-		return false // multiple clients might be registered for some endpoint
+		return true // multiple clients might be registered for some endpoint
 	})
 
 	for _, uuid := range uuids {
-		var bundles []bundle.Bundle
-		if val, ok := ra.mailbox.Load(uuid); !ok {
-			bundles = []bundle.Bundle{msg.Bundle}
-		} else {
-			bundles = append(val.([]bundle.Bundle), msg.Bundle)
+		mb, ok := ra.clientMailbox(uuid)
+		if !ok {
+			continue
 		}
 
-		ra.mailbox.Store(uuid, bundles)
+		var store func(restMailboxEntry) error
+		if ra.store != nil {
+			store = func(entry restMailboxEntry) error { return ra.store.storeEntry(uuid, entry) }
+		}
 
-		log.WithFields(log.Fields{
+		logger := log.WithFields(log.Fields{
 			"bundle": msg.Bundle.ID().String(),
 			"uuid":   uuid,
-		}).Info("REST Application Agent delivering message to a client's inbox")
+		})
+
+		if _, err := mb.add(msg.Bundle, store); err != nil {
+			logger.WithError(err).Warn("REST Application Agent failed to persist message for a client's inbox")
+		} else {
+			logger.Info("REST Application Agent delivering message to a client's inbox")
+		}
 	}
 }
 
@@ -168,8 +273,11 @@ func (ra *RestAgent) handleRegister(w http.ResponseWriter, r *http.Request) {
 		registerResponse.Error = eidErr.Error()
 	} else if uuid, uuidErr := ra.randomUuid(); uuidErr != nil {
 		registerResponse.Error = uuidErr.Error()
+	} else if ra.store != nil && ra.store.register(uuid, eid) != nil {
+		registerResponse.Error = "Failed to persist registration"
 	} else {
 		ra.clients.Store(uuid, eid)
+		ra.mailbox.Store(uuid, new(restMailbox))
 		registerResponse.UUID = uuid
 	}
 
@@ -193,10 +301,21 @@ func (ra *RestAgent) handleUnregister(w http.ResponseWriter, r *http.Request) {
 
 	if jsonErr := json.NewDecoder(r.Body).Decode(&unregisterRequest); jsonErr != nil {
 		log.WithError(jsonErr).Warn("Failed to parse REST unregistration request")
+		unregisterResponse.Error = jsonErr.Error()
+	} else if _, ok := ra.clients.Load(unregisterRequest.UUID); !ok {
+		log.WithField("uuid", unregisterRequest.UUID).Debug("REST client cannot unregister unknown UUID")
+		unregisterResponse.Error = "Invalid UUID"
 	} else {
 		log.WithField("uuid", unregisterRequest.UUID).Info("Unregister REST client")
 		ra.clients.Delete(unregisterRequest.UUID)
 		ra.mailbox.Delete(unregisterRequest.UUID)
+
+		if ra.store != nil {
+			if err := ra.store.unregister(unregisterRequest.UUID); err != nil {
+				log.WithError(err).WithField("uuid", unregisterRequest.UUID).Warn(
+					"Failed to remove persisted REST client")
+			}
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -215,14 +334,34 @@ func (ra *RestAgent) handleFetch(w http.ResponseWriter, r *http.Request) {
 	if jsonErr := json.NewDecoder(r.Body).Decode(&fetchRequest); jsonErr != nil {
 		log.WithError(jsonErr).Warn("Failed to parse REST fetch request")
 		fetchResponse.Error = jsonErr.Error()
-	} else if val, ok := ra.mailbox.Load(fetchRequest.UUID); ok {
-		log.WithField("uuid", fetchRequest.UUID).Info("REST client fetches bundles")
-		fetchResponse.Bundles = val.([]bundle.Bundle)
-
-		ra.mailbox.Delete(fetchRequest.UUID)
-	} else if !ok {
-		log.WithField("uuid", fetchRequest.UUID).Debug("REST client has no new bundles to fetch")
-		fetchResponse.Bundles = make([]bundle.Bundle, 0)
+	} else if mb, ok := ra.clientMailbox(fetchRequest.UUID); !ok {
+		log.WithField("uuid", fetchRequest.UUID).Debug("REST client cannot fetch for unknown UUID")
+		fetchResponse.Error = "Invalid UUID"
+	} else if timeout, timeoutErr := restFetchTimeout(fetchRequest.Timeout); timeoutErr != nil {
+		log.WithError(timeoutErr).WithField("uuid", fetchRequest.UUID).Debug("REST client's fetch timeout is invalid")
+		fetchResponse.Error = timeoutErr.Error()
+	} else {
+		if ra.store == nil {
+			// Without persistence, each fetch acknowledges all previously fetched bundles.
+			mb.releaseFetched()
+		}
+
+		entries, sequence := mb.fetchWait(r.Context(), timeout)
+
+		fetchResponse.Bundles = make([]bundle.Bundle, 0, len(entries))
+		fetchResponse.Sequences = make([]uint64, 0, len(entries))
+		for _, entry := range entries {
+			fetchResponse.Bundles = append(fetchResponse.Bundles, entry.Bundle)
+			fetchResponse.Sequences = append(fetchResponse.Sequences, entry.Sequence)
+		}
+		fetchResponse.Sequence = sequence
+
+		log.WithFields(log.Fields{
+			"uuid":     fetchRequest.UUID,
+			"timeout":  timeout,
+			"bundles":  len(fetchResponse.Bundles),
+			"sequence": fetchResponse.Sequence,
+		}).Info("REST client fetches bundles")
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -231,6 +370,65 @@ func (ra *RestAgent) handleFetch(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// restFetchMaxTimeout limits the time a /fetch request might wait for new bundles.
+const restFetchMaxTimeout = 5 * time.Minute
+
+// restFetchTimeout parses a /fetch request's optional timeout, bounded by restFetchMaxTimeout.
+func restFetchTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return 0, err
+	} else if d < 0 {
+		return 0, fmt.Errorf("timeout must not be negative")
+	} else if d > restFetchMaxTimeout {
+		d = restFetchMaxTimeout
+	}
+	return d, nil
+}
+
+// handleAck releases the acknowledged bundles from some client's inbox, called by /ack.
+func (ra *RestAgent) handleAck(w http.ResponseWriter, r *http.Request) {
+	var (
+		ackRequest  RestAckRequest
+		ackResponse RestAckResponse
+	)
+
+	if jsonErr := json.NewDecoder(r.Body).Decode(&ackRequest); jsonErr != nil {
+		log.WithError(jsonErr).Warn("Failed to parse REST ack request")
+		ackResponse.Error = jsonErr.Error()
+	} else if mb, ok := ra.clientMailbox(ackRequest.UUID); !ok {
+		log.WithField("uuid", ackRequest.UUID).Debug("REST client cannot acknowledge for unknown UUID")
+		ackResponse.Error = "Invalid UUID"
+	} else if released, ackErr := mb.acknowledge(ackRequest.Sequence); ackErr != nil {
+		log.WithError(ackErr).WithField("uuid", ackRequest.UUID).Debug("REST client's acknowledgement failed")
+		ackResponse.Error = ackErr.Error()
+	} else {
+		log.WithFields(log.Fields{
+			"uuid":     ackRequest.UUID,
+			"sequence": ackRequest.Sequence,
+			"released": len(released),
+		}).Info("REST client acknowledged fetched bundles")
+
+		if ra.store != nil {
+			for _, entry := range released {
+				if err := ra.store.deleteEntry(ackRequest.UUID, entry); err != nil {
+					log.WithError(err).WithField("uuid", ackRequest.UUID).Warn(
+						"Failed to remove persisted mailbox entry")
+				}
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ackResponse); err != nil {
+		log.WithError(err).Warn("Failed to write REST ack response")
+	}
+}
+
 // handleBuild creates and dispatches a new bundle, called by /build.
 func (ra *RestAgent) handleBuild(w http.ResponseWriter, r *http.Request) {
 	var (
@@ -272,7 +470,7 @@ func (ra *RestAgent) handleBuild(w http.ResponseWriter, r *http.Request) {
 func (ra *RestAgent) Endpoints() (eids []bundle.EndpointID) {
 	ra.clients.Range(func(_, v interface{}) bool {
 		eids = append(eids, v.(bundle.EndpointID))
-		return false
+		return true
 	})
 	return
 }
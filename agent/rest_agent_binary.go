@@ -0,0 +1,193 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/gorilla/mux"
+)
+
+// restUploadMaxSize limits the size of an uploaded payload.
+const restUploadMaxSize = 64 << 20
+
+// restParam returns a request's parameter, either from the URL's query or from the equivalent X-Dtn- header, e.g.,
+// "report_to" might be passed as a query parameter or as the X-Dtn-Report-To header.
+func restParam(r *http.Request, name string) string {
+	if v := r.URL.Query().Get(name); v != "" {
+		return v
+	}
+	return r.Header.Get("X-Dtn-" + strings.ReplaceAll(name, "_", "-"))
+}
+
+// restUploadPayload reads an upload's payload, either the raw body or the "payload" field of a multipart form.
+func restUploadPayload(r *http.Request) ([]byte, error) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		f, _, err := r.FormFile("payload")
+		if err != nil {
+			return nil, fmt.Errorf("multipart upload misses its payload field: %v", err)
+		}
+		defer f.Close()
+
+		return ioutil.ReadAll(f)
+	}
+
+	return ioutil.ReadAll(r.Body)
+}
+
+// restUploadBundle creates a bundle for an uploaded payload from the request's parameters. The client's endpoint is
+// used as the default source.
+func restUploadBundle(r *http.Request, eid bundle.EndpointID, payload []byte) (b bundle.Bundle, err error) {
+	bldr := bundle.Builder().
+		Destination(restParam(r, "destination")).
+		CreationTimestampNow()
+
+	if source := restParam(r, "source"); source != "" {
+		bldr.Source(source)
+	} else {
+		bldr.Source(eid)
+	}
+
+	if reportTo := restParam(r, "report_to"); reportTo != "" {
+		bldr.ReportTo(reportTo)
+	}
+
+	if lifetime := restParam(r, "lifetime"); lifetime != "" {
+		bldr.Lifetime(lifetime)
+	} else {
+		bldr.Lifetime("24h")
+	}
+
+	if flags := restParam(r, "bundle_ctrl_flags"); flags != "" {
+		if bcf, bcfErr := strconv.ParseUint(flags, 0, 64); bcfErr != nil {
+			err = fmt.Errorf("invalid bundle_ctrl_flags: %v", bcfErr)
+			return
+		} else {
+			bldr.BundleCtrlFlags(bundle.BundleControlFlags(bcf))
+		}
+	}
+
+	if hopCount := restParam(r, "hop_count_block"); hopCount != "" {
+		if limit, limitErr := strconv.ParseUint(hopCount, 10, 8); limitErr != nil {
+			err = fmt.Errorf("invalid hop_count_block: %v", limitErr)
+			return
+		} else {
+			bldr.HopCountBlock(int(limit))
+		}
+	}
+
+	return bldr.PayloadBlock(payload).Build()
+}
+
+// handleUpload creates and dispatches a new bundle for a binary payload, called by /upload.
+func (ra *RestAgent) handleUpload(w http.ResponseWriter, r *http.Request) {
+	var uploadResponse RestUploadResponse
+
+	uuid := restParam(r, "uuid")
+	r.Body = http.MaxBytesReader(w, r.Body, restUploadMaxSize)
+
+	if eid, ok := ra.clients.Load(uuid); !ok {
+		log.WithField("uuid", uuid).Debug("REST client cannot upload for unknown UUID")
+		uploadResponse.Error = "Invalid UUID"
+	} else if payload, payloadErr := restUploadPayload(r); payloadErr != nil {
+		log.WithError(payloadErr).WithField("uuid", uuid).Warn("Failed to read REST client's upload")
+		uploadResponse.Error = payloadErr.Error()
+	} else if b, bErr := restUploadBundle(r, eid.(bundle.EndpointID), payload); bErr != nil {
+		log.WithError(bErr).WithField("uuid", uuid).Warn("REST client failed to build a bundle from its upload")
+		uploadResponse.Error = bErr.Error()
+	} else if pb := b.PrimaryBlock; pb.SourceNode != eid && pb.ReportTo != eid {
+		msg := "REST client's endpoint is neither the source nor the report_to field"
+		log.WithFields(log.Fields{
+			"uuid":     uuid,
+			"endpoint": eid,
+			"bundle":   b.ID().String(),
+		}).Warn(msg)
+		uploadResponse.Error = msg
+	} else {
+		log.WithFields(log.Fields{
+			"uuid":    uuid,
+			"bundle":  b.ID().String(),
+			"payload": len(payload),
+		}).Info("REST client uploaded bundle")
+		ra.sender <- BundleMessage{Bundle: b}
+		uploadResponse.BundleId = b.ID().String()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(uploadResponse); err != nil {
+		log.WithError(err).Warn("Failed to write REST upload response")
+	}
+}
+
+// handleDownload writes a received bundle's payload or, for the "cbor" format, its CBOR encoding, called by
+// /download/{uuid}/{sequence}.
+func (ra *RestAgent) handleDownload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	logger := log.WithFields(log.Fields{
+		"uuid":     vars["uuid"],
+		"sequence": vars["sequence"],
+	})
+
+	mb, ok := ra.clientMailbox(vars["uuid"])
+	if !ok {
+		logger.Debug("REST client cannot download for unknown UUID")
+		http.Error(w, "Invalid UUID", http.StatusNotFound)
+		return
+	}
+
+	sequence, err := strconv.ParseUint(vars["sequence"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid sequence number", http.StatusBadRequest)
+		return
+	}
+
+	entry, ok := mb.entry(sequence)
+	if !ok {
+		logger.Debug("REST client cannot download unknown mailbox entry")
+		http.Error(w, "Unknown sequence number", http.StatusNotFound)
+		return
+	}
+
+	var writeBody func(io.Writer) error
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "payload":
+		payloadBlock, pbErr := entry.Bundle.PayloadBlock()
+		if pbErr != nil {
+			http.Error(w, pbErr.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		data := payloadBlock.Value.(*bundle.PayloadBlock).Data()
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		writeBody = func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		}
+
+	case "cbor":
+		writeBody = entry.Bundle.WriteBundle
+
+	default:
+		http.Error(w, fmt.Sprintf("Unknown format %q", format), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if err := writeBody(w); err != nil {
+		logger.WithError(err).Warn("Failed to write REST download")
+	} else {
+		logger.WithField("bundle", entry.Bundle.ID().String()).Info("REST client downloaded bundle")
+	}
+}
@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/gorilla/mux"
+)
+
+// restEventsKeepalive is the interval of comments sent on an idle event stream, keeping its connection alive.
+const restEventsKeepalive = 15 * time.Second
+
+// handleEvents streams the bundles from some client's inbox as Server-Sent Events, called by GET /events/{uuid}.
+func (ra *RestAgent) handleEvents(w http.ResponseWriter, r *http.Request) {
+	uuid := mux.Vars(r)["uuid"]
+	logger := log.WithField("uuid", uuid)
+
+	mb, ok := ra.clientMailbox(uuid)
+	if !ok {
+		logger.Debug("REST client cannot stream events for unknown UUID")
+		http.Error(w, "Invalid UUID", http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		logger.Warn("REST event stream is not supported by the HTTP connection")
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var lastEventId uint64
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		var err error
+		if lastEventId, err = strconv.ParseUint(id, 10, 64); err != nil {
+			logger.WithError(err).Debug("REST client's Last-Event-ID is invalid")
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	logger.WithField("last_event_id", lastEventId).Info("REST client started an event stream")
+	defer logger.Info("REST client's event stream ended")
+
+	keepalive := time.NewTicker(restEventsKeepalive)
+	defer keepalive.Stop()
+
+	// Send all bundles after the last received event, or all unacknowledged ones for a new stream.
+	sequence := lastEventId
+
+	for {
+		var (
+			entries []restMailboxEntry
+			wait    <-chan struct{}
+		)
+
+		entries, sequence, wait = mb.fetchSince(sequence)
+		for _, entry := range entries {
+			if err := writeRestEvent(w, entry); err != nil {
+				logger.WithError(err).Debug("Failed to write REST event")
+				return
+			}
+		}
+
+		if len(entries) > 0 {
+			flusher.Flush()
+			continue
+		}
+
+		select {
+		case <-wait:
+
+		case <-keepalive.C:
+			if _, ok := ra.clients.Load(uuid); !ok {
+				return
+			} else if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeRestEvent writes a mailbox entry as a Server-Sent Event, identified by its sequence number.
+func writeRestEvent(w io.Writer, entry restMailboxEntry) error {
+	var (
+		event = "bundle"
+		data  interface{}
+	)
+
+	if sr, ok := restStatusReport(entry.Bundle); ok {
+		event, data = "status-report", sr
+	} else {
+		data = entry.Bundle
+	}
+
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", entry.Sequence, event, dataJson)
+	return err
+}
+
+// restStatusReport creates a RestStatusReport for a bundle, if it contains a bundle status report.
+func restStatusReport(b bundle.Bundle) (rsr RestStatusReport, ok bool) {
+	if !b.IsAdministrativeRecord() {
+		return
+	}
+
+	payloadBlock, err := b.PayloadBlock()
+	if err != nil {
+		return
+	}
+
+	ar, err := bundle.NewAdministrativeRecordFromCbor(payloadBlock.Value.(*bundle.PayloadBlock).Data())
+	if err != nil {
+		return
+	}
+
+	sr, ok := ar.(*bundle.StatusReport)
+	if !ok {
+		return
+	}
+
+	rsr = RestStatusReport{
+		Bundle:    b,
+		RefBundle: sr.RefBundle.String(),
+		Reason:    sr.ReportReason.String(),
+		Status:    make([]string, 0, len(sr.StatusInformation)),
+	}
+	for _, sip := range sr.StatusInformations() {
+		rsr.Status = append(rsr.Status, sip.String())
+	}
+	return
+}
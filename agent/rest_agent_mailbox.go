@@ -0,0 +1,296 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dtn7/dtn7-go/bundle"
+)
+
+// restMailboxEntry is a bundle within a restMailbox, identified by its sequence number.
+type restMailboxEntry struct {
+	Sequence uint64
+	Bundle   bundle.Bundle
+}
+
+// restMailbox holds the received bundles of a REST client until their acknowledgement.
+//
+// Each fetch returns the bundles received since the previous fetch. Those bundles are kept until the client
+// acknowledges them by their sequence number. After a restart, all unacknowledged bundles are fetched again.
+type restMailbox struct {
+	sync.Mutex
+
+	entries []restMailboxEntry
+	fetched uint64
+
+	// notify will be closed on the next added entry, if not nil.
+	notify chan struct{}
+}
+
+// nextSequence returns a new sequence number, which is greater than all others of this mailbox.
+func (mb *restMailbox) nextSequence() uint64 {
+	seq := uint64(time.Now().UnixNano())
+	if l := len(mb.entries); l > 0 && mb.entries[l-1].Sequence >= seq {
+		seq = mb.entries[l-1].Sequence + 1
+	}
+	return seq
+}
+
+// add a bundle to this mailbox, returning its new entry. The entry is created by the store function, if not nil,
+// allowing to persist it before being added.
+func (mb *restMailbox) add(b bundle.Bundle, store func(restMailboxEntry) error) (entry restMailboxEntry, err error) {
+	mb.Lock()
+	defer mb.Unlock()
+
+	entry = restMailboxEntry{Sequence: mb.nextSequence(), Bundle: b}
+	if store != nil {
+		if err = store(entry); err != nil {
+			return
+		}
+	}
+
+	mb.entries = append(mb.entries, entry)
+
+	if mb.notify != nil {
+		close(mb.notify)
+		mb.notify = nil
+	}
+	return
+}
+
+// fetch all entries received since the last fetch, together with the last entry's sequence number. If there are no
+// such entries, the returned channel will be closed after the next entry was added.
+func (mb *restMailbox) fetch() (entries []restMailboxEntry, sequence uint64, wait <-chan struct{}) {
+	mb.Lock()
+	defer mb.Unlock()
+
+	return mb.entriesSince(mb.fetched)
+}
+
+// fetchSince fetches like fetch, but returns all entries after the given sequence number, independent of previous
+// fetches. This allows multiple readers, each one tracking its own sequence number.
+func (mb *restMailbox) fetchSince(since uint64) (entries []restMailboxEntry, sequence uint64, wait <-chan struct{}) {
+	mb.Lock()
+	defer mb.Unlock()
+
+	return mb.entriesSince(since)
+}
+
+// entriesSince implements fetch and fetchSince; the mailbox must be locked. The fetched sequence number will only be
+// increased, allowing the acknowledgement of all returned entries.
+func (mb *restMailbox) entriesSince(since uint64) (entries []restMailboxEntry, sequence uint64, wait <-chan struct{}) {
+	sequence = since
+
+	for _, entry := range mb.entries {
+		if entry.Sequence > since {
+			entries = append(entries, entry)
+			sequence = entry.Sequence
+		}
+	}
+
+	if sequence > mb.fetched {
+		mb.fetched = sequence
+	}
+
+	if len(entries) == 0 {
+		if mb.notify == nil {
+			mb.notify = make(chan struct{})
+		}
+		wait = mb.notify
+	}
+	return
+}
+
+// fetchWait fetches like fetch, but waits up to the timeout for new entries if there are none.
+func (mb *restMailbox) fetchWait(ctx context.Context, timeout time.Duration) (entries []restMailboxEntry, sequence uint64) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		var wait <-chan struct{}
+		if entries, sequence, wait = mb.fetch(); wait == nil || timeout <= 0 {
+			return
+		}
+
+		select {
+		case <-wait:
+		case <-timer.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// entry returns the mailbox entry for a sequence number, if present.
+func (mb *restMailbox) entry(sequence uint64) (restMailboxEntry, bool) {
+	mb.Lock()
+	defer mb.Unlock()
+
+	i := sort.Search(len(mb.entries), func(i int) bool { return mb.entries[i].Sequence >= sequence })
+	if i < len(mb.entries) && mb.entries[i].Sequence == sequence {
+		return mb.entries[i], true
+	}
+	return restMailboxEntry{}, false
+}
+
+// acknowledge all fetched bundles up to the given sequence number, returning the released entries.
+func (mb *restMailbox) acknowledge(sequence uint64) (released []restMailboxEntry, err error) {
+	mb.Lock()
+	defer mb.Unlock()
+
+	if sequence > mb.fetched {
+		err = fmt.Errorf("sequence number %d was not fetched yet", sequence)
+		return
+	}
+
+	i := sort.Search(len(mb.entries), func(i int) bool { return mb.entries[i].Sequence > sequence })
+	released, mb.entries = mb.entries[:i], mb.entries[i:]
+	return
+}
+
+// releaseFetched releases all previously fetched entries, as an implicit acknowledgement.
+func (mb *restMailbox) releaseFetched() (released []restMailboxEntry) {
+	mb.Lock()
+	defer mb.Unlock()
+
+	i := sort.Search(len(mb.entries), func(i int) bool { return mb.entries[i].Sequence > mb.fetched })
+	released, mb.entries = mb.entries[:i], mb.entries[i:]
+	return
+}
+
+// restAgentStore persists the RestAgent's registrations and mailboxes within a directory.
+//
+// Each registered client has a subdirectory named after its UUID. This subdirectory contains an endpoint file with
+// the client's endpoint ID and a file for each mailbox entry, named after its sequence number.
+type restAgentStore struct {
+	dir string
+}
+
+const restAgentStoreEndpoint = "endpoint"
+
+// newRestAgentStore creates a new restAgentStore, creating the directory if necessary.
+func newRestAgentStore(dir string) (*restAgentStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &restAgentStore{dir: dir}, nil
+}
+
+// register a client's UUID for its endpoint.
+func (rs *restAgentStore) register(uuid string, eid bundle.EndpointID) error {
+	clientDir := filepath.Join(rs.dir, uuid)
+	if err := os.MkdirAll(clientDir, 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(clientDir, restAgentStoreEndpoint), []byte(eid.String()), 0600)
+}
+
+// unregister a client, removing its mailbox.
+func (rs *restAgentStore) unregister(uuid string) error {
+	return os.RemoveAll(filepath.Join(rs.dir, uuid))
+}
+
+// entryPath returns the file path for a client's mailbox entry.
+func (rs *restAgentStore) entryPath(uuid string, sequence uint64) string {
+	return filepath.Join(rs.dir, uuid, fmt.Sprintf("%020d", sequence))
+}
+
+// storeEntry persists a mailbox entry.
+func (rs *restAgentStore) storeEntry(uuid string, entry restMailboxEntry) error {
+	f, err := os.OpenFile(rs.entryPath(uuid, entry.Sequence), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := entry.Bundle.WriteBundle(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// deleteEntry removes a persisted mailbox entry.
+func (rs *restAgentStore) deleteEntry(uuid string, entry restMailboxEntry) error {
+	return os.Remove(rs.entryPath(uuid, entry.Sequence))
+}
+
+// load all registered clients and their mailboxes.
+func (rs *restAgentStore) load() (clients map[string]bundle.EndpointID, mailboxes map[string]*restMailbox, err error) {
+	clients = make(map[string]bundle.EndpointID)
+	mailboxes = make(map[string]*restMailbox)
+
+	clientDirs, err := ioutil.ReadDir(rs.dir)
+	if err != nil {
+		return
+	}
+
+	for _, clientDir := range clientDirs {
+		if !clientDir.IsDir() {
+			continue
+		}
+
+		uuid := clientDir.Name()
+		if clients[uuid], mailboxes[uuid], err = rs.loadClient(uuid); err != nil {
+			err = fmt.Errorf("loading REST client %s errored: %v", uuid, err)
+			return
+		}
+	}
+
+	return
+}
+
+// loadClient loads a client's endpoint and mailbox.
+func (rs *restAgentStore) loadClient(uuid string) (eid bundle.EndpointID, mb *restMailbox, err error) {
+	clientDir := filepath.Join(rs.dir, uuid)
+
+	eidData, err := ioutil.ReadFile(filepath.Join(clientDir, restAgentStoreEndpoint))
+	if err != nil {
+		return
+	} else if eid, err = bundle.NewEndpointID(strings.TrimSpace(string(eidData))); err != nil {
+		return
+	}
+
+	files, err := ioutil.ReadDir(clientDir)
+	if err != nil {
+		return
+	}
+
+	mb = new(restMailbox)
+	for _, file := range files {
+		sequence, parseErr := strconv.ParseUint(file.Name(), 10, 64)
+		if parseErr != nil {
+			continue
+		}
+
+		f, openErr := os.Open(filepath.Join(clientDir, file.Name()))
+		if openErr != nil {
+			err = openErr
+			return
+		}
+
+		b, parseErr := bundle.ParseBundle(f)
+		_ = f.Close()
+		if parseErr != nil {
+			err = fmt.Errorf("parsing mailbox entry %d errored: %v", sequence, parseErr)
+			return
+		}
+
+		mb.entries = append(mb.entries, restMailboxEntry{Sequence: sequence, Bundle: b})
+	}
+
+	sort.Slice(mb.entries, func(i, j int) bool { return mb.entries[i].Sequence < mb.entries[j].Sequence })
+	return
+}
@@ -27,15 +27,31 @@ type RestUnregisterResponse struct {
 	Error string `json:"error"`
 }
 
-// RestFetchRequest describes a JSON to be POSTed to /fetch.
+// RestFetchRequest describes a JSON to be POSTed to /fetch. The optional Timeout is a duration, e.g., "30s", to wait
+// for new bundles if there are none.
 type RestFetchRequest struct {
-	UUID string `json:"uuid"`
+	UUID    string `json:"uuid"`
+	Timeout string `json:"timeout,omitempty"`
 }
 
-// RestFetchResponse describes a JSON response for /fetch.
+// RestFetchResponse describes a JSON response for /fetch. The Sequences list each bundle's sequence number, while the
+// Sequence number identifies the last fetched bundle.
 type RestFetchResponse struct {
-	Error   string          `json:"error"`
-	Bundles []bundle.Bundle `json:"bundles"`
+	Error     string          `json:"error"`
+	Bundles   []bundle.Bundle `json:"bundles"`
+	Sequences []uint64        `json:"sequences"`
+	Sequence  uint64          `json:"sequence"`
+}
+
+// RestAckRequest describes a JSON to be POSTed to /ack, acknowledging all fetched bundles up to the Sequence number.
+type RestAckRequest struct {
+	UUID     string `json:"uuid"`
+	Sequence uint64 `json:"sequence"`
+}
+
+// RestAckResponse describes a JSON response for /ack.
+type RestAckResponse struct {
+	Error string `json:"error"`
 }
 
 // RestBuildRequest describes a JSON to be POSTed to /build.
@@ -48,3 +64,17 @@ type RestBuildRequest struct {
 type RestBuildResponse struct {
 	Error string `json:"error"`
 }
+
+// RestUploadResponse describes a JSON response for /upload, containing the created bundle's ID.
+type RestUploadResponse struct {
+	Error    string `json:"error"`
+	BundleId string `json:"bundle_id"`
+}
+
+// RestStatusReport describes the JSON data of a "status-report" event, sent by /events for a bundle status report.
+type RestStatusReport struct {
+	Bundle    bundle.Bundle `json:"bundle"`
+	RefBundle string        `json:"ref_bundle"`
+	Reason    string        `json:"reason"`
+	Status    []string      `json:"status"`
+}
@@ -5,10 +5,16 @@
 package agent
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"os"
 	"reflect"
 	"strings"
 	"sync"
@@ -207,3 +213,523 @@ func TestRestAgentCycle(t *testing.T) {
 		t.Fatal("endpoint is still registered")
 	}
 }
+
+// restPost POSTs a JSON request to a REST endpoint and decodes its JSON response.
+func restPost(t *testing.T, url string, request, response interface{}) {
+	buf := new(bytes.Buffer)
+	if err := json.NewEncoder(buf).Encode(request); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp, err := http.Post(url, "application/json", buf); err != nil {
+		t.Fatal(err)
+	} else if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// restFetchResponse mimics a RestFetchResponse, as bundles cannot be unmarshalled from JSON.
+type restFetchResponse struct {
+	Error     string                   `json:"error"`
+	Bundles   []map[string]interface{} `json:"bundles"`
+	Sequences []uint64                 `json:"sequences"`
+	Sequence  uint64                   `json:"sequence"`
+}
+
+func TestRestAgentPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newAgent := func() (*RestAgent, *httptest.Server) {
+		r := mux.NewRouter()
+		ra, err := NewPersistentRestAgent(r.PathPrefix("/rest").Subrouter(), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ra, httptest.NewServer(r)
+	}
+
+	registerEid := bundle.MustNewEndpointID("dtn://foo/bar")
+
+	restAgent, server := newAgent()
+
+	var registerResponse RestRegisterResponse
+	restPost(t, server.URL+"/rest/register", RestRegisterRequest{EndpointId: registerEid.String()}, &registerResponse)
+	if registerResponse.Error != "" {
+		t.Fatal(registerResponse.Error)
+	}
+	uuid := registerResponse.UUID
+
+	bndls := []bundle.Bundle{
+		createBundle("dtn://sender1/", registerEid.String(), t),
+		createBundle("dtn://sender2/", registerEid.String(), t),
+	}
+	for _, b := range bndls {
+		restAgent.MessageReceiver() <- BundleMessage{Bundle: b}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// Fetch without acknowledgement, restart
+	var fetchResponse restFetchResponse
+	restPost(t, server.URL+"/rest/fetch", RestFetchRequest{UUID: uuid}, &fetchResponse)
+	if fetchResponse.Error != "" {
+		t.Fatal(fetchResponse.Error)
+	} else if l := len(fetchResponse.Bundles); l != len(bndls) {
+		t.Fatalf("Fetched %d bundles instead of %d", l, len(bndls))
+	}
+
+	restAgent.MessageReceiver() <- ShutdownMessage{}
+	server.Close()
+
+	restAgent, server = newAgent()
+	defer server.Close()
+
+	if !AppAgentHasEndpoint(restAgent, registerEid) {
+		t.Fatal("Endpoint was not restored")
+	}
+
+	// Fetch again, acknowledge the first bundle, restart
+	fetchResponse = restFetchResponse{}
+	restPost(t, server.URL+"/rest/fetch", RestFetchRequest{UUID: uuid}, &fetchResponse)
+	if fetchResponse.Error != "" {
+		t.Fatal(fetchResponse.Error)
+	} else if l := len(fetchResponse.Bundles); l != len(bndls) {
+		t.Fatalf("Fetched %d unacknowledged bundles instead of %d", l, len(bndls))
+	}
+
+	firstSequence := restAgent.mustMailbox(t, uuid).entries[0].Sequence
+
+	var ackResponse RestAckResponse
+	restPost(t, server.URL+"/rest/ack", RestAckRequest{UUID: uuid, Sequence: firstSequence}, &ackResponse)
+	if ackResponse.Error != "" {
+		t.Fatal(ackResponse.Error)
+	}
+
+	restAgent.MessageReceiver() <- ShutdownMessage{}
+	server.Close()
+
+	_, server = newAgent()
+	defer server.Close()
+
+	fetchResponse = restFetchResponse{}
+	restPost(t, server.URL+"/rest/fetch", RestFetchRequest{UUID: uuid}, &fetchResponse)
+	if fetchResponse.Error != "" {
+		t.Fatal(fetchResponse.Error)
+	} else if l := len(fetchResponse.Bundles); l != 1 {
+		t.Fatalf("Fetched %d bundles instead of the unacknowledged one", l)
+	} else if src := fetchResponse.Bundles[0]["primaryBlock"].(map[string]interface{})["source"]; src != "dtn://sender2/" {
+		t.Fatalf("Fetched bundle from %v instead of the second one", src)
+	}
+
+	// Unregister, the UUID becomes invalid
+	var unregisterResponse RestUnregisterResponse
+	restPost(t, server.URL+"/rest/unregister", RestUnregisterRequest{UUID: uuid}, &unregisterResponse)
+	if unregisterResponse.Error != "" {
+		t.Fatal(unregisterResponse.Error)
+	}
+
+	fetchResponse = restFetchResponse{}
+	restPost(t, server.URL+"/rest/fetch", RestFetchRequest{UUID: uuid}, &fetchResponse)
+	if fetchResponse.Error != "Invalid UUID" {
+		t.Fatalf("Fetching for an unregistered UUID resulted in %q", fetchResponse.Error)
+	}
+}
+
+func TestRestAgentReleaseOnFetch(t *testing.T) {
+	registerEid := bundle.MustNewEndpointID("dtn://foo/bar")
+
+	restAgent, server, uuid := newTestRestAgent(t, registerEid)
+	defer server.Close()
+
+	deliver := func(src string) {
+		restAgent.MessageReceiver() <- BundleMessage{Bundle: createBundle(src, registerEid.String(), t)}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	deliver("dtn://sender1/")
+	deliver("dtn://sender2/")
+
+	var fetchResponse restFetchResponse
+	restPost(t, server.URL+"/rest/fetch", RestFetchRequest{UUID: uuid}, &fetchResponse)
+	if l := len(fetchResponse.Bundles); l != 2 {
+		t.Fatalf("Fetched %d bundles instead of 2", l)
+	} else if l := len(restAgent.mustMailbox(t, uuid).entries); l != 2 {
+		t.Fatalf("Mailbox contains %d entries before the next fetch", l)
+	}
+
+	// The next fetch releases the previously fetched bundles without any acknowledgement.
+	deliver("dtn://sender3/")
+
+	fetchResponse = restFetchResponse{}
+	restPost(t, server.URL+"/rest/fetch", RestFetchRequest{UUID: uuid}, &fetchResponse)
+	if l := len(fetchResponse.Bundles); l != 1 {
+		t.Fatalf("Fetched %d bundles instead of 1", l)
+	} else if l := len(restAgent.mustMailbox(t, uuid).entries); l != 1 {
+		t.Fatalf("Mailbox contains %d entries instead of the last fetched one", l)
+	}
+
+	fetchResponse = restFetchResponse{}
+	restPost(t, server.URL+"/rest/fetch", RestFetchRequest{UUID: uuid}, &fetchResponse)
+	if l := len(fetchResponse.Bundles); l != 0 {
+		t.Fatalf("Fetched %d bundles again", l)
+	} else if l := len(restAgent.mustMailbox(t, uuid).entries); l != 0 {
+		t.Fatalf("Mailbox contains %d entries after all were fetched", l)
+	}
+}
+
+// mustMailbox returns a registered client's mailbox.
+func (ra *RestAgent) mustMailbox(t *testing.T, uuid string) *restMailbox {
+	mb, ok := ra.clientMailbox(uuid)
+	if !ok {
+		t.Fatalf("UUID %s is unknown", uuid)
+	}
+	return mb
+}
+
+// newTestRestAgent starts a RestAgent and registers a client for the given endpoint, returning its UUID.
+func newTestRestAgent(t *testing.T, eid bundle.EndpointID) (*RestAgent, *httptest.Server, string) {
+	r := mux.NewRouter()
+	ra := NewRestAgent(r.PathPrefix("/rest").Subrouter())
+	server := httptest.NewServer(r)
+
+	var registerResponse RestRegisterResponse
+	restPost(t, server.URL+"/rest/register", RestRegisterRequest{EndpointId: eid.String()}, &registerResponse)
+	if registerResponse.Error != "" {
+		t.Fatal(registerResponse.Error)
+	}
+	return ra, server, registerResponse.UUID
+}
+
+func TestRestAgentLongPolling(t *testing.T) {
+	registerEid := bundle.MustNewEndpointID("dtn://foo/bar")
+
+	restAgent, server, uuid := newTestRestAgent(t, registerEid)
+	defer server.Close()
+
+	// Timeout without any bundle
+	start := time.Now()
+	var fetchResponse restFetchResponse
+	restPost(t, server.URL+"/rest/fetch", RestFetchRequest{UUID: uuid, Timeout: "200ms"}, &fetchResponse)
+	if fetchResponse.Error != "" {
+		t.Fatal(fetchResponse.Error)
+	} else if l := len(fetchResponse.Bundles); l != 0 {
+		t.Fatalf("Fetched %d bundles from an empty mailbox", l)
+	} else if d := time.Since(start); d < 200*time.Millisecond {
+		t.Fatalf("Fetch returned after %v, before its timeout", d)
+	}
+
+	// Bundle arrives while waiting
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		restAgent.MessageReceiver() <- BundleMessage{Bundle: createBundle("dtn://sender/", registerEid.String(), t)}
+	}()
+
+	start = time.Now()
+	fetchResponse = restFetchResponse{}
+	restPost(t, server.URL+"/rest/fetch", RestFetchRequest{UUID: uuid, Timeout: "10s"}, &fetchResponse)
+	if fetchResponse.Error != "" {
+		t.Fatal(fetchResponse.Error)
+	} else if l := len(fetchResponse.Bundles); l != 1 {
+		t.Fatalf("Fetched %d bundles instead of one", l)
+	} else if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("Fetch returned after %v, not on the bundle's arrival", d)
+	}
+
+	// Invalid timeout
+	fetchResponse = restFetchResponse{}
+	restPost(t, server.URL+"/rest/fetch", RestFetchRequest{UUID: uuid, Timeout: "soon"}, &fetchResponse)
+	if fetchResponse.Error == "" {
+		t.Fatal("Fetch with an invalid timeout did not error")
+	}
+}
+
+// restEvent is a received Server-Sent Event.
+type restEvent struct {
+	id    string
+	event string
+	data  map[string]interface{}
+}
+
+// readRestEvents reads Server-Sent Events from a stream until it is closed.
+func readRestEvents(t *testing.T, r io.Reader, events chan<- restEvent) {
+	defer close(events)
+
+	var ev restEvent
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if ev.event != "" {
+				events <- ev
+			}
+			ev = restEvent{}
+
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+
+		case strings.HasPrefix(line, "event: "):
+			ev.event = strings.TrimPrefix(line, "event: ")
+
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.data); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+}
+
+func TestRestAgentEvents(t *testing.T) {
+	registerEid := bundle.MustNewEndpointID("dtn://foo/bar")
+
+	restAgent, server, uuid := newTestRestAgent(t, registerEid)
+	defer server.Close()
+
+	// Bundle received before the stream was opened
+	b := createBundle("dtn://sender/", registerEid.String(), t)
+	restAgent.MessageReceiver() <- BundleMessage{Bundle: b}
+
+	openStream := func(lastEventId string) (*http.Response, chan restEvent) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/rest/events/"+uuid, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lastEventId != "" {
+			req.Header.Set("Last-Event-ID", lastEventId)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		} else if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Event stream returned status %d", resp.StatusCode)
+		} else if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("Event stream has content type %q", ct)
+		}
+
+		events := make(chan restEvent)
+		go readRestEvents(t, resp.Body, events)
+		return resp, events
+	}
+
+	nextEvent := func(events chan restEvent) restEvent {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatal("Event stream was closed")
+			}
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("Event stream timed out")
+		}
+		return restEvent{}
+	}
+
+	resp, events := openStream("")
+	defer resp.Body.Close()
+
+	if ev := nextEvent(events); ev.event != "bundle" {
+		t.Fatalf("Received %q event instead of a bundle", ev.event)
+	}
+
+	// Status report received while streaming
+	sr := bundle.NewStatusReport(b, bundle.DeliveredBundle, bundle.NoInformation, bundle.DtnTimeNow())
+	ar, err := bundle.AdministrativeRecordToCbor(&sr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srBundle, err := bundle.Builder().
+		BundleCtrlFlags(bundle.AdministrativeRecordPayload).
+		Source("dtn://dst/").
+		Destination(registerEid).
+		CreationTimestampNow().
+		Lifetime("60m").
+		Canonical(ar).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restAgent.MessageReceiver() <- BundleMessage{Bundle: srBundle}
+
+	ev := nextEvent(events)
+	if ev.event != "status-report" {
+		t.Fatalf("Received %q event instead of a status report", ev.event)
+	} else if ref := ev.data["ref_bundle"]; ref != b.ID().String() {
+		t.Fatalf("Status report references %v instead of %v", ref, b.ID())
+	} else if status := ev.data["status"].([]interface{}); len(status) != 1 || status[0] != "delivered bundle" {
+		t.Fatalf("Status report has status %v", status)
+	}
+	_ = resp.Body.Close()
+
+	// Reconnecting after the last event does not repeat it
+	resp, events = openStream(ev.id)
+	defer resp.Body.Close()
+
+	restAgent.MessageReceiver() <- BundleMessage{Bundle: createBundle("dtn://sender2/", registerEid.String(), t)}
+
+	if ev := nextEvent(events); ev.event != "bundle" {
+		t.Fatalf("Received %q event instead of a bundle", ev.event)
+	} else if src := ev.data["primaryBlock"].(map[string]interface{})["source"]; src != "dtn://sender2/" {
+		t.Fatalf("Received bundle from %v instead of the new one", src)
+	}
+
+	// Unknown UUID
+	if resp, err := http.Get(server.URL + "/rest/events/invalid"); err != nil {
+		t.Fatal(err)
+	} else if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Event stream for an unknown UUID returned status %d", resp.StatusCode)
+	}
+}
+
+func TestRestAgentBinary(t *testing.T) {
+	registerEid := bundle.MustNewEndpointID("dtn://foo/bar")
+
+	restAgent, server, uuid := newTestRestAgent(t, registerEid)
+	defer server.Close()
+
+	payload := make([]byte, 4096)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	sent := make(chan bundle.Bundle, 2)
+	go func() {
+		for msg := range restAgent.MessageSender() {
+			if bMsg, ok := msg.(BundleMessage); ok {
+				sent <- bMsg.Bundle
+			}
+		}
+	}()
+
+	upload := func(req *http.Request) bundle.Bundle {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var uploadResponse RestUploadResponse
+		if err := json.NewDecoder(resp.Body).Decode(&uploadResponse); err != nil {
+			t.Fatal(err)
+		} else if uploadResponse.Error != "" {
+			t.Fatal(uploadResponse.Error)
+		}
+
+		b := <-sent
+		if b.ID().String() != uploadResponse.BundleId {
+			t.Fatalf("Uploaded bundle %v, response states %s", b.ID(), uploadResponse.BundleId)
+		} else if pb, _ := b.PayloadBlock(); !bytes.Equal(pb.Value.(*bundle.PayloadBlock).Data(), payload) {
+			t.Fatal("Uploaded payload differs")
+		}
+		return b
+	}
+
+	// Raw upload, parameters as query and header
+	req, err := http.NewRequest(http.MethodPost,
+		server.URL+"/rest/upload?uuid="+uuid+"&destination=dtn://dst/&bundle_ctrl_flags=0", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-Dtn-Lifetime", "10m")
+
+	if b := upload(req); b.PrimaryBlock.Destination != bundle.MustNewEndpointID("dtn://dst/") {
+		t.Fatalf("Uploaded bundle is addressed to %v", b.PrimaryBlock.Destination)
+	} else if b.PrimaryBlock.SourceNode != registerEid {
+		t.Fatalf("Uploaded bundle's source is %v", b.PrimaryBlock.SourceNode)
+	} else if b.PrimaryBlock.Lifetime != 10*60*1000 {
+		t.Fatalf("Uploaded bundle's lifetime is %d", b.PrimaryBlock.Lifetime)
+	} else if b.PrimaryBlock.BundleControlFlags != 0 {
+		t.Fatalf("Uploaded bundle's control flags are %v", b.PrimaryBlock.BundleControlFlags)
+	}
+
+	// Multipart upload
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	if fw, err := mw.CreateFormFile("payload", "payload.bin"); err != nil {
+		t.Fatal(err)
+	} else if _, err := fw.Write(payload); err != nil {
+		t.Fatal(err)
+	} else if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err = http.NewRequest(http.MethodPost, server.URL+"/rest/upload?destination=dtn://dst/", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("X-Dtn-Uuid", uuid)
+	upload(req)
+
+	// Foreign source
+	var uploadResponse RestUploadResponse
+	if resp, err := http.Post(server.URL+"/rest/upload?uuid="+uuid+"&destination=dtn://dst/&source=dtn://other/",
+		"application/octet-stream", bytes.NewReader(payload)); err != nil {
+		t.Fatal(err)
+	} else if err := json.NewDecoder(resp.Body).Decode(&uploadResponse); err != nil {
+		t.Fatal(err)
+	} else if uploadResponse.Error == "" {
+		t.Fatal("Upload for a foreign source did not error")
+	}
+
+	// Download a received bundle
+	b, err := bundle.Builder().
+		Source("dtn://sender/").
+		Destination(registerEid).
+		CreationTimestampNow().
+		Lifetime("24h").
+		PayloadBlock(payload).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restAgent.MessageReceiver() <- BundleMessage{Bundle: b}
+
+	var fetchResponse restFetchResponse
+	restPost(t, server.URL+"/rest/fetch", RestFetchRequest{UUID: uuid, Timeout: "5s"}, &fetchResponse)
+	if fetchResponse.Error != "" {
+		t.Fatal(fetchResponse.Error)
+	} else if l := len(fetchResponse.Sequences); l != 1 {
+		t.Fatalf("Fetched %d sequence numbers instead of one", l)
+	}
+
+	download := func(query string) []byte {
+		resp, err := http.Get(fmt.Sprintf("%s/rest/download/%s/%d%s", server.URL, uuid, fetchResponse.Sequences[0], query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Download returned status %d", resp.StatusCode)
+		} else if ct := resp.Header.Get("Content-Type"); ct != "application/octet-stream" {
+			t.Fatalf("Download has content type %q", ct)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	if data := download(""); !bytes.Equal(data, payload) {
+		t.Fatal("Downloaded payload differs")
+	}
+
+	if b2, err := bundle.ParseBundle(bytes.NewReader(download("?format=cbor"))); err != nil {
+		t.Fatal(err)
+	} else if b2.ID() != b.ID() {
+		t.Fatalf("Downloaded bundle %v instead of %v", b2.ID(), b.ID())
+	}
+
+	if resp, err := http.Get(server.URL + "/rest/download/" + uuid + "/23"); err != nil {
+		t.Fatal(err)
+	} else if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Download of an unknown sequence number returned status %d", resp.StatusCode)
+	}
+}
@@ -23,6 +23,9 @@ type webAgentClient struct {
 	receiver chan Message
 	sender   chan Message
 
+	// eventFilter selects the BundleEvents to be sent to the client; nil if no events were subscribed.
+	eventFilter *BundleEventFilter
+
 	shutdownOnce sync.Once
 }
 
@@ -76,6 +79,18 @@ func (client *webAgentClient) handleReceiver() {
 				logger.WithField("syscall", msg.Request).Info("Sent syscall response to client")
 			}
 
+		case EventMessage:
+			if !client.subscribed(msg.Event) {
+				continue
+			}
+
+			if err := client.writeMessage(newEventMessage(msg.Event)); err != nil {
+				logger.WithError(err).Warn("Sending bundle event errored")
+				return
+			} else {
+				logger.WithField("event", msg.Event).Debug("Sent bundle event to client")
+			}
+
 		default:
 			logger.WithField("message", msg).Info("Received unknown / unsupported message")
 		}
@@ -123,6 +138,13 @@ func (client *webAgentClient) handleConn() {
 					Request: msg.request,
 				}
 
+			case *wamEventSubscribe:
+				err := client.handleIncomingEventSubscribe(msg)
+				if err = client.acknowledgeIncoming(err); err != nil {
+					logger.WithError(err).Warn("Handling event subscription errored")
+					return
+				}
+
 			default:
 				logger.WithField("message", msg).Info("Received unknown / unsupported message")
 			}
@@ -160,6 +182,36 @@ func (client *webAgentClient) handleIncomingRegister(m *wamRegister) error {
 	}
 }
 
+// handleIncomingEventSubscribe sets the client's event filter and subscribes the events for its endpoint.
+func (client *webAgentClient) handleIncomingEventSubscribe(m *wamEventSubscribe) error {
+	client.Lock()
+	endpoint := client.endpoint
+	if endpoint != (bundle.EndpointID{}) {
+		client.eventFilter = &m.filter
+	}
+	client.Unlock()
+
+	if endpoint == (bundle.EndpointID{}) {
+		return fmt.Errorf("event subscription errored, no endpoint ID is registered")
+	}
+
+	log.WithFields(log.Fields{
+		"web agent client": client.conn.RemoteAddr().String(),
+		"filter":           m.filter,
+	}).Info("Received bundle event subscription")
+
+	client.sender <- EventSubscribeMessage{Sender: endpoint}
+	return nil
+}
+
+// subscribed checks if a BundleEvent was subscribed by the client.
+func (client *webAgentClient) subscribed(event BundleEvent) bool {
+	client.Lock()
+	defer client.Unlock()
+
+	return client.eventFilter != nil && client.eventFilter.Matches(event)
+}
+
 func (client *webAgentClient) acknowledgeIncoming(err error) error {
 	if writeErr := client.writeMessage(newStatusMessage(err)); writeErr != nil {
 		return writeErr
@@ -21,6 +21,8 @@ type WebSocketAgentConnector struct {
 
 	msgInBundleChan  chan bundle.Bundle
 	msgInSyscallChan chan []byte
+	msgInStatusChan  chan string
+	msgInEventChan   chan BundleEvent
 
 	closeSyn chan struct{}
 	closeAck chan struct{}
@@ -41,6 +43,8 @@ func NewWebSocketAgentConnector(apiUrl, endpointId string) (wac *WebSocketAgentC
 
 		msgInBundleChan:  make(chan bundle.Bundle),
 		msgInSyscallChan: make(chan []byte),
+		msgInStatusChan:  make(chan string, 1),
+		msgInEventChan:   make(chan BundleEvent, 64),
 
 		closeSyn: make(chan struct{}),
 		closeAck: make(chan struct{}),
@@ -102,6 +106,8 @@ func (wac *WebSocketAgentConnector) registerEndpoint(endpointId string) error {
 func (wac *WebSocketAgentConnector) handleReader() {
 	defer close(wac.msgInBundleChan)
 	defer close(wac.msgInSyscallChan)
+	defer close(wac.msgInStatusChan)
+	defer close(wac.msgInEventChan)
 
 	for {
 		if msg, err := wac.readMessage(); err != nil {
@@ -114,6 +120,12 @@ func (wac *WebSocketAgentConnector) handleReader() {
 			case *wamSyscallResponse:
 				wac.msgInSyscallChan <- msg.response
 
+			case *wamStatus:
+				wac.msgInStatusChan <- msg.errorMsg
+
+			case *wamEvent:
+				wac.msgInEventChan <- msg.event
+
 			default:
 				// oof
 			}
@@ -167,6 +179,9 @@ func (wac *WebSocketAgentConnector) ReadBundle() (b bundle.Bundle, err error) {
 }
 
 // Syscall will be send to the server. An answer or an error after a timeout will be returned.
+//
+// The answer is CBOR encoded, as described by the core's SyscallManager. An empty answer indicates an unknown or
+// failed syscall.
 func (wac *WebSocketAgentConnector) Syscall(request string, timeout time.Duration) (response []byte, err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -189,6 +204,46 @@ func (wac *WebSocketAgentConnector) Syscall(request string, timeout time.Duratio
 	}
 }
 
+// SubscribeEvents requests the BundleEvents selected by the filter, replacing a previous subscription. An error is
+// returned if the server did not acknowledge the subscription before the timeout.
+//
+// The events are only emitted by a Core, e.g., for a WebSocketAgent within dtnd.
+func (wac *WebSocketAgentConnector) SubscribeEvents(filter BundleEventFilter, timeout time.Duration) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	wac.msgOutChan <- newEventSubscribeMessage(filter)
+	if err = <-wac.msgOutErr; err != nil {
+		return
+	}
+
+	select {
+	case errMsg, ok := <-wac.msgInStatusChan:
+		if !ok {
+			err = fmt.Errorf("connection was closed")
+		} else if errMsg != "" {
+			err = fmt.Errorf("received non-empty error message: %s", errMsg)
+		}
+		return
+
+	case <-time.After(timeout):
+		err = fmt.Errorf("event subscription timed out")
+		return
+	}
+}
+
+// ReadEvent returns the next subscribed BundleEvent. This method blocks.
+func (wac *WebSocketAgentConnector) ReadEvent() (event BundleEvent, err error) {
+	event, ok := <-wac.msgInEventChan
+	if !ok {
+		err = fmt.Errorf("connection was closed")
+	}
+	return
+}
+
 // Close this WebSocketAgentConnector.
 func (wac *WebSocketAgentConnector) Close() {
 	defer func() {
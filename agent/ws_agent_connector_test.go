@@ -119,6 +119,39 @@ func TestWebAgentConnector(t *testing.T) {
 		t.Fatalf("received %x", response)
 	}
 
+	subscribed := make(chan EventSubscribeMessage, 1)
+	go func() {
+		if msg, ok := (<-ws.MessageSender()).(EventSubscribeMessage); ok {
+			subscribed <- msg
+		}
+	}()
+
+	filter := BundleEventFilter{Types: []BundleEventType{BundleDelivered}}
+	if err := wac.SubscribeEvents(filter, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-subscribed:
+		if msg.Sender.String() != "dtn://foobar/23" {
+			t.Fatalf("subscription's sender is %v", msg.Sender)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WebSocketAgent did not pass the subscription; time out")
+	}
+
+	recipient := b.PrimaryBlock.Destination
+	deleted := BundleEvent{Type: BundleDeleted, Bundle: b.ID(), Time: time.Unix(0, 0)}
+	delivered := BundleEvent{Type: BundleDelivered, Bundle: b.ID(), Time: time.Unix(0, 0)}
+	ws.MessageReceiver() <- EventMessage{Event: deleted, Recipient: recipient}
+	ws.MessageReceiver() <- EventMessage{Event: delivered, Recipient: recipient}
+
+	if event, err := wac.ReadEvent(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(event, delivered) {
+		t.Fatalf("expected %v, got %v", delivered, event)
+	}
+
 	wac.Close()
 
 	// Let the WebSocketAgent act on the closed connection
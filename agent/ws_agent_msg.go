@@ -30,6 +30,8 @@ const (
 	wamBundleCode          uint64 = 2
 	wamSyscallRequestCode  uint64 = 3
 	wamSyscallResponseCode uint64 = 4
+	wamEventSubscribeCode  uint64 = 5
+	wamEventCode           uint64 = 6
 )
 
 var wamMapping = map[interface{}]reflect.Type{
@@ -38,6 +40,8 @@ var wamMapping = map[interface{}]reflect.Type{
 	wamBundleCode:          reflect.TypeOf(wamBundle{}),
 	wamSyscallRequestCode:  reflect.TypeOf(wamSyscallRequest{}),
 	wamSyscallResponseCode: reflect.TypeOf(wamSyscallResponse{}),
+	wamEventSubscribeCode:  reflect.TypeOf(wamEventSubscribe{}),
+	wamEventCode:           reflect.TypeOf(wamEvent{}),
 }
 
 // marshalCbor writes a webAgentMessage wrapped with its type code as CBOR.
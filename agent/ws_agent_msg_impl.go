@@ -162,3 +162,48 @@ func (wsr *wamSyscallResponse) UnmarshalCbor(r io.Reader) error {
 
 	return nil
 }
+
+// wamEventSubscribe is a webAgentMessage sent from a client to subscribe BundleEvents, selected by a filter. Another
+// subscription replaces the previous filter.
+type wamEventSubscribe struct {
+	filter BundleEventFilter
+}
+
+// newEventSubscribeMessage creates a new wamEventSubscribe webAgentMessage.
+func newEventSubscribeMessage(filter BundleEventFilter) *wamEventSubscribe {
+	return &wamEventSubscribe{filter}
+}
+
+func (_ *wamEventSubscribe) typeCode() uint64 {
+	return wamEventSubscribeCode
+}
+
+func (wes *wamEventSubscribe) MarshalCbor(w io.Writer) error {
+	return cboring.Marshal(&wes.filter, w)
+}
+
+func (wes *wamEventSubscribe) UnmarshalCbor(r io.Reader) error {
+	return cboring.Unmarshal(&wes.filter, r)
+}
+
+// wamEvent is a webAgentMessage sent from the server to pass a subscribed BundleEvent.
+type wamEvent struct {
+	event BundleEvent
+}
+
+// newEventMessage creates a new wamEvent webAgentMessage.
+func newEventMessage(event BundleEvent) *wamEvent {
+	return &wamEvent{event}
+}
+
+func (_ *wamEvent) typeCode() uint64 {
+	return wamEventCode
+}
+
+func (we *wamEvent) MarshalCbor(w io.Writer) error {
+	return cboring.Marshal(&we.event, w)
+}
+
+func (we *wamEvent) UnmarshalCbor(r io.Reader) error {
+	return cboring.Unmarshal(&we.event, r)
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/dtn7/dtn7-go/bundle"
 )
@@ -34,6 +35,26 @@ func TestWebsocketAgentMessageEnDecode(t *testing.T) {
 		newBundleMessage(b),
 		newSyscallRequestMessage("test"),
 		newSyscallResponseMessage("foobar", []byte{0x23, 0x42, 0xAC, 0xAB}),
+		newEventSubscribeMessage(BundleEventFilter{}),
+		newEventSubscribeMessage(BundleEventFilter{
+			Types:       []BundleEventType{BundleForwarded, BundleDeleted},
+			Source:      bundle.MustNewEndpointID("dtn://src/"),
+			Destination: bundle.MustNewEndpointID("dtn://dst/"),
+		}),
+		newEventMessage(BundleEvent{
+			Type:        BundleForwarded,
+			Bundle:      b.ID(),
+			Destination: b.PrimaryBlock.Destination,
+			Peer:        bundle.MustNewEndpointID("dtn://peer/"),
+			Cla:         "localhost:4556",
+			Time:        time.Unix(0, 1598000000000000000),
+		}),
+		newEventMessage(BundleEvent{
+			Type:   BundleDeleted,
+			Bundle: bundle.BundleID{SourceNode: b.PrimaryBlock.SourceNode, IsFragment: true, FragmentOffset: 23, TotalDataLength: 42},
+			Reason: bundle.LifetimeExpired,
+			Time:   time.Unix(0, 1598000000000000000),
+		}),
 	}
 
 	for _, msg := range msgs {
@@ -146,6 +146,26 @@ const (
 	// HopLimitExceeded is the "Hop limit exceeded" bundle status report reason
 	// code.
 	HopLimitExceeded StatusReportReason = 9
+
+	// MissingSecurityOperation is the "Missing security operation" bundle status
+	// report reason code, RFC 9172 section 11.2.
+	MissingSecurityOperation StatusReportReason = 12
+
+	// UnknownSecurityOperation is the "Unknown security operation" bundle status
+	// report reason code, RFC 9172 section 11.2.
+	UnknownSecurityOperation StatusReportReason = 13
+
+	// UnexpectedSecurityOperation is the "Unexpected security operation" bundle
+	// status report reason code, RFC 9172 section 11.2.
+	UnexpectedSecurityOperation StatusReportReason = 14
+
+	// FailedSecurityOperation is the "Failed security operation" bundle status
+	// report reason code, RFC 9172 section 11.2.
+	FailedSecurityOperation StatusReportReason = 15
+
+	// ConflictingSecurityOperation is the "Conflicting security operation"
+	// bundle status report reason code, RFC 9172 section 11.2.
+	ConflictingSecurityOperation StatusReportReason = 16
 )
 
 func (srr StatusReportReason) String() string {
@@ -180,6 +200,21 @@ func (srr StatusReportReason) String() string {
 	case HopLimitExceeded:
 		return "Hop limit exceeded"
 
+	case MissingSecurityOperation:
+		return "Missing security operation"
+
+	case UnknownSecurityOperation:
+		return "Unknown security operation"
+
+	case UnexpectedSecurityOperation:
+		return "Unexpected security operation"
+
+	case FailedSecurityOperation:
+		return "Failed security operation"
+
+	case ConflictingSecurityOperation:
+		return "Conflicting security operation"
+
 	default:
 		return "unknown"
 	}
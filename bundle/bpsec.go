@@ -0,0 +1,375 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package bundle
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dtn7/cboring"
+	"github.com/hashicorp/go-multierror"
+)
+
+// Security context identifiers, as specified in RFC 9172 and RFC 9173. Negative values are reserved for local or
+// site-specific usage.
+const (
+	// SecConIdBIBHMACSHA2 is the BIB-HMAC-SHA2 security context, RFC 9173 section 3, bundle/bpsec_bib_hmac_sha2.go
+	SecConIdBIBHMACSHA2 int64 = 1
+
+	// SecConIdBCBAESGCM is the BCB-AES-GCM security context, RFC 9173 section 4, bundle/bpsec_bcb_aes_gcm.go
+	SecConIdBCBAESGCM int64 = 2
+
+	// SecConIdBIBEd25519 is a site-specific security context for ed25519 signatures, bundle/bpsec_bib_ed25519.go
+	SecConIdBIBEd25519 int64 = -1
+)
+
+// cborNegInt is CBOR's major type for negative integers, which is not exported by cboring.
+const cborNegInt byte = 0x20
+
+// asbFlagParameters is the only defined Security Context Flag, indicating present Security Context Parameters.
+const asbFlagParameters uint64 = 0x01
+
+// IdValueTuple is either a Security Context Parameter or a Security Result, both identified by an Id.
+//
+// The Value's type depends on the security context. Values must be either an uint64 or a byte slice, which covers
+// all types used within the implemented security contexts.
+type IdValueTuple struct {
+	Id    uint64
+	Value interface{}
+}
+
+// UInt returns the Value as an uint64, if possible.
+func (ivt IdValueTuple) UInt() (n uint64, ok bool) {
+	n, ok = ivt.Value.(uint64)
+	return
+}
+
+// Bytes returns the Value as a byte slice, if possible.
+func (ivt IdValueTuple) Bytes() (data []byte, ok bool) {
+	data, ok = ivt.Value.([]byte)
+	return
+}
+
+// CheckValid checks if the Value is of a supported type.
+func (ivt IdValueTuple) CheckValid() error {
+	switch ivt.Value.(type) {
+	case uint64, []byte:
+		return nil
+	default:
+		return fmt.Errorf("IdValueTuple: value of id %d has unsupported type %T", ivt.Id, ivt.Value)
+	}
+}
+
+// MarshalCbor writes the CBOR representation of an IdValueTuple.
+func (ivt *IdValueTuple) MarshalCbor(w io.Writer) error {
+	if err := cboring.WriteArrayLength(2, w); err != nil {
+		return err
+	}
+
+	if err := cboring.WriteUInt(ivt.Id, w); err != nil {
+		return err
+	}
+
+	switch v := ivt.Value.(type) {
+	case uint64:
+		return cboring.WriteUInt(v, w)
+	case []byte:
+		return cboring.WriteByteString(v, w)
+	default:
+		return fmt.Errorf("IdValueTuple: value of id %d has unsupported type %T", ivt.Id, ivt.Value)
+	}
+}
+
+// UnmarshalCbor reads a CBOR representation of an IdValueTuple.
+func (ivt *IdValueTuple) UnmarshalCbor(r io.Reader) error {
+	if n, err := cboring.ReadArrayLength(r); err != nil {
+		return err
+	} else if n != 2 {
+		return fmt.Errorf("IdValueTuple: array has %d instead of 2 elements", n)
+	}
+
+	if id, err := cboring.ReadUInt(r); err != nil {
+		return err
+	} else {
+		ivt.Id = id
+	}
+
+	m, n, err := cboring.ReadMajors(r)
+	if err != nil {
+		return err
+	}
+
+	switch m {
+	case cboring.UInt:
+		ivt.Value = n
+	case cboring.ByteString:
+		if data, err := cboring.ReadRawBytes(n, r); err != nil {
+			return err
+		} else {
+			ivt.Value = data
+		}
+	default:
+		return fmt.Errorf("IdValueTuple: value of id %d has unsupported major type 0x%x", ivt.Id, m)
+	}
+
+	return nil
+}
+
+// AbstractSecurityBlock is the common structure of both BPSec security blocks, the Block Integrity Block and the
+// Block Confidentiality Block, as defined in RFC 9172 section 3.6.
+//
+// Its block-type-specific data is a CBOR sequence of the Security Targets, the Security Context Id, the Security
+// Context Flags, the Security Source, the optional Security Context Parameters and the Security Results. The Security
+// Context Flags are derived from the presence of Security Context Parameters.
+type AbstractSecurityBlock struct {
+	// SecurityTargets are the block numbers of the protected blocks, where 0 addresses the Primary Block.
+	SecurityTargets []uint64
+
+	// SecurityContextId identifies the used security context.
+	SecurityContextId int64
+
+	// SecuritySource is the EndpointID of the node which added this security block.
+	SecuritySource EndpointID
+
+	// SecurityContextParameters are optional parameters for the security context, e.g., the used algorithm.
+	SecurityContextParameters []IdValueTuple
+
+	// SecurityResults holds one list of results for each Security Target in the same order.
+	SecurityResults [][]IdValueTuple
+}
+
+// Parameter returns the Security Context Parameter for the given id.
+func (asb *AbstractSecurityBlock) Parameter(id uint64) (ivt IdValueTuple, ok bool) {
+	for _, param := range asb.SecurityContextParameters {
+		if param.Id == id {
+			return param, true
+		}
+	}
+	return
+}
+
+// targetIndex returns the index of the Security Target for the given block number or -1, if not targeted.
+func (asb *AbstractSecurityBlock) targetIndex(blockNumber uint64) int {
+	for i, target := range asb.SecurityTargets {
+		if target == blockNumber {
+			return i
+		}
+	}
+	return -1
+}
+
+// HasTarget checks if the block of the given block number is a Security Target.
+func (asb *AbstractSecurityBlock) HasTarget(blockNumber uint64) bool {
+	return asb.targetIndex(blockNumber) >= 0
+}
+
+// CheckValid checks the Security Targets, Results and Parameters for errors.
+func (asb *AbstractSecurityBlock) CheckValid() (errs error) {
+	if len(asb.SecurityTargets) == 0 {
+		errs = multierror.Append(errs, fmt.Errorf("AbstractSecurityBlock: no Security Targets"))
+	}
+
+	var targets = make(map[uint64]bool)
+	for _, target := range asb.SecurityTargets {
+		if targets[target] {
+			errs = multierror.Append(errs,
+				fmt.Errorf("AbstractSecurityBlock: Security Target %d occurred multiple times", target))
+		}
+		targets[target] = true
+	}
+
+	if lt, lr := len(asb.SecurityTargets), len(asb.SecurityResults); lt != lr {
+		errs = multierror.Append(errs,
+			fmt.Errorf("AbstractSecurityBlock: %d Security Targets, but %d Security Results", lt, lr))
+	}
+
+	if err := asb.SecuritySource.CheckValid(); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+
+	for _, param := range asb.SecurityContextParameters {
+		if err := param.CheckValid(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
+	for _, results := range asb.SecurityResults {
+		for _, result := range results {
+			if err := result.CheckValid(); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+	}
+
+	return
+}
+
+// MarshalCbor writes the CBOR sequence of an AbstractSecurityBlock.
+func (asb *AbstractSecurityBlock) MarshalCbor(w io.Writer) error {
+	// Security Targets
+	if err := cboring.WriteArrayLength(uint64(len(asb.SecurityTargets)), w); err != nil {
+		return err
+	}
+	for _, target := range asb.SecurityTargets {
+		if err := cboring.WriteUInt(target, w); err != nil {
+			return err
+		}
+	}
+
+	// Security Context Id
+	if asb.SecurityContextId >= 0 {
+		if err := cboring.WriteUInt(uint64(asb.SecurityContextId), w); err != nil {
+			return err
+		}
+	} else if err := cboring.WriteMajors(cborNegInt, uint64(-1-asb.SecurityContextId), w); err != nil {
+		return err
+	}
+
+	// Security Context Flags
+	var flags uint64 = 0
+	if len(asb.SecurityContextParameters) > 0 {
+		flags |= asbFlagParameters
+	}
+	if err := cboring.WriteUInt(flags, w); err != nil {
+		return err
+	}
+
+	// Security Source
+	if err := cboring.Marshal(&asb.SecuritySource, w); err != nil {
+		return err
+	}
+
+	// Security Context Parameters
+	if flags&asbFlagParameters != 0 {
+		if err := writeIdValueTuples(asb.SecurityContextParameters, w); err != nil {
+			return err
+		}
+	}
+
+	// Security Results
+	if err := cboring.WriteArrayLength(uint64(len(asb.SecurityResults)), w); err != nil {
+		return err
+	}
+	for _, results := range asb.SecurityResults {
+		if err := writeIdValueTuples(results, w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UnmarshalCbor reads the CBOR sequence of an AbstractSecurityBlock.
+func (asb *AbstractSecurityBlock) UnmarshalCbor(r io.Reader) error {
+	// Security Targets
+	if n, err := cboring.ReadArrayLength(r); err != nil {
+		return err
+	} else {
+		asb.SecurityTargets = make([]uint64, n)
+		for i := uint64(0); i < n; i++ {
+			if target, err := cboring.ReadUInt(r); err != nil {
+				return err
+			} else {
+				asb.SecurityTargets[i] = target
+			}
+		}
+	}
+
+	// Security Context Id
+	if m, n, err := cboring.ReadMajors(r); err != nil {
+		return err
+	} else if m == cboring.UInt {
+		asb.SecurityContextId = int64(n)
+	} else if m == cborNegInt {
+		asb.SecurityContextId = -1 - int64(n)
+	} else {
+		return fmt.Errorf("AbstractSecurityBlock: Security Context Id has major type 0x%x", m)
+	}
+
+	// Security Context Flags
+	flags, err := cboring.ReadUInt(r)
+	if err != nil {
+		return err
+	}
+
+	// Security Source
+	if err := cboring.Unmarshal(&asb.SecuritySource, r); err != nil {
+		return err
+	}
+
+	// Security Context Parameters
+	if flags&asbFlagParameters != 0 {
+		if asb.SecurityContextParameters, err = readIdValueTuples(r); err != nil {
+			return err
+		}
+	} else {
+		asb.SecurityContextParameters = nil
+	}
+
+	// Security Results
+	if n, err := cboring.ReadArrayLength(r); err != nil {
+		return err
+	} else {
+		asb.SecurityResults = make([][]IdValueTuple, n)
+		for i := uint64(0); i < n; i++ {
+			if results, err := readIdValueTuples(r); err != nil {
+				return err
+			} else {
+				asb.SecurityResults[i] = results
+			}
+		}
+	}
+
+	return nil
+}
+
+// securityBlock returns the CanonicalBlock of this Bundle containing the given security block.
+func (b *Bundle) securityBlock(eb ExtensionBlock) (*CanonicalBlock, error) {
+	for i := 0; i < len(b.CanonicalBlocks); i++ {
+		if cb := &b.CanonicalBlocks[i]; cb.Value == eb {
+			return cb, nil
+		}
+	}
+	return nil, fmt.Errorf("security block is not part of Bundle %v", b.ID())
+}
+
+// removeSecurityBlock removes the CanonicalBlock containing the given security block from this Bundle.
+func (b *Bundle) removeSecurityBlock(eb ExtensionBlock) {
+	for i := 0; i < len(b.CanonicalBlocks); i++ {
+		if b.CanonicalBlocks[i].Value == eb {
+			b.CanonicalBlocks = append(b.CanonicalBlocks[:i], b.CanonicalBlocks[i+1:]...)
+			return
+		}
+	}
+}
+
+// writeIdValueTuples writes a CBOR array of IdValueTuples.
+func writeIdValueTuples(ivts []IdValueTuple, w io.Writer) error {
+	if err := cboring.WriteArrayLength(uint64(len(ivts)), w); err != nil {
+		return err
+	}
+	for i := range ivts {
+		if err := cboring.Marshal(&ivts[i], w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readIdValueTuples reads a CBOR array of IdValueTuples.
+func readIdValueTuples(r io.Reader) (ivts []IdValueTuple, err error) {
+	n, err := cboring.ReadArrayLength(r)
+	if err != nil {
+		return
+	}
+
+	ivts = make([]IdValueTuple, n)
+	for i := uint64(0); i < n; i++ {
+		if err = cboring.Unmarshal(&ivts[i], r); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,177 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package bundle
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+)
+
+// AESVariant identifies the AES key length of the BCB-AES-GCM security context.
+type AESVariant uint64
+
+const (
+	// A128GCM is AES-GCM with a 128 bit key.
+	A128GCM AESVariant = 1
+
+	// A256GCM is AES-GCM with a 256 bit key. This is the default variant.
+	A256GCM AESVariant = 3
+)
+
+// Security Context Parameter and Security Result ids of BCB-AES-GCM, RFC 9173 section 4.3 and 4.4.
+const (
+	bcbAESGCMParamIV         uint64 = 1
+	bcbAESGCMParamVariant    uint64 = 2
+	bcbAESGCMParamWrappedKey uint64 = 3
+	bcbAESGCMParamScope      uint64 = 4
+	bcbAESGCMResultTag       uint64 = 1
+)
+
+// bcbAESGCMIVLen is the recommended IV length of 96 bits, RFC 9173 section 4.3.1.
+const bcbAESGCMIVLen = 12
+
+// keyLen returns the length of the content encryption key for this variant.
+func (v AESVariant) keyLen() (int, error) {
+	switch v {
+	case A128GCM:
+		return 16, nil
+	case A256GCM:
+		return 32, nil
+	default:
+		return 0, fmt.Errorf("unknown AES variant %d", v)
+	}
+}
+
+// NewBCBAESGCM creates an unencrypted BlockConfidentialityBlock for the BCB-AES-GCM security context, RFC 9173
+// section 4.
+//
+// For each encryption, a random content encryption key is created, which is wrapped by the key encryption key (KEK)
+// according to RFC 3394. The KEK must be a valid AES key of 128, 192 or 256 bits.
+func NewBCBAESGCM(securitySource EndpointID, variant AESVariant, scope AADScopeFlags) *BlockConfidentialityBlock {
+	return &BlockConfidentialityBlock{AbstractSecurityBlock{
+		SecurityContextId: SecConIdBCBAESGCM,
+		SecuritySource:    securitySource,
+		SecurityContextParameters: []IdValueTuple{
+			{Id: bcbAESGCMParamVariant, Value: uint64(variant)},
+			{Id: bcbAESGCMParamScope, Value: uint64(scope)},
+		},
+	}}
+}
+
+// bcbAESGCMVariant returns the BCB's AESVariant or the default variant.
+func bcbAESGCMVariant(bcb *BlockConfidentialityBlock) AESVariant {
+	if param, ok := bcb.Parameter(bcbAESGCMParamVariant); ok {
+		if variant, ok := param.UInt(); ok {
+			return AESVariant(variant)
+		}
+	}
+	return A256GCM
+}
+
+// bcbAESGCMScope returns the BCB's AADScopeFlags or the default value.
+func bcbAESGCMScope(bcb *BlockConfidentialityBlock) AADScopeFlags {
+	if param, ok := bcb.Parameter(bcbAESGCMParamScope); ok {
+		if scope, ok := param.UInt(); ok {
+			return AADScopeFlags(scope)
+		}
+	}
+	return AADScopeAll
+}
+
+// bcbAESGCMSetParameter sets or replaces a Security Context Parameter.
+func bcbAESGCMSetParameter(bcb *BlockConfidentialityBlock, param IdValueTuple) {
+	for i := range bcb.SecurityContextParameters {
+		if bcb.SecurityContextParameters[i].Id == param.Id {
+			bcb.SecurityContextParameters[i] = param
+			return
+		}
+	}
+	bcb.SecurityContextParameters = append(bcb.SecurityContextParameters, param)
+}
+
+// bcbAESGCMEncrypt encrypts a plaintext with a new content encryption key, stores the IV and the wrapped key as
+// Security Context Parameters and returns the ciphertext together with the Security Results.
+func bcbAESGCMEncrypt(bcb *BlockConfidentialityBlock, plaintext, aad, kek []byte) ([]byte, []IdValueTuple, error) {
+	keyLen, err := bcbAESGCMVariant(bcb).keyLen()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := make([]byte, keyLen)
+	iv := make([]byte, bcbAESGCMIVLen)
+	for _, buff := range [][]byte{key, iv} {
+		if _, err := rand.Read(buff); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	wrappedKey, err := aesKeyWrap(kek, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gcm, err := bcbAESGCMCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sealed := gcm.Seal(nil, iv, plaintext, aad)
+	ciphertext, tag := sealed[:len(plaintext)], sealed[len(plaintext):]
+
+	bcbAESGCMSetParameter(bcb, IdValueTuple{Id: bcbAESGCMParamIV, Value: iv})
+	bcbAESGCMSetParameter(bcb, IdValueTuple{Id: bcbAESGCMParamWrappedKey, Value: wrappedKey})
+
+	return ciphertext, []IdValueTuple{{Id: bcbAESGCMResultTag, Value: tag}}, nil
+}
+
+// bcbAESGCMDecrypt decrypts and authenticates a ciphertext based on the BCB's parameters and Security Results.
+func bcbAESGCMDecrypt(bcb *BlockConfidentialityBlock, ciphertext, aad []byte, results []IdValueTuple, kek []byte) ([]byte, error) {
+	var iv, wrappedKey, tag []byte
+	if param, ok := bcb.Parameter(bcbAESGCMParamIV); ok {
+		iv, _ = param.Bytes()
+	}
+	if param, ok := bcb.Parameter(bcbAESGCMParamWrappedKey); ok {
+		wrappedKey, _ = param.Bytes()
+	}
+	for _, result := range results {
+		if result.Id == bcbAESGCMResultTag {
+			tag, _ = result.Bytes()
+		}
+	}
+
+	if iv == nil || wrappedKey == nil || tag == nil {
+		return nil, fmt.Errorf("BCB-AES-GCM requires an IV, a wrapped key and an authentication tag")
+	}
+
+	key, err := aesKeyUnwrap(kek, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyLen, err := bcbAESGCMVariant(bcb).keyLen(); err != nil {
+		return nil, err
+	} else if keyLen != len(key) {
+		return nil, fmt.Errorf("unwrapped key's length is %d, not %d", len(key), keyLen)
+	}
+
+	gcm, err := bcbAESGCMCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := append(append([]byte(nil), ciphertext...), tag...)
+	return gcm.Open(nil, iv, sealed, aad)
+}
+
+// bcbAESGCMCipher creates an AES-GCM AEAD for the content encryption key.
+func bcbAESGCMCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCMWithNonceSize(block, bcbAESGCMIVLen)
+}
@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package bundle
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+)
+
+// Security Context Parameter and Security Result ids of the site-specific ed25519 security context.
+const (
+	bibEd25519ParamPublicKey uint64 = 1
+	bibEd25519ResultSig      uint64 = 1
+)
+
+// NewBIBEd25519 creates an unsigned BlockIntegrityBlock for the site-specific ed25519 security context.
+//
+// This security context is NOT specified in RFC 9173. It uses the same Integrity-Protected Plaintext as BIB-HMAC-SHA2,
+// but signs it with an ed25519.PrivateKey. The public key is attached as a Security Context Parameter, allowing a
+// lookup against trusted keys. Verification requires the ed25519.PublicKey.
+//
+// This security context replaces the custom SignatureBlock.
+func NewBIBEd25519(securitySource EndpointID, pub ed25519.PublicKey, scope IntegrityScopeFlags) *BlockIntegrityBlock {
+	return &BlockIntegrityBlock{AbstractSecurityBlock{
+		SecurityContextId: SecConIdBIBEd25519,
+		SecuritySource:    securitySource,
+		SecurityContextParameters: []IdValueTuple{
+			{Id: bibEd25519ParamPublicKey, Value: []byte(pub)},
+			{Id: integrityParamScope, Value: uint64(scope)},
+		},
+	}}
+}
+
+// Ed25519PublicKey returns the attached ed25519.PublicKey, if this BIB uses the ed25519 security context.
+func (bib *BlockIntegrityBlock) Ed25519PublicKey() (pub ed25519.PublicKey, ok bool) {
+	if bib.SecurityContextId != SecConIdBIBEd25519 {
+		return
+	}
+
+	if param, paramOk := bib.Parameter(bibEd25519ParamPublicKey); paramOk {
+		if data, dataOk := param.Bytes(); dataOk && len(data) == ed25519.PublicKeySize {
+			pub, ok = data, true
+		}
+	}
+	return
+}
+
+// bibEd25519Sign creates the Security Results for an IPPT.
+func bibEd25519Sign(bib *BlockIntegrityBlock, ippt, key []byte) ([]IdValueTuple, error) {
+	if l := len(key); l != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("ed25519 private key's length is %d, not %d", l, ed25519.PrivateKeySize)
+	}
+
+	priv := ed25519.PrivateKey(key)
+	if pub, ok := bib.Ed25519PublicKey(); !ok || !bytes.Equal(pub, priv.Public().(ed25519.PublicKey)) {
+		return nil, fmt.Errorf("ed25519 private key does not match the attached public key")
+	}
+
+	return []IdValueTuple{{Id: bibEd25519ResultSig, Value: ed25519.Sign(priv, ippt)}}, nil
+}
+
+// bibEd25519Verify checks the Security Results for an IPPT.
+func bibEd25519Verify(_ *BlockIntegrityBlock, ippt []byte, results []IdValueTuple, key []byte) error {
+	if l := len(key); l != ed25519.PublicKeySize {
+		return fmt.Errorf("ed25519 public key's length is %d, not %d", l, ed25519.PublicKeySize)
+	}
+
+	for _, result := range results {
+		if result.Id != bibEd25519ResultSig {
+			continue
+		}
+
+		if sig, ok := result.Bytes(); ok && len(sig) == ed25519.SignatureSize && ed25519.Verify(key, ippt, sig) {
+			return nil
+		}
+		return fmt.Errorf("ed25519 signature mismatches")
+	}
+
+	return fmt.Errorf("ed25519 Security Result is missing")
+}
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package bundle
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+)
+
+// HMACSHA2Variant identifies the HMAC and SHA-2 combination of the BIB-HMAC-SHA2 security context.
+type HMACSHA2Variant uint64
+
+const (
+	// HMAC256 is HMAC 256/256, using SHA-256.
+	HMAC256 HMACSHA2Variant = 5
+
+	// HMAC384 is HMAC 384/384, using SHA-384. This is the default variant.
+	HMAC384 HMACSHA2Variant = 6
+
+	// HMAC512 is HMAC 512/512, using SHA-512.
+	HMAC512 HMACSHA2Variant = 7
+)
+
+// Security Context Parameter and Security Result ids of BIB-HMAC-SHA2, RFC 9173 section 3.3 and 3.4.
+const (
+	bibHMACSHA2ParamVariant uint64 = 1
+	bibHMACSHA2ResultMAC    uint64 = 1
+)
+
+// hash returns the hash function for this variant.
+func (v HMACSHA2Variant) hash() (func() hash.Hash, error) {
+	switch v {
+	case HMAC256:
+		return sha256.New, nil
+	case HMAC384:
+		return sha512.New384, nil
+	case HMAC512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unknown HMAC SHA-2 variant %d", v)
+	}
+}
+
+// NewBIBHMACSHA2 creates an unsigned BlockIntegrityBlock for the BIB-HMAC-SHA2 security context, RFC 9173 section 3.
+//
+// The key for signing and verification is the plain HMAC key. Key wrapping is not supported.
+func NewBIBHMACSHA2(securitySource EndpointID, variant HMACSHA2Variant, scope IntegrityScopeFlags) *BlockIntegrityBlock {
+	return &BlockIntegrityBlock{AbstractSecurityBlock{
+		SecurityContextId: SecConIdBIBHMACSHA2,
+		SecuritySource:    securitySource,
+		SecurityContextParameters: []IdValueTuple{
+			{Id: bibHMACSHA2ParamVariant, Value: uint64(variant)},
+			{Id: integrityParamScope, Value: uint64(scope)},
+		},
+	}}
+}
+
+// bibHMACSHA2Variant returns the BIB's HMACSHA2Variant or the default variant.
+func bibHMACSHA2Variant(bib *BlockIntegrityBlock) HMACSHA2Variant {
+	if param, ok := bib.Parameter(bibHMACSHA2ParamVariant); ok {
+		if variant, ok := param.UInt(); ok {
+			return HMACSHA2Variant(variant)
+		}
+	}
+	return HMAC384
+}
+
+// bibHMACSHA2Mac calculates the HMAC for an IPPT.
+func bibHMACSHA2Mac(bib *BlockIntegrityBlock, ippt, key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("BIB-HMAC-SHA2 key is empty")
+	}
+
+	h, err := bibHMACSHA2Variant(bib).hash()
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(h, key)
+	_, _ = mac.Write(ippt)
+	return mac.Sum(nil), nil
+}
+
+// bibHMACSHA2Sign creates the Security Results for an IPPT.
+func bibHMACSHA2Sign(bib *BlockIntegrityBlock, ippt, key []byte) ([]IdValueTuple, error) {
+	if mac, err := bibHMACSHA2Mac(bib, ippt, key); err != nil {
+		return nil, err
+	} else {
+		return []IdValueTuple{{Id: bibHMACSHA2ResultMAC, Value: mac}}, nil
+	}
+}
+
+// bibHMACSHA2Verify checks the Security Results for an IPPT.
+func bibHMACSHA2Verify(bib *BlockIntegrityBlock, ippt []byte, results []IdValueTuple, key []byte) error {
+	mac, err := bibHMACSHA2Mac(bib, ippt, key)
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		if result.Id != bibHMACSHA2ResultMAC {
+			continue
+		}
+
+		if expected, ok := result.Bytes(); ok && hmac.Equal(mac, expected) {
+			return nil
+		}
+		return fmt.Errorf("BIB-HMAC-SHA2 MAC mismatches")
+	}
+
+	return fmt.Errorf("BIB-HMAC-SHA2 Security Result is missing")
+}
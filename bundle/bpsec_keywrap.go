@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package bundle
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+)
+
+// keyWrapIV is the default initial value of the AES Key Wrap, RFC 3394 section 2.2.3.1.
+var keyWrapIV = []byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+
+// aesKeyWrap wraps a key by a key encryption key (KEK), as specified in RFC 3394 section 2.2.1.
+func aesKeyWrap(kek, key []byte) ([]byte, error) {
+	if len(key)%8 != 0 || len(key) < 16 {
+		return nil, fmt.Errorf("key wrap: key length %d is not a multiple of 64 bits of at least 128 bits", len(key))
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(key) / 8
+	out := make([]byte, 8+len(key))
+	copy(out[:8], keyWrapIV)
+	copy(out[8:], key)
+
+	var buff [16]byte
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buff[:8], out[:8])
+			copy(buff[8:], out[8*i:8*i+8])
+			block.Encrypt(buff[:], buff[:])
+
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(buff[:8])^t)
+			copy(out[8*i:8*i+8], buff[8:])
+		}
+	}
+
+	return out, nil
+}
+
+// aesKeyUnwrap unwraps a key by a key encryption key (KEK), as specified in RFC 3394 section 2.2.2.
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, fmt.Errorf("key unwrap: wrapped key length %d is invalid", len(wrapped))
+	}
+
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrapped)/8 - 1
+	out := make([]byte, len(wrapped))
+	copy(out, wrapped)
+
+	var buff [16]byte
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buff[:8], binary.BigEndian.Uint64(out[:8])^t)
+			copy(buff[8:], out[8*i:8*i+8])
+			block.Decrypt(buff[:], buff[:])
+
+			copy(out[:8], buff[:8])
+			copy(out[8*i:8*i+8], buff[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(out[:8], keyWrapIV) != 1 {
+		return nil, fmt.Errorf("key unwrap: integrity check failed")
+	}
+
+	return out[8:], nil
+}
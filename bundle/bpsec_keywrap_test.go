@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package bundle
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestAESKeyWrap(t *testing.T) {
+	// Test vectors from RFC 3394 section 4
+	tests := []struct {
+		name    string
+		kek     string
+		key     string
+		wrapped string
+	}{
+		{"128 bit key, 128 bit KEK",
+			"000102030405060708090A0B0C0D0E0F",
+			"00112233445566778899AABBCCDDEEFF",
+			"1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5"},
+		{"128 bit key, 256 bit KEK",
+			"000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F",
+			"00112233445566778899AABBCCDDEEFF",
+			"64E8C3F9CE0F5BA263E9777905818A2A93C8191E7D6E8AE7"},
+		{"256 bit key, 256 bit KEK",
+			"000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F",
+			"00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F",
+			"28C9F404C4B810F4CBCCB35CFB87F8263F5786E2D80ED326CBC7F0E71A99F43BFB988B9B7A02DD21"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kek, _ := hex.DecodeString(test.kek)
+			key, _ := hex.DecodeString(test.key)
+			wrapped, _ := hex.DecodeString(test.wrapped)
+
+			if w, err := aesKeyWrap(kek, key); err != nil {
+				t.Fatal(err)
+			} else if !bytes.Equal(w, wrapped) {
+				t.Fatalf("wrapped key is %x, expected %x", w, wrapped)
+			}
+
+			if k, err := aesKeyUnwrap(kek, wrapped); err != nil {
+				t.Fatal(err)
+			} else if !bytes.Equal(k, key) {
+				t.Fatalf("unwrapped key is %x, expected %x", k, key)
+			}
+
+			wrapped[0] ^= 0xFF
+			if _, err := aesKeyUnwrap(kek, wrapped); err == nil {
+				t.Fatal("unwrapping an altered key succeeded")
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"time"
 
 	"github.com/dtn7/cboring"
 	"github.com/hashicorp/go-multierror"
@@ -173,7 +174,7 @@ func (b Bundle) CheckValid() (errs error) {
 
 	// Check uniqueness of block numbers
 	var cbBlockNumbers = make(map[uint64]bool)
-	// Check max 1 occurrence of extension blocks
+	// Check max 1 occurrence of extension blocks, except for Block Confidentiality Blocks
 	var cbBlockTypes = make(map[uint64]bool)
 
 	for _, cb := range b.CanonicalBlocks {
@@ -184,13 +185,25 @@ func (b Bundle) CheckValid() (errs error) {
 		cbBlockNumbers[cb.BlockNumber] = true
 
 		blockType := cb.Value.BlockTypeCode()
-		if _, ok := cbBlockTypes[blockType]; ok {
+		if _, ok := cbBlockTypes[blockType]; ok && blockType != ExtBlockTypeBlockConfidentialityBlock {
 			errs = multierror.Append(errs,
 				fmt.Errorf("Bundle: Block type %d occurred multiple times", blockType))
 		}
 		cbBlockTypes[blockType] = true
 	}
 
+	// Check if blocks of a known type could not be parsed, which is only allowed for encrypted blocks.
+	for _, cb := range b.CanonicalBlocks {
+		if _, generic := cb.Value.(*GenericExtensionBlock); !generic || !GetExtensionBlockManager().IsKnown(cb.TypeCode()) {
+			continue
+		}
+
+		if !bcbTargetAllowed(cb.TypeCode()) || !b.isEncrypted(cb.BlockNumber) {
+			errs = multierror.Append(errs,
+				fmt.Errorf("Bundle: Block %d of known type %d is unintelligible", cb.BlockNumber, cb.TypeCode()))
+		}
+	}
+
 	// Check if the PayloadBlock is the last block.
 	if last := b.CanonicalBlocks[len(b.CanonicalBlocks)-1].Value.BlockTypeCode(); last != ExtBlockTypePayloadBlock {
 		errs = multierror.Append(errs,
@@ -207,11 +220,10 @@ func (b Bundle) CheckValid() (errs error) {
 
 	// Check if Bundle Age Block's time is exceeded.
 	if canBab, err := b.ExtensionBlock(ExtBlockTypeBundleAgeBlock); err == nil {
-		bundleAge := canBab.Value.(*BundleAgeBlock).Age()
-		if bundleAge > b.PrimaryBlock.Lifetime {
+		if bab, ok := canBab.Value.(*BundleAgeBlock); ok && bab.Age() > b.PrimaryBlock.Lifetime {
 			errs = multierror.Append(errs, fmt.Errorf(
 				"Bundle: Bundle Age Block's value %d exceeded lifetime %d",
-				bundleAge, b.PrimaryBlock.Lifetime))
+				bab.Age(), b.PrimaryBlock.Lifetime))
 		}
 	}
 
@@ -224,6 +236,47 @@ func (b Bundle) IsAdministrativeRecord() bool {
 	return b.PrimaryBlock.BundleControlFlags.Has(AdministrativeRecordPayload)
 }
 
+// ExpirationTime returns the time of this Bundle's expiration for a node,
+// which has received or created this Bundle at the given time.
+//
+// Based on the PrimaryBlock's creation timestamp, a Bundle expires after its
+// lifetime. A Bundle Age Block's value is the Bundle's age at its reception;
+// thus, such a Bundle expires when this age plus the time since its reception
+// exceeds the lifetime. If both are available, the earlier expiration is
+// used. A Bundle with neither is treated as being created at its reception.
+func (b Bundle) ExpirationTime(received time.Time) time.Time {
+	lifetime := time.Duration(b.PrimaryBlock.Lifetime) * time.Millisecond
+
+	var expiration time.Time
+	if !b.PrimaryBlock.CreationTimestamp.IsZeroTime() {
+		expiration = b.PrimaryBlock.CreationTimestamp.DtnTime().Time().Add(lifetime)
+	}
+
+	if canBab, err := b.ExtensionBlock(ExtBlockTypeBundleAgeBlock); err == nil {
+		if bab, ok := canBab.Value.(*BundleAgeBlock); ok {
+			ageExpiration := received.Add(lifetime - time.Duration(bab.Age())*time.Millisecond)
+			if expiration.IsZero() || ageExpiration.Before(expiration) {
+				expiration = ageExpiration
+			}
+		}
+	}
+
+	if expiration.IsZero() {
+		expiration = received.Add(lifetime)
+	}
+	return expiration
+}
+
+// IsLifetimeExceeded returns true if this Bundle's lifetime is exceeded, based
+// on both its PrimaryBlock's creation timestamp and its Bundle Age Block.
+//
+// The Bundle Age Block's value is taken as it is. To include the time since
+// this Bundle's reception, ExpirationTime must be used.
+func (b Bundle) IsLifetimeExceeded() bool {
+	now := time.Now()
+	return now.After(b.ExpirationTime(now))
+}
+
 // MarshalCbor writes this Bundle's CBOR representation.
 func (b *Bundle) MarshalCbor(w io.Writer) error {
 	if _, err := w.Write([]byte{cboring.IndefiniteArray}); err != nil {
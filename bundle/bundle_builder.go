@@ -30,6 +30,9 @@ type BundleBuilder struct {
 	canonicals       []CanonicalBlock
 	canonicalCounter uint64
 	crcType          CRCType
+
+	integrityKey     []byte
+	integrityTargets []uint64
 }
 
 // Builder creates a new BundleBuilder.
@@ -89,6 +92,15 @@ func (bldr *BundleBuilder) Build() (bndl Bundle, err error) {
 		bndl.SetCRCType(bldr.crcType)
 	}
 
+	// Sign the finished Bundle, if requested.
+	if err == nil && bldr.integrityKey != nil {
+		bib := NewBIBHMACSHA2(bndl.PrimaryBlock.SourceNode, HMAC384, IntegrityScopeAll)
+		if err = bndl.AddBlockIntegrityBlock(bib, bldr.integrityTargets, bldr.integrityKey); err == nil {
+			bibBlock, _ := bndl.ExtensionBlock(ExtBlockTypeBlockIntegrityBlock)
+			bibBlock.SetCRCType(bldr.crcType)
+		}
+	}
+
 	return
 }
 
@@ -433,6 +445,42 @@ func (bldr *BundleBuilder) PreviousNodeBlock(args ...interface{}) *BundleBuilder
 	return bldr.Canonical(NewPreviousNodeBlock(eid), flags)
 }
 
+// BlockIntegrityBlock adds a BPSec Block Integrity Block using the BIB-HMAC-SHA2 security context with HMAC 384/384
+// and all integrity scope flags. The Bundle's Source Node becomes the Security Source. The Security Results are
+// calculated within Build, after all other blocks were added. The parameters are:
+//
+//   Key[, Targets]
+//
+//   where Key is the HMAC key as a byte slice and Targets are the _optional_ block type codes of the blocks to be
+//   protected as an []uint64, defaulting to the Payload Block. A block type code of 0 addresses the Primary Block.
+//
+func (bldr *BundleBuilder) BlockIntegrityBlock(args ...interface{}) *BundleBuilder {
+	if bldr.err != nil {
+		return bldr
+	}
+
+	var chk0, chk1 bool
+	switch len(args) {
+	case 1:
+		bldr.integrityKey, chk0 = args[0].([]byte)
+		bldr.integrityTargets, chk1 = []uint64{ExtBlockTypePayloadBlock}, true
+	case 2:
+		bldr.integrityKey, chk0 = args[0].([]byte)
+		bldr.integrityTargets, chk1 = args[1].([]uint64)
+	default:
+		bldr.err = fmt.Errorf("BlockIntegrityBlock was called with neither one nor two parameters")
+		return bldr
+	}
+
+	if !(chk0 && chk1) {
+		bldr.err = fmt.Errorf("BlockIntegrityBlock received wrong parameter types, %v %v", chk0, chk1)
+	} else if len(bldr.integrityKey) == 0 {
+		bldr.err = fmt.Errorf("BlockIntegrityBlock received an empty key")
+	}
+
+	return bldr
+}
+
 // BuildFromMap creates a Bundle from a map which "calls" the BundleBuilder's methods.
 //
 // This function does not use reflection or other dark magic. So it is safe to be called by unchecked data.
@@ -10,6 +10,7 @@ import (
 	"math/rand"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/dtn7/cboring"
 )
@@ -294,3 +295,91 @@ func BenchmarkBundleDeserializationCboring(b *testing.B) {
 		}
 	}
 }
+
+func TestBundleExpirationTime(t *testing.T) {
+	received := time.Now().Truncate(time.Millisecond)
+	created := received.Add(-10 * time.Minute)
+
+	// setAge alters the Bundle Age Block of an already built Bundle, because the builder refuses expired Bundles.
+	setAge := func(age time.Duration) func(*Bundle) {
+		return func(b *Bundle) {
+			if cb, err := b.ExtensionBlock(ExtBlockTypeBundleAgeBlock); err != nil {
+				t.Fatal(err)
+			} else {
+				cb.Value = NewBundleAgeBlock(uint64(age / time.Millisecond))
+			}
+		}
+	}
+
+	tests := []struct {
+		name       string
+		builder    *BundleBuilder
+		modify     func(*Bundle)
+		expiration time.Time
+		exceeded   bool
+	}{
+		{
+			name:       "creation timestamp",
+			builder:    Builder().CreationTimestampTime(created).Lifetime("1h"),
+			expiration: created.Add(time.Hour),
+		},
+		{
+			name:       "bundle age",
+			builder:    Builder().CreationTimestampEpoch().Lifetime("1h").BundleAgeBlock(0),
+			modify:     setAge(20 * time.Minute),
+			expiration: received.Add(40 * time.Minute),
+		},
+		{
+			name:       "earlier bundle age",
+			builder:    Builder().CreationTimestampTime(created).Lifetime("1h").BundleAgeBlock(0),
+			modify:     setAge(30 * time.Minute),
+			expiration: received.Add(30 * time.Minute),
+		},
+		{
+			name:       "earlier creation timestamp",
+			builder:    Builder().CreationTimestampTime(created).Lifetime("1h").BundleAgeBlock(0),
+			modify:     setAge(time.Minute),
+			expiration: created.Add(time.Hour),
+		},
+		{
+			name:       "exceeded bundle age",
+			builder:    Builder().CreationTimestampEpoch().Lifetime("1h").BundleAgeBlock(0),
+			modify:     setAge(2 * time.Hour),
+			expiration: received.Add(-time.Hour),
+			exceeded:   true,
+		},
+		{
+			name:    "exceeded creation timestamp",
+			builder: Builder().CreationTimestampNow().Lifetime("1h"),
+			modify: func(b *Bundle) {
+				b.PrimaryBlock.CreationTimestamp = NewCreationTimestamp(DtnTimeFromTime(created), 0)
+				b.PrimaryBlock.Lifetime = uint64(time.Minute / time.Millisecond)
+			},
+			expiration: created.Add(time.Minute),
+			exceeded:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := test.builder.
+				Source("dtn://src/").
+				Destination("dtn://dst/").
+				PayloadBlock([]byte("hello world")).
+				Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.modify != nil {
+				test.modify(&b)
+			}
+
+			if expiration := b.ExpirationTime(received); !expiration.Equal(test.expiration) {
+				t.Fatalf("Expiration is %v, expected %v", expiration, test.expiration)
+			} else if exceeded := b.IsLifetimeExceeded(); exceeded != test.exceeded {
+				t.Fatalf("Lifetime exceeded is %t, expected %t", exceeded, test.exceeded)
+			}
+		})
+	}
+}
@@ -142,10 +142,13 @@ func (cb *CanonicalBlock) UnmarshalCbor(r io.Reader) error {
 		cb.CRCType = CRCType(crcT)
 	}
 
-	if b, err := GetExtensionBlockManager().ReadBlock(blockType, r); err != nil {
-		return fmt.Errorf("unmarshalling block type %d failed: %v", blockType, err)
-	} else {
+	if b, err := GetExtensionBlockManager().ReadBlock(blockType, r); err == nil {
 		cb.Value = b
+	} else if geb, ok := b.(*GenericExtensionBlock); ok {
+		// The block's data might be encrypted. Thus, Bundle.CheckValid decides later on.
+		cb.Value = geb
+	} else {
+		return fmt.Errorf("unmarshalling block type %d failed: %v", blockType, err)
 	}
 
 	if blockLen == 6 {
@@ -29,6 +29,12 @@ const (
 	// ExtBlockTypeHopCountBlock is the block type code for a Hop Count Block, bundle/extension_block_hop_count.go
 	ExtBlockTypeHopCountBlock uint64 = 10
 
+	// ExtBlockTypeBlockIntegrityBlock is the block type code for a BPSec Block Integrity Block, bundle/extension_block_integrity.go
+	ExtBlockTypeBlockIntegrityBlock uint64 = 11
+
+	// ExtBlockTypeBlockConfidentialityBlock is the block type code for a BPSec Block Confidentiality Block, bundle/extension_block_confidentiality.go
+	ExtBlockTypeBlockConfidentialityBlock uint64 = 12
+
 	// ExtBlockTypeBinarySprayBlock is the custom block type code for a BinarySprayBlock, core/routing_spray.go
 	ExtBlockTypeBinarySprayBlock uint64 = 192
 
@@ -40,6 +46,9 @@ const (
 
 	// ExtBlockTypeSignatureBlock is the custom block type code for a SignatureBlock, bundle/extension_block_signature.go
 	ExtBlockTypeSignatureBlock uint64 = 195
+
+	// ExtBlockTypeMaxPropBlock is the custom block type code for a MaxPropBlock, core/routing_maxprop.go
+	ExtBlockTypeMaxPropBlock uint64 = 196
 )
 
 // ExtensionBlock describes the block-type specific data of any Canonical Block. Such an ExtensionBlock
@@ -143,21 +152,22 @@ func (ebm *ExtensionBlockManager) WriteBlock(b ExtensionBlock, w io.Writer) erro
 
 // ReadBlock reads an ExtensionBlock from its correct binary format from the io.Reader.
 // Unknown block types are treated as GenericExtensionBlock.
+//
+// If the data of a known block type cannot be parsed, e.g., because it was encrypted by a BlockConfidentialityBlock,
+// the raw data is returned as a GenericExtensionBlock together with the error.
 func (ebm *ExtensionBlockManager) ReadBlock(typeCode uint64, r io.Reader) (b ExtensionBlock, err error) {
 	b = ebm.createBlock(typeCode)
 
+	var data []byte
+
 	switch b := b.(type) {
 	case encoding.BinaryUnmarshaler:
-		if data, dataErr := cboring.ReadByteString(r); dataErr != nil {
-			err = dataErr
-		} else {
+		if data, err = cboring.ReadByteString(r); err == nil {
 			err = b.UnmarshalBinary(data)
 		}
 
 	case cboring.CborMarshaler:
-		if data, dataErr := cboring.ReadByteString(r); dataErr != nil {
-			err = dataErr
-		} else {
+		if data, err = cboring.ReadByteString(r); err == nil {
 			var buff = bytes.NewBuffer(data)
 			err = cboring.Unmarshal(b, buff)
 		}
@@ -166,6 +176,10 @@ func (ebm *ExtensionBlockManager) ReadBlock(typeCode uint64, r io.Reader) (b Ext
 		err = fmt.Errorf("ExtensionBlock does not implement any expected types")
 	}
 
+	if err != nil && data != nil {
+		b = NewGenericExtensionBlock(data, typeCode)
+	}
+
 	return
 }
 
@@ -176,7 +190,8 @@ var (
 
 // GetExtensionBlockManager returns the singleton ExtensionBlockManager. If none
 // exists, a new ExtensionBlockManager will be generated with a knowledge of the
-// PayloadBlock, PreviousNodeBlock, BundleAgeBlock and HopCountBlock.
+// PayloadBlock, PreviousNodeBlock, BundleAgeBlock, HopCountBlock,
+// BlockIntegrityBlock and BlockConfidentialityBlock.
 func GetExtensionBlockManager() *ExtensionBlockManager {
 	extensionBlockManagerMutex.Lock()
 	defer extensionBlockManagerMutex.Unlock()
@@ -188,6 +203,8 @@ func GetExtensionBlockManager() *ExtensionBlockManager {
 		_ = extensionBlockManager.Register(NewPreviousNodeBlock(DtnNone()))
 		_ = extensionBlockManager.Register(NewBundleAgeBlock(0))
 		_ = extensionBlockManager.Register(NewHopCountBlock(0))
+		_ = extensionBlockManager.Register(&BlockIntegrityBlock{})
+		_ = extensionBlockManager.Register(&BlockConfidentialityBlock{})
 	}
 
 	return extensionBlockManager
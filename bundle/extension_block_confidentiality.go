@@ -0,0 +1,309 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dtn7/cboring"
+)
+
+// AADScopeFlags define which additional data is part of the Additional Authenticated Data (AAD) of a
+// Block Confidentiality Block, RFC 9173 section 4.3.4.
+type AADScopeFlags uint64
+
+const (
+	// AADScopePrimaryBlock includes the Primary Block into the AAD.
+	AADScopePrimaryBlock AADScopeFlags = 0x01
+
+	// AADScopeTargetHeader includes the Security Target's block type code, number and flags into the AAD.
+	AADScopeTargetHeader AADScopeFlags = 0x02
+
+	// AADScopeSecurityHeader includes the BCB's block type code, number and flags into the AAD.
+	AADScopeSecurityHeader AADScopeFlags = 0x04
+
+	// AADScopeAll combines all AAD scope flags, which is also the default.
+	AADScopeAll AADScopeFlags = AADScopePrimaryBlock | AADScopeTargetHeader | AADScopeSecurityHeader
+)
+
+// BlockConfidentialityBlock implements the BPSec Block Confidentiality Block (BCB), RFC 9172 section 3.8.
+//
+// A BCB encrypts its Security Targets in place. Thus, the block-type-specific data of each target block is replaced
+// by its ciphertext. Currently, the BCB-AES-GCM default security context, RFC 9173 section 4, is implemented. Because
+// its Security Context Parameters, e.g., the IV, are shared among all Security Targets, each BCB of this context
+// protects exactly one target block. Multiple BCBs might be attached to one Bundle.
+//
+// Like the BIB, the BCB must be attached to the Bundle before encryption. Bundle.AddBlockConfidentialityBlock takes
+// care of this. It also encrypts a BIB sharing the Security Target by an additional BCB, RFC 9172 section 3.9.
+//
+// 	bcb := bundle.NewBCBAESGCM(b.PrimaryBlock.SourceNode, bundle.A256GCM, bundle.AADScopeAll)
+// 	err := b.AddBlockConfidentialityBlock(bcb, bundle.ExtBlockTypePayloadBlock, kek)
+//
+// Blocks which must be readable by each node, the Previous Node, Bundle Age and Hop Count Block, cannot be encrypted.
+// The block-type-specific data is an Abstract Security Block, as described for the AbstractSecurityBlock.
+type BlockConfidentialityBlock struct {
+	AbstractSecurityBlock
+}
+
+// BlockTypeCode must return a constant integer, indicating the block type code.
+func (bcb *BlockConfidentialityBlock) BlockTypeCode() uint64 {
+	return ExtBlockTypeBlockConfidentialityBlock
+}
+
+// CheckValid checks the AbstractSecurityBlock's fields for errors.
+func (bcb *BlockConfidentialityBlock) CheckValid() error {
+	if bcb.HasTarget(0) {
+		return fmt.Errorf("BlockConfidentialityBlock: the Primary Block cannot be a Security Target")
+	}
+	return bcb.AbstractSecurityBlock.CheckValid()
+}
+
+// bcbTargetAllowed checks if a block of this type code might be encrypted.
+func bcbTargetAllowed(typeCode uint64) bool {
+	switch typeCode {
+	case ExtBlockTypePreviousNodeBlock, ExtBlockTypeBundleAgeBlock, ExtBlockTypeHopCountBlock,
+		ExtBlockTypeBlockConfidentialityBlock:
+		return false
+	default:
+		return true
+	}
+}
+
+// isEncrypted checks if the block of the given number is a Security Target of any BCB.
+func (b *Bundle) isEncrypted(blockNumber uint64) bool {
+	for _, cb := range b.CanonicalBlocks {
+		if bcb, ok := cb.Value.(*BlockConfidentialityBlock); ok && bcb.HasTarget(blockNumber) {
+			return true
+		}
+	}
+	return false
+}
+
+// aad creates the Additional Authenticated Data for a Security Target, RFC 9173 section 4.7.2.
+func (bcb *BlockConfidentialityBlock) aad(b *Bundle, targetBlock *CanonicalBlock, scope AADScopeFlags) ([]byte, error) {
+	var buff bytes.Buffer
+
+	bcbBlock, err := b.securityBlock(bcb)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cboring.WriteUInt(uint64(scope), &buff); err != nil {
+		return nil, err
+	}
+
+	if scope&AADScopePrimaryBlock != 0 {
+		pb := b.PrimaryBlock
+		if err := cboring.Marshal(&pb, &buff); err != nil {
+			return nil, err
+		}
+	}
+
+	var headers []*CanonicalBlock
+	if scope&AADScopeTargetHeader != 0 {
+		headers = append(headers, targetBlock)
+	}
+	if scope&AADScopeSecurityHeader != 0 {
+		headers = append(headers, bcbBlock)
+	}
+	for _, header := range headers {
+		for _, field := range []uint64{header.TypeCode(), header.BlockNumber, uint64(header.BlockControlFlags)} {
+			if err := cboring.WriteUInt(field, &buff); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return buff.Bytes(), nil
+}
+
+// targetBlock returns the CanonicalBlock of a Security Target.
+func (bcb *BlockConfidentialityBlock) targetBlock(b *Bundle, target uint64) (*CanonicalBlock, error) {
+	for i := 0; i < len(b.CanonicalBlocks); i++ {
+		if cb := &b.CanonicalBlocks[i]; cb.BlockNumber == target {
+			return cb, nil
+		}
+	}
+	return nil, fmt.Errorf("Security Target %d does not exist", target)
+}
+
+// Encrypt all Security Targets in place. This BCB must already be part of the Bundle.
+//
+// The key encryption key (KEK) wraps a random content encryption key, as described for the security context.
+func (bcb *BlockConfidentialityBlock) Encrypt(b *Bundle, kek []byte) error {
+	if bcb.SecurityContextId != SecConIdBCBAESGCM {
+		return fmt.Errorf("unsupported security context %d", bcb.SecurityContextId)
+	}
+	if len(bcb.SecurityTargets) != 1 {
+		return fmt.Errorf("BCB-AES-GCM requires exactly one Security Target, not %d", len(bcb.SecurityTargets))
+	}
+
+	targetBlock, err := bcb.targetBlock(b, bcb.SecurityTargets[0])
+	if err != nil {
+		return err
+	}
+
+	typeCode := targetBlock.TypeCode()
+	if !bcbTargetAllowed(typeCode) {
+		return fmt.Errorf("blocks of type %d cannot be encrypted", typeCode)
+	}
+
+	var buff bytes.Buffer
+	if err := GetExtensionBlockManager().WriteBlock(targetBlock.Value, &buff); err != nil {
+		return err
+	}
+	plaintext, err := cboring.ReadByteString(&buff)
+	if err != nil {
+		return err
+	}
+
+	aad, err := bcb.aad(b, targetBlock, bcbAESGCMScope(bcb))
+	if err != nil {
+		return err
+	}
+
+	ciphertext, results, err := bcbAESGCMEncrypt(bcb, plaintext, aad, kek)
+	if err != nil {
+		return err
+	}
+
+	if typeCode == ExtBlockTypePayloadBlock {
+		targetBlock.Value = NewPayloadBlock(ciphertext)
+	} else {
+		targetBlock.Value = NewGenericExtensionBlock(ciphertext, typeCode)
+	}
+
+	bcb.SecurityResults = [][]IdValueTuple{results}
+	return nil
+}
+
+// Decrypt all Security Targets in place and remove this BCB from the Bundle afterwards.
+func (bcb *BlockConfidentialityBlock) Decrypt(b *Bundle, kek []byte) error {
+	if err := bcb.CheckValid(); err != nil {
+		return err
+	}
+	if bcb.SecurityContextId != SecConIdBCBAESGCM {
+		return fmt.Errorf("unsupported security context %d", bcb.SecurityContextId)
+	}
+	if len(bcb.SecurityTargets) != 1 {
+		return fmt.Errorf("BCB-AES-GCM requires exactly one Security Target, not %d", len(bcb.SecurityTargets))
+	}
+
+	targetBlock, err := bcb.targetBlock(b, bcb.SecurityTargets[0])
+	if err != nil {
+		return err
+	}
+
+	var ciphertext []byte
+	switch eb := targetBlock.Value.(type) {
+	case *PayloadBlock:
+		ciphertext = eb.Data()
+	case *GenericExtensionBlock:
+		ciphertext, _ = eb.MarshalBinary()
+	default:
+		return fmt.Errorf("Security Target %d is not encrypted", bcb.SecurityTargets[0])
+	}
+
+	aad, err := bcb.aad(b, targetBlock, bcbAESGCMScope(bcb))
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := bcbAESGCMDecrypt(bcb, ciphertext, aad, bcb.SecurityResults[0], kek)
+	if err != nil {
+		return err
+	}
+
+	typeCode := targetBlock.TypeCode()
+	if typeCode == ExtBlockTypePayloadBlock {
+		targetBlock.Value = NewPayloadBlock(plaintext)
+	} else {
+		var buff bytes.Buffer
+		if err := cboring.WriteByteString(plaintext, &buff); err != nil {
+			return err
+		}
+
+		if eb, err := GetExtensionBlockManager().ReadBlock(typeCode, &buff); err != nil {
+			return fmt.Errorf("decrypted block of type %d is invalid: %v", typeCode, err)
+		} else {
+			targetBlock.Value = eb
+		}
+	}
+
+	b.removeSecurityBlock(bcb)
+	return nil
+}
+
+// AddBlockConfidentialityBlock attaches an unencrypted BlockConfidentialityBlock to this Bundle and encrypts the
+// block of the given block type code.
+//
+// If a BIB protects this block, the BIB will also be encrypted by an additional BCB with the same parameters. The
+// KEK's format depends on the BCB's security context, as described for BlockConfidentialityBlock.Encrypt.
+func (b *Bundle) AddBlockConfidentialityBlock(bcb *BlockConfidentialityBlock, targetType uint64, kek []byte) error {
+	// The block number is stored, because adding blocks re-sorts the CanonicalBlocks and invalidates pointers.
+	var target uint64
+	if cb, err := b.ExtensionBlock(targetType); err != nil {
+		return err
+	} else if target = cb.BlockNumber; b.isEncrypted(target) {
+		return fmt.Errorf("block of type %d is already encrypted", targetType)
+	}
+
+	// Copy the BCB's configuration before being altered for a BIB's additional BCB.
+	bibBcb := &BlockConfidentialityBlock{AbstractSecurityBlock{
+		SecurityContextId:         bcb.SecurityContextId,
+		SecuritySource:            bcb.SecuritySource,
+		SecurityContextParameters: append([]IdValueTuple(nil), bcb.SecurityContextParameters...),
+	}}
+
+	bcb.SecurityTargets = []uint64{target}
+	b.AddExtensionBlock(NewCanonicalBlock(0, ReplicateBlock, bcb))
+
+	if err := bcb.Encrypt(b, kek); err != nil {
+		b.removeSecurityBlock(bcb)
+		return err
+	}
+
+	bibBlock, bibErr := b.ExtensionBlock(ExtBlockTypeBlockIntegrityBlock)
+	if bibErr != nil || b.isEncrypted(bibBlock.BlockNumber) {
+		return nil
+	} else if bib, ok := bibBlock.Value.(*BlockIntegrityBlock); !ok || !bib.HasTarget(target) {
+		return nil
+	}
+
+	bibBcb.SecurityTargets = []uint64{bibBlock.BlockNumber}
+	b.AddExtensionBlock(NewCanonicalBlock(0, ReplicateBlock, bibBcb))
+
+	if err := bibBcb.Encrypt(b, kek); err != nil {
+		b.removeSecurityBlock(bibBcb)
+		return fmt.Errorf("encrypting the Block Integrity Block errored: %v", err)
+	}
+
+	return nil
+}
+
+// DecryptBlockConfidentialityBlocks decrypts all encrypted blocks of this Bundle and removes the BCBs afterwards.
+func (b *Bundle) DecryptBlockConfidentialityBlocks(kek []byte) error {
+	var bcbs []*BlockConfidentialityBlock
+	for _, cb := range b.CanonicalBlocks {
+		if bcb, ok := cb.Value.(*BlockConfidentialityBlock); ok {
+			bcbs = append(bcbs, bcb)
+		}
+	}
+
+	for _, bcb := range bcbs {
+		if err := bcb.Decrypt(b, kek); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HasBlockConfidentialityBlock checks if this Bundle contains at least one BCB.
+func (b *Bundle) HasBlockConfidentialityBlock() bool {
+	_, err := b.ExtensionBlock(ExtBlockTypeBlockConfidentialityBlock)
+	return err == nil
+}
@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package bundle
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testConfidentialityKek is a 256 bit key encryption key for the BlockConfidentialityBlock tests.
+var testConfidentialityKek = []byte("0123456789abcdef0123456789abcdef")
+
+func TestBlockConfidentialityBlockPayload(t *testing.T) {
+	payload := []byte("hello world")
+
+	for _, variant := range []AESVariant{A128GCM, A256GCM} {
+		b := testIntegrityBundle(t)
+
+		bcb := NewBCBAESGCM(b.PrimaryBlock.SourceNode, variant, AADScopeAll)
+		if err := b.AddBlockConfidentialityBlock(bcb, ExtBlockTypePayloadBlock, testConfidentialityKek); err != nil {
+			t.Fatal(err)
+		}
+
+		b2 := testIntegrityRoundtrip(b, t)
+		if !b2.HasBlockConfidentialityBlock() {
+			t.Fatal("Bundle has no Block Confidentiality Block")
+		}
+
+		pb, _ := b2.PayloadBlock()
+		if bytes.Equal(pb.Value.(*PayloadBlock).Data(), payload) {
+			t.Fatal("Payload was not encrypted")
+		}
+
+		b3 := testIntegrityRoundtrip(b2, t)
+		if err := b3.DecryptBlockConfidentialityBlocks([]byte("fedcba9876543210fedcba9876543210")); err == nil {
+			t.Fatal("Decryption with a wrong KEK succeeded")
+		}
+
+		if err := b2.DecryptBlockConfidentialityBlocks(testConfidentialityKek); err != nil {
+			t.Fatal(err)
+		}
+		if b2.HasBlockConfidentialityBlock() {
+			t.Fatal("Block Confidentiality Block was not removed")
+		}
+
+		pb, _ = b2.PayloadBlock()
+		if data := pb.Value.(*PayloadBlock).Data(); !bytes.Equal(data, payload) {
+			t.Fatalf("Decrypted payload is %x, expected %x", data, payload)
+		}
+		if err := b2.CheckValid(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBlockConfidentialityBlockAlteredPrimaryBlock(t *testing.T) {
+	b := testIntegrityBundle(t)
+
+	bcb := NewBCBAESGCM(b.PrimaryBlock.SourceNode, A256GCM, AADScopeAll)
+	if err := b.AddBlockConfidentialityBlock(bcb, ExtBlockTypePayloadBlock, testConfidentialityKek); err != nil {
+		t.Fatal(err)
+	}
+
+	b.PrimaryBlock.Destination = MustNewEndpointID("dtn://evil/")
+	if err := b.DecryptBlockConfidentialityBlocks(testConfidentialityKek); err == nil {
+		t.Fatal("Decryption succeeded for an altered Primary Block")
+	}
+}
+
+func TestBlockConfidentialityBlockWithIntegrity(t *testing.T) {
+	b := testIntegrityBundle(t)
+	key := []byte("hmac key")
+
+	bib := NewBIBHMACSHA2(b.PrimaryBlock.SourceNode, HMAC384, IntegrityScopeAll)
+	if err := b.AddBlockIntegrityBlock(bib, []uint64{0, ExtBlockTypePayloadBlock}, key); err != nil {
+		t.Fatal(err)
+	}
+
+	bcb := NewBCBAESGCM(b.PrimaryBlock.SourceNode, A256GCM, AADScopeAll)
+	if err := b.AddBlockConfidentialityBlock(bcb, ExtBlockTypePayloadBlock, testConfidentialityKek); err != nil {
+		t.Fatal(err)
+	}
+
+	// An intermediate node cannot parse the encrypted BIB.
+	b2 := testIntegrityRoundtrip(b, t)
+	bibBlock, err := b2.ExtensionBlock(ExtBlockTypeBlockIntegrityBlock)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, ok := bibBlock.Value.(*GenericExtensionBlock); !ok {
+		t.Fatalf("BIB was not encrypted, but is %T", bibBlock.Value)
+	}
+
+	if err := b2.DecryptBlockConfidentialityBlocks(testConfidentialityKek); err != nil {
+		t.Fatal(err)
+	}
+
+	bibBlock, _ = b2.ExtensionBlock(ExtBlockTypeBlockIntegrityBlock)
+	if bib2, ok := bibBlock.Value.(*BlockIntegrityBlock); !ok {
+		t.Fatalf("Decrypted BIB is %T", bibBlock.Value)
+	} else if err := bib2.Verify(b2, key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlockConfidentialityBlockGenericTarget(t *testing.T) {
+	b := testIntegrityBundle(t)
+	data := []byte("some custom block")
+	b.AddExtensionBlock(NewCanonicalBlock(0, 0, NewGenericExtensionBlock(data, 250)))
+
+	bcb := NewBCBAESGCM(b.PrimaryBlock.SourceNode, A256GCM, AADScopeAll)
+	if err := b.AddBlockConfidentialityBlock(bcb, 250, testConfidentialityKek); err != nil {
+		t.Fatal(err)
+	}
+
+	b2 := testIntegrityRoundtrip(b, t)
+	if err := b2.DecryptBlockConfidentialityBlocks(testConfidentialityKek); err != nil {
+		t.Fatal(err)
+	}
+
+	cb, _ := b2.ExtensionBlock(250)
+	if plain, _ := cb.Value.(*GenericExtensionBlock).MarshalBinary(); !bytes.Equal(plain, data) {
+		t.Fatalf("Decrypted block is %x, expected %x", plain, data)
+	}
+}
+
+func TestBlockConfidentialityBlockErrors(t *testing.T) {
+	b := testIntegrityBundle(t)
+
+	bcb := NewBCBAESGCM(b.PrimaryBlock.SourceNode, A256GCM, AADScopeAll)
+	if err := b.AddBlockConfidentialityBlock(bcb, ExtBlockTypeHopCountBlock, testConfidentialityKek); err == nil {
+		t.Fatal("Encrypting the Hop Count Block succeeded")
+	} else if b.HasBlockConfidentialityBlock() {
+		t.Fatal("Failed BCB was not removed from the Bundle")
+	}
+
+	bcb = NewBCBAESGCM(b.PrimaryBlock.SourceNode, A256GCM, AADScopeAll)
+	if err := b.AddBlockConfidentialityBlock(bcb, ExtBlockTypePayloadBlock, []byte("short")); err == nil {
+		t.Fatal("Encrypting with an invalid KEK succeeded")
+	}
+
+	bcb = NewBCBAESGCM(b.PrimaryBlock.SourceNode, A256GCM, AADScopeAll)
+	if err := b.AddBlockConfidentialityBlock(bcb, ExtBlockTypePayloadBlock, testConfidentialityKek); err != nil {
+		t.Fatal(err)
+	}
+	bcb = NewBCBAESGCM(b.PrimaryBlock.SourceNode, A256GCM, AADScopeAll)
+	if err := b.AddBlockConfidentialityBlock(bcb, ExtBlockTypePayloadBlock, testConfidentialityKek); err == nil {
+		t.Fatal("Encrypting an encrypted block succeeded")
+	}
+}
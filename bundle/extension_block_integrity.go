@@ -0,0 +1,233 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dtn7/cboring"
+)
+
+// IntegrityScopeFlags define which additional data is part of the Integrity-Protected Plaintext (IPPT) of a
+// Block Integrity Block, RFC 9173 section 3.3.3.
+type IntegrityScopeFlags uint64
+
+const (
+	// IntegrityScopePrimaryBlock includes the Primary Block into the IPPT.
+	IntegrityScopePrimaryBlock IntegrityScopeFlags = 0x01
+
+	// IntegrityScopeTargetHeader includes the Security Target's block type code, number and flags into the IPPT.
+	IntegrityScopeTargetHeader IntegrityScopeFlags = 0x02
+
+	// IntegrityScopeSecurityHeader includes the BIB's block type code, number and flags into the IPPT.
+	IntegrityScopeSecurityHeader IntegrityScopeFlags = 0x04
+
+	// IntegrityScopeAll combines all integrity scope flags, which is also the default.
+	IntegrityScopeAll IntegrityScopeFlags = IntegrityScopePrimaryBlock | IntegrityScopeTargetHeader | IntegrityScopeSecurityHeader
+)
+
+// integrityParamScope is the Security Context Parameter id of the IntegrityScopeFlags, shared by all implemented
+// BIB security contexts.
+const integrityParamScope uint64 = 3
+
+// BlockIntegrityBlock implements the BPSec Block Integrity Block (BIB), RFC 9172 section 3.7.
+//
+// A BIB protects one or more Security Targets, referenced by their block numbers, by a security context. Currently,
+// the BIB-HMAC-SHA2 default security context, RFC 9173 section 3, and a site-specific ed25519 signature context are
+// implemented. Because the BIB's own block header might be part of the integrity scope, the BIB must be attached to
+// the Bundle before being signed. Bundle.AddBlockIntegrityBlock takes care of this.
+//
+// 	bib := bundle.NewBIBHMACSHA2(b.PrimaryBlock.SourceNode, bundle.HMAC384, bundle.IntegrityScopeAll)
+// 	err := b.AddBlockIntegrityBlock(bib, []uint64{bundle.ExtBlockTypePayloadBlock}, key)
+//
+// The block-type-specific data is an Abstract Security Block, as described for the AbstractSecurityBlock.
+type BlockIntegrityBlock struct {
+	AbstractSecurityBlock
+}
+
+// BlockTypeCode must return a constant integer, indicating the block type code.
+func (bib *BlockIntegrityBlock) BlockTypeCode() uint64 {
+	return ExtBlockTypeBlockIntegrityBlock
+}
+
+// CheckValid checks the AbstractSecurityBlock's fields for errors.
+//
+// This DOES NOT verify the security results. Therefore please use the Verify method.
+func (bib *BlockIntegrityBlock) CheckValid() error {
+	return bib.AbstractSecurityBlock.CheckValid()
+}
+
+// IntegrityScope returns the IntegrityScopeFlags of this BIB's Security Context Parameters or the default value.
+func (bib *BlockIntegrityBlock) IntegrityScope() IntegrityScopeFlags {
+	if param, ok := bib.Parameter(integrityParamScope); ok {
+		if scope, ok := param.UInt(); ok {
+			return IntegrityScopeFlags(scope)
+		}
+	}
+	return IntegrityScopeAll
+}
+
+// ippt creates the Integrity-Protected Plaintext for a Security Target, RFC 9173 section 3.7.
+func (bib *BlockIntegrityBlock) ippt(b *Bundle, target uint64) ([]byte, error) {
+	var buff bytes.Buffer
+	var scope = bib.IntegrityScope()
+
+	bibBlock, err := b.securityBlock(bib)
+	if err != nil {
+		return nil, err
+	}
+
+	var targetBlock *CanonicalBlock
+	if target != 0 {
+		for i := 0; i < len(b.CanonicalBlocks); i++ {
+			if cb := &b.CanonicalBlocks[i]; cb.BlockNumber == target {
+				targetBlock = cb
+				break
+			}
+		}
+
+		if targetBlock == nil {
+			return nil, fmt.Errorf("Security Target %d does not exist", target)
+		} else if tc := targetBlock.TypeCode(); tc == ExtBlockTypeBlockIntegrityBlock || tc == ExtBlockTypeBlockConfidentialityBlock {
+			return nil, fmt.Errorf("Security Target %d is a security block of type %d", target, tc)
+		}
+	}
+
+	if err := cboring.WriteUInt(uint64(scope), &buff); err != nil {
+		return nil, err
+	}
+
+	if scope&IntegrityScopePrimaryBlock != 0 {
+		pb := b.PrimaryBlock
+		if err := cboring.Marshal(&pb, &buff); err != nil {
+			return nil, err
+		}
+	}
+
+	var headers []*CanonicalBlock
+	if scope&IntegrityScopeTargetHeader != 0 && targetBlock != nil {
+		headers = append(headers, targetBlock)
+	}
+	if scope&IntegrityScopeSecurityHeader != 0 {
+		headers = append(headers, bibBlock)
+	}
+	for _, header := range headers {
+		for _, field := range []uint64{header.TypeCode(), header.BlockNumber, uint64(header.BlockControlFlags)} {
+			if err := cboring.WriteUInt(field, &buff); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if targetBlock == nil {
+		pb := b.PrimaryBlock
+		if err := cboring.Marshal(&pb, &buff); err != nil {
+			return nil, err
+		}
+	} else if err := GetExtensionBlockManager().WriteBlock(targetBlock.Value, &buff); err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
+
+// Sign calculates the Security Results for all Security Targets. This BIB must already be part of the Bundle.
+//
+// The key's format depends on the security context, e.g., the HMAC key for BIB-HMAC-SHA2 or an ed25519.PrivateKey.
+func (bib *BlockIntegrityBlock) Sign(b *Bundle, key []byte) error {
+	if b.PrimaryBlock.BundleControlFlags.Has(IsFragment) {
+		return fmt.Errorf("fragmented Bundles cannot be signed")
+	}
+
+	results := make([][]IdValueTuple, len(bib.SecurityTargets))
+	for i, target := range bib.SecurityTargets {
+		ippt, err := bib.ippt(b, target)
+		if err != nil {
+			return err
+		}
+
+		switch bib.SecurityContextId {
+		case SecConIdBIBHMACSHA2:
+			results[i], err = bibHMACSHA2Sign(bib, ippt, key)
+		case SecConIdBIBEd25519:
+			results[i], err = bibEd25519Sign(bib, ippt, key)
+		default:
+			err = fmt.Errorf("unsupported security context %d", bib.SecurityContextId)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	bib.SecurityResults = results
+	return nil
+}
+
+// Verify the Security Results of all Security Targets against the given Bundle, containing this BIB.
+//
+// The key's format depends on the security context, e.g., the HMAC key for BIB-HMAC-SHA2 or an ed25519.PublicKey.
+func (bib *BlockIntegrityBlock) Verify(b Bundle, key []byte) error {
+	if err := bib.CheckValid(); err != nil {
+		return err
+	}
+
+	if b.PrimaryBlock.BundleControlFlags.Has(IsFragment) {
+		return fmt.Errorf("fragmented Bundles cannot be verified")
+	}
+
+	for i, target := range bib.SecurityTargets {
+		ippt, err := bib.ippt(&b, target)
+		if err != nil {
+			return err
+		}
+
+		switch bib.SecurityContextId {
+		case SecConIdBIBHMACSHA2:
+			err = bibHMACSHA2Verify(bib, ippt, bib.SecurityResults[i], key)
+		case SecConIdBIBEd25519:
+			err = bibEd25519Verify(bib, ippt, bib.SecurityResults[i], key)
+		default:
+			err = fmt.Errorf("unsupported security context %d", bib.SecurityContextId)
+		}
+
+		if err != nil {
+			return fmt.Errorf("Security Target %d: %v", target, err)
+		}
+	}
+
+	return nil
+}
+
+// AddBlockIntegrityBlock attaches an unsigned BlockIntegrityBlock to this Bundle and signs the blocks of the given
+// block type codes. The block type code 0 addresses the Primary Block.
+//
+// The key's format depends on the BIB's security context, as described for BlockIntegrityBlock.Sign.
+func (b *Bundle) AddBlockIntegrityBlock(bib *BlockIntegrityBlock, targetTypes []uint64, key []byte) error {
+	if _, err := b.ExtensionBlock(ExtBlockTypeBlockIntegrityBlock); err == nil {
+		return fmt.Errorf("Bundle already contains a Block Integrity Block")
+	}
+
+	bib.SecurityTargets = make([]uint64, 0, len(targetTypes))
+	for _, typeCode := range targetTypes {
+		if typeCode == 0 {
+			bib.SecurityTargets = append(bib.SecurityTargets, 0)
+		} else if cb, err := b.ExtensionBlock(typeCode); err != nil {
+			return err
+		} else {
+			bib.SecurityTargets = append(bib.SecurityTargets, cb.BlockNumber)
+		}
+	}
+
+	b.AddExtensionBlock(NewCanonicalBlock(0, 0, bib))
+
+	if err := bib.Sign(b, key); err != nil {
+		b.removeSecurityBlock(bib)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,261 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package bundle
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"reflect"
+	"testing"
+
+	"github.com/dtn7/cboring"
+)
+
+func TestAbstractSecurityBlockCbor(t *testing.T) {
+	tests := []struct {
+		name string
+		asb  AbstractSecurityBlock
+	}{
+		{"no parameters", AbstractSecurityBlock{
+			SecurityTargets:   []uint64{1},
+			SecurityContextId: SecConIdBIBHMACSHA2,
+			SecuritySource:    MustNewEndpointID("dtn://src/"),
+			SecurityResults:   [][]IdValueTuple{{{Id: 1, Value: []byte("foo")}}},
+		}},
+		{"negative context id", AbstractSecurityBlock{
+			SecurityTargets:   []uint64{0, 1},
+			SecurityContextId: SecConIdBIBEd25519,
+			SecuritySource:    MustNewEndpointID("ipn:23.42"),
+			SecurityContextParameters: []IdValueTuple{
+				{Id: 1, Value: []byte{0x00, 0x01}},
+				{Id: 3, Value: uint64(7)},
+			},
+			SecurityResults: [][]IdValueTuple{
+				{{Id: 1, Value: []byte("foo")}},
+				{{Id: 1, Value: []byte("bar")}, {Id: 2, Value: uint64(42)}},
+			},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buff bytes.Buffer
+			if err := cboring.Marshal(&test.asb, &buff); err != nil {
+				t.Fatal(err)
+			}
+
+			var asb AbstractSecurityBlock
+			if err := cboring.Unmarshal(&asb, &buff); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(test.asb, asb) {
+				t.Fatalf("AbstractSecurityBlocks differ:\n%v\n%v", test.asb, asb)
+			}
+		})
+	}
+}
+
+func TestAbstractSecurityBlockCheckValid(t *testing.T) {
+	src := MustNewEndpointID("dtn://src/")
+	res := []IdValueTuple{{Id: 1, Value: []byte("foo")}}
+
+	tests := []struct {
+		name    string
+		asb     AbstractSecurityBlock
+		wantErr bool
+	}{
+		{"valid", AbstractSecurityBlock{[]uint64{1}, 1, src, nil, [][]IdValueTuple{res}}, false},
+		{"no targets", AbstractSecurityBlock{nil, 1, src, nil, nil}, true},
+		{"duplicate targets", AbstractSecurityBlock{[]uint64{1, 1}, 1, src, nil, [][]IdValueTuple{res, res}}, true},
+		{"missing results", AbstractSecurityBlock{[]uint64{0, 1}, 1, src, nil, [][]IdValueTuple{res}}, true},
+		{"invalid value", AbstractSecurityBlock{[]uint64{1}, 1, src,
+			[]IdValueTuple{{Id: 1, Value: "foo"}}, [][]IdValueTuple{res}}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.asb.CheckValid(); (err != nil) != test.wantErr {
+				t.Fatalf("CheckValid() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+// testIntegrityBundle creates a new Bundle for the BlockIntegrityBlock tests.
+func testIntegrityBundle(t *testing.T) Bundle {
+	b, err := Builder().
+		CRC(CRC32).
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("1h").
+		HopCountBlock(64).
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// testIntegrityRoundtrip serializes and deserializes a Bundle.
+func testIntegrityRoundtrip(b Bundle, t *testing.T) Bundle {
+	var buff bytes.Buffer
+	if err := b.MarshalCbor(&buff); err != nil {
+		t.Fatal(err)
+	}
+
+	var b2 Bundle
+	if err := b2.UnmarshalCbor(&buff); err != nil {
+		t.Fatal(err)
+	}
+	return b2
+}
+
+func TestBlockIntegrityBlockHMACSHA2(t *testing.T) {
+	variants := []HMACSHA2Variant{HMAC256, HMAC384, HMAC512}
+	targets := [][]uint64{{ExtBlockTypePayloadBlock}, {0, ExtBlockTypePayloadBlock, ExtBlockTypeHopCountBlock}}
+	key := []byte("correct horse battery staple")
+
+	for _, variant := range variants {
+		for _, target := range targets {
+			b := testIntegrityBundle(t)
+
+			bib := NewBIBHMACSHA2(b.PrimaryBlock.SourceNode, variant, IntegrityScopeAll)
+			if err := b.AddBlockIntegrityBlock(bib, target, key); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.CheckValid(); err != nil {
+				t.Fatal(err)
+			}
+
+			b2 := testIntegrityRoundtrip(b, t)
+			bibBlock, err := b2.ExtensionBlock(ExtBlockTypeBlockIntegrityBlock)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bib2 := bibBlock.Value.(*BlockIntegrityBlock)
+
+			if err := bib2.Verify(b2, key); err != nil {
+				t.Fatalf("Verification of variant %d, targets %v failed: %v", variant, target, err)
+			}
+			if err := bib2.Verify(b2, []byte("wrong key")); err == nil {
+				t.Fatalf("Verification of variant %d, targets %v succeeded for a wrong key", variant, target)
+			}
+
+			pb, _ := b2.PayloadBlock()
+			pb.Value = NewPayloadBlock([]byte("HELLO WORLD"))
+			if err := bib2.Verify(b2, key); err == nil {
+				t.Fatalf("Verification of variant %d, targets %v succeeded for an altered payload", variant, target)
+			}
+		}
+	}
+}
+
+func TestBlockIntegrityBlockEd25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+
+	b := testIntegrityBundle(t)
+	bib := NewBIBEd25519(b.PrimaryBlock.SourceNode, pub, IntegrityScopeAll)
+	if err := b.AddBlockIntegrityBlock(bib, []uint64{0, ExtBlockTypePayloadBlock}, priv); err != nil {
+		t.Fatal(err)
+	}
+
+	b2 := testIntegrityRoundtrip(b, t)
+	bibBlock, _ := b2.ExtensionBlock(ExtBlockTypeBlockIntegrityBlock)
+	bib2 := bibBlock.Value.(*BlockIntegrityBlock)
+
+	if attachedPub, ok := bib2.Ed25519PublicKey(); !ok || !bytes.Equal(attachedPub, pub) {
+		t.Fatalf("Attached public key %x differs from %x", attachedPub, pub)
+	}
+
+	if err := bib2.Verify(b2, pub); err != nil {
+		t.Fatal(err)
+	}
+	if err := bib2.Verify(b2, otherPub); err == nil {
+		t.Fatal("Verification succeeded for another public key")
+	}
+
+	b2.PrimaryBlock.Lifetime++
+	if err := bib2.Verify(b2, pub); err == nil {
+		t.Fatal("Verification succeeded for an altered Primary Block")
+	}
+}
+
+func TestBlockIntegrityBlockErrors(t *testing.T) {
+	b := testIntegrityBundle(t)
+	key := []byte("key")
+
+	bib := NewBIBHMACSHA2(b.PrimaryBlock.SourceNode, HMAC384, IntegrityScopeAll)
+	if err := b.AddBlockIntegrityBlock(bib, []uint64{ExtBlockTypeBundleAgeBlock}, key); err == nil {
+		t.Fatal("Signing a non-existing block succeeded")
+	}
+
+	bib = NewBIBHMACSHA2(b.PrimaryBlock.SourceNode, HMAC384, IntegrityScopeAll)
+	if err := b.AddBlockIntegrityBlock(bib, []uint64{ExtBlockTypePayloadBlock}, nil); err == nil {
+		t.Fatal("Signing with an empty key succeeded")
+	} else if _, err := b.ExtensionBlock(ExtBlockTypeBlockIntegrityBlock); err == nil {
+		t.Fatal("Failed BIB was not removed from the Bundle")
+	}
+
+	_, priv, _ := ed25519.GenerateKey(nil)
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	bib = NewBIBEd25519(b.PrimaryBlock.SourceNode, otherPub, IntegrityScopeAll)
+	if err := b.AddBlockIntegrityBlock(bib, []uint64{ExtBlockTypePayloadBlock}, priv); err == nil {
+		t.Fatal("Signing with a mismatching ed25519 key succeeded")
+	}
+
+	bib = NewBIBHMACSHA2(b.PrimaryBlock.SourceNode, HMAC384, IntegrityScopeAll)
+	if err := b.AddBlockIntegrityBlock(bib, []uint64{ExtBlockTypePayloadBlock}, key); err != nil {
+		t.Fatal(err)
+	}
+	bib = NewBIBHMACSHA2(b.PrimaryBlock.SourceNode, HMAC384, IntegrityScopeAll)
+	if err := b.AddBlockIntegrityBlock(bib, []uint64{ExtBlockTypePayloadBlock}, key); err == nil {
+		t.Fatal("Adding a second BIB succeeded")
+	}
+}
+
+func TestBundleBuilderBlockIntegrityBlock(t *testing.T) {
+	key := []byte("key")
+
+	b, err := Builder().
+		CRC(CRC32).
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("1h").
+		BlockIntegrityBlock(key, []uint64{0, ExtBlockTypePayloadBlock}).
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b2 := testIntegrityRoundtrip(b, t)
+	bibBlock, err := b2.ExtensionBlock(ExtBlockTypeBlockIntegrityBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bibBlock.CRCType != CRC32 {
+		t.Fatalf("BIB's CRC type is %v", bibBlock.CRCType)
+	}
+
+	bib := bibBlock.Value.(*BlockIntegrityBlock)
+	if !reflect.DeepEqual(bib.SecurityTargets, []uint64{0, 1}) {
+		t.Fatalf("BIB's Security Targets are %v", bib.SecurityTargets)
+	}
+	if err := bib.Verify(b2, key); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Builder().BlockIntegrityBlock("key").Build(); err == nil {
+		t.Fatal("BlockIntegrityBlock accepted a string key")
+	}
+}
@@ -32,8 +32,9 @@ import (
 // elements are firstly the PublicKey and secondly the Signature, both represented as a CBOR byte string. Both the array
 // and the byte strings MUST be of a defined length, NOT indefinite-length items.
 //
-// Although this block is present in the bundle package, it is NOT specified in ietf-dtn-bpbis. It might be removed once
-// ietf-dtn-bpsec is implemented.
+// Although this block is present in the bundle package, it is NOT specified in ietf-dtn-bpbis.
+//
+// Deprecated: SignatureBlock is superseded by the BPSec BlockIntegrityBlock. Use NewBIBEd25519 for ed25519 signatures.
 type SignatureBlock struct {
 	PublicKey []byte
 	Signature []byte
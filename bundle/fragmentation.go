@@ -14,6 +14,8 @@ import (
 )
 
 // Fragment a Bundle into multiple Bundles, with each serialized Bundle limited to mtu bytes.
+//
+// A fragment might also be fragmented again. The resulting fragments refer to the original Bundle's payload.
 func (b Bundle) Fragment(mtu int) (bs []Bundle, err error) {
 	if b.PrimaryBlock.BundleControlFlags.Has(MustNotFragmented) {
 		err = fmt.Errorf("bundle control flags forbids bundle fragmentation")
@@ -34,6 +36,8 @@ func (b Bundle) Fragment(mtu int) (bs []Bundle, err error) {
 	}
 	payloadBlockLen = len(payloadBlock.Value.(*PayloadBlock).Data())
 
+	baseOffset, totalDataLength := fragmentRange(b.PrimaryBlock, payloadBlockLen)
+
 	if extFirstOverhead, extOtherOverhead, err = fragmentExtensionBlocksLen(b, mtu); err != nil {
 		return
 	}
@@ -44,7 +48,7 @@ func (b Bundle) Fragment(mtu int) (bs []Bundle, err error) {
 			primaryOverhead  int
 		)
 
-		if fragPrimaryBlock, primaryOverhead, err = fragmentPrimaryBlock(b.PrimaryBlock, i, payloadBlockLen); err != nil {
+		if fragPrimaryBlock, primaryOverhead, err = fragmentPrimaryBlock(b.PrimaryBlock, baseOffset+i, totalDataLength); err != nil {
 			return
 		}
 
@@ -60,29 +64,12 @@ func (b Bundle) Fragment(mtu int) (bs []Bundle, err error) {
 			return
 		}
 
-		fragBundle := MustNewBundle(fragPrimaryBlock, nil)
-
-		for _, cb := range b.CanonicalBlocks {
-			if cb.TypeCode() == ExtBlockTypePayloadBlock {
-				continue
-			}
-			if i > 0 && !cb.BlockControlFlags.Has(ReplicateBlock) {
-				continue
-			}
-
-			fragBundle.AddExtensionBlock(cb)
-		}
-
 		fragPayloadBlockLen := mtu - overhead
 
 		offset := int(math.Min(float64(i+fragPayloadBlockLen), float64(len(payloadBlock.Value.(*PayloadBlock).Data()))))
-		fragBundle.AddExtensionBlock(CanonicalBlock{
-			BlockControlFlags: payloadBlock.BlockControlFlags,
-			CRCType:           payloadBlock.CRCType,
-			Value:             NewPayloadBlock(payloadBlock.Value.(*PayloadBlock).Data()[i:offset]),
-		})
 
-		if err = fragBundle.CheckValid(); err != nil {
+		var fragBundle Bundle
+		if fragBundle, err = fragmentBundle(b, fragPrimaryBlock, payloadBlock.Value.(*PayloadBlock).Data()[i:offset], i == 0); err != nil {
 			return
 		}
 		bs = append(bs, fragBundle)
@@ -97,6 +84,46 @@ func (b Bundle) Fragment(mtu int) (bs []Bundle, err error) {
 	return
 }
 
+// fragmentRange returns the offset and the total data length of a Bundle's payload, referring to the original
+// Bundle's payload for an already fragmented Bundle.
+func fragmentRange(pb PrimaryBlock, payloadLen int) (baseOffset, totalDataLength int) {
+	if pb.BundleControlFlags.Has(IsFragment) {
+		return int(pb.FragmentOffset), int(pb.TotalDataLength)
+	}
+	return 0, payloadLen
+}
+
+// fragmentBundle creates a fragment of a Bundle for the fragment's Primary Block and its payload data. Only the first
+// fragment contains all Extension Blocks; the other fragments only those which must be replicated.
+func fragmentBundle(b Bundle, fragPrimaryBlock PrimaryBlock, payload []byte, first bool) (fragBundle Bundle, err error) {
+	payloadBlock, err := b.PayloadBlock()
+	if err != nil {
+		return
+	}
+
+	fragBundle = MustNewBundle(fragPrimaryBlock, nil)
+
+	for _, cb := range b.CanonicalBlocks {
+		if cb.TypeCode() == ExtBlockTypePayloadBlock {
+			continue
+		}
+		if !first && !cb.BlockControlFlags.Has(ReplicateBlock) {
+			continue
+		}
+
+		fragBundle.AddExtensionBlock(cb)
+	}
+
+	fragBundle.AddExtensionBlock(CanonicalBlock{
+		BlockControlFlags: payloadBlock.BlockControlFlags,
+		CRCType:           payloadBlock.CRCType,
+		Value:             NewPayloadBlock(payload),
+	})
+
+	err = fragBundle.CheckValid()
+	return
+}
+
 // fragmentPrimaryBlock creates a fragment's Primary Block and calculates its length.
 func fragmentPrimaryBlock(pb PrimaryBlock, fragmentOffset, totalDataLength int) (fragPb PrimaryBlock, l int, err error) {
 	fragPb = PrimaryBlock{
@@ -161,7 +188,8 @@ func fragmentExtensionBlocksLen(b Bundle, mtu int) (first int, others int, err e
 	return
 }
 
-// prepareReassembly sorts the slice of Bundle fragments and checks if their are any gaps left.
+// prepareReassembly sorts the slice of Bundle fragments and checks if their are any gaps left. Fragments might overlap
+// or even be contained within other fragments.
 func prepareReassembly(bs []Bundle) error {
 	if len(bs) == 0 {
 		return fmt.Errorf("slice of fragments is empty")
@@ -181,8 +209,8 @@ func prepareReassembly(bs []Bundle) error {
 			return fmt.Errorf("next fragment starts at offset %d, gap from %d to %d", fragOff, lastIndex, fragOff)
 		} else if payloadBlock, err := b.PayloadBlock(); err != nil {
 			return err
-		} else {
-			lastIndex = fragOff + uint64(len(payloadBlock.Value.(*PayloadBlock).Data()))
+		} else if fragEnd := fragOff + uint64(len(payloadBlock.Value.(*PayloadBlock).Data())); fragEnd > lastIndex {
+			lastIndex = fragEnd
 		}
 	}
 
@@ -199,7 +227,7 @@ func IsBundleReassemblable(bs []Bundle) bool {
 	return prepareReassembly(bs) == nil
 }
 
-// mergeFragmentPayload merges the fragmented payload.
+// mergeFragmentPayload merges the fragmented payload. Already covered parts of overlapping fragments are skipped.
 func mergeFragmentPayload(bs []Bundle) (data []byte, err error) {
 	lastIndex := 0
 	for _, b := range bs {
@@ -216,8 +244,10 @@ func mergeFragmentPayload(bs []Bundle) (data []byte, err error) {
 		}
 		fragPayloadData = fragPayloadBlock.Value.(*PayloadBlock).Data()
 
-		data = append(data, fragPayloadData[lastIndex-fragStartIndex:]...)
-		lastIndex = fragStartIndex + len(fragPayloadData)
+		if fragEndIndex := fragStartIndex + len(fragPayloadData); fragEndIndex > lastIndex {
+			data = append(data, fragPayloadData[lastIndex-fragStartIndex:]...)
+			lastIndex = fragEndIndex
+		}
 	}
 
 	return
@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/dtn7/cboring"
+)
+
+// ReactiveFragment creates a fragment from the received prefix of a serialized Bundle, whose transmission was
+// interrupted. This is the receiving side of the reactive fragmentation.
+//
+// The prefix must contain the Primary Block, all Extension Blocks and at least one byte of the Payload Block's data.
+// The sender creates the complementary fragment by ReactiveFragmentRemainder. Both sides should be cut at the same
+// acknowledged length to create adjacent fragments; overlapping fragments are still reassembled.
+func ReactiveFragment(prefix []byte) (frag Bundle, err error) {
+	r := bytes.NewReader(prefix)
+
+	if err = cboring.ReadExpect(cboring.IndefiniteArray, r); err != nil {
+		err = fmt.Errorf("reading bundle's array failed: %v", err)
+		return
+	}
+
+	var b Bundle
+	if err = cboring.Unmarshal(&b.PrimaryBlock, r); err != nil {
+		err = fmt.Errorf("unmarshalling primary block failed: %v", err)
+		return
+	} else if b.PrimaryBlock.BundleControlFlags.Has(MustNotFragmented) {
+		err = fmt.Errorf("bundle control flags forbids bundle fragmentation")
+		return
+	}
+
+	for {
+		// Peek into the next block to identify the Payload Block, which might be truncated.
+		peek := bytes.NewReader(prefix[len(prefix)-r.Len():])
+		if _, peekErr := cboring.ReadArrayLength(peek); peekErr != nil {
+			err = fmt.Errorf("prefix ends before the payload block: %v", peekErr)
+			return
+		} else if typeCode, peekErr := cboring.ReadUInt(peek); peekErr != nil {
+			err = fmt.Errorf("prefix ends before the payload block: %v", peekErr)
+			return
+		} else if typeCode == ExtBlockTypePayloadBlock {
+			r = peek
+			break
+		}
+
+		var cb CanonicalBlock
+		if err = cboring.Unmarshal(&cb, r); err != nil {
+			err = fmt.Errorf("unmarshalling canonical block failed: %v", err)
+			return
+		}
+		b.CanonicalBlocks = append(b.CanonicalBlocks, cb)
+	}
+
+	var fields [3]uint64
+	for i := range fields {
+		if fields[i], err = cboring.ReadUInt(r); err != nil {
+			err = fmt.Errorf("prefix ends within the payload block: %v", err)
+			return
+		}
+	}
+
+	payloadLen, err := cboring.ReadByteStringLen(r)
+	if err != nil {
+		err = fmt.Errorf("prefix ends within the payload block: %v", err)
+		return
+	}
+
+	data := prefix[len(prefix)-r.Len():]
+	if uint64(len(data)) > payloadLen {
+		data = data[:payloadLen]
+	}
+	if len(data) == 0 {
+		err = fmt.Errorf("prefix contains no payload data")
+		return
+	}
+
+	b.CanonicalBlocks = append(b.CanonicalBlocks, CanonicalBlock{
+		BlockNumber:       fields[0],
+		BlockControlFlags: BlockControlFlags(fields[1]),
+		CRCType:           CRCType(fields[2]),
+		Value:             NewPayloadBlock(nil),
+	})
+
+	baseOffset, totalDataLength := fragmentRange(b.PrimaryBlock, int(payloadLen))
+
+	fragPrimaryBlock, _, err := fragmentPrimaryBlock(b.PrimaryBlock, baseOffset, totalDataLength)
+	if err != nil {
+		return
+	}
+
+	frag, err = fragmentBundle(b, fragPrimaryBlock, data, true)
+	return
+}
+
+// ReactiveFragmentRemainder creates the fragment of this Bundle which was not transmitted, after the transmission of
+// its serialization was interrupted after the given amount of bytes. This is the sending side of the reactive
+// fragmentation and complements the receiver's ReactiveFragment.
+//
+// The returned boolean is false if the whole payload was transmitted and no fragment remains. An error is returned if
+// no payload data was transmitted, which requires the retransmission of the whole Bundle.
+func (b Bundle) ReactiveFragmentRemainder(transmitted int) (frag Bundle, remains bool, err error) {
+	if b.PrimaryBlock.BundleControlFlags.Has(MustNotFragmented) {
+		err = fmt.Errorf("bundle control flags forbids bundle fragmentation")
+		return
+	}
+
+	if l := len(b.CanonicalBlocks); l == 0 || b.CanonicalBlocks[l-1].TypeCode() != ExtBlockTypePayloadBlock {
+		err = fmt.Errorf("payload block is not the last block")
+		return
+	}
+
+	payloadBlock, err := b.PayloadBlock()
+	if err != nil {
+		return
+	}
+	data := payloadBlock.Value.(*PayloadBlock).Data()
+
+	var buff bytes.Buffer
+	if err = b.MarshalCbor(&buff); err != nil {
+		return
+	}
+
+	// The payload data is followed by the optional CRC's byte string and the indefinite array's break code.
+	trailerLen := 1
+	if payloadBlock.HasCRC() {
+		var crc []byte
+		if crc, err = emptyCRC(payloadBlock.CRCType); err != nil {
+			return
+		}
+		trailerLen += 1 + len(crc)
+	}
+
+	acknowledged := transmitted - (buff.Len() - trailerLen - len(data))
+	if acknowledged <= 0 {
+		err = fmt.Errorf("no payload data was transmitted")
+		return
+	} else if acknowledged >= len(data) {
+		return
+	}
+
+	baseOffset, totalDataLength := fragmentRange(b.PrimaryBlock, len(data))
+
+	fragPrimaryBlock, _, err := fragmentPrimaryBlock(b.PrimaryBlock, baseOffset+acknowledged, totalDataLength)
+	if err != nil {
+		return
+	}
+
+	if frag, err = fragmentBundle(b, fragPrimaryBlock, data[acknowledged:], false); err == nil {
+		remains = true
+	}
+	return
+}
@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package bundle
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestReactiveFragment(t *testing.T) {
+	payload := make([]byte, 1024)
+	rand.Read(payload)
+
+	for _, crcType := range []CRCType{CRCNo, CRC16, CRC32} {
+		bndl, err := Builder().
+			CRC(crcType).
+			Source("dtn://src/").
+			Destination("dtn://dst/").
+			CreationTimestampNow().
+			Lifetime("5m").
+			HopCountBlock(64).
+			PayloadBlock(payload).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buff bytes.Buffer
+		if err := bndl.MarshalCbor(&buff); err != nil {
+			t.Fatal(err)
+		}
+		serialized := buff.Bytes()
+		payloadStart := len(serialized) - len(payload) - 1
+		if crcType != CRCNo {
+			crc, _ := emptyCRC(crcType)
+			payloadStart -= 1 + len(crc)
+		}
+
+		for _, transmitted := range []int{payloadStart + 1, payloadStart + 512, payloadStart + len(payload) - 1} {
+			head, err := ReactiveFragment(serialized[:transmitted])
+			if err != nil {
+				t.Fatalf("%v, %d bytes: %v", crcType, transmitted, err)
+			}
+
+			tail, remains, err := bndl.ReactiveFragmentRemainder(transmitted)
+			if err != nil {
+				t.Fatalf("%v, %d bytes: %v", crcType, transmitted, err)
+			} else if !remains {
+				t.Fatalf("%v, %d bytes: no fragment remains", crcType, transmitted)
+			}
+
+			b, err := ReassembleFragments([]Bundle{tail, head})
+			if err != nil {
+				t.Fatalf("%v, %d bytes: %v", crcType, transmitted, err)
+			} else if pb, err := b.PayloadBlock(); err != nil {
+				t.Fatal(err)
+			} else if data := pb.Value.(*PayloadBlock).Data(); !bytes.Equal(data, payload) {
+				t.Fatalf("%v, %d bytes: reassembled payload differs", crcType, transmitted)
+			} else if b.ID() != bndl.ID() {
+				t.Fatalf("%v, %d bytes: reassembled ID %v differs from %v", crcType, transmitted, b.ID(), bndl.ID())
+			}
+		}
+
+		if _, err := ReactiveFragment(serialized[:payloadStart]); err == nil {
+			t.Fatalf("%v: prefix without payload data resulted in a fragment", crcType)
+		} else if _, _, err := bndl.ReactiveFragmentRemainder(payloadStart); err == nil {
+			t.Fatalf("%v: remainder of the whole payload did not error", crcType)
+		}
+
+		if _, remains, err := bndl.ReactiveFragmentRemainder(len(serialized)); err != nil {
+			t.Fatal(err)
+		} else if remains {
+			t.Fatalf("%v: fragment remains for a complete transmission", crcType)
+		}
+	}
+}
+
+func TestReactiveFragmentMustNotFragment(t *testing.T) {
+	bndl, err := Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		BundleCtrlFlags(MustNotFragmented).
+		CreationTimestampNow().
+		Lifetime("5m").
+		PayloadBlock(make([]byte, 128)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buff bytes.Buffer
+	if err := bndl.MarshalCbor(&buff); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReactiveFragment(buff.Bytes()[:buff.Len()-64]); err == nil {
+		t.Fatal("Bundle which must not be fragmented was fragmented")
+	} else if _, _, err := bndl.ReactiveFragmentRemainder(buff.Len() - 64); err == nil {
+		t.Fatal("Bundle which must not be fragmented was fragmented")
+	}
+}
@@ -119,6 +119,53 @@ func TestBundleFragmentHugeMtu(t *testing.T) {
 	}
 }
 
+func TestBundleFragmentFragment(t *testing.T) {
+	payload := make([]byte, 1024)
+	rand.Read(payload)
+
+	bndl, err := Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("5m").
+		PayloadBlock(payload).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frags, err := bndl.Fragment(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var subFrags []Bundle
+	for _, frag := range frags {
+		if fs, err := frag.Fragment(128); err != nil {
+			t.Fatal(err)
+		} else {
+			subFrags = append(subFrags, fs...)
+		}
+	}
+
+	for _, frag := range subFrags {
+		if total := frag.PrimaryBlock.TotalDataLength; total != uint64(len(payload)) {
+			t.Fatalf("Fragment's total data length is %d, not %d", total, len(payload))
+		}
+	}
+
+	b, err := ReassembleFragments(subFrags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pb, err := b.PayloadBlock(); err != nil {
+		t.Fatal(err)
+	} else if data := pb.Value.(*PayloadBlock).Data(); !bytes.Equal(data, payload) {
+		t.Fatal("Reassembled payload differs")
+	}
+}
+
 func TestIsBundleReassemblable(t *testing.T) {
 	bndl, err := Builder().
 		Source("dtn://src/").
@@ -224,3 +271,75 @@ func TestReassembleFragmentsMissing(t *testing.T) {
 		t.Fatalf("Expected error for missing fragment")
 	}
 }
+
+func TestReassembleFragmentsOverlapping(t *testing.T) {
+	payloadData := make([]byte, 1000)
+	rand.Seed(23)
+	_, _ = rand.Read(payloadData)
+
+	bndl, err := Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("5m").
+		PayloadBlock(payloadData).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fragment := func(start, end int) Bundle {
+		pb, _, err := fragmentPrimaryBlock(bndl.PrimaryBlock, start, len(payloadData))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		frag, err := fragmentBundle(bndl, pb, payloadData[start:end], start == 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return frag
+	}
+
+	tests := []struct {
+		name   string
+		ranges [][2]int
+		valid  bool
+	}{
+		{"contained", [][2]int{{0, 500}, {450, 470}, {460, 1000}}, true},
+		{"overlapping", [][2]int{{0, 600}, {400, 1000}}, true},
+		{"duplicate", [][2]int{{0, 500}, {0, 500}, {500, 1000}}, true},
+		{"enclosing", [][2]int{{200, 300}, {0, 1000}}, true},
+		{"covered by first", [][2]int{{0, 500}, {450, 470}, {480, 1000}}, true},
+		{"gap after contained", [][2]int{{0, 500}, {450, 470}, {501, 1000}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var frags []Bundle
+			for _, r := range test.ranges {
+				frags = append(frags, fragment(r[0], r[1]))
+			}
+
+			if IsBundleReassemblable(frags) != test.valid {
+				t.Fatalf("Fragments are reassemblable: %t", !test.valid)
+			}
+
+			bndl2, err := ReassembleFragments(frags)
+			if !test.valid {
+				if err == nil {
+					t.Fatal("Reassembling fragments with a gap did not error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if pb, err := bndl2.PayloadBlock(); err != nil {
+				t.Fatal(err)
+			} else if !bytes.Equal(pb.Value.(*PayloadBlock).Data(), payloadData) {
+				t.Fatal("Reassembled payload differs")
+			}
+		})
+	}
+}
@@ -302,7 +302,7 @@ func (pb PrimaryBlock) CheckValid() (errs error) {
 // against the current time.
 //
 // If the creation timestamp's time value is zero, this method will always
-// return false.
+// return false. Bundle.IsLifetimeExceeded also considers a Bundle Age Block.
 func (pb PrimaryBlock) IsLifetimeExceeded() bool {
 	if pb.CreationTimestamp.IsZeroTime() {
 		return false
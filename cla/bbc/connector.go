@@ -199,6 +199,21 @@ func (c *Connector) IsPermanent() bool {
 	return c.permanent
 }
 
+// MaxBundleSize limits outgoing bundles to the Modem's MTU, reduced by the Fragment's header and the compression's
+// overhead. Thus, larger bundles will be fragmented by the core and each bundle fragment fits into one Fragment of
+// this CLA. For a MTU too small for this overhead, zero is returned to not limit the bundle size.
+func (c *Connector) MaxBundleSize() int {
+	return maxBundleSize(c.modem.Mtu())
+}
+
+// maxBundleSize of a bundle to fit into one Fragment of an OutgoingTransmission for this MTU, or zero.
+func maxBundleSize(mtu int) int {
+	if size := mtu - fragmentIdentifierSize - transmissionCompressionOverhead; size > 0 {
+		return size
+	}
+	return 0
+}
+
 func (c *Connector) Send(bndl *bundle.Bundle) error {
 	var t, tErr = NewOutgoingTransmission(c.tid, *bndl, c.modem.Mtu())
 	if tErr != nil {
@@ -0,0 +1,20 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package bbc
+
+import (
+	"github.com/dtn7/dtn7-go/cla"
+)
+
+func init() {
+	// A Bundle Broadcasting Connector is not announced by the peer discovery.
+	_ = cla.RegisterListener("bbc", cla.BBC, func(conf cla.ConvergenceConfig) (cla.Convergable, uint, error) {
+		if conn, err := NewBundleBroadcastingConnector(conf.Endpoint, true); err != nil {
+			return nil, 0, err
+		} else {
+			return conn, 0, nil
+		}
+	})
+}
@@ -116,6 +116,10 @@ type OutgoingTransmission struct {
 	nextSegmentNo byte
 }
 
+// transmissionCompressionOverhead is the maximum size added by the xz container of an OutgoingTransmission's payload
+// for incompressible bundles, which fit into a single Fragment.
+const transmissionCompressionOverhead int = 64
+
 // NewOutgoingTransmission creates a new OutgoingTransmission for a Bundle.
 func NewOutgoingTransmission(transmissionID byte, bndl bundle.Bundle, mtu int) (t *OutgoingTransmission, err error) {
 	var buf bytes.Buffer
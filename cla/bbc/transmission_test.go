@@ -6,7 +6,10 @@ package bbc
 
 import (
 	"bytes"
+	"math/rand"
 	"testing"
+
+	"github.com/dtn7/dtn7-go/bundle"
 )
 
 func TestSuccessfulIncomingTransmission(t *testing.T) {
@@ -134,3 +137,45 @@ func TestTransmissionMissingFragment(t *testing.T) {
 		t.Fatalf("Reading skipped Fragment did not errored")
 	}
 }
+
+func TestMaxBundleSizeSingleFragment(t *testing.T) {
+	payload := make([]byte, 2048)
+	rand.Read(payload)
+
+	b, err := bundle.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("1h").
+		PayloadBlock(payload).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mtu := range []int{250, 512, 1500} {
+		frags, err := b.Fragment(maxBundleSize(mtu))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, frag := range frags {
+			tr, err := NewOutgoingTransmission(0, frag, mtu)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if f, finished, err := tr.WriteFragment(); err != nil {
+				t.Fatal(err)
+			} else if !finished {
+				t.Fatalf("Bundle fragment for MTU %d spans multiple Fragments", mtu)
+			} else if l := len(f.Bytes()); l > mtu {
+				t.Fatalf("Fragment of %d bytes exceeds MTU %d", l, mtu)
+			}
+		}
+	}
+
+	if size := maxBundleSize(fragmentIdentifierSize); size != 0 {
+		t.Fatalf("Too small MTU results in a maximum bundle size of %d", size)
+	}
+}
@@ -23,7 +23,11 @@
 // work seamlessly with the types above.
 package cla
 
-import "github.com/dtn7/dtn7-go/bundle"
+import (
+	"fmt"
+
+	"github.com/dtn7/dtn7-go/bundle"
+)
 
 // Convergable describes any kind of type which supports convergence layer-
 // related services. This can be both a more specified Convergence interface
@@ -82,6 +86,57 @@ type ConvergenceSender interface {
 	GetPeerEndpointID() bundle.EndpointID
 }
 
+// MaxBundleSizer is an optional interface for a ConvergenceSender, which is
+// limited in the size of its transmitted bundles, e.g., by the underlying
+// link's maximum transmission unit (MTU). Larger bundles will be fragmented
+// before being sent, if permitted.
+type MaxBundleSizer interface {
+	// MaxBundleSize returns the maximum size in bytes for a serialized bundle.
+	// A non-positive value indicates no limit.
+	MaxBundleSize() int
+}
+
+// ReceptionFilter decides if an incoming bundle of the given size in bytes
+// should be received, e.g., based on the available storage.
+type ReceptionFilter func(size uint64) bool
+
+// Accepts checks a bundle's size against this ReceptionFilter. A nil
+// ReceptionFilter accepts all bundles.
+func (filter ReceptionFilter) Accepts(size uint64) bool {
+	return filter == nil || filter(size)
+}
+
+// ReceptionFilterable is an optional interface for a ConvergenceReceiver,
+// which is able to refuse incoming bundles before or while receiving them.
+// The Manager passes its ReceptionFilter to each registered CLA before it is
+// started.
+type ReceptionFilterable interface {
+	// SetReceptionFilter sets the ReceptionFilter for incoming bundles.
+	SetReceptionFilter(filter ReceptionFilter)
+}
+
+// PartialTransmissionError might be returned by a ConvergenceSender's Send
+// method, if a bundle's transmission was interrupted after its peer has
+// acknowledged the first bytes of the serialized bundle. The remaining part
+// can be sent later as a reactive fragment.
+type PartialTransmissionError struct {
+	// Acknowledged is the amount of the serialized bundle's bytes which were
+	// acknowledged by the peer.
+	Acknowledged uint64
+
+	// Err is the cause of the interruption.
+	Err error
+}
+
+func (pte *PartialTransmissionError) Error() string {
+	return fmt.Sprintf("transmission was interrupted after %d acknowledged bytes: %v", pte.Acknowledged, pte.Err)
+}
+
+// Unwrap the cause of the interruption.
+func (pte *PartialTransmissionError) Unwrap() error {
+	return pte.Err
+}
+
 // ConvergenceProvider is a more general kind of CLA service which does not
 // transfer any Bundles by itself, but supplies/creates new Convergence types.
 // Those Convergence objects will be passed to a Manager. Thus, one might think
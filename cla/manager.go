@@ -36,6 +36,10 @@ type Manager struct {
 	providers      []ConvergenceProvider
 	providersMutex sync.Mutex
 
+	// receptionFilter is passed to each registered ReceptionFilterable CLA.
+	receptionFilter      ReceptionFilter
+	receptionFilterMutex sync.RWMutex
+
 	// inChnl receives ConvergenceStatus while outChnl passes it on. Both channels
 	// are not buffered. While this is not a problem for inChnl, outChnl must
 	// always be read, otherwise the Manager will block.
@@ -181,6 +185,14 @@ func (manager *Manager) Register(conv Convergable) {
 	}
 }
 
+// SetReceptionFilter sets a ReceptionFilter, passed to all ReceptionFilterable
+// CLAs. Thus, it should be set before registering CLAs.
+func (manager *Manager) SetReceptionFilter(filter ReceptionFilter) {
+	manager.receptionFilterMutex.Lock()
+	manager.receptionFilter = filter
+	manager.receptionFilterMutex.Unlock()
+}
+
 func (manager *Manager) registerConvergence(conv Convergence) {
 	// Check if this CLA is already known. Re-activate a deactivated CLA or abort.
 	var ce *convergenceElem
@@ -212,6 +224,12 @@ func (manager *Manager) registerConvergence(conv Convergence) {
 		}
 	}
 
+	if rf, ok := conv.(ReceptionFilterable); ok {
+		manager.receptionFilterMutex.RLock()
+		rf.SetReceptionFilter(manager.receptionFilter)
+		manager.receptionFilterMutex.RUnlock()
+	}
+
 	if successful, retry := ce.activate(); !successful && !retry {
 		log.WithFields(log.Fields{
 			"cla":     conv,
@@ -318,6 +336,37 @@ func (manager *Manager) Receiver() (crs []ConvergenceReceiver) {
 	return
 }
 
+// ConvergenceState describes a Convergence supervised by a Manager.
+type ConvergenceState struct {
+	Convergence Convergence
+
+	// Active is true for a started Convergence. Otherwise, the Manager will try
+	// to start it again for the remaining Retries.
+	Active  bool
+	Retries int
+}
+
+// States returns the state of all supervised Convergences.
+func (manager *Manager) States() (states []ConvergenceState) {
+	manager.convs.Range(func(_, convElem interface{}) bool {
+		ce := convElem.(*convergenceElem)
+
+		ce.mutex.Lock()
+		state := ConvergenceState{
+			Convergence: ce.conv,
+			Active:      ce.ttl < 0,
+		}
+		if !state.Active {
+			state.Retries = ce.ttl
+		}
+		ce.mutex.Unlock()
+
+		states = append(states, state)
+		return true
+	})
+	return
+}
+
 func (manager *Manager) RegisterEndpointID(claType CLAType, eid bundle.EndpointID) {
 	clas, ok := manager.listenerIDs[claType]
 
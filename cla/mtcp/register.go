@@ -0,0 +1,24 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package mtcp
+
+import (
+	"github.com/dtn7/dtn7-go/cla"
+)
+
+func init() {
+	_ = cla.RegisterListener("mtcp", cla.MTCP, func(conf cla.ConvergenceConfig) (cla.Convergable, uint, error) {
+		port, err := cla.ListenPort(conf.Endpoint)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return NewMTCPServer(conf.Endpoint, conf.NodeId, true), port, nil
+	})
+
+	_ = cla.RegisterPeer("mtcp", func(conf cla.ConvergenceConfig) (cla.ConvergenceSender, error) {
+		return NewMTCPClient(conf.Endpoint, conf.PeerId, true), nil
+	})
+}
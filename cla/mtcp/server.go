@@ -7,6 +7,8 @@ package mtcp
 import (
 	"bufio"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"time"
 
@@ -26,6 +28,8 @@ type MTCPServer struct {
 	endpointID    bundle.EndpointID
 	permanent     bool
 
+	receptionFilter cla.ReceptionFilter
+
 	stopSyn chan struct{}
 	stopAck chan struct{}
 }
@@ -117,6 +121,23 @@ func (serv *MTCPServer) handleSender(conn net.Conn) {
 			return
 		} else if n == 0 {
 			continue
+		} else if !serv.receptionFilter.Accepts(n) {
+			log.WithFields(log.Fields{
+				"cla":  serv,
+				"conn": conn,
+				"size": n,
+			}).Info("MTCP handleServer connection refused a bundle, discarding its data")
+
+			if _, err := io.CopyN(ioutil.Discard, connReader, int64(n)); err != nil {
+				log.WithFields(log.Fields{
+					"cla":   serv,
+					"conn":  conn,
+					"error": err,
+				}).Warn("MTCP handleServer connection failed to discard refused bundle")
+
+				return
+			}
+			continue
 		}
 
 		bndl := new(bundle.Bundle)
@@ -139,6 +160,11 @@ func (serv *MTCPServer) handleSender(conn net.Conn) {
 	}
 }
 
+// SetReceptionFilter sets a filter to refuse incoming bundles based on their size.
+func (serv *MTCPServer) SetReceptionFilter(filter cla.ReceptionFilter) {
+	serv.receptionFilter = filter
+}
+
 func (serv *MTCPServer) Channel() chan cla.ConvergenceStatus {
 	return serv.reportChan
 }
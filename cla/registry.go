@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cla
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/dtn7/dtn7-go/bundle"
+)
+
+// ConvergenceConfig describes a configured listener or peer, e.g., by dtnd's "listen" and "peer" blocks.
+type ConvergenceConfig struct {
+	// NodeId is this node's ID or, for a listener, its own configured node ID.
+	NodeId bundle.EndpointID
+
+	// PeerId is a peer's configured node ID. It is only set for peers.
+	PeerId bundle.EndpointID
+
+	// Endpoint is the address to listen on or to connect to, e.g., "localhost:4556".
+	Endpoint string
+
+	// Options might be used to configure registered third-party CLAs.
+	Options map[string]interface{}
+}
+
+// ListenerFactory creates a listening Convergable from its configuration. A non-zero discovery port announces this
+// listener by the peer discovery.
+type ListenerFactory func(conf ConvergenceConfig) (conv Convergable, discoveryPort uint, err error)
+
+// PeerFactory creates a ConvergenceSender to a configured peer.
+type PeerFactory func(conf ConvergenceConfig) (ConvergenceSender, error)
+
+// listenerEntry is a registered ListenerFactory together with its CLAType.
+type listenerEntry struct {
+	claType CLAType
+	factory ListenerFactory
+}
+
+var (
+	listenerFactories = make(map[string]listenerEntry)
+	peerFactories     = make(map[string]PeerFactory)
+	factoriesMutex    sync.RWMutex
+)
+
+// RegisterListener makes a listener available by its protocol name, e.g., "tcpcl". This might be used to register
+// third-party CLAs within an init function.
+func RegisterListener(protocol string, claType CLAType, factory ListenerFactory) error {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+
+	if _, exists := listenerFactories[protocol]; exists {
+		return fmt.Errorf("listener %s is already registered", protocol)
+	}
+
+	listenerFactories[protocol] = listenerEntry{claType: claType, factory: factory}
+	return nil
+}
+
+// NewListener creates a listening Convergable for a protocol, registered by RegisterListener.
+func NewListener(protocol string, conf ConvergenceConfig) (conv Convergable, claType CLAType, discoveryPort uint, err error) {
+	factoriesMutex.RLock()
+	entry, ok := listenerFactories[protocol]
+	factoriesMutex.RUnlock()
+
+	if !ok {
+		err = fmt.Errorf("unknown listen.protocol \"%s\"", protocol)
+		return
+	}
+
+	claType = entry.claType
+	conv, discoveryPort, err = entry.factory(conf)
+	return
+}
+
+// RegisterPeer makes a peer available by its protocol name, e.g., "tcpcl". This might be used to register
+// third-party CLAs within an init function.
+func RegisterPeer(protocol string, factory PeerFactory) error {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+
+	if _, exists := peerFactories[protocol]; exists {
+		return fmt.Errorf("peer %s is already registered", protocol)
+	}
+
+	peerFactories[protocol] = factory
+	return nil
+}
+
+// NewPeer creates a ConvergenceSender for a protocol, registered by RegisterPeer.
+func NewPeer(protocol string, conf ConvergenceConfig) (ConvergenceSender, error) {
+	factoriesMutex.RLock()
+	factory, ok := peerFactories[protocol]
+	factoriesMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown peer.protocol \"%s\"", protocol)
+	}
+	return factory(conf)
+}
+
+// ListenPort extracts the port of a listening endpoint, e.g., 4556 for ":4556".
+func ListenPort(endpoint string) (uint, error) {
+	_, portStr, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return 0, err
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	return uint(port), err
+}
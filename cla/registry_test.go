@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cla
+
+import (
+	"testing"
+
+	"github.com/dtn7/dtn7-go/bundle"
+)
+
+func TestRegisterListener(t *testing.T) {
+	const claType CLAType = 100
+
+	factory := func(conf ConvergenceConfig) (Convergable, uint, error) {
+		port, err := ListenPort(conf.Endpoint)
+		if err != nil {
+			return nil, 0, err
+		}
+		return newMockConvRec(true, conf.Endpoint, conf.NodeId), port, nil
+	}
+
+	// Ignore the error of an already registered listener for repeated test runs.
+	_ = RegisterListener("test-mock", claType, factory)
+
+	if err := RegisterListener("test-mock", claType, factory); err == nil {
+		t.Fatal("Registered listener was replaced")
+	}
+
+	conf := ConvergenceConfig{NodeId: bundle.MustNewEndpointID("dtn://node/"), Endpoint: ":4556"}
+	conv, convType, port, err := NewListener("test-mock", conf)
+	if err != nil {
+		t.Fatal(err)
+	} else if convType != claType || port != 4556 {
+		t.Fatalf("Listener has CLA type %d and port %d", convType, port)
+	} else if convRec, ok := conv.(*mockConvRec); !ok || convRec.endpointId != conf.NodeId {
+		t.Fatalf("Listener is %v", conv)
+	}
+
+	if _, _, _, err := NewListener("test-mock", ConvergenceConfig{Endpoint: "no port"}); err == nil {
+		t.Fatal("Invalid endpoint did not error")
+	}
+
+	if _, _, _, err := NewListener("unknown", conf); err == nil {
+		t.Fatal("Unknown listener did not error")
+	}
+}
+
+func TestRegisterPeer(t *testing.T) {
+	factory := func(conf ConvergenceConfig) (ConvergenceSender, error) {
+		return newMockConvSender(true, conf.Endpoint, conf.PeerId), nil
+	}
+
+	// Ignore the error of an already registered peer for repeated test runs.
+	_ = RegisterPeer("test-mock", factory)
+
+	if err := RegisterPeer("test-mock", factory); err == nil {
+		t.Fatal("Registered peer was replaced")
+	}
+
+	conf := ConvergenceConfig{PeerId: bundle.MustNewEndpointID("dtn://peer/"), Endpoint: "peer:4556"}
+	if cs, err := NewPeer("test-mock", conf); err != nil {
+		t.Fatal(err)
+	} else if cs.GetPeerEndpointID() != conf.PeerId {
+		t.Fatalf("Peer has endpoint ID %v", cs.GetPeerEndpointID())
+	}
+
+	if _, err := NewPeer("unknown", conf); err == nil {
+		t.Fatal("Unknown peer did not error")
+	}
+}
@@ -76,6 +76,12 @@ type Client struct {
 
 	transferIn *IncomingTransfer
 
+	// receptionFilter might refuse incoming transfers; the last refused Transfer ID is kept to ignore its remaining
+	// XFER_SEGMENTs.
+	receptionFilter     cla.ReceptionFilter
+	transferInRefused   bool
+	transferInRefusedId uint64
+
 	reportChan chan cla.ConvergenceStatus
 }
 
@@ -200,6 +206,11 @@ func (client *Client) Channel() chan cla.ConvergenceStatus {
 	return client.reportChan
 }
 
+// SetReceptionFilter sets a filter to refuse incoming transfers based on their received size.
+func (client *Client) SetReceptionFilter(filter cla.ReceptionFilter) {
+	client.receptionFilter = filter
+}
+
 func (client *Client) Address() string {
 	return client.address
 }
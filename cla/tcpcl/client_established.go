@@ -67,9 +67,13 @@ func (client *Client) handleEstablished() (err error) {
 				client.log().WithField("msg", dtm).Warn(
 					"Received XFER_SEGMENT with START flag, but has old transfer; resetting")
 
+				client.reportTransferInFragment()
 				client.transferIn = NewIncomingTransfer(dtm.TransferId)
 			} else if client.transferIn == nil {
-				if dtm.Flags&SegmentStart == 0 {
+				if dtm.Flags&SegmentStart == 0 && client.transferInRefused && dtm.TransferId == client.transferInRefusedId {
+					client.log().WithField("msg", dtm).Debug("Ignoring XFER_SEGMENT of a refused transfer")
+					break
+				} else if dtm.Flags&SegmentStart == 0 {
 					client.log().WithField("msg", dtm).Warn(
 						"Received XFER_SEGMENT without a START flag, but no transfer state")
 
@@ -89,8 +93,22 @@ func (client *Client) handleEstablished() (err error) {
 
 					ackMsg := NewTransferRefusalMessage(RefusalUnknown, dtm.TransferId)
 					client.msgsOut <- &ackMsg
+				} else if !client.receptionFilter.Accepts(dam.AckLen) {
+					client.log().WithFields(log.Fields{
+						"transfer": client.transferIn,
+						"size":     dam.AckLen,
+					}).Info("Refusing incoming transfer, exceeding the available resources")
+
+					refuseMsg := NewTransferRefusalMessage(RefusalNoResources, dtm.TransferId)
+					client.msgsOut <- &refuseMsg
+
+					client.transferIn = nil
+					client.transferInRefused = true
+					client.transferInRefusedId = dtm.TransferId
+					break
 				} else {
 					client.msgsOut <- &dam
+					client.transferIn.Acknowledge(dam)
 					client.log().WithField("msg", dam).Debug("Sent XFER_ACK")
 				}
 
@@ -144,6 +162,29 @@ func (client *Client) handleEstablished() (err error) {
 	return nil
 }
 
+// reportTransferInFragment reports the received part of an unfinished incoming transfer as a reactive fragment.
+func (client *Client) reportTransferInFragment() {
+	if client.transferIn == nil || client.transferIn.IsFinished() {
+		return
+	}
+
+	if bndl, err := client.transferIn.ToFragment(); err != nil {
+		client.log().WithError(err).WithField("transfer", client.transferIn).Debug(
+			"Creating a reactive fragment of the unfinished transfer failed")
+	} else {
+		client.log().WithFields(log.Fields{
+			"transfer": client.transferIn,
+			"bundle":   bndl,
+		}).Info("Received reactive fragment of unfinished transfer")
+
+		client.reportChan <- cla.NewConvergenceReceivedBundle(client, client.endpointID, &bndl)
+	}
+
+	client.transferIn = nil
+}
+
+// Send transmits a Bundle to the peer. If the session terminates during the transfer after some bytes have been
+// acknowledged, a cla.PartialTransmissionError will be returned.
 func (client *Client) Send(bndl *bundle.Bundle) error {
 	client.transferOutMutex.Lock()
 	defer client.transferOutMutex.Unlock()
@@ -152,6 +193,19 @@ func (client *Client) Send(bndl *bundle.Bundle) error {
 		return fmt.Errorf("Client is not in an established state")
 	}
 
+	var (
+		stateStopAck = client.handlerStateStopAck
+		acknowledged uint64
+	)
+
+	// interrupted wraps an error for an interrupted transfer, including the acknowledged bytes.
+	interrupted := func(err error) error {
+		if acknowledged == 0 {
+			return err
+		}
+		return &cla.PartialTransmissionError{Acknowledged: acknowledged, Err: err}
+	}
+
 	client.transferOutId += 1
 	var t = NewBundleOutgoingTransfer(client.transferOutId, *bndl)
 
@@ -172,10 +226,24 @@ func (client *Client) Send(bndl *bundle.Bundle) error {
 			return err
 		}
 
-		client.transferOutSend <- &dtm
-		tlog.WithField("msg", dtm).Debug("Send disposed XFER_SEGMENT")
+		select {
+		case client.transferOutSend <- &dtm:
+			tlog.WithField("msg", dtm).Debug("Send disposed XFER_SEGMENT")
+
+		case <-stateStopAck:
+			tlog.WithField("acknowledged", acknowledged).Warn("Session terminated during transfer")
+			return interrupted(fmt.Errorf("Session terminated during transfer"))
+		}
+
+		var ackMsg Message
+		select {
+		case ackMsg = <-client.transferOutAck:
+
+		case <-stateStopAck:
+			tlog.WithField("acknowledged", acknowledged).Warn("Session terminated during transfer")
+			return interrupted(fmt.Errorf("Session terminated during transfer"))
+		}
 
-		ackMsg := <-client.transferOutAck
 		switch ackMsg := ackMsg.(type) {
 		case *DataAcknowledgementMessage:
 			tlog.WithField("msg", ackMsg).Debug("Received XFER_ACK")
@@ -184,6 +252,7 @@ func (client *Client) Send(bndl *bundle.Bundle) error {
 				tlog.WithField("msg", ackMsg).Warn("XFER_ACK does not match XFER_SEGMENT")
 				return fmt.Errorf("XFER_ACK does not match XFER_SEGMENT")
 			}
+			acknowledged = ackMsg.AckLen
 
 		case *TransferRefusalMessage:
 			tlog.WithField("msg", ackMsg).Warn("Received XFER_REFUSE, aborting transfer")
@@ -160,6 +160,8 @@ func (client *Client) handleState() {
 			default:
 				client.log().Info("Entering Termination state")
 
+				client.reportTransferInFragment()
+
 				var sessTerm = NewSessionTerminationMessage(0, TerminationUnknown)
 				client.msgsOut <- &sessTerm
 
@@ -0,0 +1,24 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package tcpcl
+
+import (
+	"github.com/dtn7/dtn7-go/cla"
+)
+
+func init() {
+	_ = cla.RegisterListener("tcpcl", cla.TCPCL, func(conf cla.ConvergenceConfig) (cla.Convergable, uint, error) {
+		port, err := cla.ListenPort(conf.Endpoint)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return NewListener(conf.Endpoint, conf.NodeId), port, nil
+	})
+
+	_ = cla.RegisterPeer("tcpcl", func(conf cla.ConvergenceConfig) (cla.ConvergenceSender, error) {
+		return DialClient(conf.Endpoint, conf.NodeId, true), nil
+	})
+}
@@ -18,6 +18,9 @@ type IncomingTransfer struct {
 
 	endFlag bool
 	buf     *bytes.Buffer
+
+	// acked is the length of the received data which was acknowledged by the last sent XFER_ACK.
+	acked uint64
 }
 
 // NewIncomingTransfer creates a new IncomingTransfer for the given Transfer ID.
@@ -65,6 +68,14 @@ func (t *IncomingTransfer) NextSegment(dtm DataTransmissionMessage) (dam DataAck
 	return
 }
 
+// Acknowledge marks the XFER_ACK for this Transfer's last segment as sent. Only acknowledged data is used for a reactive
+// fragment, matching the remainder created by the sender.
+func (t *IncomingTransfer) Acknowledge(dam DataAcknowledgementMessage) {
+	if dam.TransferId == t.Id && dam.AckLen <= uint64(t.buf.Len()) {
+		t.acked = dam.AckLen
+	}
+}
+
 // ToBundle returns the Bundle for a finished Transfer.
 func (t *IncomingTransfer) ToBundle() (bndl bundle.Bundle, err error) {
 	if !t.IsFinished() {
@@ -75,3 +86,15 @@ func (t *IncomingTransfer) ToBundle() (bndl bundle.Bundle, err error) {
 	err = bndl.UnmarshalCbor(t.buf)
 	return
 }
+
+// ToFragment returns a fragment for an unfinished Transfer, based on the already received and acknowledged data. This
+// allows a reactive fragmentation of interrupted Transfers. The sender creates the remaining fragment starting at the
+// same acknowledged length, compare bundle.Bundle.ReactiveFragmentRemainder.
+func (t *IncomingTransfer) ToFragment() (bndl bundle.Bundle, err error) {
+	if t.IsFinished() {
+		err = fmt.Errorf("Transfer has already been finished")
+		return
+	}
+
+	return bundle.ReactiveFragment(t.buf.Bytes()[:t.acked])
+}
@@ -68,3 +68,63 @@ func TestTransfer(t *testing.T) {
 		})
 	}
 }
+
+func TestTransferInterrupted(t *testing.T) {
+	payload := testGetRandomData(16384)
+
+	bndlOut, err := bundle.Builder().
+		CRC(bundle.CRC32).
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("30m").
+		HopCountBlock(64).
+		PayloadBlock(payload).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := NewBundleOutgoingTransfer(42, bndlOut)
+	in := NewIncomingTransfer(42)
+
+	// The last segment is received, but its XFER_ACK is not sent before the interruption.
+	var acknowledged uint64
+	for i := 0; i < 5; i++ {
+		if dtm, err := out.NextSegment(1400); err != nil {
+			t.Fatal(err)
+		} else if dam, err := in.NextSegment(dtm); err != nil {
+			t.Fatal(err)
+		} else if i < 4 {
+			in.Acknowledge(dam)
+			acknowledged = dam.AckLen
+		}
+	}
+
+	head, err := in.ToFragment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tail, remains, err := bndlOut.ReactiveFragmentRemainder(int(acknowledged))
+	if err != nil {
+		t.Fatal(err)
+	} else if !remains {
+		t.Fatal("No fragment remains")
+	}
+
+	// Both fragments are cut at the same acknowledged boundary.
+	if pb, err := head.PayloadBlock(); err != nil {
+		t.Fatal(err)
+	} else if headEnd := head.PrimaryBlock.FragmentOffset + uint64(len(pb.Value.(*bundle.PayloadBlock).Data())); headEnd != tail.PrimaryBlock.FragmentOffset {
+		t.Fatalf("Received fragment ends at %d, remaining fragment starts at %d", headEnd, tail.PrimaryBlock.FragmentOffset)
+	}
+
+	if bndlIn, err := bundle.ReassembleFragments([]bundle.Bundle{head, tail}); err != nil {
+		t.Fatal(err)
+	} else if pb, err := bndlIn.PayloadBlock(); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(pb.Value.(*bundle.PayloadBlock).Data(), payload) {
+		t.Fatal("Reassembled payload differs")
+	}
+}
@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// adminTokenEnv is the environment variable for the management API's token.
+const adminTokenEnv = "DTN_MANAGEMENT_TOKEN"
+
+// adminRequest to dtnd's management API, returning the response's body.
+func adminRequest(method, api, path string, query url.Values) []byte {
+	token := os.Getenv(adminTokenEnv)
+	if token == "" {
+		printFatal(fmt.Errorf("%s is not set", adminTokenEnv), "Reading the management token errored")
+	}
+
+	reqUrl := strings.TrimSuffix(api, "/") + path
+	if len(query) > 0 {
+		reqUrl += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, reqUrl, nil)
+	if err != nil {
+		printFatal(err, "Creating the request errored")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		printFatal(err, "Requesting the management API errored")
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		printFatal(err, "Reading the response errored")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errMsg struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &errMsg) != nil || errMsg.Error == "" {
+			errMsg.Error = resp.Status
+		}
+		printFatal(fmt.Errorf("%s", errMsg.Error), "Management API request failed")
+	}
+
+	return body
+}
+
+// startAdmin for the "admin" CLI options.
+func startAdmin(args []string) {
+	if len(args) < 2 {
+		printUsage()
+	}
+
+	var (
+		api     = args[0]
+		command = args[1]
+		cmdArgs = args[2:]
+
+		body []byte
+	)
+
+	switch command {
+	case "bundles":
+		query := url.Values{}
+		for _, filter := range cmdArgs {
+			kv := strings.SplitN(filter, "=", 2)
+			if len(kv) != 2 {
+				printUsage()
+			}
+			query.Set(kv[0], kv[1])
+		}
+		body = adminRequest(http.MethodGet, api, "/bundles", query)
+
+	case "bundle", "delete", "pending":
+		if len(cmdArgs) != 1 {
+			printUsage()
+		}
+		query := url.Values{"id": []string{cmdArgs[0]}}
+
+		switch command {
+		case "bundle":
+			body = adminRequest(http.MethodGet, api, "/bundle", query)
+		case "delete":
+			body = adminRequest(http.MethodDelete, api, "/bundle", query)
+		case "pending":
+			body = adminRequest(http.MethodPost, api, "/pending", query)
+		}
+
+	case "peers", "clas":
+		if len(cmdArgs) != 0 {
+			printUsage()
+		}
+		body = adminRequest(http.MethodGet, api, "/"+command, nil)
+
+	default:
+		printUsage()
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, body, "", "  "); err != nil {
+		printFatal(err, "Formatting the response errored")
+	}
+	fmt.Print(out.String())
+}
@@ -0,0 +1,92 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dtn7/dtn7-go/agent"
+	"github.com/dtn7/dtn7-go/bundle"
+)
+
+// eventTypes maps the event names of the command line to their types.
+var eventTypes = map[string]agent.BundleEventType{
+	agent.BundleReceived.String():        agent.BundleReceived,
+	agent.BundleForwarded.String():       agent.BundleForwarded,
+	agent.BundleDelivered.String():       agent.BundleDelivered,
+	agent.BundleContraindicated.String(): agent.BundleContraindicated,
+	agent.BundleDeleted.String():         agent.BundleDeleted,
+}
+
+// printedEvent is the JSON representation of a printed BundleEvent.
+type printedEvent struct {
+	Time        time.Time `json:"time"`
+	Type        string    `json:"type"`
+	Bundle      string    `json:"bundle"`
+	Destination string    `json:"destination"`
+	Peer        string    `json:"peer,omitempty"`
+	Cla         string    `json:"cla,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+}
+
+// startEvents prints the bundle events of a dtnd as JSON lines, optionally filtered by their types.
+func startEvents(args []string) {
+	if len(args) < 2 {
+		printUsage()
+	}
+
+	var (
+		websocketAddr = args[0]
+		endpointId    = args[1]
+		filter        agent.BundleEventFilter
+	)
+
+	for _, name := range args[2:] {
+		if eventType, ok := eventTypes[name]; !ok {
+			printFatal(fmt.Errorf("unknown event %s", name), "Parsing event filter errored")
+		} else {
+			filter.Types = append(filter.Types, eventType)
+		}
+	}
+
+	wac, err := agent.NewWebSocketAgentConnector(websocketAddr, endpointId)
+	if err != nil {
+		printFatal(err, "Starting WebSocketAgentConnector errored")
+	}
+	defer wac.Close()
+
+	if err := wac.SubscribeEvents(filter, 5*time.Second); err != nil {
+		printFatal(err, "Subscribing events errored")
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for {
+		event, err := wac.ReadEvent()
+		if err != nil {
+			printFatal(err, "Reading event errored")
+		}
+
+		pe := printedEvent{
+			Time:        event.Time,
+			Type:        event.Type.String(),
+			Bundle:      event.Bundle.String(),
+			Destination: event.Destination.String(),
+			Cla:         event.Cla,
+		}
+		if event.Peer != (bundle.EndpointID{}) {
+			pe.Peer = event.Peer.String()
+		}
+		if event.Type == agent.BundleDeleted {
+			pe.Reason = event.Reason.String()
+		}
+
+		if err := enc.Encode(pe); err != nil {
+			printFatal(err, "Printing event errored")
+		}
+	}
+}
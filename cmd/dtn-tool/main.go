@@ -11,7 +11,7 @@ import (
 
 // printUsage of dtn-tool and exit with an error code afterwards.
 func printUsage() {
-	_, _ = fmt.Fprintf(os.Stderr, "Usage of %s create|show|exchange:\n\n", os.Args[0])
+	_, _ = fmt.Fprintf(os.Stderr, "Usage of %s create|show|exchange|events|admin:\n\n", os.Args[0])
 
 	_, _ = fmt.Fprintf(os.Stderr, "%s create sender receiver -|filename [-|filename]\n", os.Args[0])
 	_, _ = fmt.Fprintf(os.Stderr, "  Creates a new Bundle, addressed from sender to receiver with the stdin (-)\n")
@@ -28,6 +28,24 @@ func printUsage() {
 	_, _ = fmt.Fprintf(os.Stderr, "  incoming Bundles in the directory. If the user dropps a new Bundle in the\n")
 	_, _ = fmt.Fprintf(os.Stderr, "  directory, it will be sent to the server.\n\n")
 
+	_, _ = fmt.Fprintf(os.Stderr, "%s events websocket endpoint-id [event...]\n", os.Args[0])
+	_, _ = fmt.Fprintf(os.Stderr, "  Registers as an agent on the given websocket and prints the bundle events\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  of dtnd as JSON lines. The events might be filtered by their types:\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  received, forwarded, delivered, contraindicated, or deleted.\n\n")
+
+	_, _ = fmt.Fprintf(os.Stderr, "%s admin url bundles|bundle|delete|pending|peers|clas [args]\n", os.Args[0])
+	_, _ = fmt.Fprintf(os.Stderr, "  Queries dtnd's management API at the url, e.g.,\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  http://localhost:8080/management, authenticated by the token from the\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  %s environment variable.\n", adminTokenEnv)
+	_, _ = fmt.Fprintf(os.Stderr, "  bundles [key=value...]  lists bundles, filtered by id, source,\n")
+	_, _ = fmt.Fprintf(os.Stderr, "                          destination, or constraint and paginated by\n")
+	_, _ = fmt.Fprintf(os.Stderr, "                          offset and limit\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  bundle id               shows a bundle's metadata and properties\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  delete id               deletes a bundle\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  pending id              forces a bundle back to forwarding pending\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  peers                   lists the current peers\n")
+	_, _ = fmt.Fprintf(os.Stderr, "  clas                    lists all CLAs with their state\n\n")
+
 	os.Exit(1)
 }
 
@@ -52,6 +70,12 @@ func main() {
 	case "exchange":
 		startExchange(os.Args[2:])
 
+	case "events":
+		startEvents(os.Args[2:])
+
+	case "admin":
+		startAdmin(os.Args[2:])
+
 	default:
 		printUsage()
 	}
@@ -9,9 +9,8 @@ import (
 	"crypto/ed25519"
 	"encoding/hex"
 	"fmt"
-	"net"
 	"net/http"
-	"strconv"
+	"path/filepath"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -21,11 +20,10 @@ import (
 	"github.com/dtn7/dtn7-go/agent"
 	"github.com/dtn7/dtn7-go/bundle"
 	"github.com/dtn7/dtn7-go/cla"
-	"github.com/dtn7/dtn7-go/cla/bbc"
-	"github.com/dtn7/dtn7-go/cla/mtcp"
-	"github.com/dtn7/dtn7-go/cla/tcpcl"
 	"github.com/dtn7/dtn7-go/core"
 	"github.com/dtn7/dtn7-go/discovery"
+	"github.com/dtn7/dtn7-go/metrics"
+	"github.com/dtn7/dtn7-go/storage"
 )
 
 // tomlConfig describes the TOML-configuration.
@@ -37,14 +35,20 @@ type tomlConfig struct {
 	Listen    []convergenceConf
 	Peer      []convergenceConf
 	Routing   core.RoutingConf
+	Metrics   metricsConf
 }
 
 // coreConf describes the Core-configuration block.
 type coreConf struct {
 	Store             string
+	StoreBackend      string `toml:"store-backend"`
 	InspectAllBundles bool   `toml:"inspect-all-bundles"`
 	NodeId            string `toml:"node-id"`
 	SignPriv          string `toml:"signature-private"`
+	Integrity         *core.IntegrityConf
+	Confidentiality   []core.ConfidentialityConf
+	Trust             *core.TrustConf
+	Quota             *core.StorageQuotaConf
 }
 
 // logConf describes the Logging-configuration block.
@@ -68,9 +72,21 @@ type agentsConfig struct {
 
 // agentsWebserverConfig describes the nested "Webserver" configuration for agents.
 type agentsWebserverConfig struct {
-	Address   string
-	Websocket bool
-	Rest      bool
+	Address    string
+	Websocket  bool
+	Rest       bool
+	Management agentsManagementConfig
+}
+
+// agentsManagementConfig describes the nested "Management" configuration for the webserver's management API.
+type agentsManagementConfig struct {
+	Token string
+}
+
+// metricsConf describes the Metrics-configuration block.
+type metricsConf struct {
+	Address string
+	Path    string
 }
 
 // convergenceConf describes the Convergence-configuration block, used for
@@ -79,19 +95,11 @@ type convergenceConf struct {
 	Node     string
 	Protocol string
 	Endpoint string
+	Options  map[string]interface{}
 }
 
-func parseListenPort(endpoint string) (port int, err error) {
-	var portStr string
-	_, portStr, err = net.SplitHostPort(endpoint)
-	if err != nil {
-		return
-	}
-	port, err = strconv.Atoi(portStr)
-	return
-}
-
-// parseListen inspects a "listen" convergenceConf and returns a Convergable.
+// parseListen inspects a "listen" convergenceConf and returns a Convergable, created by the CLA registered for its
+// protocol, compare cla.RegisterListener.
 func parseListen(conv convergenceConf, nodeId bundle.EndpointID) (cla.Convergable, bundle.EndpointID, cla.CLAType, discovery.DiscoveryMessage, error) {
 	log.WithFields(log.Fields{
 		"EndpointID": conv.Node,
@@ -112,69 +120,70 @@ func parseListen(conv convergenceConf, nodeId bundle.EndpointID) (cla.Convergabl
 		}
 	}
 
-	switch conv.Protocol {
-	case "bbc":
-		conn, err := bbc.NewBundleBroadcastingConnector(conv.Endpoint, true)
-		return conn, nodeId, cla.BBC, discovery.DiscoveryMessage{}, err
-
-	case "mtcp":
-		portInt, err := parseListenPort(conv.Endpoint)
-		if err != nil {
-			return nil, nodeId, cla.MTCP, discovery.DiscoveryMessage{}, err
-		}
-
-		msg := discovery.DiscoveryMessage{
-			Type:     cla.MTCP,
-			Endpoint: nodeId,
-			Port:     uint(portInt),
-		}
-
-		return mtcp.NewMTCPServer(conv.Endpoint, nodeId, true), nodeId, cla.MTCP, msg, nil
-
-	case "tcpcl":
-		portInt, err := parseListenPort(conv.Endpoint)
-		if err != nil {
-			return nil, nodeId, cla.TCPCL, discovery.DiscoveryMessage{}, err
-		}
-
-		listener := tcpcl.NewListener(conv.Endpoint, nodeId)
+	convRec, claType, port, err := cla.NewListener(conv.Protocol, cla.ConvergenceConfig{
+		NodeId:   nodeId,
+		Endpoint: conv.Endpoint,
+		Options:  conv.Options,
+	})
+	if err != nil {
+		return nil, nodeId, claType, discovery.DiscoveryMessage{}, err
+	}
 
-		msg := discovery.DiscoveryMessage{
-			Type:     cla.TCPCL,
+	var msg discovery.DiscoveryMessage
+	if port != 0 {
+		msg = discovery.DiscoveryMessage{
+			Type:     claType,
 			Endpoint: nodeId,
-			Port:     uint(portInt),
+			Port:     port,
 		}
-
-		return listener, nodeId, cla.TCPCL, msg, nil
-
-	default:
-		return nil, nodeId, 0, discovery.DiscoveryMessage{}, fmt.Errorf("unknown listen.protocol \"%s\"", conv.Protocol)
 	}
+
+	return convRec, nodeId, claType, msg, nil
 }
 
+// parsePeer inspects a "peer" convergenceConf and returns a ConvergenceSender, created by the CLA registered for its
+// protocol, compare cla.RegisterPeer.
 func parsePeer(conv convergenceConf, nodeId bundle.EndpointID) (cla.ConvergenceSender, error) {
 	endpointID, err := bundle.NewEndpointID(conv.Node)
 	if err != nil {
 		return nil, err
 	}
 
-	switch conv.Protocol {
-	case "mtcp":
-		return mtcp.NewMTCPClient(conv.Endpoint, endpointID, true), nil
+	return cla.NewPeer(conv.Protocol, cla.ConvergenceConfig{
+		NodeId:   nodeId,
+		PeerId:   endpointID,
+		Endpoint: conv.Endpoint,
+		Options:  conv.Options,
+	})
+}
 
-	case "tcpcl":
-		return tcpcl.DialClient(conv.Endpoint, nodeId, true), nil
+// parseStore opens the Core's store based on the selected backend. The default "badger" backend persists bundles in
+// the store directory, while the "memory" backend loses all bundles when dtnd stops.
+func parseStore(conf coreConf) (storage.Store, error) {
+	switch conf.StoreBackend {
+	case "", "badger":
+		if conf.Store == "" {
+			return nil, fmt.Errorf("core.store is empty")
+		} else if store, err := storage.NewStore(conf.Store); err != nil {
+			return nil, err
+		} else {
+			return store, nil
+		}
+
+	case "memory":
+		return storage.NewMemoryStore(), nil
 
 	default:
-		return nil, fmt.Errorf("unknown peer.protocol \"%s\"", conv.Protocol)
+		return nil, fmt.Errorf("unknown core.store-backend %s", conf.StoreBackend)
 	}
 }
 
-// parseAgents for the ApplicationAgents.
-func parseAgents(conf agentsConfig) (agents []agent.ApplicationAgent, err error) {
+// parseAgents for the ApplicationAgents. The REST agent persists its clients within the store directory, if one is
+// configured. A configured management token enables the Core's management API.
+func parseAgents(conf agentsConfig, c *core.Core, store string) (agents []agent.ApplicationAgent, err error) {
 	if (conf.Webserver != agentsWebserverConfig{}) {
-		if !conf.Webserver.Websocket && !conf.Webserver.Rest {
-			err = fmt.Errorf("webserver agent needs at least one of Websocket or REST")
+		if !conf.Webserver.Websocket && !conf.Webserver.Rest && conf.Webserver.Management.Token == "" {
+			err = fmt.Errorf("webserver agent needs at least one of Websocket, REST, or a management token")
 			return
 		}
 
@@ -189,9 +198,22 @@ func parseAgents(conf agentsConfig) (agents []agent.ApplicationAgent, err error)
 
 		if conf.Webserver.Rest {
 			restRouter := r.PathPrefix("/rest").Subrouter()
-			ra := agent.NewRestAgent(restRouter)
+			if store == "" {
+				agents = append(agents, agent.NewRestAgent(restRouter))
+			} else if ra, raErr := agent.NewPersistentRestAgent(restRouter, filepath.Join(store, "rest")); raErr != nil {
+				err = raErr
+				return
+			} else {
+				agents = append(agents, ra)
+			}
+		}
 
-			agents = append(agents, ra)
+		if conf.Webserver.Management.Token != "" {
+			managementRouter := r.PathPrefix("/management").Subrouter()
+			if _, maErr := core.NewManagementAPI(c, managementRouter, conf.Webserver.Management.Token); maErr != nil {
+				err = maErr
+				return
+			}
 		}
 
 		httpServer := &http.Server{
@@ -214,6 +236,36 @@ func parseAgents(conf agentsConfig) (agents []agent.ApplicationAgent, err error)
 	return
 }
 
+// parseMetrics starts an HTTP server exposing the metrics in the Prometheus text format.
+func parseMetrics(conf metricsConf) (err error) {
+	if conf.Path == "" {
+		conf.Path = "/metrics"
+	}
+
+	serveMux := http.NewServeMux()
+	serveMux.Handle(conf.Path, metrics.DefaultRegistry)
+
+	httpServer := &http.Server{
+		Addr:    conf.Address,
+		Handler: serveMux,
+	}
+
+	errChan := make(chan error)
+	go func() { errChan <- httpServer.ListenAndServe() }()
+
+	select {
+	case err = <-errChan:
+		return
+
+	case <-time.After(100 * time.Millisecond):
+		log.WithFields(log.Fields{
+			"address": conf.Address,
+			"path":    conf.Path,
+		}).Info("Started metrics endpoint")
+		return
+	}
+}
+
 // parseCore creates the Core based on the given TOML configuration.
 func parseCore(filename string) (c *core.Core, ds *discovery.DiscoveryService, err error) {
 	var conf tomlConfig
@@ -255,11 +307,6 @@ func parseCore(filename string) (c *core.Core, ds *discovery.DiscoveryService, e
 	var discoveryMsgs []discovery.DiscoveryMessage
 
 	// Core
-	if conf.Core.Store == "" {
-		err = fmt.Errorf("core.store is empty")
-		return
-	}
-
 	log.WithFields(log.Fields{
 		"routing": conf.Routing.Algorithm,
 	}).Debug("Selected routing algorithm")
@@ -277,13 +324,44 @@ func parseCore(filename string) (c *core.Core, ds *discovery.DiscoveryService, e
 		}
 	}
 
-	if c, err = core.NewCore(conf.Core.Store, nodeId, conf.Core.InspectAllBundles, conf.Routing, signPriv); err != nil {
+	store, storeErr := parseStore(conf.Core)
+	if storeErr != nil {
+		err = storeErr
 		return
 	}
 
+	if c, err = core.NewCore(store, nodeId, conf.Core.InspectAllBundles, conf.Routing, signPriv); err != nil {
+		_ = store.Close()
+		return
+	}
+
+	if conf.Core.Integrity != nil {
+		if err = c.SetIntegrityConf(*conf.Core.Integrity); err != nil {
+			return
+		}
+	}
+
+	if len(conf.Core.Confidentiality) > 0 {
+		if err = c.SetConfidentialityConf(conf.Core.Confidentiality); err != nil {
+			return
+		}
+	}
+
+	if conf.Core.Trust != nil {
+		if err = c.SetTrustConf(*conf.Core.Trust); err != nil {
+			return
+		}
+	}
+
+	if conf.Core.Quota != nil {
+		if err = c.SetStorageQuotaConf(*conf.Core.Quota); err != nil {
+			return
+		}
+	}
+
 	// Agents
 	if conf.Agents != (agentsConfig{}) {
-		if appAgents, appErr := parseAgents(conf.Agents); appErr != nil {
+		if appAgents, appErr := parseAgents(conf.Agents, c, conf.Core.Store); appErr != nil {
 			err = appErr
 			return
 		} else {
@@ -293,6 +371,13 @@ func parseCore(filename string) (c *core.Core, ds *discovery.DiscoveryService, e
 		}
 	}
 
+	// Metrics
+	if conf.Metrics.Address != "" {
+		if err = parseMetrics(conf.Metrics); err != nil {
+			return
+		}
+	}
+
 	// Listen/ConvergenceReceiver
 	for _, conv := range conf.Listen {
 		if convRec, eid, claType, discoMsg, lErr := parseListen(conv, c.NodeId); lErr != nil {
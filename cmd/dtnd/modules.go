@@ -0,0 +1,13 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+// The CLAs register their listeners and peers within their init functions. Further third-party CLAs or routing
+// algorithms might be included in dtnd by another file, importing their packages in the same way.
+import (
+	_ "github.com/dtn7/dtn7-go/cla/bbc"
+	_ "github.com/dtn7/dtn7-go/cla/mtcp"
+	_ "github.com/dtn7/dtn7-go/cla/tcpcl"
+)
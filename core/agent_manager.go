@@ -6,6 +6,7 @@ package core
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -20,6 +21,10 @@ type AgentManager struct {
 
 	mux *agent.MuxAgent
 
+	// subscriptions are the endpoints which subscribed BundleEvents.
+	subscriptions      map[bundle.EndpointID]struct{}
+	subscriptionsMutex sync.Mutex
+
 	closeSyn chan struct{}
 	closeAck chan struct{}
 }
@@ -30,6 +35,9 @@ func NewAgentManager(core *Core) (manager *AgentManager) {
 		core:     core,
 		mux:      agent.NewMuxAgent(),
 		closeSyn: make(chan struct{}),
+
+		subscriptions: make(map[bundle.EndpointID]struct{}),
+
 		closeAck: make(chan struct{}),
 	}
 
@@ -60,8 +68,14 @@ func (manager *AgentManager) handleMessage(msg agent.Message) {
 		log.WithField("bundle", msg.Bundle).Debug("AgentManager received Bundle from client")
 		manager.core.SendBundle(&msg.Bundle)
 
+	case agent.SyscallRequestMessage:
+		// Answer asynchronously, as the response is sent back through the MuxAgent this handler is reading from.
+		go manager.handleSyscall(msg)
+
+	case agent.EventSubscribeMessage:
+		manager.handleEventSubscribe(msg)
+
 	// TODO
-	//case agent.SyscallRequestMessage:
 	//case agent.ShutdownMessage:
 
 	default:
@@ -69,9 +83,73 @@ func (manager *AgentManager) handleMessage(msg agent.Message) {
 	}
 }
 
-// Register a new ApplicationAgent.
+// handleSyscall answers a SyscallRequestMessage by the SyscallManager. An unknown or failed syscall results in an
+// empty response, as each valid response contains at least one CBOR item.
+func (manager *AgentManager) handleSyscall(msg agent.SyscallRequestMessage) {
+	logger := log.WithFields(log.Fields{
+		"syscall": msg.Request,
+		"sender":  msg.Sender,
+	})
+
+	response, err := GetSyscallManager().Syscall(manager.core, msg.Request)
+	if err != nil {
+		logger.WithError(err).Warn("AgentManager failed to answer syscall")
+		response = []byte{}
+	} else {
+		logger.Debug("AgentManager answers syscall")
+	}
+
+	manager.mux.MessageReceiver() <- agent.SyscallResponseMessage{
+		Request:   msg.Request,
+		Response:  response,
+		Recipient: msg.Sender,
+	}
+}
+
+// handleEventSubscribe subscribes all BundleEvents for an endpoint, unless it is already subscribed. The events are
+// passed as EventMessages until no ApplicationAgent is registered for this endpoint anymore.
+func (manager *AgentManager) handleEventSubscribe(msg agent.EventSubscribeMessage) {
+	manager.subscriptionsMutex.Lock()
+	defer manager.subscriptionsMutex.Unlock()
+
+	if _, ok := manager.subscriptions[msg.Sender]; ok {
+		return
+	}
+	manager.subscriptions[msg.Sender] = struct{}{}
+
+	log.WithField("endpoint", msg.Sender).Debug("AgentManager subscribes bundle events")
+
+	events, unsubscribe := manager.core.SubscribeEvents(agent.BundleEventFilter{})
+	go func() {
+		defer func() {
+			unsubscribe()
+
+			manager.subscriptionsMutex.Lock()
+			delete(manager.subscriptions, msg.Sender)
+			manager.subscriptionsMutex.Unlock()
+
+			log.WithField("endpoint", msg.Sender).Debug("AgentManager ended bundle event subscription")
+		}()
+
+		for event := range events {
+			if !manager.HasEndpoint(msg.Sender) {
+				return
+			}
+
+			select {
+			case manager.mux.MessageReceiver() <- agent.EventMessage{Event: event, Recipient: msg.Sender}:
+			case <-manager.closeSyn:
+				return
+			}
+		}
+	}()
+}
+
+// Register a new ApplicationAgent. Bundles which were retained for its endpoints will be delivered afterwards.
 func (manager *AgentManager) Register(appAgent agent.ApplicationAgent) {
 	manager.mux.Register(appAgent)
+
+	go manager.core.deliverRetained()
 }
 
 // HasEndpoint checks if some specific EndpointID is registered for some ApplicationAgent.
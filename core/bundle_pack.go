@@ -25,11 +25,11 @@ type BundlePack struct {
 	Constraints map[Constraint]bool
 
 	bndl  *bundle.Bundle
-	store *storage.Store
+	store storage.Store
 }
 
 // NewBundlePack returns a BundlePack for the given bundle.
-func NewBundlePack(bid bundle.BundleID, store *storage.Store) BundlePack {
+func NewBundlePack(bid bundle.BundleID, store storage.Store) BundlePack {
 	bp := BundlePack{
 		Id:          bid,
 		Receiver:    bundle.DtnNone(),
@@ -41,25 +41,53 @@ func NewBundlePack(bid bundle.BundleID, store *storage.Store) BundlePack {
 	}
 
 	if bi, err := bp.store.QueryId(bp.Id.Scrub()); err == nil {
-		if v, ok := bi.Properties["bundlepack/receiver"]; ok {
-			bp.Receiver = v.(bundle.EndpointID)
-		}
-		if v, ok := bi.Properties["bundlepack/timestamp"]; ok {
-			bp.Timestamp = v.(time.Time)
-		}
-		if v, ok := bi.Properties["bundlepack/constraints"]; ok {
-			bp.Constraints = v.(map[Constraint]bool)
-		}
+		bp.restore(bi)
+	}
+
+	return bp
+}
+
+// newBundlePackFromItem returns a BundlePack for an already queried BundleItem.
+func newBundlePackFromItem(bi storage.BundleItem, store storage.Store) BundlePack {
+	bp := BundlePack{
+		Id:          bi.BId,
+		Receiver:    bundle.DtnNone(),
+		Timestamp:   time.Now(),
+		Constraints: make(map[Constraint]bool),
+
+		bndl:  nil,
+		store: store,
 	}
 
+	bp.restore(bi)
 	return bp
 }
 
-func NewBundlePackFromBundle(b bundle.Bundle, store *storage.Store) BundlePack {
+// restore the BundlePack's fields from its BundleItem's properties.
+func (bp *BundlePack) restore(bi storage.BundleItem) {
+	if v, ok := bi.Properties["bundlepack/receiver"]; ok {
+		bp.Receiver = v.(bundle.EndpointID)
+	}
+	if v, ok := bi.Properties["bundlepack/timestamp"]; ok {
+		bp.Timestamp = v.(time.Time)
+	}
+	if v, ok := bi.Properties["bundlepack/constraints"]; ok {
+		bp.Constraints = v.(map[Constraint]bool)
+	}
+}
+
+func NewBundlePackFromBundle(b bundle.Bundle, store storage.Store) BundlePack {
 	bp := NewBundlePack(b.ID(), store)
 
 	bp.bndl = &b
 
+	// Further fragments of an already known bundle are added to its stored parts.
+	if b.PrimaryBlock.BundleControlFlags.Has(bundle.IsFragment) && store.KnowsBundle(b.ID()) {
+		if err := store.Push(b); err != nil {
+			log.WithField("bundle", b.ID()).WithError(err).Warn("Storing bundle fragment errored")
+		}
+	}
+
 	_ = bp.Sync()
 	return bp
 }
@@ -75,6 +103,8 @@ func (bp BundlePack) Sync() error {
 	} else {
 		bi.Pending = !bp.HasConstraint(ReassemblyPending) &&
 			(bp.HasConstraint(ForwardPending) || bp.HasConstraint(Contraindicated))
+		bi.Local = bp.HasConstraint(LocalEndpoint)
+		bi.Contraindicated = bp.HasConstraint(Contraindicated)
 
 		bi.Properties["bundlepack/receiver"] = bp.Receiver
 		bi.Properties["bundlepack/timestamp"] = bp.Timestamp
@@ -161,21 +191,48 @@ func (bp *BundlePack) PurgeConstraints() {
 	}
 }
 
-// UpdateBundleAge updates the bundle's Bundle Age block based on its reception
-// timestamp, if such a block exists.
-func (bp *BundlePack) UpdateBundleAge() (uint64, error) {
+// DwellTime returns the time since this BundlePack's reception or creation at this node.
+func (bp BundlePack) DwellTime() time.Duration {
+	if dwell := time.Since(bp.Timestamp); dwell > 0 {
+		return dwell
+	}
+	return 0
+}
+
+// ExpirationTime returns the time of the bundle's expiration, based on its creation timestamp or on its Bundle Age
+// block together with its dwell time at this node.
+func (bp *BundlePack) ExpirationTime() time.Time {
+	return bp.MustBundle().ExpirationTime(bp.Timestamp)
+}
+
+// IsLifetimeExceeded checks if the bundle's lifetime is exceeded, compare ExpirationTime.
+func (bp *BundlePack) IsLifetimeExceeded() bool {
+	return time.Now().After(bp.ExpirationTime())
+}
+
+// UpdateBundleAge increments the bundle's Bundle Age block by its dwell time at
+// this node, if such a block exists. The updated age is only meant for the
+// bundle's transmission. Afterwards, the returned reset function must be
+// called to restore the age at its reception, which is the base for the
+// BundlePack's further calculations.
+func (bp *BundlePack) UpdateBundleAge() (age uint64, reset func(), err error) {
 	bndl, err := bp.Bundle()
 	if err != nil {
-		return 0, err
+		return
 	}
 
 	ageBlock, err := bndl.ExtensionBlock(bundle.ExtBlockTypeBundleAgeBlock)
 	if err != nil {
-		return 0, newCoreError("No such block")
+		err = newCoreError("No such block")
+		return
 	}
 
-	age := ageBlock.Value.(*bundle.BundleAgeBlock)
-	return age.Increment(uint64(time.Since(bp.Timestamp)) / 1000), nil
+	bab := ageBlock.Value.(*bundle.BundleAgeBlock)
+	receivedAge := bab.Age()
+
+	age = bab.Increment(uint64(bp.DwellTime() / time.Millisecond))
+	reset = func() { *bab = *bundle.NewBundleAgeBlock(receivedAge) }
+	return
 }
 
 func (bp BundlePack) String() string {
@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+func TestBundlePackBundleAge(t *testing.T) {
+	store := storage.NewMemoryStore()
+	defer store.Close()
+
+	b, err := bundle.Builder().
+		Source("dtn://sensor/").
+		Destination("dtn://dst/").
+		CreationTimestampEpoch().
+		Lifetime("1h").
+		BundleAgeBlock(1000).
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bp := NewBundlePackFromBundle(b, store)
+	bp.Timestamp = time.Now().Add(-2 * time.Second)
+
+	expiration := bp.Timestamp.Add(time.Hour - time.Second)
+	if exp := bp.ExpirationTime(); !exp.Equal(expiration) {
+		t.Fatalf("Expiration is %v, expected %v", exp, expiration)
+	} else if bp.IsLifetimeExceeded() {
+		t.Fatal("Lifetime is exceeded")
+	}
+
+	age, reset, err := bp.UpdateBundleAge()
+	if err != nil {
+		t.Fatal(err)
+	} else if age < 3000 || age > 4000 {
+		t.Fatalf("Updated age is %d ms", age)
+	}
+
+	reset()
+
+	if ageBlock, err := bp.MustBundle().ExtensionBlock(bundle.ExtBlockTypeBundleAgeBlock); err != nil {
+		t.Fatal(err)
+	} else if age := ageBlock.Value.(*bundle.BundleAgeBlock).Age(); age != 1000 {
+		t.Fatalf("Age was reset to %d ms", age)
+	} else if exp := bp.ExpirationTime(); !exp.Equal(expiration) {
+		t.Fatalf("Expiration after reset is %v, expected %v", exp, expiration)
+	}
+
+	// Stored for more than its lifetime at this node.
+	bp.Timestamp = time.Now().Add(-time.Hour)
+	if !bp.IsLifetimeExceeded() {
+		t.Fatal("Lifetime is not exceeded")
+	}
+}
@@ -0,0 +1,160 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/bundle"
+)
+
+// ConfidentialityConf configures the BPSec Block Confidentiality Blocks for bundles addressed to one destination.
+//
+// Outgoing bundles created at this node for this destination will be encrypted. In the same way, incoming bundles
+// for this destination, which must be one of this node's endpoints, will be decrypted before being delivered.
+type ConfidentialityConf struct {
+	// Destination is the bundle's destination, compared by the node part of the Endpoint ID.
+	Destination string
+
+	// Key is a hex encoded key encryption key (KEK), 16 or 32 bytes long, used for the AES key wrap of each bundle's
+	// randomly generated content encryption key.
+	Key string
+
+	// Targets are the block type codes of the blocks to be encrypted. Defaults to the Payload Block.
+	Targets []uint64
+
+	// AESVariant of the BCB-AES-GCM security context, either 128 or 256. Defaults to 256.
+	AESVariant uint `toml:"aes-variant"`
+}
+
+// confidentialityPolicy is a parsed ConfidentialityConf, used by the Core.
+type confidentialityPolicy struct {
+	destination bundle.EndpointID
+	kek         []byte
+	targets     []uint64
+	variant     bundle.AESVariant
+}
+
+// newConfidentialityPolicy from a ConfidentialityConf.
+func newConfidentialityPolicy(conf ConfidentialityConf) (cp confidentialityPolicy, err error) {
+	if cp.destination, err = bundle.NewEndpointID(conf.Destination); err != nil {
+		err = fmt.Errorf("parsing destination %s errored: %v", conf.Destination, err)
+		return
+	}
+
+	if cp.kek, err = hex.DecodeString(conf.Key); err != nil {
+		err = fmt.Errorf("decoding key for %v errored: %v", cp.destination, err)
+		return
+	} else if l := len(cp.kek); l != 16 && l != 32 {
+		err = fmt.Errorf("key for %v has a length of %d bytes, not 16 or 32", cp.destination, l)
+		return
+	}
+
+	cp.targets = conf.Targets
+	if len(cp.targets) == 0 {
+		cp.targets = []uint64{bundle.ExtBlockTypePayloadBlock}
+	}
+
+	switch conf.AESVariant {
+	case 128:
+		cp.variant = bundle.A128GCM
+	case 0, 256:
+		cp.variant = bundle.A256GCM
+	default:
+		err = fmt.Errorf("unknown AES variant %d", conf.AESVariant)
+	}
+
+	return
+}
+
+// SetConfidentialityConf configures the Block Confidentiality Blocks for bundles to the configured destinations.
+// Each call replaces the former configuration.
+func (c *Core) SetConfidentialityConf(confs []ConfidentialityConf) error {
+	cps := make([]confidentialityPolicy, 0, len(confs))
+	for _, conf := range confs {
+		if cp, err := newConfidentialityPolicy(conf); err != nil {
+			return err
+		} else {
+			cps = append(cps, cp)
+		}
+	}
+
+	c.confidentiality = cps
+	return nil
+}
+
+// confidentialityPolicyFor returns the confidentialityPolicy for a bundle's destination, if any.
+func (c *Core) confidentialityPolicyFor(destination bundle.EndpointID) (cp confidentialityPolicy, ok bool) {
+	for _, cp = range c.confidentiality {
+		if cp.destination.SameNode(destination) {
+			return cp, true
+		}
+	}
+	return
+}
+
+// sendBundleEncrypt encrypts the configured blocks of outgoing bundles by Block Confidentiality Blocks.
+func (c *Core) sendBundleEncrypt(bndl *bundle.Bundle) {
+	cp, ok := c.confidentialityPolicyFor(bndl.PrimaryBlock.Destination)
+	if !ok {
+		return
+	}
+
+	var encrypted []uint64
+	for _, target := range cp.targets {
+		bcb := bundle.NewBCBAESGCM(c.NodeId, cp.variant, bundle.AADScopeAll)
+		if err := bndl.AddBlockConfidentialityBlock(bcb, target, cp.kek); err != nil {
+			log.WithFields(log.Fields{
+				"bundle": bndl.ID(),
+				"target": target,
+			}).WithError(err).Error("Creating Block Confidentiality Block errored, proceeding without")
+		} else {
+			encrypted = append(encrypted, target)
+		}
+	}
+
+	if len(encrypted) == 0 {
+		log.WithFields(log.Fields{
+			"bundle":  bndl.ID(),
+			"targets": cp.targets,
+		}).Warn("No block of the outgoing bundle could be encrypted, bundle is sent unencrypted")
+		return
+	}
+
+	for i := range bndl.CanonicalBlocks {
+		if cb := &bndl.CanonicalBlocks[i]; cb.TypeCode() == bundle.ExtBlockTypeBlockConfidentialityBlock {
+			cb.SetCRCType(bundle.CRC32)
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"bundle":  bndl.ID(),
+		"targets": encrypted,
+	}).Info("Encrypted outgoing bundle by Block Confidentiality Blocks")
+}
+
+// localDeliveryDecrypt decrypts all Block Confidentiality Blocks of a bundle before its local delivery. The returned
+// boolean indicates if the bundle was encrypted.
+func (c *Core) localDeliveryDecrypt(bp BundlePack) (bool, error) {
+	bndl := bp.MustBundle()
+	if !bndl.HasBlockConfidentialityBlock() {
+		return false, nil
+	}
+
+	cp, ok := c.confidentialityPolicyFor(bndl.PrimaryBlock.Destination)
+	if !ok {
+		return true, fmt.Errorf("no key is configured for destination %v", bndl.PrimaryBlock.Destination)
+	}
+
+	if err := bndl.DecryptBlockConfidentialityBlocks(cp.kek); err != nil {
+		return true, err
+	}
+
+	log.WithField("bundle", bp.ID()).Info("Decrypted Block Confidentiality Blocks of bundle for local delivery")
+	return true, nil
+}
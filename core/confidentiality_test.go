@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dtn7/dtn7-go/bundle"
+)
+
+func TestCoreSendBundleEncrypt(t *testing.T) {
+	payload := []byte("hello world")
+
+	c := &Core{NodeId: bundle.MustNewEndpointID("dtn://src/")}
+	err := c.SetConfidentialityConf([]ConfidentialityConf{
+		{Destination: "dtn://dst/", Key: "000102030405060708090a0b0c0d0e0f", AESVariant: 128},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dst := range []string{"dtn://dst/", "dtn://dst/app", "dtn://other/"} {
+		t.Run(dst, func(t *testing.T) {
+			b, err := bundle.Builder().
+				Source("dtn://src/").
+				Destination(dst).
+				CreationTimestampNow().
+				Lifetime("1h").
+				PayloadBlock(payload).
+				Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c.sendBundleEncrypt(&b)
+
+			_, expected := c.confidentialityPolicyFor(b.PrimaryBlock.Destination)
+			if encrypted := b.HasBlockConfidentialityBlock(); encrypted != expected {
+				t.Fatalf("Bundle encrypted: %t, expected %t", encrypted, expected)
+			} else if !encrypted {
+				return
+			}
+
+			if err := b.CheckValid(); err != nil {
+				t.Fatal(err)
+			}
+
+			bp := BundlePack{Id: b.ID(), bndl: &b}
+			if _, err := c.localDeliveryDecrypt(bp); err != nil {
+				t.Fatal(err)
+			}
+
+			pb, _ := b.PayloadBlock()
+			if data := pb.Value.(*bundle.PayloadBlock).Data(); !bytes.Equal(data, payload) {
+				t.Fatalf("Decrypted payload is %x, expected %x", data, payload)
+			}
+		})
+	}
+}
+
+func TestNewConfidentialityPolicyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		conf ConfidentialityConf
+	}{
+		{"invalid destination", ConfidentialityConf{Destination: "nope", Key: "000102030405060708090a0b0c0d0e0f"}},
+		{"invalid hex", ConfidentialityConf{Destination: "dtn://dst/", Key: "nope"}},
+		{"invalid key length", ConfidentialityConf{Destination: "dtn://dst/", Key: "0001"}},
+		{"invalid variant", ConfidentialityConf{Destination: "dtn://dst/", Key: "000102030405060708090a0b0c0d0e0f", AESVariant: 192}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := newConfidentialityPolicy(test.conf); err == nil {
+				t.Fatal("newConfidentialityPolicy did not error")
+			}
+		})
+	}
+}
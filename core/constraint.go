@@ -25,6 +25,8 @@ const (
 
 	// LocalEndpoint is assigned to a bundle after delivery to a local endpoint.
 	// This constraint demands storage until the endpoint removes this constraint.
+	// Bundles for currently unregistered endpoints are retained with this
+	// constraint until an application registers or the bundle expires.
 	LocalEndpoint Constraint = iota
 )
 
@@ -9,6 +9,7 @@ import (
 	"crypto/ed25519"
 	"encoding/gob"
 	"fmt"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -19,20 +20,53 @@ import (
 	"github.com/dtn7/dtn7-go/storage"
 )
 
+// pendingBundlesInterval is the interval to retry all pending bundles. The transmissions queued for a disappeared peer
+// are resumed on its reappearance; this interval is the fallback for all other pending bundles, e.g., for new peers.
+const pendingBundlesInterval = time.Minute
+
 // Core is the inner core of our DTN which handles transmission, reception and
 // reception of bundles.
 type Core struct {
 	InspectAllBundles bool
 	NodeId            bundle.EndpointID
 
-	agentManager *AgentManager
-	cron         *Cron
-	claManager   *cla.Manager
-	idKeeper     IdKeeper
-	routing      RoutingAlgorithm
-	signPriv     ed25519.PrivateKey
+	agentManager    *AgentManager
+	cron            *Cron
+	claManager      *cla.Manager
+	confidentiality []confidentialityPolicy
+	idKeeper        IdKeeper
+	integrity       *integrityPolicy
+	routing         RoutingAlgorithm
+	routingConf     RoutingConf
+	signPriv        ed25519.PrivateKey
+	trust           *trustPolicy
+
+	store storage.Store
+
+	// retainedMutex serializes the delivery of retained bundles.
+	retainedMutex sync.Mutex
+
+	// bundlesMutex serializes the state changes of stored bundles by the handler, the check of pending bundles, the
+	// finished transmissions, the delivery of retained bundles, and the ManagementAPI.
+	bundlesMutex sync.Mutex
+
+	// events passes BundleEvents to their subscribers.
+	events eventBus
+
+	// transmissions queue forwarded bundles for each ConvergenceSender.
+	transmissions *transmissionQueues
+
+	// pendingSyn requests a check of the pending bundles, compare triggerPendingBundles.
+	pendingSyn chan struct{}
+	pendingAck chan struct{}
 
-	store *storage.Store
+	// evicted bundles await their status reports, which are sent by the handler after evictedSyn was signaled.
+	evicted      []BundlePack
+	evictedMutex sync.Mutex
+	evictedSyn   chan struct{}
+
+	// reactiveRemainders maps a bundle and a peer to its remaining fragments of an interrupted transmission.
+	reactiveRemainders sync.Map
 
 	stopSyn chan struct{}
 	stopAck chan struct{}
@@ -40,22 +74,21 @@ type Core struct {
 
 // NewCore will be created according to the parameters.
 //
-// 	storePath: path for the bundle and metadata storage
+// 	store: storage for bundles and their metadata, e.g., a storage.BadgerStore or a storage.MemoryStore; it is closed
+// 	       when the Core is closed
 // 	nodeId: singleton Endpoint ID/Node ID
 // 	inspectAllBundles: inspect all administrative records, not only those addressed to this node
 // 	routingConf: selected routing algorithm and its configuration
-// 	signPriv: optional ed25519 private key (64 bytes long) to sign all outgoing bundles; or nil to not use this feature
-func NewCore(storePath string, nodeId bundle.EndpointID, inspectAllBundles bool, routingConf RoutingConf, signPriv ed25519.PrivateKey) (*Core, error) {
+// 	signPriv: optional ed25519 private key (64 bytes long) to sign outgoing administrative records by a Block
+// 	          Integrity Block; or nil to not use this feature. Use SetIntegrityConf for further configuration.
+func NewCore(store storage.Store, nodeId bundle.EndpointID, inspectAllBundles bool, routingConf RoutingConf, signPriv ed25519.PrivateKey) (*Core, error) {
 	var c = new(Core)
 
-	gob.Register([]bundle.EndpointID{})
-	gob.Register(bundle.EndpointID{})
-	gob.Register(map[cla.CLAType][]bundle.EndpointID{})
-	gob.Register(bundle.DtnEndpoint{})
-	gob.Register(bundle.IpnEndpoint{})
-	gob.Register(map[Constraint]bool{})
-	gob.Register(time.Time{})
+	registerGobTypes()
 
+	if store == nil {
+		return nil, fmt.Errorf("passed store MUST NOT be nil")
+	}
 	if !nodeId.IsSingleton() {
 		return nil, fmt.Errorf("passed Node ID MUST be a singleton; %s is not", nodeId)
 	}
@@ -63,23 +96,23 @@ func NewCore(storePath string, nodeId bundle.EndpointID, inspectAllBundles bool,
 	c.NodeId = nodeId
 
 	c.cron = NewCron()
-
-	if store, err := storage.NewStore(storePath); err != nil {
-		return nil, err
-	} else {
-		c.store = store
-	}
+	c.store = store
 
 	c.agentManager = NewAgentManager(c)
 
 	c.claManager = cla.NewManager()
+	c.claManager.SetReceptionFilter(c.store.Admits)
 
 	c.idKeeper = NewIdKeeper()
+	if err := c.idKeeper.restore(c.store); err != nil {
+		return nil, err
+	}
 
 	if ra, raErr := routingConf.RoutingAlgorithm(c); raErr != nil {
 		return nil, raErr
 	} else {
 		c.routing = ra
+		c.routingConf = routingConf
 	}
 
 	if signPriv != nil {
@@ -88,50 +121,164 @@ func NewCore(storePath string, nodeId bundle.EndpointID, inspectAllBundles bool,
 		}
 		c.signPriv = signPriv
 
-		if err := bundle.GetExtensionBlockManager().Register(&bundle.SignatureBlock{}); err != nil {
-			return nil, fmt.Errorf("SignatureBlock registration errored: %v", err)
+		if ip, err := newIntegrityPolicy(IntegrityConf{AdministrativeRecordsOnly: true}, signPriv); err != nil {
+			return nil, err
+		} else {
+			c.integrity = ip
+		}
+
+		if err := registerSignatureBlock(); err != nil {
+			return nil, err
 		}
 	}
 
 	c.stopSyn = make(chan struct{})
 	c.stopAck = make(chan struct{})
 
-	if err := c.cron.Register("pending_bundles", c.checkPendingBundles, 10*time.Second); err != nil {
+	c.transmissions = newTransmissionQueues(c, transmissionWorkers)
+
+	c.pendingSyn = make(chan struct{}, 1)
+	c.pendingAck = make(chan struct{})
+
+	c.evictedSyn = make(chan struct{}, 1)
+
+	if err := c.cron.Register("pending_bundles", c.triggerPendingBundles, pendingBundlesInterval); err != nil {
 		log.WithError(err).Warn("Failed to register pending_bundles at cron")
 	}
 	if err := c.cron.Register("clean_store", c.store.DeleteExpired, 10*time.Minute); err != nil {
 		log.WithError(err).Warn("Failed to register clean_store at cron")
 	}
+	if err := c.cron.Register("retained_bundles", c.deliverRetained, 10*time.Second); err != nil {
+		log.WithError(err).Warn("Failed to register retained_bundles at cron")
+	}
+
+	if _, ok := c.routing.(RoutingStatePersister); ok {
+		c.restoreRoutingState()
+
+		if err := c.cron.Register("routing_state", c.persistRoutingState, routingStateInterval); err != nil {
+			log.WithError(err).Warn("Failed to register routing_state at cron")
+		}
+	}
+
+	c.registerMetrics()
 
 	go c.handler()
+	go c.pendingHandler()
 
 	return c, nil
 }
 
+// registerGobTypes registers all types being stored as properties within the store's BundleItems.
+func registerGobTypes() {
+	gob.Register([]bundle.EndpointID{})
+	gob.Register(bundle.EndpointID{})
+	gob.Register(map[cla.CLAType][]bundle.EndpointID{})
+	gob.Register(bundle.DtnEndpoint{})
+	gob.Register(bundle.IpnEndpoint{})
+	gob.Register(map[Constraint]bool{})
+	gob.Register(time.Time{})
+}
+
 // SetRoutingAlgorithm overwrites the used RoutingAlgorithm, which defaults to
 // EpidemicRouting.
 func (c *Core) SetRoutingAlgorithm(routing RoutingAlgorithm) {
 	c.routing = routing
 }
 
+// triggerPendingBundles requests a check of the pending bundles without
+// blocking. Requests during a running check are merged into one further check.
+func (c *Core) triggerPendingBundles() {
+	select {
+	case c.pendingSyn <- struct{}{}:
+	default:
+	}
+}
+
+// pendingHandler checks the pending bundles on request, one check at a time.
+func (c *Core) pendingHandler() {
+	for {
+		select {
+		case <-c.stopSyn:
+			close(c.pendingAck)
+			return
+
+		case <-c.pendingSyn:
+			c.checkPendingBundles()
+		}
+	}
+}
+
 // checkPendingBundles queries pending bundle (packs) from the store and
-// tries to dispatch them.
+// tries to dispatch them. Bundles which are currently being transmitted are
+// skipped.
 func (c *Core) checkPendingBundles() {
+	c.transmissions.prune(c.store.KnowsBundle)
+
 	if bis, err := c.store.QueryPending(); err != nil {
 		log.WithFields(log.Fields{
 			"error": err,
 		}).Warn("Failed to fetch pending bundle packs")
 	} else {
-		for _, bi := range bis {
-			log.WithFields(log.Fields{
-				"bundle": bi.Id,
-			}).Info("Retrying bundle from store")
+		if prioritizer, ok := c.routing.(BundlePrioritizer); ok {
+			prioritizer.PrioritizeBundles(bis)
+		}
 
-			c.dispatching(NewBundlePack(bi.BId, c.store))
+		for _, bi := range bis {
+			c.retryPending(bi)
 		}
 	}
 }
 
+// retryPending dispatches a pending bundle again, unless it is currently being transmitted or was deleted since
+// querying the pending bundles.
+func (c *Core) retryPending(bi storage.BundleItem) {
+	c.bundlesMutex.Lock()
+	defer c.bundlesMutex.Unlock()
+
+	if c.transmissions.transmitting(bi.BId) || !c.store.KnowsBundle(bi.BId) {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"bundle": bi.Id,
+	}).Info("Retrying bundle from store")
+
+	c.dispatching(NewBundlePack(bi.BId, c.store))
+}
+
+// resumeTransmissions enqueues the kept transmissions of a reappeared peer again, without consulting the
+// RoutingAlgorithm. Bundles which were deleted or are currently being transmitted are skipped.
+func (c *Core) resumeTransmissions(sender cla.ConvergenceSender) {
+	for _, kt := range c.transmissions.resume(sender.GetPeerEndpointID()) {
+		c.resumeTransmission(kt, sender)
+	}
+}
+
+// resumeTransmission of one kept transmission to its reappeared peer.
+func (c *Core) resumeTransmission(kt keptTransmission, sender cla.ConvergenceSender) {
+	c.bundlesMutex.Lock()
+	defer c.bundlesMutex.Unlock()
+
+	if c.transmissions.transmitting(kt.bid) || !c.store.KnowsBundle(kt.bid) {
+		return
+	}
+
+	bp := NewBundlePack(kt.bid, c.store)
+	if _, err := bp.Bundle(); err != nil {
+		log.WithField("bundle", bp.ID()).WithError(err).Warn("Failed to load bundle of a kept transmission")
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"bundle": bp.ID(),
+		"cla":    sender,
+	}).Info("Resuming transmission for a reappeared peer")
+
+	if c.prepareForwarding(bp) {
+		c.enqueueTransmission(bp, []cla.ConvergenceSender{sender}, kt.deleteAfterwards)
+	}
+}
+
 // handler does the Core's background tasks
 func (c *Core) handler() {
 	for {
@@ -139,8 +286,14 @@ func (c *Core) handler() {
 		// Invoked by Close(), shuts down
 		case <-c.stopSyn:
 			c.cron.Stop()
+			<-c.pendingAck
+
+			c.persistRoutingState()
+
+			c.unregisterMetrics()
 
-			c.claManager.Close()
+			c.closeClaManager()
+			c.transmissions.close()
 
 			if storeErr := c.store.Close(); storeErr != nil {
 				log.WithFields(log.Fields{
@@ -151,25 +304,49 @@ func (c *Core) handler() {
 			close(c.stopAck)
 			return
 
+		// Send the status reports of evicted bundles, outside of the store's eviction
+		case <-c.evictedSyn:
+			c.reportEvictions()
+
 		// Handle a received ConvergenceStatus
 		case cs := <-c.claManager.Channel():
 			switch cs.MessageType {
 			case cla.ReceivedBundle:
 				crb := cs.Message.(cla.ConvergenceReceivedBundle)
+				observeClaReceived(cs.Sender, crb.Bundle)
+				c.emitClaEvent(agent.BundleReceived, crb.Bundle, cs.Sender, receivedFrom(crb.Bundle, cs.Sender))
+
+				c.bundlesMutex.Lock()
 
 				bp := NewBundlePackFromBundle(*crb.Bundle, c.store)
+				if !c.store.KnowsBundle(bp.Id) {
+					c.bundlesMutex.Unlock()
+
+					log.WithField("bundle", bp.ID()).Info("Received bundle was refused by the store")
+					continue
+				}
+
 				bp.Receiver = crb.Endpoint
 				_ = bp.Sync()
 
 				c.receive(bp)
 
+				c.bundlesMutex.Unlock()
+
 			case cla.PeerAppeared:
 				c.routing.ReportPeerAppeared(cs.Sender)
-				c.checkPendingBundles()
+
+				if sender, ok := cs.Sender.(cla.ConvergenceSender); ok {
+					c.resumeTransmissions(sender)
+				}
 
 			case cla.PeerDisappeared:
 				c.routing.ReportPeerDisappeared(cs.Sender)
 
+				if sender, ok := cs.Sender.(cla.ConvergenceSender); ok {
+					c.transmissions.drop(sender)
+				}
+
 			default:
 				log.WithFields(log.Fields{
 					"cla":    cs.Sender,
@@ -181,6 +358,24 @@ func (c *Core) handler() {
 	}
 }
 
+// closeClaManager closes the CLA Manager while discarding its last ConvergenceStatus messages. Otherwise, the CLA
+// Manager might block on sending a message to the already stopped handler.
+func (c *Core) closeClaManager() {
+	closed := make(chan struct{})
+	go func() {
+		c.claManager.Close()
+		close(closed)
+	}()
+
+	for {
+		select {
+		case <-c.claManager.Channel():
+		case <-closed:
+			return
+		}
+	}
+}
+
 // Close shuts the Core down and notifies all bounded ConvergenceReceivers to
 // also close the connection.
 func (c *Core) Close() {
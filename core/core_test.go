@@ -0,0 +1,70 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+func TestNewCoreMemoryStore(t *testing.T) {
+	nodeId := bundle.MustNewEndpointID("dtn://node/")
+	routingConf := RoutingConf{Algorithm: "epidemic"}
+
+	if _, err := NewCore(nil, nodeId, false, routingConf, nil); err == nil {
+		t.Fatal("NewCore accepted a nil store")
+	}
+
+	store := storage.NewMemoryStore()
+	c, err := NewCore(store, nodeId, false, routingConf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both bundles share the same creation timestamp, which must be distinguished by the IdKeeper before storing them.
+	now := time.Now()
+	var bndls []bundle.Bundle
+	for i := 0; i < 2; i++ {
+		b, err := bundle.Builder().
+			BundleCtrlFlags(0).
+			Source("dtn://node/").
+			Destination("dtn://dst/").
+			CreationTimestampTime(now).
+			Lifetime("1h").
+			PayloadBlock([]byte("hello world")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.SendBundle(&b)
+		bndls = append(bndls, b)
+	}
+
+	if bndls[0].ID() == bndls[1].ID() {
+		t.Fatalf("Both bundles have the same ID %v", bndls[0].ID())
+	}
+
+	if bis, err := store.QueryPending(); err != nil {
+		t.Fatal(err)
+	} else if len(bis) != len(bndls) {
+		t.Fatalf("Store contains %d pending bundles", len(bis))
+	}
+
+	for _, b := range bndls {
+		if !store.KnowsBundle(b.ID()) {
+			t.Fatalf("Store does not know bundle %v", b.ID())
+		}
+	}
+
+	c.Close()
+
+	if store.KnowsBundle(bndls[0].ID()) {
+		t.Fatal("MemoryStore was not closed by the Core")
+	}
+}
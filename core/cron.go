@@ -18,6 +18,13 @@ type cronjob struct {
 	nextEvent time.Time
 }
 
+// run executes this job's task, observing its duration.
+func (job *cronjob) run(name string) {
+	start := time.Now()
+	job.task()
+	metricCronJobDuration.Observe(time.Since(start).Seconds(), name)
+}
+
 // Cron manages different jobs which require interval based execution.
 type Cron struct {
 	jobs  map[string]*cronjob
@@ -66,7 +73,7 @@ func (cron *Cron) fire(t time.Time) {
 		}
 
 		job.nextEvent = job.nextEvent.Add(job.interval)
-		go job.task()
+		go job.run(name)
 
 		log.WithFields(log.Fields{
 			"job":        name,
@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/agent"
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+)
+
+// eventBufferSize is the amount of BundleEvents buffered for each subscriber. Further events are dropped until the
+// subscriber catches up.
+const eventBufferSize = 64
+
+// eventSubscriber receives the BundleEvents selected by its filter.
+type eventSubscriber struct {
+	filter agent.BundleEventFilter
+	events chan agent.BundleEvent
+}
+
+// eventBus passes the Core's BundleEvents to its subscribers. Its zero value is ready to use.
+type eventBus struct {
+	mutex       sync.Mutex
+	subscribers map[*eventSubscriber]struct{}
+}
+
+// subscribe a new eventSubscriber, returning its channel and a function to unsubscribe.
+func (bus *eventBus) subscribe(filter agent.BundleEventFilter) (<-chan agent.BundleEvent, func()) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	if bus.subscribers == nil {
+		bus.subscribers = make(map[*eventSubscriber]struct{})
+	}
+
+	sub := &eventSubscriber{
+		filter: filter,
+		events: make(chan agent.BundleEvent, eventBufferSize),
+	}
+	bus.subscribers[sub] = struct{}{}
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			bus.mutex.Lock()
+			defer bus.mutex.Unlock()
+
+			delete(bus.subscribers, sub)
+			close(sub.events)
+		})
+	}
+
+	return sub.events, unsubscribe
+}
+
+// publish a BundleEvent to all matching subscribers without blocking.
+func (bus *eventBus) publish(event agent.BundleEvent) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	for sub := range bus.subscribers {
+		if !sub.filter.Matches(event) {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			log.WithField("event", event).Debug("Dropping bundle event for a congested subscriber")
+		}
+	}
+}
+
+// SubscribeEvents for the lifecycle of bundles, selected by the filter. The returned channel must be read
+// continuously, as events are dropped if its buffer is full. The subscription ends by calling the returned
+// function, which closes the channel.
+func (c *Core) SubscribeEvents(filter agent.BundleEventFilter) (events <-chan agent.BundleEvent, unsubscribe func()) {
+	return c.events.subscribe(filter)
+}
+
+// newBundleEvent creates a BundleEvent for a bundle, to be completed by the caller.
+func newBundleEvent(eventType agent.BundleEventType, b *bundle.Bundle) agent.BundleEvent {
+	return agent.BundleEvent{
+		Type:        eventType,
+		Bundle:      b.ID(),
+		Destination: b.PrimaryBlock.Destination,
+		Time:        time.Now(),
+	}
+}
+
+// emitEvent publishes a BundleEvent without further information.
+func (c *Core) emitEvent(eventType agent.BundleEventType, bp BundlePack) {
+	c.events.publish(newBundleEvent(eventType, bp.MustBundle()))
+}
+
+// emitClaEvent publishes a BundleEvent for a bundle received from or sent to a CLA.
+func (c *Core) emitClaEvent(eventType agent.BundleEventType, b *bundle.Bundle, conv cla.Convergence, peer bundle.EndpointID) {
+	event := newBundleEvent(eventType, b)
+	event.Cla = conv.Address()
+	event.Peer = peer
+	c.events.publish(event)
+}
+
+// receivedFrom returns the node a bundle was received from, based on its Previous Node Block or the CLA's peer.
+func receivedFrom(b *bundle.Bundle, conv cla.Convergence) bundle.EndpointID {
+	if pnBlock, err := b.ExtensionBlock(bundle.ExtBlockTypePreviousNodeBlock); err == nil {
+		return pnBlock.Value.(*bundle.PreviousNodeBlock).Endpoint()
+	} else if cs, ok := conv.(cla.ConvergenceSender); ok {
+		return cs.GetPeerEndpointID()
+	}
+	return bundle.EndpointID{}
+}
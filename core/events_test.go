@@ -0,0 +1,181 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/agent"
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+func TestCoreEvents(t *testing.T) {
+	registerGobTypes()
+
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := storage.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	b, err := bundle.Builder().
+		BundleCtrlFlags(0).
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("1h").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Core{store: store}
+
+	events, unsubscribe := c.SubscribeEvents(agent.BundleEventFilter{
+		Types:  []agent.BundleEventType{agent.BundleContraindicated, agent.BundleDeleted},
+		Source: bundle.MustNewEndpointID("dtn://src/"),
+	})
+	otherEvents, otherUnsubscribe := c.SubscribeEvents(agent.BundleEventFilter{
+		Destination: bundle.MustNewEndpointID("dtn://other/"),
+	})
+	defer otherUnsubscribe()
+
+	bp := NewBundlePackFromBundle(b, store)
+	bp.AddConstraint(ForwardPending)
+	_ = bp.Sync()
+
+	c.emitEvent(agent.BundleReceived, bp)
+	c.bundleContraindicated(bp)
+	c.bundleDeletion(bp, bundle.HopLimitExceeded)
+
+	for _, expected := range []agent.BundleEventType{agent.BundleContraindicated, agent.BundleDeleted} {
+		select {
+		case event := <-events:
+			if event.Type != expected {
+				t.Fatalf("Received %v event instead of %v", event.Type, expected)
+			} else if event.Bundle != b.ID() {
+				t.Fatalf("Event's bundle is %v instead of %v", event.Bundle, b.ID())
+			} else if event.Type == agent.BundleDeleted && event.Reason != bundle.HopLimitExceeded {
+				t.Fatalf("Event's reason is %v", event.Reason)
+			}
+
+		case <-time.After(time.Second):
+			t.Fatalf("Waiting for %v event timed out", expected)
+		}
+	}
+
+	select {
+	case event := <-events:
+		t.Fatalf("Received unexpected event %v", event)
+	case event := <-otherEvents:
+		t.Fatalf("Filter selected unexpected event %v", event)
+	default:
+	}
+
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Fatal("Events channel is still open after unsubscribing")
+	}
+}
+
+// eventAgent is an ApplicationAgent for a single endpoint, passing all received events to its inbox.
+type eventAgent struct {
+	syscallAgent
+	events chan agent.BundleEvent
+}
+
+func newEventAgent(endpoint bundle.EndpointID) *eventAgent {
+	ea := &eventAgent{
+		syscallAgent: syscallAgent{
+			endpoint: endpoint,
+			receiver: make(chan agent.Message),
+			sender:   make(chan agent.Message),
+		},
+		events: make(chan agent.BundleEvent, 16),
+	}
+
+	go func() {
+		for msg := range ea.receiver {
+			if em, ok := msg.(agent.EventMessage); ok {
+				ea.events <- em.Event
+			}
+		}
+	}()
+
+	return ea
+}
+
+func TestAgentManagerEvents(t *testing.T) {
+	registerGobTypes()
+
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := storage.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	c := &Core{
+		NodeId:     bundle.MustNewEndpointID("dtn://node/"),
+		store:      store,
+		claManager: cla.NewManager(),
+	}
+	c.agentManager = NewAgentManager(c)
+
+	ea := newEventAgent(bundle.MustNewEndpointID("dtn://node/monitor"))
+	c.agentManager.Register(ea)
+	ea.sender <- agent.EventSubscribeMessage{Sender: ea.endpoint}
+
+	b, err := bundle.Builder().
+		BundleCtrlFlags(0).
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("1h").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bp := NewBundlePackFromBundle(b, store)
+
+	// The subscription is handled asynchronously; emit events until the first one arrives.
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(2 * time.Second)
+
+	for {
+		select {
+		case <-ticker.C:
+			c.emitEvent(agent.BundleDelivered, bp)
+
+		case event := <-ea.events:
+			if event.Type != agent.BundleDelivered || event.Bundle != b.ID() {
+				t.Fatalf("Received unexpected event %v", event)
+			}
+			return
+
+		case <-timeout:
+			t.Fatal("Waiting for an EventMessage timed out")
+		}
+	}
+}
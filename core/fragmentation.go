@@ -0,0 +1,236 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+)
+
+// sendToCLA transmits a bundle to a ConvergenceSender.
+//
+// If the ConvergenceSender limits its bundle size by implementing the cla.MaxBundleSizer, larger bundles will be
+// fragmented proactively and each fragment will be sent individually. Bundles which must not be fragmented and
+// those whose fragmentation fails are sent as a whole.
+//
+// If a transmission was interrupted after parts of the bundle were acknowledged, as reported by a
+// cla.PartialTransmissionError, the remaining fragment will be created reactively. The next transmission of this
+// bundle to the same peer only sends the remaining fragments instead of the whole bundle.
+func (c *Core) sendToCLA(bndl *bundle.Bundle, node cla.ConvergenceSender) error {
+	key, keyOk := reactiveRemainderKey(bndl.ID(), node)
+
+	var bndls []bundle.Bundle
+	if remainder, ok := c.reactiveRemainders.Load(key); keyOk && ok {
+		bndls = remainder.([]bundle.Bundle)
+
+		log.WithFields(log.Fields{
+			"bundle":    bndl.ID(),
+			"cla":       node,
+			"fragments": len(bndls),
+		}).Info("Sending remaining fragments of an interrupted transmission")
+	} else if frags := c.proactiveFragments(bndl, node); frags != nil {
+		bndls = frags
+	} else {
+		bndls = []bundle.Bundle{*bndl}
+	}
+
+	for i := range bndls {
+		err := node.Send(&bndls[i])
+		observeClaSent(node, &bndls[i], err)
+		if err == nil {
+			continue
+		}
+
+		remainder, remains, partial := reactiveRemainder(bndls[i], err)
+		if partial && !remains {
+			continue
+		}
+
+		if keyOk && partial {
+			c.reactiveRemainders.Store(key, append([]bundle.Bundle{remainder}, bndls[i+1:]...))
+		} else if keyOk && i > 0 {
+			c.reactiveRemainders.Store(key, bndls[i:])
+		}
+		return err
+	}
+
+	if keyOk {
+		c.reactiveRemainders.Delete(key)
+	}
+	return nil
+}
+
+// reactiveRemainderKey identifies the remaining fragments of a bundle for a ConvergenceSender's peer. The boolean is
+// false if the peer is unknown.
+func reactiveRemainderKey(bid bundle.BundleID, node cla.ConvergenceSender) (string, bool) {
+	peer := node.GetPeerEndpointID()
+	if peer == (bundle.EndpointID{}) {
+		return "", false
+	}
+	return bid.String() + " " + peer.String(), true
+}
+
+// reactiveRemainder creates the untransmitted fragment of a bundle, if its transmission's error was a
+// cla.PartialTransmissionError. The first boolean reports if a fragment remains, the second one if the transmission
+// was partially successful, i.e., at least some payload was transmitted.
+func reactiveRemainder(bndl bundle.Bundle, err error) (remainder bundle.Bundle, remains, partial bool) {
+	var pte *cla.PartialTransmissionError
+	if !errors.As(err, &pte) {
+		return
+	}
+
+	logger := log.WithFields(log.Fields{
+		"bundle":       bndl.ID(),
+		"acknowledged": pte.Acknowledged,
+	})
+
+	remainder, remains, fragErr := bndl.ReactiveFragmentRemainder(int(pte.Acknowledged))
+	if fragErr != nil {
+		logger.WithError(fragErr).Info("Reactive fragmentation of interrupted transmission failed")
+		return
+	}
+
+	partial = true
+	if remains {
+		logger.WithField("remainder", remainder.ID()).Info("Created reactive fragment of interrupted transmission")
+	} else {
+		logger.Info("Interrupted transmission has already transmitted the whole payload")
+	}
+	return
+}
+
+// dropReactiveRemainders forgets all remaining fragments of a bundle.
+func (c *Core) dropReactiveRemainders(bid bundle.BundleID) {
+	prefix := bid.String() + " "
+	c.reactiveRemainders.Range(func(key, _ interface{}) bool {
+		if strings.HasPrefix(key.(string), prefix) {
+			c.reactiveRemainders.Delete(key)
+		}
+		return true
+	})
+}
+
+// proactiveFragments returns the fragments of a bundle for a ConvergenceSender or nil, if the bundle should be sent
+// unfragmented.
+func (c *Core) proactiveFragments(bndl *bundle.Bundle, node cla.ConvergenceSender) []bundle.Bundle {
+	sizer, ok := node.(cla.MaxBundleSizer)
+	if !ok {
+		return nil
+	}
+
+	maxSize := sizer.MaxBundleSize()
+	if maxSize <= 0 {
+		return nil
+	}
+
+	var buff bytes.Buffer
+	if err := bndl.MarshalCbor(&buff); err != nil || buff.Len() <= maxSize {
+		return nil
+	}
+
+	logger := log.WithFields(log.Fields{
+		"bundle":   bndl.ID(),
+		"cla":      node,
+		"size":     buff.Len(),
+		"max_size": maxSize,
+	})
+
+	if bndl.PrimaryBlock.BundleControlFlags.Has(bundle.MustNotFragmented) {
+		logger.Info("Bundle exceeds CLA's maximum bundle size, but must not be fragmented")
+		return nil
+	}
+
+	frags, err := bndl.Fragment(maxSize)
+	if err != nil {
+		logger.WithError(err).Warn("Proactive fragmentation failed, sending the bundle as a whole")
+		return nil
+	}
+
+	logger.WithField("fragments", len(frags)).Info("Bundle was fragmented proactively for CLA")
+	return frags
+}
+
+// reassemble a fragment for its local delivery.
+//
+// The fragment's BundlePack, which is shared among all fragments of the same bundle, will be marked as
+// ReassemblyPending until all fragments are present. Afterwards, the fragments will be replaced by the reassembled
+// bundle within the store. Its BundlePack will be returned, with the boolean indicating a successful reassembly.
+func (c *Core) reassemble(bp BundlePack) (BundlePack, bool) {
+	if !bp.HasConstraint(ReassemblyPending) {
+		bp.AddConstraint(ReassemblyPending)
+		_ = bp.Sync()
+	}
+
+	frags, err := c.storedFragments(bp)
+	if err != nil {
+		log.WithField("bundle", bp.ID()).WithError(err).Warn("Loading stored fragments errored")
+		return bp, false
+	}
+
+	if !bundle.IsBundleReassemblable(frags) {
+		log.WithFields(log.Fields{
+			"bundle":    bp.ID(),
+			"fragments": len(frags),
+		}).Info("Bundle's reassembly is pending, waiting for further fragments")
+		return bp, false
+	}
+
+	bndl, err := bundle.ReassembleFragments(frags)
+	if err != nil {
+		log.WithField("bundle", bp.ID()).WithError(err).Warn("Reassembling fragments errored")
+
+		c.bundleDeletion(bp, bundle.NoInformation)
+		return bp, false
+	}
+
+	if err := c.store.Delete(bp.Id); err != nil {
+		log.WithField("bundle", bp.ID()).WithError(err).Warn("Deleting stored fragments errored")
+	}
+
+	reassembled := NewBundlePackFromBundle(bndl, c.store)
+	reassembled.Receiver = bp.Receiver
+	reassembled.Timestamp = bp.Timestamp
+	for constraint := range bp.Constraints {
+		if constraint != ReassemblyPending {
+			reassembled.AddConstraint(constraint)
+		}
+	}
+	_ = reassembled.Sync()
+
+	log.WithFields(log.Fields{
+		"bundle":    reassembled.ID(),
+		"fragments": len(frags),
+	}).Info("Reassembled bundle from its fragments")
+
+	return reassembled, true
+}
+
+// storedFragments loads all stored fragments of a bundle.
+func (c *Core) storedFragments(bp BundlePack) ([]bundle.Bundle, error) {
+	bi, err := c.store.QueryId(bp.Id.Scrub())
+	if err != nil {
+		return nil, err
+	} else if !bi.Fragmented {
+		return nil, fmt.Errorf("stored bundle is not fragmented")
+	}
+
+	frags := make([]bundle.Bundle, 0, len(bi.Parts))
+	for _, part := range bi.Parts {
+		if frag, err := part.Load(); err != nil {
+			return nil, err
+		} else {
+			frags = append(frags, frag)
+		}
+	}
+
+	return frags, nil
+}
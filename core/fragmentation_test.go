@@ -0,0 +1,261 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+// mtuConvSender is a ConvergenceSender with a limited bundle size, storing all sent bundles.
+type mtuConvSender struct {
+	mtu       int
+	sentBndls []bundle.Bundle
+}
+
+func (m *mtuConvSender) Close()                              {}
+func (m *mtuConvSender) Start() (error, bool)                { return nil, false }
+func (m *mtuConvSender) Channel() chan cla.ConvergenceStatus { return nil }
+func (m *mtuConvSender) Address() string                     { return "mtu://" }
+func (m *mtuConvSender) IsPermanent() bool                   { return false }
+func (m *mtuConvSender) GetPeerEndpointID() bundle.EndpointID {
+	return bundle.MustNewEndpointID("dtn://peer/")
+}
+func (m *mtuConvSender) MaxBundleSize() int { return m.mtu }
+
+func (m *mtuConvSender) Send(bndl *bundle.Bundle) error {
+	m.sentBndls = append(m.sentBndls, *bndl)
+	return nil
+}
+
+func TestCoreSendToCLAFragmentation(t *testing.T) {
+	payload := make([]byte, 2048)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	tests := []struct {
+		name       string
+		mtu        int
+		flags      bundle.BundleControlFlags
+		fragmented bool
+	}{
+		{"unlimited", 0, 0, false},
+		{"large mtu", 4096, 0, false},
+		{"small mtu", 512, 0, true},
+		{"must not fragment", 512, bundle.MustNotFragmented, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := bundle.Builder().
+				Source("dtn://src/").
+				Destination("dtn://dst/").
+				BundleCtrlFlags(test.flags).
+				CreationTimestampNow().
+				Lifetime("1h").
+				HopCountBlock(64).
+				PayloadBlock(payload).
+				Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := &Core{}
+			node := &mtuConvSender{mtu: test.mtu}
+			if err := c.sendToCLA(&b, node); err != nil {
+				t.Fatal(err)
+			}
+
+			if !test.fragmented {
+				if l := len(node.sentBndls); l != 1 {
+					t.Fatalf("CLA received %d bundles instead of one", l)
+				} else if node.sentBndls[0].PrimaryBlock.BundleControlFlags.Has(bundle.IsFragment) {
+					t.Fatal("CLA received a fragment")
+				}
+				return
+			}
+
+			if len(node.sentBndls) < 2 {
+				t.Fatalf("CLA received %d bundles, expected fragments", len(node.sentBndls))
+			}
+			for _, frag := range node.sentBndls {
+				var buff bytes.Buffer
+				if err := frag.MarshalCbor(&buff); err != nil {
+					t.Fatal(err)
+				} else if buff.Len() > test.mtu {
+					t.Fatalf("Fragment of %d bytes exceeds MTU of %d bytes", buff.Len(), test.mtu)
+				}
+			}
+
+			if b2, err := bundle.ReassembleFragments(node.sentBndls); err != nil {
+				t.Fatal(err)
+			} else if pb, _ := b2.PayloadBlock(); !bytes.Equal(pb.Value.(*bundle.PayloadBlock).Data(), payload) {
+				t.Fatal("Reassembled payload differs")
+			}
+		})
+	}
+}
+
+// interruptingConvSender is a ConvergenceSender which interrupts its first transmission after some bytes.
+type interruptingConvSender struct {
+	mtuConvSender
+	interruptAfter int
+	prefix         []byte
+}
+
+func (i *interruptingConvSender) Send(bndl *bundle.Bundle) error {
+	if i.prefix != nil {
+		return i.mtuConvSender.Send(bndl)
+	}
+
+	var buff bytes.Buffer
+	if err := bndl.MarshalCbor(&buff); err != nil {
+		return err
+	}
+	i.prefix = buff.Bytes()[:i.interruptAfter]
+
+	return &cla.PartialTransmissionError{
+		Acknowledged: uint64(i.interruptAfter),
+		Err:          fmt.Errorf("connection reset"),
+	}
+}
+
+func TestCoreSendToCLAReactiveFragmentation(t *testing.T) {
+	payload := make([]byte, 2048)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	b, err := bundle.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("1h").
+		HopCountBlock(64).
+		PayloadBlock(payload).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Core{}
+	node := &interruptingConvSender{interruptAfter: 1024}
+
+	if err := c.sendToCLA(&b, node); err == nil {
+		t.Fatal("Interrupted transmission did not error")
+	}
+
+	if err := c.sendToCLA(&b, node); err != nil {
+		t.Fatal(err)
+	} else if l := len(node.sentBndls); l != 1 {
+		t.Fatalf("CLA received %d bundles instead of the remaining fragment", l)
+	} else if !node.sentBndls[0].PrimaryBlock.BundleControlFlags.Has(bundle.IsFragment) {
+		t.Fatal("CLA did not receive a fragment")
+	}
+
+	head, err := bundle.ReactiveFragment(node.prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b2, err := bundle.ReassembleFragments([]bundle.Bundle{head, node.sentBndls[0]}); err != nil {
+		t.Fatal(err)
+	} else if pb, _ := b2.PayloadBlock(); !bytes.Equal(pb.Value.(*bundle.PayloadBlock).Data(), payload) {
+		t.Fatal("Reassembled payload differs")
+	}
+
+	if err := c.sendToCLA(&b, node); err != nil {
+		t.Fatal(err)
+	} else if l := len(node.sentBndls); l != 2 {
+		t.Fatalf("CLA received %d bundles", l)
+	} else if node.sentBndls[1].PrimaryBlock.BundleControlFlags.Has(bundle.IsFragment) {
+		t.Fatal("Remaining fragment was sent twice instead of the whole bundle")
+	}
+}
+
+func TestCoreReassemble(t *testing.T) {
+	registerGobTypes()
+
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := storage.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	payload := make([]byte, 1024)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	b, err := bundle.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("1h").
+		PayloadBlock(payload).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frags, err := b.Fragment(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Core{store: store}
+	for i, frag := range frags {
+		bp := NewBundlePackFromBundle(frag, store)
+		bp.AddConstraint(DispatchPending)
+		_ = bp.Sync()
+
+		reassembledBp, reassembled := c.reassemble(bp)
+		if last := i == len(frags)-1; reassembled != last {
+			t.Fatalf("Fragment %d resulted in reassembly: %t", i, reassembled)
+		} else if !last {
+			if !bp.HasConstraint(ReassemblyPending) {
+				t.Fatalf("Fragment %d is not reassembly pending", i)
+			}
+			continue
+		}
+
+		if reassembledBp.HasConstraint(ReassemblyPending) {
+			t.Fatal("Reassembled bundle is still reassembly pending")
+		} else if !reassembledBp.HasConstraint(DispatchPending) {
+			t.Fatal("Reassembled bundle lost its constraints")
+		}
+
+		if id := reassembledBp.MustBundle().ID(); id != b.ID() {
+			t.Fatalf("Reassembled bundle's ID is %v, not %v", id, b.ID())
+		}
+	}
+
+	bi, err := store.QueryId(b.ID())
+	if err != nil {
+		t.Fatal(err)
+	} else if bi.Fragmented || len(bi.Parts) != 1 {
+		t.Fatalf("Store contains fragments: %t, %d parts", bi.Fragmented, len(bi.Parts))
+	}
+
+	if b2, err := bi.Parts[0].Load(); err != nil {
+		t.Fatal(err)
+	} else if pb, _ := b2.PayloadBlock(); !bytes.Equal(pb.Value.(*bundle.PayloadBlock).Data(), payload) {
+		t.Fatal("Stored payload differs")
+	}
+}
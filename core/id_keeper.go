@@ -5,72 +5,154 @@
 package core
 
 import (
+	"bytes"
+	"encoding/gob"
 	"sync"
 
+	log "github.com/sirupsen/logrus"
+
 	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/storage"
 )
 
-// idTuple is a tuple struct for looking up a bundle's ID - based on it's source
-// node and DTN time part of the creation timestamp.
-type idTuple struct {
-	source bundle.EndpointID
-	time   bundle.DtnTime
+// idKeeperStateName identifies the IdKeeper's counters within the store.
+const idKeeperStateName = "core/idkeeper"
+
+// idCounter is a source node's state of issued creation timestamps.
+type idCounter struct {
+	// Time is the latest DTN time of an issued creation timestamp.
+	Time bundle.DtnTime
+	// Seq is the latest sequence number issued for Time.
+	Seq uint64
+	// MaxSeq is the highest sequence number ever issued, independent of the DTN time.
+	MaxSeq uint64
+
+	// behind is set while creation timestamps are behind Time, to only log this once.
+	behind bool
 }
 
-// newIdTuple creates an idTuple based on the given bundle.
-func newIdTuple(bndl *bundle.Bundle) idTuple {
-	return idTuple{
-		source: bndl.PrimaryBlock.SourceNode,
-		time:   bndl.PrimaryBlock.CreationTimestamp.DtnTime(),
+// next returns the sequence number for a new creation timestamp of the given DTN time and updates the idCounter.
+//
+// Creation timestamps before the latest Time, e.g., due to a clock being set back or lacking time, continue with
+// MaxSeq. Thus, no earlier creation timestamp of this DTN time can be reissued.
+func (ic *idCounter) next(t bundle.DtnTime) uint64 {
+	switch {
+	case t > ic.Time:
+		ic.Time = t
+		ic.Seq = 0
+
+	case t == ic.Time:
+		ic.Seq++
+
+	default:
+		ic.MaxSeq++
+		return ic.MaxSeq
+	}
+
+	if ic.Seq > ic.MaxSeq {
+		ic.MaxSeq = ic.Seq
 	}
+	return ic.Seq
 }
 
 // IdKeeper keeps track of the creation timestamp's sequence number for
-// outbounding bundles.
+// outbounding bundles. A persistent IdKeeper stores its counters to not
+// reissue a creation timestamp after a restart.
 type IdKeeper struct {
-	data      map[idTuple]uint64
-	mutex     sync.Mutex
-	autoClean bool
+	data  map[string]*idCounter
+	mutex sync.Mutex
+	store storage.Store
 }
 
 // NewIdKeeper creates a new, empty IdKeeper.
 func NewIdKeeper() IdKeeper {
 	return IdKeeper{
-		data:      make(map[idTuple]uint64),
-		autoClean: true,
+		data: make(map[string]*idCounter),
+	}
+}
+
+// restore the counters from the store, which makes this IdKeeper persistent.
+// Afterwards, the counters are persisted after each update.
+func (idk *IdKeeper) restore(store storage.Store) error {
+	idk.mutex.Lock()
+	defer idk.mutex.Unlock()
+
+	idk.store = store
+
+	si, err := store.QueryState(idKeeperStateName)
+	if err == storage.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var data map[string]idCounter
+	if err := gob.NewDecoder(bytes.NewReader(si.Data)).Decode(&data); err != nil {
+		return err
+	}
+
+	now := bundle.DtnTimeNow()
+	for source, counter := range data {
+		counter := counter
+		idk.data[source] = &counter
+
+		if counter.Time > now {
+			counter.behind = true
+			log.WithFields(log.Fields{
+				"source":    source,
+				"now":       now,
+				"persisted": counter.Time,
+			}).Warn("Clock is behind the last persisted creation timestamp; sequence numbers will be continued")
+		}
 	}
+
+	log.WithField("sources", len(data)).Debug("IdKeeper restored its persisted counters")
+	return nil
 }
 
 // update updates the IdKeeper's state regarding this bundle and sets this
 // bundle's sequence number.
 func (idk *IdKeeper) update(bndl *bundle.Bundle) {
-	var tpl = newIdTuple(bndl)
+	source := bndl.PrimaryBlock.SourceNode.String()
+	t := bndl.PrimaryBlock.CreationTimestamp.DtnTime()
 
 	idk.mutex.Lock()
-	if state, ok := idk.data[tpl]; ok {
-		idk.data[tpl] = state + 1
-	} else {
-		idk.data[tpl] = 0
-	}
+	defer idk.mutex.Unlock()
 
-	bndl.PrimaryBlock.CreationTimestamp[1] = idk.data[tpl]
-	idk.mutex.Unlock()
+	if counter, ok := idk.data[source]; !ok {
+		idk.data[source] = &idCounter{Time: t}
+		bndl.PrimaryBlock.CreationTimestamp[1] = 0
+	} else {
+		if t < counter.Time && t != bundle.DtnTimeEpoch && !counter.behind {
+			log.WithFields(log.Fields{
+				"source": source,
+				"time":   t,
+				"latest": counter.Time,
+			}).Warn("Creation timestamp is behind a previous one; clock might have been set back")
+		}
+		counter.behind = t < counter.Time && t != bundle.DtnTimeEpoch
 
-	if idk.autoClean {
-		idk.clean()
+		bndl.PrimaryBlock.CreationTimestamp[1] = counter.next(t)
 	}
+
+	idk.persist()
 }
 
-// clean removes states which are older an hour and aren't the epoch time.
-func (idk *IdKeeper) clean() {
-	idk.mutex.Lock()
+// persist the counters to the store, if this IdKeeper is persistent. The mutex must be held.
+func (idk *IdKeeper) persist() {
+	if idk.store == nil {
+		return
+	}
 
-	var threshold = bundle.DtnTimeNow() - 60*60*24
+	data := make(map[string]idCounter, len(idk.data))
+	for source, counter := range idk.data {
+		data[source] = *counter
+	}
 
-	for tpl := range idk.data {
-		if tpl.time < threshold && tpl.time != bundle.DtnTimeEpoch {
-			delete(idk.data, tpl)
-		}
+	var buff bytes.Buffer
+	if err := gob.NewEncoder(&buff).Encode(data); err != nil {
+		log.WithError(err).Warn("Serializing IdKeeper's counters failed")
+	} else if err := idk.store.StoreState(idKeeperStateName, buff.Bytes()); err != nil {
+		log.WithError(err).Warn("Persisting IdKeeper's counters failed")
 	}
-	idk.mutex.Unlock()
 }
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/storage"
 )
 
 func TestIdKeeper(t *testing.T) {
@@ -50,3 +51,73 @@ func TestIdKeeper(t *testing.T) {
 		t.Errorf("Second bundle's sequence number is %d", seq)
 	}
 }
+
+// idKeeperBundle creates a bundle from dtn://src/ with the given creation timestamp's DTN time.
+func idKeeperBundle(t *testing.T, dtnTime bundle.DtnTime) bundle.Bundle {
+	bndl, err := bundle.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dest/").
+		CreationTimestampTime(dtnTime.Time()).
+		Lifetime("60s").
+		BundleAgeBlock(0, bundle.DeleteBundle).
+		PayloadBlock([]byte("hello world!")).
+		Build()
+	if err != nil {
+		t.Fatalf("Creating bundle failed: %v", err)
+	}
+	return bndl
+}
+
+func TestIdKeeperPersistent(t *testing.T) {
+	store := storage.NewMemoryStore()
+	defer store.Close()
+
+	now := bundle.DtnTimeNow()
+
+	tests := []struct {
+		restart bool
+		time    bundle.DtnTime
+		seq     uint64
+	}{
+		{false, now, 0},
+		{false, now, 1},
+		{false, now, 2},
+		// Restart within the same millisecond
+		{true, now, 3},
+		{false, now + 1, 0},
+		// Clock was set back, continue with the highest sequence number
+		{true, now, 4},
+		{false, now, 5},
+		{false, bundle.DtnTimeEpoch, 6},
+		// Clock caught up again
+		{false, now + 1, 1},
+		{true, now + 2, 0},
+	}
+
+	var keeper = NewIdKeeper()
+	if err := keeper.restore(store); err != nil {
+		t.Fatal(err)
+	}
+
+	issued := make(map[bundle.CreationTimestamp]bool)
+
+	for i, test := range tests {
+		if test.restart {
+			keeper = NewIdKeeper()
+			if err := keeper.restore(store); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		bndl := idKeeperBundle(t, test.time)
+		keeper.update(&bndl)
+
+		ts := bndl.PrimaryBlock.CreationTimestamp
+		if seq := ts.SequenceNumber(); seq != test.seq {
+			t.Fatalf("Test %d: sequence number is %d instead of %d", i, seq, test.seq)
+		} else if issued[ts] {
+			t.Fatalf("Test %d: creation timestamp %v was reissued", i, ts)
+		}
+		issued[ts] = true
+	}
+}
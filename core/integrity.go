@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/bundle"
+)
+
+// IntegrityConf configures the BPSec Block Integrity Blocks, attached to outgoing bundles created at this node.
+type IntegrityConf struct {
+	// Targets are the block type codes of the blocks to be signed, where 0 addresses the Primary Block. Blocks being
+	// altered in transit, e.g., the Hop Count Block, should not be selected. Defaults to the Payload Block.
+	Targets []uint64
+
+	// AdministrativeRecordsOnly limits signing to bundles containing an administrative record.
+	AdministrativeRecordsOnly bool `toml:"administrative-records-only"`
+
+	// HMACKey is a hex encoded key for the BIB-HMAC-SHA2 security context. If empty, the Core's ed25519 private key
+	// will be used within the ed25519 security context.
+	HMACKey string `toml:"hmac-key"`
+
+	// SHAVariant of the BIB-HMAC-SHA2 security context, one of 256, 384 or 512. Defaults to 384.
+	SHAVariant uint `toml:"sha-variant"`
+}
+
+// integrityPolicy is the parsed IntegrityConf, used by the Core.
+type integrityPolicy struct {
+	targets   []uint64
+	adminOnly bool
+	hmacKey   []byte
+	variant   bundle.HMACSHA2Variant
+}
+
+// newIntegrityPolicy from an IntegrityConf. The signPriv is required if no HMAC key was configured.
+func newIntegrityPolicy(conf IntegrityConf, signPriv ed25519.PrivateKey) (ip *integrityPolicy, err error) {
+	ip = &integrityPolicy{
+		targets:   conf.Targets,
+		adminOnly: conf.AdministrativeRecordsOnly,
+	}
+
+	if len(ip.targets) == 0 {
+		ip.targets = []uint64{bundle.ExtBlockTypePayloadBlock}
+	}
+
+	if conf.HMACKey != "" {
+		if ip.hmacKey, err = hex.DecodeString(conf.HMACKey); err != nil {
+			return nil, fmt.Errorf("decoding HMAC key errored: %v", err)
+		}
+	} else if signPriv == nil {
+		return nil, fmt.Errorf("integrity protection requires either an HMAC key or an ed25519 private key")
+	}
+
+	switch conf.SHAVariant {
+	case 256:
+		ip.variant = bundle.HMAC256
+	case 0, 384:
+		ip.variant = bundle.HMAC384
+	case 512:
+		ip.variant = bundle.HMAC512
+	default:
+		return nil, fmt.Errorf("unknown SHA variant %d", conf.SHAVariant)
+	}
+
+	return
+}
+
+// SetIntegrityConf configures the Block Integrity Blocks for outgoing bundles created at this node.
+//
+// If the Core was created with an ed25519 private key, a default configuration is already present. This signs the
+// Payload Block of administrative records, as the former SignatureBlock did.
+func (c *Core) SetIntegrityConf(conf IntegrityConf) error {
+	if ip, err := newIntegrityPolicy(conf, c.signPriv); err != nil {
+		return err
+	} else {
+		c.integrity = ip
+		return nil
+	}
+}
+
+// sendBundleAttachIntegrity attaches a BlockIntegrityBlock to outgoing bundles, if configured.
+func (c *Core) sendBundleAttachIntegrity(bndl *bundle.Bundle) {
+	if c.integrity == nil || (c.integrity.adminOnly && !bndl.IsAdministrativeRecord()) {
+		return
+	}
+
+	if _, err := bndl.ExtensionBlock(bundle.ExtBlockTypeBlockIntegrityBlock); err == nil {
+		log.WithField("bundle", bndl.ID()).Debug("Outgoing bundle already contains a Block Integrity Block")
+		return
+	}
+
+	var bib *bundle.BlockIntegrityBlock
+	var key []byte
+	if c.integrity.hmacKey != nil {
+		bib = bundle.NewBIBHMACSHA2(c.NodeId, c.integrity.variant, bundle.IntegrityScopeAll)
+		key = c.integrity.hmacKey
+	} else {
+		bib = bundle.NewBIBEd25519(c.NodeId, c.signPriv.Public().(ed25519.PublicKey), bundle.IntegrityScopeAll)
+		key = c.signPriv
+	}
+
+	if err := bndl.AddBlockIntegrityBlock(bib, c.integrity.targets, key); err != nil {
+		log.WithField("bundle", bndl.ID()).WithError(err).Error("Creating Block Integrity Block errored, proceeding without")
+		return
+	}
+
+	if cb, err := bndl.ExtensionBlock(bundle.ExtBlockTypeBlockIntegrityBlock); err == nil {
+		cb.SetCRCType(bundle.CRC32)
+	}
+
+	log.WithFields(log.Fields{
+		"bundle":  bndl.ID(),
+		"targets": bib.SecurityTargets,
+	}).Info("Attached Block Integrity Block to outgoing bundle")
+}
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+
+	"github.com/dtn7/dtn7-go/bundle"
+)
+
+func TestCoreSendBundleAttachIntegrity(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmacKey := []byte("secret")
+
+	tests := []struct {
+		name     string
+		conf     IntegrityConf
+		admin    bool
+		attached bool
+		key      []byte
+	}{
+		{"admin only, normal bundle", IntegrityConf{AdministrativeRecordsOnly: true}, false, false, nil},
+		{"admin only, admin record", IntegrityConf{AdministrativeRecordsOnly: true}, true, true, pub},
+		{"ed25519", IntegrityConf{Targets: []uint64{0, 1}}, false, true, pub},
+		{"hmac", IntegrityConf{HMACKey: hex.EncodeToString(hmacKey), SHAVariant: 512}, false, true, hmacKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Core{NodeId: bundle.MustNewEndpointID("dtn://src/"), signPriv: priv}
+			if err := c.SetIntegrityConf(test.conf); err != nil {
+				t.Fatal(err)
+			}
+
+			bldr := bundle.Builder().
+				Source("dtn://src/").
+				Destination("dtn://dst/").
+				CreationTimestampNow().
+				Lifetime("1h").
+				PayloadBlock([]byte("hello world"))
+			if test.admin {
+				bldr.BundleCtrlFlags(bundle.AdministrativeRecordPayload)
+			}
+
+			b, err := bldr.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c.sendBundleAttachIntegrity(&b)
+
+			bibBlock, bibErr := b.ExtensionBlock(bundle.ExtBlockTypeBlockIntegrityBlock)
+			if attached := bibErr == nil; attached != test.attached {
+				t.Fatalf("Block Integrity Block attached: %t, expected %t", attached, test.attached)
+			} else if !attached {
+				return
+			}
+
+			if err := bibBlock.Value.(*bundle.BlockIntegrityBlock).Verify(b, test.key); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestNewIntegrityPolicyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		conf IntegrityConf
+	}{
+		{"no key", IntegrityConf{}},
+		{"invalid hex", IntegrityConf{HMACKey: "nope"}},
+		{"invalid variant", IntegrityConf{HMACKey: "00", SHAVariant: 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := newIntegrityPolicy(test.conf, nil); err == nil {
+				t.Fatal("newIntegrityPolicy did not error")
+			}
+		})
+	}
+}
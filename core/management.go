@@ -0,0 +1,498 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+	"github.com/dtn7/dtn7-go/storage"
+	"github.com/gorilla/mux"
+)
+
+// ManagementAPI is a RESTful API to inspect and manage a Core's stored bundles, peers, and CLAs.
+//
+// Each request must be authenticated by the configured token, passed as a bearer token in the Authorization header.
+// All responses are JSON objects; failed requests result in a ManagementError. The following endpoints exist:
+//
+//   GET    /bundles          list all bundles as ManagementBundles, optionally filtered by the
+//                            id, source, destination, and constraint query parameters and
+//                            paginated by the offset and limit query parameters
+//   GET    /bundle?id=ID     show one bundle's ManagementBundle, including its properties
+//   DELETE /bundle?id=ID     delete a bundle from the store
+//   POST   /pending?id=ID    force a bundle back to forwarding pending, to be retried
+//   GET    /peers            list all peers as ManagementPeers
+//   GET    /clas             list all CLAs as ManagementClas
+//
+// A bundle's id is its string representation, e.g., "dtn://src/-648000000000-0". Constraints are named as by their
+// String method, e.g., "forwarding pending". Listed bundles are ordered by their id.
+type ManagementAPI struct {
+	core  *Core
+	token string
+}
+
+// ManagementError is the JSON response of a failed ManagementAPI request.
+type ManagementError struct {
+	Error string `json:"error"`
+}
+
+// ManagementBundle describes a stored bundle.
+type ManagementBundle struct {
+	Id          string            `json:"id"`
+	Source      string            `json:"source"`
+	Destination string            `json:"destination"`
+	Receiver    string            `json:"receiver"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Expires     time.Time         `json:"expires"`
+	Constraints []string          `json:"constraints"`
+	Pending     bool              `json:"pending"`
+	Local       bool              `json:"local"`
+	Fragmented  bool              `json:"fragmented"`
+	Parts       int               `json:"parts"`
+	Properties  map[string]string `json:"properties,omitempty"`
+}
+
+// ManagementPeer describes a peer, reachable by an active ConvergenceSender.
+type ManagementPeer struct {
+	Endpoint  string `json:"endpoint"`
+	Address   string `json:"address"`
+	Cla       string `json:"cla"`
+	Permanent bool   `json:"permanent"`
+}
+
+// ManagementCla describes a CLA, supervised by the Core's cla.Manager. Its Endpoint is either the peer's endpoint of
+// a sender or the own endpoint of a receiver.
+type ManagementCla struct {
+	Address   string `json:"address"`
+	Cla       string `json:"cla"`
+	Endpoint  string `json:"endpoint"`
+	Sender    bool   `json:"sender"`
+	Receiver  bool   `json:"receiver"`
+	Permanent bool   `json:"permanent"`
+	Active    bool   `json:"active"`
+	Retries   int    `json:"retries"`
+}
+
+// NewManagementAPI binds a ManagementAPI for a Core to the router. The token is required for authentication.
+func NewManagementAPI(c *Core, router *mux.Router, token string) (*ManagementAPI, error) {
+	if token == "" {
+		return nil, fmt.Errorf("management API requires a token")
+	}
+
+	ma := &ManagementAPI{
+		core:  c,
+		token: token,
+	}
+
+	router.Use(ma.authenticate)
+
+	router.HandleFunc("/bundles", ma.handleBundles).Methods(http.MethodGet)
+	router.HandleFunc("/bundle", ma.handleBundle).Methods(http.MethodGet)
+	router.HandleFunc("/bundle", ma.handleDelete).Methods(http.MethodDelete)
+	router.HandleFunc("/pending", ma.handlePending).Methods(http.MethodPost)
+	router.HandleFunc("/peers", ma.handlePeers).Methods(http.MethodGet)
+	router.HandleFunc("/clas", ma.handleClas).Methods(http.MethodGet)
+
+	return ma, nil
+}
+
+// authenticate is a middleware, rejecting all requests without the bearer token.
+func (ma *ManagementAPI) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const prefix = "Bearer "
+
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, prefix) ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, prefix)), []byte(ma.token)) != 1 {
+			log.WithField("remote", r.RemoteAddr).Warn("Rejecting unauthenticated management request")
+
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			ma.writeError(w, http.StatusUnauthorized, fmt.Errorf("unauthorized"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJson writes a JSON response.
+func (ma *ManagementAPI) writeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithError(err).Warn("Failed to write management response")
+	}
+}
+
+// writeError writes a ManagementError response.
+func (ma *ManagementAPI) writeError(w http.ResponseWriter, status int, err error) {
+	ma.writeJson(w, status, ManagementError{Error: err.Error()})
+}
+
+// managementBundle creates a ManagementBundle for a stored bundle from its BundleItem.
+func (ma *ManagementAPI) managementBundle(bi storage.BundleItem, properties bool) ManagementBundle {
+	bp := newBundlePackFromItem(bi, ma.core.store)
+
+	mb := ManagementBundle{
+		Id:          bi.Id,
+		Source:      bi.BId.SourceNode.String(),
+		Destination: ma.bundleDestination(bi),
+		Receiver:    bp.Receiver.String(),
+		Timestamp:   bp.Timestamp,
+		Expires:     bi.Expires,
+		Constraints: make([]string, 0, len(bp.Constraints)),
+		Pending:     bi.Pending,
+		Local:       bi.Local,
+		Fragmented:  bi.Fragmented,
+		Parts:       len(bi.Parts),
+	}
+
+	for constraint := range bp.Constraints {
+		mb.Constraints = append(mb.Constraints, constraint.String())
+	}
+
+	if properties {
+		mb.Properties = make(map[string]string)
+		for k, v := range bi.Properties {
+			mb.Properties[k] = fmt.Sprintf("%v", v)
+		}
+	}
+
+	return mb
+}
+
+// bundleDestination of a stored bundle. Only bundles stored without their destination within the BundleItem are read.
+func (ma *ManagementAPI) bundleDestination(bi storage.BundleItem) string {
+	if bi.Destination.EndpointType != nil {
+		return bi.Destination.String()
+	}
+
+	bp := newBundlePackFromItem(bi, ma.core.store)
+	if b, err := bp.Bundle(); err == nil {
+		return b.PrimaryBlock.Destination.String()
+	}
+	return ""
+}
+
+// bundleFilter selects stored bundles by the query parameters of GET /bundles.
+type bundleFilter struct {
+	id          string
+	source      string
+	destination string
+	constraint  string
+}
+
+// newBundleFilter from a request's query parameters. Endpoint IDs are normalized to their string representation.
+func newBundleFilter(query url.Values) (bf bundleFilter, err error) {
+	bf.id = query.Get("id")
+	bf.constraint = query.Get("constraint")
+
+	if bf.source, err = normalizeEndpoint(query.Get("source")); err != nil {
+		return
+	}
+	bf.destination, err = normalizeEndpoint(query.Get("destination"))
+	return
+}
+
+// normalizeEndpoint returns an endpoint ID's string representation or an empty string for an empty endpoint.
+func normalizeEndpoint(endpoint string) (string, error) {
+	if endpoint == "" {
+		return "", nil
+	}
+
+	eid, err := bundle.NewEndpointID(endpoint)
+	if err != nil {
+		return "", err
+	}
+	return eid.String(), nil
+}
+
+// matchBundle checks if a BundleItem passes a bundleFilter. Empty criteria match everything.
+func (ma *ManagementAPI) matchBundle(bf bundleFilter, bi storage.BundleItem) bool {
+	if bf.id != "" && bf.id != bi.Id {
+		return false
+	}
+
+	if bf.source != "" && bf.source != bi.BId.SourceNode.String() {
+		return false
+	}
+
+	if bf.constraint != "" {
+		found := false
+		for constraint := range newBundlePackFromItem(bi, ma.core.store).Constraints {
+			found = found || constraint.String() == bf.constraint
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return bf.destination == "" || bf.destination == ma.bundleDestination(bi)
+}
+
+// queryUint parses an optional non-negative integer query parameter.
+func queryUint(query url.Values, key string) (int, error) {
+	v := query.Get(key)
+	if v == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s parameter must be a non-negative integer", key)
+	}
+	return n, nil
+}
+
+// handleBundles lists all bundles, optionally filtered and paginated, called by GET /bundles. Only the returned
+// bundles' ManagementBundles are created.
+func (ma *ManagementAPI) handleBundles(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter, err := newBundleFilter(query)
+	if err != nil {
+		ma.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	offset, err := queryUint(query, "offset")
+	if err != nil {
+		ma.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit, err := queryUint(query, "limit")
+	if err != nil {
+		ma.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	bis, err := ma.core.store.QueryAll()
+	if err != nil {
+		ma.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sort.Slice(bis, func(i, j int) bool { return bis[i].Id < bis[j].Id })
+
+	mbs := make([]ManagementBundle, 0)
+	for _, bi := range bis {
+		if limit > 0 && len(mbs) == limit {
+			break
+		}
+
+		if !ma.matchBundle(filter, bi) {
+			continue
+		} else if offset > 0 {
+			offset--
+			continue
+		}
+
+		mbs = append(mbs, ma.managementBundle(bi, false))
+	}
+
+	ma.writeJson(w, http.StatusOK, mbs)
+}
+
+// parseBundleId parses the string representation of a scrubbed BundleID, e.g., "dtn://src/-648000000000-0".
+func parseBundleId(id string) (bid bundle.BundleID, err error) {
+	fields := strings.Split(id, "-")
+	if len(fields) < 3 {
+		err = fmt.Errorf("bundle id %s is malformed", id)
+		return
+	}
+
+	n := len(fields)
+	timestamp, timestampErr := strconv.ParseUint(fields[n-2], 10, 64)
+	sequence, sequenceErr := strconv.ParseUint(fields[n-1], 10, 64)
+	if timestampErr != nil || sequenceErr != nil {
+		err = fmt.Errorf("bundle id %s has a malformed creation timestamp", id)
+		return
+	}
+
+	bid.SourceNode, err = bundle.NewEndpointID(strings.Join(fields[:n-2], "-"))
+	bid.Timestamp = bundle.NewCreationTimestamp(bundle.DtnTime(timestamp), sequence)
+	return
+}
+
+// queryBundle fetches the BundleItem identified by the request's id parameter. On failure, an error response is
+// written and false is returned.
+func (ma *ManagementAPI) queryBundle(w http.ResponseWriter, r *http.Request) (storage.BundleItem, bool) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		ma.writeError(w, http.StatusBadRequest, fmt.Errorf("missing id parameter"))
+		return storage.BundleItem{}, false
+	}
+
+	bid, err := parseBundleId(id)
+	if err != nil {
+		ma.writeError(w, http.StatusBadRequest, err)
+		return storage.BundleItem{}, false
+	}
+
+	bi, err := ma.core.store.QueryId(bid)
+	if err == storage.ErrNotFound {
+		ma.writeError(w, http.StatusNotFound, fmt.Errorf("bundle %s is unknown", id))
+		return storage.BundleItem{}, false
+	} else if err != nil {
+		ma.writeError(w, http.StatusInternalServerError, err)
+		return storage.BundleItem{}, false
+	}
+
+	return bi, true
+}
+
+// handleBundle shows one bundle, called by GET /bundle.
+func (ma *ManagementAPI) handleBundle(w http.ResponseWriter, r *http.Request) {
+	if bi, ok := ma.queryBundle(w, r); ok {
+		ma.writeJson(w, http.StatusOK, ma.managementBundle(bi, true))
+	}
+}
+
+// handleDelete deletes one bundle, called by DELETE /bundle.
+func (ma *ManagementAPI) handleDelete(w http.ResponseWriter, r *http.Request) {
+	bi, ok := ma.queryBundle(w, r)
+	if !ok {
+		return
+	}
+
+	log.WithField("bundle", bi.Id).Info("Deleting bundle by management request")
+
+	if err := ma.core.manageDelete(bi.BId); err != nil {
+		ma.writeManageError(w, bi, err)
+		return
+	}
+
+	ma.writeJson(w, http.StatusOK, ManagementError{})
+}
+
+// handlePending forces one bundle back to forwarding pending, called by POST /pending.
+func (ma *ManagementAPI) handlePending(w http.ResponseWriter, r *http.Request) {
+	bi, ok := ma.queryBundle(w, r)
+	if !ok {
+		return
+	}
+
+	log.WithField("bundle", bi.Id).Info("Marking bundle as pending by management request")
+
+	if err := ma.core.managePending(bi.BId); err != nil {
+		ma.writeManageError(w, bi, err)
+		return
+	}
+
+	if pendingBi, err := ma.core.store.QueryId(bi.BId); err != nil {
+		ma.writeManageError(w, bi, err)
+	} else {
+		ma.writeJson(w, http.StatusOK, ma.managementBundle(pendingBi, false))
+	}
+}
+
+// writeManageError writes the error response of a failed modification of a bundle.
+func (ma *ManagementAPI) writeManageError(w http.ResponseWriter, bi storage.BundleItem, err error) {
+	if err == storage.ErrNotFound {
+		ma.writeError(w, http.StatusNotFound, fmt.Errorf("bundle %s is unknown", bi.Id))
+	} else {
+		ma.writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// manageDelete deletes a stored bundle for the ManagementAPI, serialized with the Core's other state changes.
+func (c *Core) manageDelete(bid bundle.BundleID) error {
+	c.bundlesMutex.Lock()
+	defer c.bundlesMutex.Unlock()
+
+	if !c.store.KnowsBundle(bid) {
+		return storage.ErrNotFound
+	}
+
+	bp := NewBundlePack(bid, c.store)
+	if _, err := bp.Bundle(); err == nil {
+		c.bundleDeletion(bp, bundle.NoInformation)
+	}
+
+	// Bundles for a local endpoint survive the bundleDeletion, which keeps the LocalEndpoint constraint.
+	if c.store.KnowsBundle(bid) {
+		return c.store.Delete(bid)
+	}
+	return nil
+}
+
+// managePending forces a stored bundle back to forwarding pending for the ManagementAPI, serialized with the Core's
+// other state changes. The pending bundles are checked afterwards.
+func (c *Core) managePending(bid bundle.BundleID) error {
+	c.bundlesMutex.Lock()
+
+	if !c.store.KnowsBundle(bid) {
+		c.bundlesMutex.Unlock()
+		return storage.ErrNotFound
+	}
+
+	bp := NewBundlePack(bid, c.store)
+	bp.RemoveConstraint(Contraindicated)
+	bp.AddConstraint(ForwardPending)
+	err := bp.Sync()
+
+	c.bundlesMutex.Unlock()
+
+	if err == nil {
+		c.triggerPendingBundles()
+	}
+	return err
+}
+
+// handlePeers lists all peers, called by GET /peers.
+func (ma *ManagementAPI) handlePeers(w http.ResponseWriter, _ *http.Request) {
+	senders := ma.core.claManager.Sender()
+
+	peers := make([]ManagementPeer, 0, len(senders))
+	for _, cs := range senders {
+		peers = append(peers, ManagementPeer{
+			Endpoint:  cs.GetPeerEndpointID().String(),
+			Address:   cs.Address(),
+			Cla:       convergenceType(cs),
+			Permanent: cs.IsPermanent(),
+		})
+	}
+
+	ma.writeJson(w, http.StatusOK, peers)
+}
+
+// handleClas lists all CLAs, called by GET /clas.
+func (ma *ManagementAPI) handleClas(w http.ResponseWriter, _ *http.Request) {
+	states := ma.core.claManager.States()
+
+	clas := make([]ManagementCla, 0, len(states))
+	for _, state := range states {
+		mc := ManagementCla{
+			Address:   state.Convergence.Address(),
+			Cla:       convergenceType(state.Convergence),
+			Permanent: state.Convergence.IsPermanent(),
+			Active:    state.Active,
+			Retries:   state.Retries,
+		}
+
+		if cr, ok := state.Convergence.(cla.ConvergenceReceiver); ok {
+			mc.Receiver = true
+			mc.Endpoint = cr.GetEndpointID().String()
+		}
+		if cs, ok := state.Convergence.(cla.ConvergenceSender); ok {
+			mc.Sender = true
+			mc.Endpoint = cs.GetPeerEndpointID().String()
+		}
+
+		clas = append(clas, mc)
+	}
+
+	ma.writeJson(w, http.StatusOK, clas)
+}
@@ -0,0 +1,215 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+	"github.com/dtn7/dtn7-go/storage"
+	"github.com/gorilla/mux"
+)
+
+func TestManagementAPI(t *testing.T) {
+	registerGobTypes()
+
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := storage.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	c := &Core{
+		NodeId:     bundle.MustNewEndpointID("dtn://node/"),
+		store:      store,
+		claManager: cla.NewManager(),
+	}
+	defer c.claManager.Close()
+
+	c.claManager.Register(&mtuConvSender{})
+
+	r := mux.NewRouter()
+	if _, err := NewManagementAPI(c, r.PathPrefix("/management").Subrouter(), "secret"); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	request := func(method, path string, query url.Values, token string, v interface{}) int {
+		req, err := http.NewRequest(method, server.URL+"/management"+path+"?"+query.Encode(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if v != nil && resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	var bids []string
+	for _, dst := range []string{"dtn://dst1/", "dtn://dst2/"} {
+		b, err := bundle.Builder().
+			Source("dtn://src/").
+			Destination(dst).
+			BundleCtrlFlags(0).
+			CreationTimestampNow().
+			Lifetime("1h").
+			PayloadBlock([]byte("hello world")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bp := NewBundlePackFromBundle(b, store)
+		bp.AddConstraint(Contraindicated)
+		_ = bp.Sync()
+
+		bids = append(bids, b.ID().String())
+	}
+
+	// Authentication
+	if status := request(http.MethodGet, "/bundles", nil, "wrong", nil); status != http.StatusUnauthorized {
+		t.Fatalf("Request with a wrong token resulted in status %d", status)
+	}
+
+	// Listing and filtering
+	var mbs []ManagementBundle
+	if status := request(http.MethodGet, "/bundles", nil, "secret", &mbs); status != http.StatusOK {
+		t.Fatalf("Listing bundles resulted in status %d", status)
+	} else if len(mbs) != 2 {
+		t.Fatalf("Listed %d bundles instead of two", len(mbs))
+	}
+
+	filter := url.Values{"destination": []string{"dtn://dst2/"}, "constraint": []string{"contraindicated"}}
+	if status := request(http.MethodGet, "/bundles", filter, "secret", &mbs); status != http.StatusOK {
+		t.Fatalf("Listing bundles resulted in status %d", status)
+	} else if len(mbs) != 1 || mbs[0].Id != bids[1] {
+		t.Fatalf("Filtered bundles are %v", mbs)
+	}
+
+	// Pagination, ordered by the bundles' ids
+	sortedBids := append([]string{}, bids...)
+	sort.Strings(sortedBids)
+
+	page := url.Values{"offset": []string{"1"}, "limit": []string{"1"}}
+	if status := request(http.MethodGet, "/bundles", page, "secret", &mbs); status != http.StatusOK {
+		t.Fatalf("Listing bundles resulted in status %d", status)
+	} else if len(mbs) != 1 || mbs[0].Id != sortedBids[1] {
+		t.Fatalf("Paginated bundles are %v", mbs)
+	}
+
+	if status := request(http.MethodGet, "/bundles", url.Values{"limit": []string{"-1"}}, "secret", nil); status != http.StatusBadRequest {
+		t.Fatalf("Listing bundles with a negative limit resulted in status %d", status)
+	}
+
+	// Single bundle
+	var mb ManagementBundle
+	if status := request(http.MethodGet, "/bundle", url.Values{"id": []string{bids[0]}}, "secret", &mb); status != http.StatusOK {
+		t.Fatalf("Showing a bundle resulted in status %d", status)
+	} else if mb.Destination != "dtn://dst1/" || len(mb.Properties) == 0 {
+		t.Fatalf("Bundle's metadata is %v", mb)
+	}
+
+	if status := request(http.MethodGet, "/bundle", url.Values{"id": []string{"dtn://src/-0-0"}}, "secret", nil); status != http.StatusNotFound {
+		t.Fatalf("Showing an unknown bundle resulted in status %d", status)
+	}
+
+	if status := request(http.MethodGet, "/bundle", url.Values{"id": []string{"unknown"}}, "secret", nil); status != http.StatusBadRequest {
+		t.Fatalf("Showing a malformed bundle id resulted in status %d", status)
+	}
+
+	// Pending
+	if status := request(http.MethodPost, "/pending", url.Values{"id": []string{bids[0]}}, "secret", &mb); status != http.StatusOK {
+		t.Fatalf("Marking a bundle as pending resulted in status %d", status)
+	} else if !mb.Pending || len(mb.Constraints) != 1 || mb.Constraints[0] != ForwardPending.String() {
+		t.Fatalf("Pending bundle's metadata is %v", mb)
+	}
+
+	// Deletion
+	if status := request(http.MethodDelete, "/bundle", url.Values{"id": []string{bids[0]}}, "secret", nil); status != http.StatusOK {
+		t.Fatalf("Deleting a bundle resulted in status %d", status)
+	} else if status := request(http.MethodGet, "/bundles", nil, "secret", &mbs); status != http.StatusOK {
+		t.Fatalf("Listing bundles resulted in status %d", status)
+	} else if len(mbs) != 1 || mbs[0].Id != bids[1] {
+		t.Fatalf("Bundles after deletion are %v", mbs)
+	}
+
+	if status := request(http.MethodPost, "/pending", url.Values{"id": []string{bids[0]}}, "secret", nil); status != http.StatusNotFound {
+		t.Fatalf("Marking a deleted bundle as pending resulted in status %d", status)
+	}
+
+	// Peers and CLAs
+	var peers []ManagementPeer
+	for i := 0; ; i++ {
+		if status := request(http.MethodGet, "/peers", nil, "secret", &peers); status != http.StatusOK {
+			t.Fatalf("Listing peers resulted in status %d", status)
+		} else if len(peers) == 1 {
+			break
+		} else if i == 10 {
+			t.Fatalf("Listed %d peers instead of one", len(peers))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if peers[0].Endpoint != "dtn://peer/" || peers[0].Cla != "core" {
+		t.Fatalf("Peer is %v", peers[0])
+	}
+
+	var clas []ManagementCla
+	if status := request(http.MethodGet, "/clas", nil, "secret", &clas); status != http.StatusOK {
+		t.Fatalf("Listing CLAs resulted in status %d", status)
+	} else if len(clas) != 1 || !clas[0].Active || !clas[0].Sender || clas[0].Receiver {
+		t.Fatalf("CLAs are %v", clas)
+	}
+}
+
+func TestParseBundleId(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"dtn://src/-648000000000-0", true},
+		{"dtn://my-node/-648000000000-23", true},
+		{"ipn:23.42-0-1", true},
+		{"dtn://src/-648000000000", false},
+		{"dtn://src/-foo-0", false},
+		{"invalid-648000000000-0", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.id, func(t *testing.T) {
+			bid, err := parseBundleId(test.id)
+			if (err == nil) != test.valid {
+				t.Fatalf("Parsing resulted in %v", err)
+			} else if test.valid && bid.String() != test.id {
+				t.Fatalf("Parsed bundle id %v differs", bid)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+	"github.com/dtn7/dtn7-go/metrics"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+var (
+	metricBundlesReceived = metrics.NewCounter(
+		"dtn7_bundles_received_total", "Bundles received and processed by the core.")
+	metricBundlesForwarded = metrics.NewCounter(
+		"dtn7_bundles_forwarded_total", "Bundles forwarded to at least one CLA.")
+	metricBundlesDelivered = metrics.NewCounter(
+		"dtn7_bundles_delivered_total", "Bundles delivered to a local application agent.")
+	metricBundlesDeleted = metrics.NewCounter(
+		"dtn7_bundles_deleted_total", "Bundles marked for deletion, by their status report reason.", "reason")
+
+	metricClaSentBundles = metrics.NewCounter(
+		"dtn7_cla_sent_bundles_total", "Bundles, including fragments, sent by a CLA.", "cla", "address")
+	metricClaSentBytes = metrics.NewCounter(
+		"dtn7_cla_sent_bytes_total", "Bytes of serialized bundles sent by a CLA.", "cla", "address")
+	metricClaSendFailures = metrics.NewCounter(
+		"dtn7_cla_send_failures_total", "Failed bundle transmissions of a CLA.", "cla", "address")
+	metricClaReceivedBundles = metrics.NewCounter(
+		"dtn7_cla_received_bundles_total", "Bundles received by a CLA.", "cla", "address")
+	metricClaReceivedBytes = metrics.NewCounter(
+		"dtn7_cla_received_bytes_total", "Bytes of serialized bundles received by a CLA.", "cla", "address")
+
+	metricRoutingDecisions = metrics.NewCounter(
+		"dtn7_routing_decisions_total", "Decisions of the routing algorithm.", "algorithm", "decision")
+
+	metricCronJobDuration = metrics.NewSummary(
+		"dtn7_cron_job_duration_seconds", "Execution time of the core's cron jobs.", "job")
+)
+
+// Decisions for the dtn7_routing_decisions_total metric.
+const (
+	// routingDirect is a direct delivery to the bundle's destination, without consulting the routing algorithm.
+	routingDirect = "direct"
+	// routingForward is the routing algorithm's selection of at least one CLA.
+	routingForward = "forward"
+	// routingNone is the routing algorithm's selection of no CLA.
+	routingNone = "none"
+	// routingDenied is a denied dispatching by the routing algorithm.
+	routingDenied = "denied"
+)
+
+// Names of the per-Core gauges, which are registered by NewCore and unregistered by Close.
+const (
+	metricStorePendingName         = "dtn7_store_bundles_pending"
+	metricStoreContraindicatedName = "dtn7_store_bundles_contraindicated"
+)
+
+func init() {
+	for _, c := range []metrics.Collector{
+		metricBundlesReceived, metricBundlesForwarded, metricBundlesDelivered, metricBundlesDeleted,
+		metricClaSentBundles, metricClaSentBytes, metricClaSendFailures,
+		metricClaReceivedBundles, metricClaReceivedBytes,
+		metricRoutingDecisions, metricCronJobDuration,
+	} {
+		metrics.Register(c)
+	}
+}
+
+// registerMetrics registers the gauges for this Core's store.
+func (c *Core) registerMetrics() {
+	metrics.Register(metrics.NewGaugeFunc(metricStorePendingName, "Pending bundles within the store.",
+		func() float64 {
+			bis, _ := c.store.QueryPending()
+			return float64(len(bis))
+		}))
+
+	metrics.Register(metrics.NewGaugeFunc(metricStoreContraindicatedName, "Contraindicated bundles within the store.",
+		func() float64 {
+			bis, _ := c.store.QueryAll()
+
+			var n int
+			for _, bi := range bis {
+				if constraints, ok := bi.Properties["bundlepack/constraints"].(map[Constraint]bool); ok && constraints[Contraindicated] {
+					n++
+				}
+			}
+			return float64(n)
+		}))
+}
+
+// unregisterMetrics removes the gauges registered by registerMetrics.
+func (c *Core) unregisterMetrics() {
+	metrics.Unregister(metricStorePendingName)
+	metrics.Unregister(metricStoreContraindicatedName)
+}
+
+// routingName identifies the Core's routing algorithm for the dtn7_routing_decisions_total metric.
+func (c *Core) routingName() string {
+	if c.routingConf.Algorithm != "" {
+		return c.routingConf.Algorithm
+	}
+
+	routingType := reflect.TypeOf(c.routing)
+	if routingType != nil && routingType.Kind() == reflect.Ptr {
+		routingType = routingType.Elem()
+	}
+	return fmt.Sprint(routingType)
+}
+
+// observeClaSent updates the CLA metrics for a sent bundle or its failure.
+func observeClaSent(node cla.ConvergenceSender, bndl *bundle.Bundle, err error) {
+	claType, address := convergenceType(node), node.Address()
+
+	if err != nil {
+		metricClaSendFailures.Inc(claType, address)
+		return
+	}
+
+	metricClaSentBundles.Inc(claType, address)
+	metricClaSentBytes.Add(float64(storage.BundleSize(*bndl)), claType, address)
+}
+
+// observeClaReceived updates the CLA metrics for a received bundle.
+func observeClaReceived(conv cla.Convergence, bndl *bundle.Bundle) {
+	claType, address := convergenceType(conv), conv.Address()
+
+	metricClaReceivedBundles.Inc(claType, address)
+	metricClaReceivedBytes.Add(float64(storage.BundleSize(*bndl)), claType, address)
+}
@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/metrics"
+)
+
+func TestCoreMetrics(t *testing.T) {
+	b, err := bundle.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("1h").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Core{}
+	if err := c.sendToCLA(&b, &mtuConvSender{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buff strings.Builder
+	if err := metrics.DefaultRegistry.Collect(&buff); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`dtn7_cla_sent_bundles_total{cla="core",address="mtu://"} `,
+		`dtn7_cla_sent_bytes_total{cla="core",address="mtu://"} `,
+		"# TYPE dtn7_bundles_deleted_total counter",
+		"# TYPE dtn7_cron_job_duration_seconds summary",
+	}
+	for _, e := range expected {
+		if !strings.Contains(buff.String(), e) {
+			t.Fatalf("Metrics do not contain %q:\n%s", e, buff.String())
+		}
+	}
+}
@@ -6,43 +6,29 @@
 package core
 
 import (
-	"sync"
-
 	log "github.com/sirupsen/logrus"
 
+	"github.com/dtn7/dtn7-go/agent"
 	"github.com/dtn7/dtn7-go/bundle"
 	"github.com/dtn7/dtn7-go/cla"
 )
 
 // SendBundle transmits an outbounding bundle.
 func (c *Core) SendBundle(bndl *bundle.Bundle) {
-	if c.signPriv != nil && bndl.IsAdministrativeRecord() {
-		c.sendBundleAttachSignature(bndl)
-	}
-	bp := NewBundlePackFromBundle(*bndl, c.store)
+	// The sequence number must be set before the bundle is signed or stored, because both depend on its ID.
+	c.idKeeper.update(bndl)
 
-	c.routing.NotifyIncoming(bp)
-	c.transmit(bp)
-}
-
-// sendBundleAttachSignature attaches a SignatureBlock to outgoing Administrative Records, if configured.
-func (c *Core) sendBundleAttachSignature(bndl *bundle.Bundle) {
-	if c.signPriv == nil || !bndl.IsAdministrativeRecord() {
-		return
-	}
+	c.sendBundleAttachIntegrity(bndl)
+	c.sendBundleEncrypt(bndl)
 
-	sb, sbErr := bundle.NewSignatureBlock(*bndl, c.signPriv)
-	if sbErr != nil {
-		log.WithField("bundle", bndl.ID()).WithError(sbErr).Error("Creating signature errored, proceeding without")
+	bp := NewBundlePackFromBundle(*bndl, c.store)
+	if !c.store.KnowsBundle(bp.Id) {
+		log.WithField("bundle", bp.ID()).Warn("Outgoing bundle was refused by the store")
 		return
 	}
 
-	cb := bundle.NewCanonicalBlock(0, bundle.ReplicateBlock|bundle.DeleteBundle, sb)
-	cb.SetCRCType(bundle.CRC32)
-
-	bndl.AddExtensionBlock(cb)
-
-	log.WithField("bundle", bndl.ID()).Info("Attached signature to outgoing bundle")
+	c.routing.NotifyIncoming(bp)
+	c.transmit(bp)
 }
 
 // transmit starts the transmission of an outbounding bundle pack. Therefore
@@ -52,8 +38,6 @@ func (c *Core) transmit(bp BundlePack) {
 		"bundle": bp.ID(),
 	}).Info("Transmission of bundle requested")
 
-	c.idKeeper.update(bp.MustBundle())
-
 	bp.AddConstraint(DispatchPending)
 	_ = bp.Sync()
 
@@ -82,6 +66,11 @@ func (c *Core) receive(bp BundlePack) {
 			"bundle": bp.ID(),
 		}).Debug("Received bundle's ID is already known.")
 
+		// Further fragments of a bundle share the BundlePack with the first one.
+		if bp.HasConstraint(ReassemblyPending) && bp.MustBundle().PrimaryBlock.BundleControlFlags.Has(bundle.IsFragment) {
+			c.localDelivery(bp)
+		}
+
 		// bundleDeletion is _not_ called because this would delete the already
 		// stored BundlePack.
 		return
@@ -91,6 +80,8 @@ func (c *Core) receive(bp BundlePack) {
 		"bundle": bp.ID(),
 	}).Info("Processing new received bundle")
 
+	metricBundlesReceived.Inc()
+
 	bp.AddConstraint(DispatchPending)
 	_ = bp.Sync()
 
@@ -144,6 +135,11 @@ func (c *Core) receive(bp BundlePack) {
 		}
 	}
 
+	if ok, reason := c.checkIntegrity(bp); !ok {
+		c.bundleDeletion(bp, reason)
+		return
+	}
+
 	c.routing.NotifyIncoming(bp)
 
 	c.dispatching(bp)
@@ -161,6 +157,7 @@ func (c *Core) dispatching(bp BundlePack) {
 			"routing": c.routing,
 		}).Info("Routing Algorithm has not allowed dispatching of the bundle")
 
+		metricRoutingDecisions.Inc(c.routingName(), routingDenied)
 		return
 	}
 
@@ -180,12 +177,47 @@ func (c *Core) dispatching(bp BundlePack) {
 	}
 }
 
-// forward forwards a bundle pack's bundle to another node.
+// forward forwards a bundle pack's bundle to another node. The bundle is queued for
+// each ConvergenceSender of the routing decision and sent by the transmissionQueues'
+// workers. Thus, forward does not wait for the transmissions.
 func (c *Core) forward(bp BundlePack) {
 	log.WithFields(log.Fields{
 		"bundle": bp.ID(),
 	}).Printf("Bundle will be forwarded")
 
+	if !c.prepareForwarding(bp) {
+		return
+	}
+
+	var nodes []cla.ConvergenceSender
+	var deleteAfterwards = true
+
+	// Try a direct delivery or consult the RoutingAlgorithm otherwise.
+	nodes = c.senderForDestination(bp.MustBundle().PrimaryBlock.Destination)
+	if nodes != nil {
+		metricRoutingDecisions.Inc(c.routingName(), routingDirect)
+	} else if nodes, deleteAfterwards = c.routing.SenderForBundle(bp); len(nodes) > 0 {
+		metricRoutingDecisions.Inc(c.routingName(), routingForward)
+	} else {
+		metricRoutingDecisions.Inc(c.routingName(), routingNone)
+	}
+
+	if len(nodes) == 0 {
+		log.WithFields(log.Fields{
+			"bundle": bp.ID(),
+		}).Info("Failed to forward bundle to any CLA")
+
+		c.resetHopCount(bp)
+		c.bundleContraindicated(bp)
+		return
+	}
+
+	c.enqueueTransmission(bp, nodes, deleteAfterwards)
+}
+
+// prepareForwarding updates a bundle's constraints and its Hop Count and Previous Node blocks before its transmission.
+// False is returned if the bundle was deleted instead, e.g., because its hop limit or lifetime was exceeded.
+func (c *Core) prepareForwarding(bp BundlePack) bool {
 	bp.AddConstraint(ForwardPending)
 	bp.RemoveConstraint(DispatchPending)
 	_ = bp.Sync()
@@ -207,29 +239,18 @@ func (c *Core) forward(bp BundlePack) {
 			}).Info("Bundle contains an exceeded hop count block")
 
 			c.bundleDeletion(bp, bundle.HopLimitExceeded)
-			return
+			return false
 		}
 	}
 
-	if bp.MustBundle().PrimaryBlock.IsLifetimeExceeded() {
+	if bp.IsLifetimeExceeded() {
 		log.WithFields(log.Fields{
-			"bundle":        bp.ID(),
-			"primary_block": bp.MustBundle().PrimaryBlock,
-		}).Warn("Bundle's primary block's lifetime is exceeded")
+			"bundle":     bp.ID(),
+			"expiration": bp.ExpirationTime(),
+		}).Warn("Bundle's lifetime is exceeded")
 
 		c.bundleDeletion(bp, bundle.LifetimeExpired)
-		return
-	}
-
-	if age, err := bp.UpdateBundleAge(); err == nil {
-		if age >= bp.MustBundle().PrimaryBlock.Lifetime {
-			log.WithFields(log.Fields{
-				"bundle": bp.ID(),
-			}).Warn("Bundle's lifetime is expired")
-
-			c.bundleDeletion(bp, bundle.LifetimeExpired)
-			return
-		}
+		return false
 	}
 
 	if pnBlock, err := bp.MustBundle().ExtensionBlock(bundle.ExtBlockTypePreviousNodeBlock); err == nil {
@@ -248,69 +269,93 @@ func (c *Core) forward(bp BundlePack) {
 			0, 0, bundle.NewPreviousNodeBlock(c.NodeId)))
 	}
 
-	var nodes []cla.ConvergenceSender
-	var deleteAfterwards = true
+	return true
+}
 
-	// Try a direct delivery or consult the RoutingAlgorithm otherwise.
-	nodes = c.senderForDestination(bp.MustBundle().PrimaryBlock.Destination)
-	if nodes == nil {
-		nodes, deleteAfterwards = c.routing.SenderForBundle(bp)
+// enqueueTransmission of a prepared bundle to the ConvergenceSenders of its routing decision.
+func (c *Core) enqueueTransmission(bp BundlePack, nodes []cla.ConvergenceSender, deleteAfterwards bool) {
+	tr := &transmission{bp: bp, deleteAfterwards: deleteAfterwards}
+	if !c.transmissions.enqueue(tr, nodes) {
+		log.WithFields(log.Fields{
+			"bundle": bp.ID(),
+		}).Debug("Bundle is already being transmitted")
+
+		c.resetHopCount(bp)
+		return
 	}
 
-	var bundleSent = false
+	log.WithFields(log.Fields{
+		"bundle": bp.ID(),
+		"clas":   len(nodes),
+	}).Debug("Bundle was queued for transmission")
+}
 
-	var wg sync.WaitGroup
-	var once sync.Once
+// sendTransmission sends a queued bundle to one of its selected ConvergenceSenders and reports if this succeeded.
+func (c *Core) sendTransmission(tr *transmission, node cla.ConvergenceSender) bool {
+	if !c.store.KnowsBundle(tr.bp.Id) {
+		log.WithFields(log.Fields{
+			"bundle": tr.bp.ID(),
+			"cla":    node,
+		}).Info("Queued bundle was deleted before its transmission")
+		return false
+	}
 
-	wg.Add(len(nodes))
+	bndl, err := tr.bundle()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bundle": tr.bp.ID(),
+			"cla":    node,
+			"error":  err,
+		}).Warn("Preparing bundle for its transmission failed")
+		return false
+	}
 
-	for _, node := range nodes {
-		go func(node cla.ConvergenceSender) {
-			log.WithFields(log.Fields{
-				"bundle": bp.ID(),
-				"cla":    node,
-			}).Info("Sending bundle to a CLA (ConvergenceSender)")
+	log.WithFields(log.Fields{
+		"bundle": tr.bp.ID(),
+		"cla":    node,
+	}).Info("Sending bundle to a CLA (ConvergenceSender)")
 
-			if err := node.Send(bp.MustBundle()); err != nil {
-				log.WithFields(log.Fields{
-					"bundle": bp.ID(),
-					"cla":    node,
-					"error":  err,
-				}).Warn("Sending bundle failed")
+	if err := c.sendToCLA(&bndl, node); err != nil {
+		log.WithFields(log.Fields{
+			"bundle": tr.bp.ID(),
+			"cla":    node,
+			"error":  err,
+		}).Warn("Sending bundle failed")
 
-				c.routing.ReportFailure(bp, node)
-			} else {
-				log.WithFields(log.Fields{
-					"bundle": bp.ID(),
-					"cla":    node,
-				}).Printf("Sending bundle succeeded")
+		c.routing.ReportFailure(tr.bp, node)
+		return false
+	}
 
-				once.Do(func() { bundleSent = true })
-			}
+	log.WithFields(log.Fields{
+		"bundle": tr.bp.ID(),
+		"cla":    node,
+	}).Printf("Sending bundle succeeded")
 
-			wg.Done()
-		}(node)
-	}
+	c.emitClaEvent(agent.BundleForwarded, &bndl, node, node.GetPeerEndpointID())
+	return true
+}
 
-	wg.Wait()
+// forwarded completes a bundle's forwarding after its transmissions to all selected ConvergenceSenders have finished.
+func (c *Core) forwarded(tr *transmission) {
+	bp := tr.bp
 
-	if hcBlock, err := bp.MustBundle().ExtensionBlock(bundle.ExtBlockTypeHopCountBlock); err == nil {
-		hc := hcBlock.Value.(*bundle.HopCountBlock)
-		hc.Decrement()
-		hcBlock.Value = hc
+	c.resetHopCount(bp)
 
+	if !c.store.KnowsBundle(bp.Id) {
 		log.WithFields(log.Fields{
-			"bundle":    bp.ID(),
-			"hop_count": hc,
-		}).Debug("Bundle's hop count block was reset")
+			"bundle": bp.ID(),
+		}).Debug("Bundle was deleted during its transmission")
+		return
 	}
 
-	if bundleSent {
+	if tr.sent {
+		metricBundlesForwarded.Inc()
+
 		if bp.MustBundle().PrimaryBlock.BundleControlFlags.Has(bundle.StatusRequestForward) {
 			c.SendStatusReport(bp, bundle.ForwardedBundle, bundle.NoInformation)
 		}
 
-		if deleteAfterwards {
+		if tr.deleteAfterwards {
 			bp.PurgeConstraints()
 			_ = bp.Sync()
 		} else if c.InspectAllBundles && bp.MustBundle().IsAdministrativeRecord() {
@@ -327,6 +372,20 @@ func (c *Core) forward(bp BundlePack) {
 	}
 }
 
+// resetHopCount decrements the Hop Count block, which was incremented for the bundle's transmission.
+func (c *Core) resetHopCount(bp BundlePack) {
+	if hcBlock, err := bp.MustBundle().ExtensionBlock(bundle.ExtBlockTypeHopCountBlock); err == nil {
+		hc := hcBlock.Value.(*bundle.HopCountBlock)
+		hc.Decrement()
+		hcBlock.Value = hc
+
+		log.WithFields(log.Fields{
+			"bundle":    bp.ID(),
+			"hop_count": hc,
+		}).Debug("Bundle's hop count block was reset")
+	}
+}
+
 // checkAdministrativeRecord checks administrative records. If this method
 // returns false, an error occured.
 func (c *Core) checkAdministrativeRecord(bp BundlePack) bool {
@@ -443,32 +502,38 @@ func (c *Core) inspectStatusReport(bp BundlePack, ar bundle.AdministrativeRecord
 }
 
 func (c *Core) localDelivery(bp BundlePack) {
-	// TODO: check fragmentation
-
 	log.WithFields(log.Fields{
 		"bundle": bp.ID(),
 	}).Info("Received bundle for local delivery")
 
-	if bp.MustBundle().IsAdministrativeRecord() {
-		if !c.checkAdministrativeRecord(bp) {
-			c.bundleDeletion(bp, bundle.NoInformation)
+	var reassembled bool
+	if bp.MustBundle().PrimaryBlock.BundleControlFlags.Has(bundle.IsFragment) {
+		if bp, reassembled = c.reassemble(bp); !reassembled {
 			return
 		}
 	}
 
-	bp.AddConstraint(LocalEndpoint)
-	_ = bp.Sync()
+	if decrypted, err := c.localDeliveryDecrypt(bp); err != nil {
+		log.WithField("bundle", bp.ID()).WithError(err).Warn("Decrypting bundle for local delivery failed")
 
-	if err := c.agentManager.Deliver(bp); err != nil {
-		log.WithField("bundle", bp.ID()).WithError(err).Warn("Delivering local bundle errored")
+		c.bundleDeletion(bp, bundle.FailedSecurityOperation)
+		return
+	} else if decrypted || reassembled {
+		// Encrypted Block Integrity Blocks and those of fragments could not be verified on reception.
+		if ok, reason := c.checkIntegrity(bp); !ok {
+			c.bundleDeletion(bp, reason)
+			return
+		}
 	}
 
-	if bp.MustBundle().PrimaryBlock.BundleControlFlags.Has(bundle.StatusRequestDelivery) {
-		c.SendStatusReport(bp, bundle.DeliveredBundle, bundle.NoInformation)
+	if bp.MustBundle().IsAdministrativeRecord() {
+		if !c.checkAdministrativeRecord(bp) {
+			c.bundleDeletion(bp, bundle.NoInformation)
+			return
+		}
 	}
 
-	bp.PurgeConstraints()
-	_ = bp.Sync()
+	c.deliverToAgent(bp)
 }
 
 func (c *Core) bundleContraindicated(bp BundlePack) {
@@ -478,16 +543,28 @@ func (c *Core) bundleContraindicated(bp BundlePack) {
 
 	bp.AddConstraint(Contraindicated)
 	_ = bp.Sync()
+
+	c.emitEvent(agent.BundleContraindicated, bp)
 }
 
+// bundleDeletion deletes a bundle and sends a requested status report. The status reports of bundles evicted due to
+// depleted storage are deferred until the store's eviction has finished, compare evictBundle.
 func (c *Core) bundleDeletion(bp BundlePack, reason bundle.StatusReportReason) {
-	if bp.MustBundle().PrimaryBlock.BundleControlFlags.Has(bundle.StatusRequestDeletion) {
+	if reason != bundle.DepletedStorage && bp.MustBundle().PrimaryBlock.BundleControlFlags.Has(bundle.StatusRequestDeletion) {
 		c.SendStatusReport(bp, bundle.DeletedBundle, reason)
 	}
 
 	bp.PurgeConstraints()
 	_ = bp.Sync()
 
+	c.dropReactiveRemainders(bp.Id)
+
+	metricBundlesDeleted.Inc(reason.String())
+
+	event := newBundleEvent(agent.BundleDeleted, bp.MustBundle())
+	event.Reason = reason
+	c.events.publish(event)
+
 	log.WithFields(log.Fields{
 		"bundle": bp.ID(),
 	}).Info("Bundle was marked for deletion")
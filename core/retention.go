@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/agent"
+	"github.com/dtn7/dtn7-go/bundle"
+)
+
+// deliverToAgent passes a bundle to the ApplicationAgent registered for its destination.
+//
+// If no ApplicationAgent is currently registered for this endpoint, the bundle will be retained in the store by its
+// LocalEndpoint constraint. The retained bundle will be delivered after an ApplicationAgent has registered for its
+// endpoint, as checked by deliverRetained, or deleted after its expiration. The returned boolean indicates delivery.
+func (c *Core) deliverToAgent(bp BundlePack) bool {
+	bp.AddConstraint(LocalEndpoint)
+	_ = bp.Sync()
+
+	if !c.agentManager.HasEndpoint(bp.MustBundle().PrimaryBlock.Destination) {
+		log.WithFields(log.Fields{
+			"bundle":      bp.ID(),
+			"destination": bp.MustBundle().PrimaryBlock.Destination,
+		}).Info("No application is registered for the bundle's destination, retaining bundle")
+
+		bp.PurgeConstraints()
+		_ = bp.Sync()
+		return false
+	}
+
+	if err := c.agentManager.Deliver(bp); err != nil {
+		log.WithField("bundle", bp.ID()).WithError(err).Warn("Delivering local bundle errored")
+	} else {
+		metricBundlesDelivered.Inc()
+		c.emitEvent(agent.BundleDelivered, bp)
+	}
+
+	if bp.MustBundle().PrimaryBlock.BundleControlFlags.Has(bundle.StatusRequestDelivery) {
+		c.SendStatusReport(bp, bundle.DeliveredBundle, bundle.NoInformation)
+	}
+
+	// The AgentManager removes the LocalEndpoint constraint. A retained bundle without further constraints was
+	// already deleted from the store by doing so.
+	if bp.HasConstraints() {
+		bp.PurgeConstraints()
+		_ = bp.Sync()
+	}
+	return true
+}
+
+// deliverRetained delivers all retained bundles, whose endpoint has been registered in the meantime, in the order of
+// their reception.
+func (c *Core) deliverRetained() {
+	c.retainedMutex.Lock()
+	defer c.retainedMutex.Unlock()
+
+	bis, err := c.store.QueryLocal()
+	if err != nil {
+		log.WithError(err).Warn("Failed to fetch retained bundles")
+		return
+	}
+
+	var bps []BundlePack
+	for _, bi := range bis {
+		bp := NewBundlePack(bi.BId, c.store)
+		if bndl, err := bp.Bundle(); err != nil {
+			log.WithField("bundle", bi.Id).WithError(err).Warn("Failed to load retained bundle")
+		} else if c.agentManager.HasEndpoint(bndl.PrimaryBlock.Destination) {
+			bps = append(bps, bp)
+		}
+	}
+
+	sort.SliceStable(bps, func(i, j int) bool {
+		return bps[i].Timestamp.Before(bps[j].Timestamp)
+	})
+
+	for _, bp := range bps {
+		c.deliverRetainedBundle(bp.Id)
+	}
+}
+
+// deliverRetainedBundle delivers one retained bundle, unless it was already delivered or deleted in the meantime.
+func (c *Core) deliverRetainedBundle(bid bundle.BundleID) {
+	c.bundlesMutex.Lock()
+	defer c.bundlesMutex.Unlock()
+
+	if !c.store.KnowsBundle(bid) {
+		return
+	}
+
+	bp := NewBundlePack(bid, c.store)
+	if !bp.HasConstraint(LocalEndpoint) {
+		return
+	} else if _, err := bp.Bundle(); err != nil {
+		log.WithField("bundle", bp.ID()).WithError(err).Warn("Failed to load retained bundle")
+		return
+	}
+
+	log.WithField("bundle", bp.ID()).Info("Delivering retained bundle to the registered application")
+
+	// The stored bundle might still be encrypted; its integrity was checked on its first delivery attempt.
+	if _, err := c.localDeliveryDecrypt(bp); err != nil {
+		log.WithField("bundle", bp.ID()).WithError(err).Warn("Decrypting retained bundle failed")
+
+		bp.RemoveConstraint(LocalEndpoint)
+		c.bundleDeletion(bp, bundle.FailedSecurityOperation)
+		return
+	}
+
+	c.deliverToAgent(bp)
+}
@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/agent"
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+// inboxAgent is an ApplicationAgent for a single endpoint, passing all received bundles to its inbox.
+type inboxAgent struct {
+	endpoint bundle.EndpointID
+	receiver chan agent.Message
+	sender   chan agent.Message
+	inbox    chan bundle.Bundle
+}
+
+func newInboxAgent(endpoint bundle.EndpointID) *inboxAgent {
+	ia := &inboxAgent{
+		endpoint: endpoint,
+		receiver: make(chan agent.Message),
+		sender:   make(chan agent.Message),
+		inbox:    make(chan bundle.Bundle, 16),
+	}
+
+	go func() {
+		for msg := range ia.receiver {
+			if bm, ok := msg.(agent.BundleMessage); ok {
+				ia.inbox <- bm.Bundle
+			}
+		}
+	}()
+
+	return ia
+}
+
+func (ia *inboxAgent) Endpoints() []bundle.EndpointID      { return []bundle.EndpointID{ia.endpoint} }
+func (ia *inboxAgent) MessageReceiver() chan agent.Message { return ia.receiver }
+func (ia *inboxAgent) MessageSender() chan agent.Message   { return ia.sender }
+
+func TestCoreRetainUnregisteredEndpoint(t *testing.T) {
+	registerGobTypes()
+
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := storage.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	c := &Core{NodeId: bundle.MustNewEndpointID("dtn://dst/"), store: store}
+	c.agentManager = NewAgentManager(c)
+
+	var bndls []bundle.Bundle
+	for i := 0; i < 3; i++ {
+		b, err := bundle.Builder().
+			Source("dtn://src/").
+			Destination("dtn://dst/app").
+			BundleCtrlFlags(0).
+			CreationTimestampNow().
+			Lifetime("1h").
+			PayloadBlock([]byte(fmt.Sprintf("hello %d", i))).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bndls = append(bndls, b)
+
+		bp := NewBundlePackFromBundle(b, store)
+		bp.AddConstraint(DispatchPending)
+		_ = bp.Sync()
+
+		c.localDelivery(bp)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if bis, err := store.QueryLocal(); err != nil {
+		t.Fatal(err)
+	} else if len(bis) != len(bndls) {
+		t.Fatalf("Store retains %d bundles instead of %d", len(bis), len(bndls))
+	}
+
+	ia := newInboxAgent(bundle.MustNewEndpointID("dtn://dst/app"))
+	c.agentManager.Register(ia)
+
+	for i, expected := range bndls {
+		select {
+		case b := <-ia.inbox:
+			if b.ID() != expected.ID() {
+				t.Fatalf("Bundle %d is %v instead of %v", i, b.ID(), expected.ID())
+			}
+
+		case <-time.After(time.Second):
+			t.Fatalf("Bundle %d was not delivered", i)
+		}
+	}
+
+	if bis, err := store.QueryLocal(); err != nil {
+		t.Fatal(err)
+	} else if len(bis) != 0 {
+		t.Fatalf("Store still retains %d bundles", len(bis))
+	}
+
+	for _, b := range bndls {
+		if store.KnowsBundle(b.ID()) {
+			t.Fatalf("Delivered bundle %v is still stored", b.ID())
+		}
+	}
+
+	// A concurrent run, which queried the retained bundles before their delivery, must not deliver them again.
+	for _, b := range bndls {
+		c.deliverRetainedBundle(b.ID())
+	}
+
+	select {
+	case b := <-ia.inbox:
+		t.Fatalf("Bundle %v was delivered twice", b.ID())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
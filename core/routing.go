@@ -6,9 +6,14 @@
 package core
 
 import (
+	"bytes"
 	"fmt"
+	"reflect"
 	"regexp"
+	"strings"
+	"sync"
 
+	"github.com/BurntSushi/toml"
 	"github.com/dtn7/dtn7-go/bundle"
 	"github.com/dtn7/dtn7-go/cla"
 	"github.com/dtn7/dtn7-go/storage"
@@ -49,11 +54,20 @@ type RoutingAlgorithm interface {
 	ReportPeerDisappeared(peer cla.Convergence)
 }
 
+// BundlePrioritizer is an optional interface for a RoutingAlgorithm to order
+// the pending bundles before they are retried, e.g., after a new peer has
+// appeared. Thus, the algorithm controls which bundles are sent first within
+// a contact.
+type BundlePrioritizer interface {
+	// PrioritizeBundles sorts the BundleItems of pending bundles in place.
+	PrioritizeBundles(bis []storage.BundleItem)
+}
+
 // RoutingConf contains necessary configuration data to initialize a routing algorithm.
 type RoutingConf struct {
-	// Algorithm is one of the implemented routing algorithms.
+	// Algorithm is one of the implemented routing algorithms or one registered by RegisterRoutingAlgorithm.
 	//
-	// One of: "epidemic", "spray", "binary_spray", "dtlsr", "prophet", "sensor-mule"
+	// One of: "epidemic", "spray", "binary_spray", "dtlsr", "prophet", "sensor-mule", "cgr", "static", "maxprop", "filter-chain"
 	Algorithm string
 
 	// SprayConf contains data to initialize "spray" or "binary_spray"
@@ -67,40 +81,188 @@ type RoutingConf struct {
 
 	// SensorNetworkMuleConfig contains data to initialize "sensor-mule"
 	SensorMuleConf SensorNetworkMuleConfig `toml:"sensor-mule-conf"`
+
+	// CGRConf contains data to initialize "cgr"
+	CGRConf CGRConfig `toml:"cgr-conf"`
+
+	// StaticConf contains data to initialize "static"
+	StaticConf StaticRoutingConfig `toml:"static-conf"`
+
+	// MaxPropConf contains data to initialize "maxprop"
+	MaxPropConf MaxPropConfig `toml:"maxprop-conf"`
+
+	// FilterChainConf contains data to initialize "filter-chain"
+	FilterChainConf FilterChainConfig `toml:"filter-chain-conf"`
+
+	// sections are all further TOML sections, e.g., of registered third-party routing algorithms.
+	sections map[string]interface{}
+}
+
+// UnmarshalTOML decodes the known fields of a RoutingConf and keeps all other sections, to be decoded later by
+// DecodeSection. This allows third-party routing algorithms to have their own configuration section.
+func (routingConf *RoutingConf) UnmarshalTOML(data interface{}) error {
+	table, ok := data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("routing configuration is not a table, but %T", data)
+	}
+
+	known := make(map[string]interface{})
+	sections := make(map[string]interface{})
+	for key, value := range table {
+		if isRoutingConfField(key) {
+			known[key] = value
+		} else {
+			sections[key] = value
+		}
+	}
+
+	var buff bytes.Buffer
+	if err := toml.NewEncoder(&buff).Encode(known); err != nil {
+		return err
+	}
+
+	// plainRoutingConf has no UnmarshalTOML method to prevent an endless recursion.
+	type plainRoutingConf RoutingConf
+	var plainConf plainRoutingConf
+	if _, err := toml.Decode(buff.String(), &plainConf); err != nil {
+		return err
+	}
+
+	*routingConf = RoutingConf(plainConf)
+	routingConf.sections = sections
+	return nil
+}
+
+// isRoutingConfField checks if a TOML key addresses one of the RoutingConf's exported fields.
+func isRoutingConfField(key string) bool {
+	confType := reflect.TypeOf(RoutingConf{})
+	for i := 0; i < confType.NumField(); i++ {
+		field := confType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if tag := field.Tag.Get("toml"); tag == key || (tag == "" && strings.EqualFold(field.Name, key)) {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeSection decodes a further TOML section of this routing configuration into v, e.g., "[routing.my-conf]" for
+// the section "my-conf". This should be used by third-party routing algorithms to read their configuration.
+func (routingConf RoutingConf) DecodeSection(section string, v interface{}) error {
+	data, ok := routingConf.sections[section]
+	if !ok {
+		return fmt.Errorf("routing configuration has no section %s", section)
+	}
+
+	table, ok := data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("routing configuration's section %s is not a table", section)
+	}
+
+	var buff bytes.Buffer
+	if err := toml.NewEncoder(&buff).Encode(table); err != nil {
+		return err
+	}
+
+	_, err := toml.Decode(buff.String(), v)
+	return err
+}
+
+// RoutingAlgorithmFactory creates a RoutingAlgorithm from its configuration.
+type RoutingAlgorithmFactory func(c *Core, conf RoutingConf) (RoutingAlgorithm, error)
+
+var (
+	routingAlgorithmFactories      = make(map[string]RoutingAlgorithmFactory)
+	routingAlgorithmFactoriesMutex sync.RWMutex
+)
+
+// RegisterRoutingAlgorithm makes a RoutingAlgorithm available for the RoutingConf by its name. This might be used to
+// register third-party algorithms within an init function. Their settings can be read by RoutingConf.DecodeSection.
+func RegisterRoutingAlgorithm(name string, factory RoutingAlgorithmFactory) error {
+	routingAlgorithmFactoriesMutex.Lock()
+	defer routingAlgorithmFactoriesMutex.Unlock()
+
+	if _, exists := routingAlgorithmFactories[name]; exists {
+		return fmt.Errorf("routing algorithm %s is already registered", name)
+	}
+
+	routingAlgorithmFactories[name] = factory
+	return nil
 }
 
 // RoutingAlgorithm from its configuration.
-func (routingConf RoutingConf) RoutingAlgorithm(c *Core) (ra RoutingAlgorithm, err error) {
-	switch routingConf.Algorithm {
-	case "epidemic":
-		ra = NewEpidemicRouting(c)
+func (routingConf RoutingConf) RoutingAlgorithm(c *Core) (RoutingAlgorithm, error) {
+	routingAlgorithmFactoriesMutex.RLock()
+	factory, ok := routingAlgorithmFactories[routingConf.Algorithm]
+	routingAlgorithmFactoriesMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown routing algorithm %s", routingConf.Algorithm)
+	}
+	return factory(c, routingConf)
+}
+
+func init() {
+	_ = RegisterRoutingAlgorithm("epidemic", func(c *Core, _ RoutingConf) (RoutingAlgorithm, error) {
+		return NewEpidemicRouting(c), nil
+	})
 
-	case "spray":
-		ra = NewSprayAndWait(c, routingConf.SprayConf)
+	_ = RegisterRoutingAlgorithm("spray", func(c *Core, conf RoutingConf) (RoutingAlgorithm, error) {
+		return NewSprayAndWait(c, conf.SprayConf), nil
+	})
 
-	case "binary_spray":
-		ra = NewBinarySpray(c, routingConf.SprayConf)
+	_ = RegisterRoutingAlgorithm("binary_spray", func(c *Core, conf RoutingConf) (RoutingAlgorithm, error) {
+		return NewBinarySpray(c, conf.SprayConf), nil
+	})
 
-	case "dtlsr":
-		ra = NewDTLSR(c, routingConf.DTLSRConf)
+	_ = RegisterRoutingAlgorithm("dtlsr", func(c *Core, conf RoutingConf) (RoutingAlgorithm, error) {
+		return NewDTLSR(c, conf.DTLSRConf), nil
+	})
 
-	case "prophet":
-		ra = NewProphet(c, routingConf.ProphetConf)
+	_ = RegisterRoutingAlgorithm("prophet", func(c *Core, conf RoutingConf) (RoutingAlgorithm, error) {
+		return NewProphet(c, conf.ProphetConf), nil
+	})
 
-	case "sensor-mule":
-		if algo, algoErr := routingConf.SensorMuleConf.Algorithm.RoutingAlgorithm(c); algoErr != nil {
-			err = algoErr
-		} else if sensorNode, sensorNodeErr := regexp.Compile(routingConf.SensorMuleConf.SensorNodeRegex); sensorNodeErr != nil {
-			err = sensorNodeErr
+	_ = RegisterRoutingAlgorithm("sensor-mule", func(c *Core, conf RoutingConf) (RoutingAlgorithm, error) {
+		if algo, err := conf.SensorMuleConf.Algorithm.RoutingAlgorithm(c); err != nil {
+			return nil, err
+		} else if sensorNode, err := regexp.Compile(conf.SensorMuleConf.SensorNodeRegex); err != nil {
+			return nil, err
 		} else {
-			ra = NewSensorNetworkMuleRouting(algo, sensorNode)
+			return NewSensorNetworkMuleRouting(algo, sensorNode), nil
 		}
+	})
 
-	default:
-		err = fmt.Errorf("unknown routing algorithm %s", routingConf.Algorithm)
-	}
+	_ = RegisterRoutingAlgorithm("cgr", func(c *Core, conf RoutingConf) (RoutingAlgorithm, error) {
+		if ra, err := NewCGR(c, conf.CGRConf); err != nil {
+			return nil, err
+		} else {
+			return ra, nil
+		}
+	})
 
-	return
+	_ = RegisterRoutingAlgorithm("static", func(c *Core, conf RoutingConf) (RoutingAlgorithm, error) {
+		if ra, err := NewStaticRouting(c, conf.StaticConf); err != nil {
+			return nil, err
+		} else {
+			return ra, nil
+		}
+	})
+
+	_ = RegisterRoutingAlgorithm("maxprop", func(c *Core, conf RoutingConf) (RoutingAlgorithm, error) {
+		return NewMaxProp(c, conf.MaxPropConf), nil
+	})
+
+	_ = RegisterRoutingAlgorithm("filter-chain", func(c *Core, conf RoutingConf) (RoutingAlgorithm, error) {
+		if ra, err := NewFilterChainFromConfig(c, conf.FilterChainConf); err != nil {
+			return nil, err
+		} else {
+			return ra, nil
+		}
+	})
 }
 
 // sendMetadataBundle can be used by routing algorithm to send relevant metadata to peers
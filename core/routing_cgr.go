@@ -0,0 +1,476 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/BurntSushi/toml"
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+// CGRConfig contains data to initialize "cgr".
+type CGRConfig struct {
+	// ContactPlan is the path to a TOML file describing the contact plan, as parsed by ParseContactPlan.
+	ContactPlan string `toml:"contact-plan"`
+
+	// ReloadTime is the optional interval to check the contact plan file for modifications and reload it.
+	// Note: Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h".
+	ReloadTime string `toml:"reload-time"`
+}
+
+// Contact is a scheduled, unidirectional link from one node to another.
+type Contact struct {
+	From  bundle.EndpointID
+	To    bundle.EndpointID
+	Start time.Time
+	End   time.Time
+
+	// Rate is the transmission rate in bytes per second.
+	Rate uint64
+	// OWLT is the one-way light time, i.e., the propagation delay.
+	OWLT time.Duration
+}
+
+// Volume of this Contact in bytes, the product of its duration and rate.
+func (contact Contact) Volume() uint64 {
+	return uint64(contact.End.Sub(contact.Start).Seconds() * float64(contact.Rate))
+}
+
+// IsActive checks if this Contact is active at the given time.
+func (contact Contact) IsActive(t time.Time) bool {
+	return !t.Before(contact.Start) && t.Before(contact.End)
+}
+
+func (contact Contact) String() string {
+	return fmt.Sprintf("%v -> %v [%v, %v]", contact.From, contact.To,
+		contact.Start.Format(time.RFC3339), contact.End.Format(time.RFC3339))
+}
+
+// transmissionTime of some bytes within this Contact.
+func (contact Contact) transmissionTime(size uint64) time.Duration {
+	if contact.Rate == 0 {
+		return 0
+	}
+	return time.Duration(float64(size) / float64(contact.Rate) * float64(time.Second))
+}
+
+// ContactPlan is a set of known Contacts.
+type ContactPlan []Contact
+
+// contactPlanFile is the TOML representation of a ContactPlan.
+type contactPlanFile struct {
+	Contact []struct {
+		From  string
+		To    string
+		Start time.Time
+		End   time.Time
+		Rate  uint64
+		OWLT  string `toml:"owlt"`
+	}
+}
+
+// ParseContactPlan reads a ContactPlan from its TOML representation. Each contact is a [[contact]] table:
+//
+//	[[contact]]
+//	from = "dtn://alpha/"
+//	to = "dtn://beta/"
+//	start = 2020-08-21T10:00:00Z
+//	end = 2020-08-21T10:10:00Z
+//	rate = 125000   # bytes per second
+//	owlt = "1.3s"   # optional one-way light time
+func ParseContactPlan(r io.Reader) (plan ContactPlan, err error) {
+	var planFile contactPlanFile
+	if _, err = toml.DecodeReader(r, &planFile); err != nil {
+		return
+	}
+
+	for i, c := range planFile.Contact {
+		contact := Contact{Start: c.Start, End: c.End, Rate: c.Rate}
+
+		if contact.From, err = bundle.NewEndpointID(c.From); err != nil {
+			err = fmt.Errorf("contact %d has an invalid from endpoint: %v", i, err)
+			return
+		} else if contact.To, err = bundle.NewEndpointID(c.To); err != nil {
+			err = fmt.Errorf("contact %d has an invalid to endpoint: %v", i, err)
+			return
+		}
+
+		if c.OWLT != "" {
+			if contact.OWLT, err = time.ParseDuration(c.OWLT); err != nil {
+				err = fmt.Errorf("contact %d has an invalid OWLT: %v", i, err)
+				return
+			}
+		}
+
+		if !contact.End.After(contact.Start) {
+			err = fmt.Errorf("contact %d ends before it starts", i)
+			return
+		}
+
+		plan = append(plan, contact)
+	}
+
+	return
+}
+
+// LoadContactPlan reads a ContactPlan from a TOML file, compare ParseContactPlan.
+func LoadContactPlan(filename string) (ContactPlan, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseContactPlan(f)
+}
+
+// CGR is an implementation of Contact Graph Routing, forwarding each bundle along the route of its earliest arrival
+// based on a ContactPlan of scheduled contacts.
+//
+// Routes are computed by Dijkstra's algorithm over the contact graph, whose vertices are the contacts. A route must
+// deliver the bundle before its expiration and each contact must have enough residual volume for the bundle. The
+// bundle is sent to the first hop's ConvergenceSender while this contact is active. Otherwise, it stays pending until
+// being retried.
+type CGR struct {
+	c *Core
+
+	// contacts of the current ContactPlan and their residual volumes in bytes.
+	contacts []Contact
+	residual []uint64
+
+	// bookings maps a bundle to the contacts and the volume it has reserved.
+	bookings map[string]cgrBooking
+
+	// planFile and planModTime identify the loaded contact plan file for reloading.
+	planFile    string
+	planModTime time.Time
+
+	mutex sync.Mutex
+}
+
+// cgrBooking is a bundle's reservation of volume on a route's contacts.
+type cgrBooking struct {
+	contacts []int
+	size     uint64
+}
+
+// NewCGR creates a new CGR RoutingAlgorithm interacting with the given Core. The contact plan is loaded from the
+// configured file, if set.
+func NewCGR(c *Core, config CGRConfig) (*CGR, error) {
+	log.WithField("config", config).Debug("Initialising CGR")
+
+	cgr := &CGR{
+		c:        c,
+		bookings: make(map[string]cgrBooking),
+		planFile: config.ContactPlan,
+	}
+
+	if config.ContactPlan != "" {
+		if err := cgr.reloadContactPlan(); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.ReloadTime != "" {
+		if config.ContactPlan == "" {
+			return nil, fmt.Errorf("CGR's reload time requires a contact plan file")
+		}
+
+		reloadTime, err := time.ParseDuration(config.ReloadTime)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.cron.Register("cgr_reload", cgr.reloadCron, reloadTime); err != nil {
+			log.WithError(err).Warn("Could not register CGR reload job")
+		}
+	}
+
+	return cgr, nil
+}
+
+// SetContactPlan replaces the current ContactPlan at runtime. All reserved volumes will be reset.
+func (cgr *CGR) SetContactPlan(plan ContactPlan) {
+	cgr.mutex.Lock()
+	defer cgr.mutex.Unlock()
+
+	cgr.contacts = append([]Contact(nil), plan...)
+	cgr.residual = make([]uint64, len(plan))
+	for i, contact := range plan {
+		cgr.residual[i] = contact.Volume()
+	}
+	cgr.bookings = make(map[string]cgrBooking)
+
+	log.WithField("contacts", len(plan)).Info("CGR loaded contact plan")
+}
+
+// ContactPlan returns a copy of the current ContactPlan.
+func (cgr *CGR) ContactPlan() ContactPlan {
+	cgr.mutex.Lock()
+	defer cgr.mutex.Unlock()
+
+	return append(ContactPlan(nil), cgr.contacts...)
+}
+
+// reloadContactPlan loads the contact plan file.
+func (cgr *CGR) reloadContactPlan() error {
+	fi, err := os.Stat(cgr.planFile)
+	if err != nil {
+		return err
+	}
+
+	plan, err := LoadContactPlan(cgr.planFile)
+	if err != nil {
+		return err
+	}
+
+	cgr.SetContactPlan(plan)
+
+	cgr.mutex.Lock()
+	cgr.planModTime = fi.ModTime()
+	cgr.mutex.Unlock()
+
+	return nil
+}
+
+// reloadCron reloads a modified contact plan file.
+func (cgr *CGR) reloadCron() {
+	fi, err := os.Stat(cgr.planFile)
+	if err != nil {
+		log.WithField("file", cgr.planFile).WithError(err).Warn("CGR failed to inspect contact plan")
+		return
+	}
+
+	cgr.mutex.Lock()
+	modified := !fi.ModTime().Equal(cgr.planModTime)
+	cgr.mutex.Unlock()
+
+	if !modified {
+		return
+	}
+
+	if err := cgr.reloadContactPlan(); err != nil {
+		log.WithField("file", cgr.planFile).WithError(err).Warn("CGR failed to reload contact plan")
+	}
+}
+
+// cgrVertex is a contact within the Dijkstra search, reached at its arrival time.
+type cgrVertex struct {
+	contact     int
+	arrival     time.Time
+	predecessor int
+	index       int
+}
+
+// cgrQueue is a priority queue of cgrVertices, ordered by their arrival time.
+type cgrQueue []*cgrVertex
+
+func (q cgrQueue) Len() int           { return len(q) }
+func (q cgrQueue) Less(i, j int) bool { return q[i].arrival.Before(q[j].arrival) }
+func (q cgrQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index, q[j].index = i, j
+}
+
+func (q *cgrQueue) Push(x interface{}) {
+	v := x.(*cgrVertex)
+	v.index = len(*q)
+	*q = append(*q, v)
+}
+
+func (q *cgrQueue) Pop() interface{} {
+	old := *q
+	v := old[len(old)-1]
+	*q = old[:len(old)-1]
+	v.index = -1
+	return v
+}
+
+// route computes the earliest arrival route from the local node to the destination, starting now. The route is
+// returned as a list of contact indices, which is empty if no route exists. The mutex must be held.
+func (cgr *CGR) route(destination bundle.EndpointID, size uint64, now, expiry time.Time) (route []int, arrival time.Time) {
+	vertices := make([]*cgrVertex, len(cgr.contacts))
+	settled := make([]bool, len(cgr.contacts))
+	queue := &cgrQueue{}
+
+	// relax all contacts starting at a node, which was reached at a time by a predecessor contact.
+	relax := func(node bundle.EndpointID, reached time.Time, predecessor int) {
+		for i, contact := range cgr.contacts {
+			if settled[i] || !contact.From.SameNode(node) || cgr.residual[i] < size {
+				continue
+			}
+
+			start := reached
+			if start.Before(contact.Start) {
+				start = contact.Start
+			}
+
+			sent := start.Add(contact.transmissionTime(size))
+			if sent.After(contact.End) {
+				continue
+			}
+
+			contactArrival := sent.Add(contact.OWLT)
+			if contactArrival.After(expiry) {
+				continue
+			}
+
+			if v := vertices[i]; v == nil {
+				vertices[i] = &cgrVertex{contact: i, arrival: contactArrival, predecessor: predecessor}
+				heap.Push(queue, vertices[i])
+			} else if contactArrival.Before(v.arrival) {
+				v.arrival, v.predecessor = contactArrival, predecessor
+				heap.Fix(queue, v.index)
+			}
+		}
+	}
+
+	relax(cgr.c.NodeId, now, -1)
+
+	for queue.Len() > 0 {
+		v := heap.Pop(queue).(*cgrVertex)
+		settled[v.contact] = true
+
+		contact := cgr.contacts[v.contact]
+		if contact.To.SameNode(destination) {
+			for i := v.contact; i >= 0; i = vertices[i].predecessor {
+				route = append([]int{i}, route...)
+			}
+			arrival = v.arrival
+			return
+		}
+
+		// Do not route back to the local node.
+		if !contact.To.SameNode(cgr.c.NodeId) {
+			relax(contact.To, v.arrival, v.contact)
+		}
+	}
+
+	return
+}
+
+// NotifyIncoming is ignored by CGR, as its routes are based on the contact plan.
+func (_ *CGR) NotifyIncoming(_ BundlePack) {}
+
+// DispatchingAllowed is always true for CGR.
+func (_ *CGR) DispatchingAllowed(_ BundlePack) bool {
+	return true
+}
+
+// SenderForBundle returns the ConvergenceSender for the first hop of the bundle's earliest arrival route, if its
+// contact is currently active. The route's volume is reserved for the bundle.
+func (cgr *CGR) SenderForBundle(bp BundlePack) (css []cla.ConvergenceSender, del bool) {
+	cgr.mutex.Lock()
+	defer cgr.mutex.Unlock()
+
+	logger := log.WithField("bundle", bp.ID())
+
+	bndl := bp.MustBundle()
+	size := storage.BundleSize(*bndl)
+	now := time.Now()
+
+	// A previous reservation of this bundle, e.g., before being retried, is released for the new route.
+	cgr.release(bp.ID())
+	cgr.expireBookings(now)
+
+	route, arrival := cgr.route(bndl.PrimaryBlock.Destination, size, now, bp.ExpirationTime())
+	if len(route) == 0 {
+		logger.Info("CGR found no route for bundle")
+		return nil, false
+	}
+
+	firstHop := cgr.contacts[route[0]]
+	logger = logger.WithFields(log.Fields{
+		"first_hop": firstHop,
+		"hops":      len(route),
+		"arrival":   arrival,
+	})
+
+	if !firstHop.IsActive(now) {
+		logger.Info("CGR's route for bundle starts with an inactive contact, waiting")
+		return nil, false
+	}
+
+	for _, cs := range cgr.c.claManager.Sender() {
+		if cs.GetPeerEndpointID().SameNode(firstHop.To) {
+			css = append(css, cs)
+			break
+		}
+	}
+
+	if len(css) == 0 {
+		logger.Info("CGR has no ConvergenceSender for the first hop of the bundle's route")
+		return nil, false
+	}
+
+	for _, i := range route {
+		cgr.residual[i] -= size
+	}
+	cgr.bookings[bp.ID()] = cgrBooking{contacts: route, size: size}
+
+	logger.Debug("CGR selected the first hop of the bundle's route")
+	return css, true
+}
+
+// release the volume reserved for a bundle. The mutex must be held.
+func (cgr *CGR) release(bid string) {
+	booking, ok := cgr.bookings[bid]
+	if !ok {
+		return
+	}
+
+	for _, i := range booking.contacts {
+		cgr.residual[i] += booking.size
+	}
+	delete(cgr.bookings, bid)
+}
+
+// expireBookings forgets all bookings whose contacts have ended. The mutex must be held.
+func (cgr *CGR) expireBookings(now time.Time) {
+	for bid, booking := range cgr.bookings {
+		expired := true
+		for _, i := range booking.contacts {
+			if cgr.contacts[i].End.After(now) {
+				expired = false
+				break
+			}
+		}
+
+		if expired {
+			delete(cgr.bookings, bid)
+		}
+	}
+}
+
+// ReportFailure releases the volume reserved for the failed bundle.
+func (cgr *CGR) ReportFailure(bp BundlePack, sender cla.ConvergenceSender) {
+	cgr.mutex.Lock()
+	defer cgr.mutex.Unlock()
+
+	log.WithFields(log.Fields{
+		"bundle":  bp.ID(),
+		"bad_cla": sender,
+	}).Debug("CGR failed to transmit to CLA")
+
+	cgr.release(bp.ID())
+}
+
+func (_ *CGR) ReportPeerAppeared(_ cla.Convergence) {}
+
+func (_ *CGR) ReportPeerDisappeared(_ cla.Convergence) {}
+
+func (_ *CGR) String() string {
+	return "cgr"
+}
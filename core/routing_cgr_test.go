@@ -0,0 +1,226 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+func TestParseContactPlan(t *testing.T) {
+	plan, err := ParseContactPlan(strings.NewReader(`
+[[contact]]
+from = "dtn://alpha/"
+to = "dtn://beta/"
+start = 2020-08-21T10:00:00Z
+end = 2020-08-21T10:10:00Z
+rate = 1000
+owlt = "1.5s"
+
+[[contact]]
+from = "dtn://beta/"
+to = "dtn://gamma/"
+start = 2020-08-21T11:00:00Z
+end = 2020-08-21T11:01:00Z
+rate = 10
+`))
+	if err != nil {
+		t.Fatal(err)
+	} else if l := len(plan); l != 2 {
+		t.Fatalf("Contact plan has %d contacts", l)
+	}
+
+	if plan[0].From != bundle.MustNewEndpointID("dtn://alpha/") || plan[0].To != bundle.MustNewEndpointID("dtn://beta/") {
+		t.Fatalf("Contact's nodes are wrong: %v", plan[0])
+	} else if plan[0].OWLT != 1500*time.Millisecond {
+		t.Fatalf("Contact's OWLT is %v", plan[0].OWLT)
+	} else if v := plan[0].Volume(); v != 600000 {
+		t.Fatalf("Contact's volume is %d", v)
+	} else if v := plan[1].Volume(); v != 600 {
+		t.Fatalf("Contact's volume is %d", v)
+	}
+
+	invalidPlans := []string{
+		"[[contact]]\nfrom = \"nope\"\nto = \"dtn://beta/\"\nstart = 2020-08-21T10:00:00Z\nend = 2020-08-21T10:10:00Z",
+		"[[contact]]\nfrom = \"dtn://alpha/\"\nto = \"dtn://beta/\"\nstart = 2020-08-21T10:10:00Z\nend = 2020-08-21T10:00:00Z",
+		"[[contact]]\nfrom = \"dtn://alpha/\"\nto = \"dtn://beta/\"\nstart = 2020-08-21T10:00:00Z\nend = 2020-08-21T10:10:00Z\nowlt = \"a while\"",
+	}
+	for _, invalidPlan := range invalidPlans {
+		if _, err := ParseContactPlan(strings.NewReader(invalidPlan)); err == nil {
+			t.Fatalf("Invalid contact plan was parsed: %s", invalidPlan)
+		}
+	}
+}
+
+func TestCGRRoute(t *testing.T) {
+	var (
+		local = bundle.MustNewEndpointID("dtn://local/")
+		b     = bundle.MustNewEndpointID("dtn://b/")
+		c     = bundle.MustNewEndpointID("dtn://c/")
+		dst   = bundle.MustNewEndpointID("dtn://dst/")
+		now   = time.Now()
+	)
+
+	cgr := &CGR{c: &Core{NodeId: local}}
+	cgr.SetContactPlan(ContactPlan{
+		{From: local, To: b, Start: now.Add(-time.Minute), End: now.Add(time.Hour), Rate: 1000000},
+		{From: b, To: dst, Start: now.Add(10 * time.Minute), End: now.Add(20 * time.Minute), Rate: 1000000},
+		{From: local, To: c, Start: now, End: now.Add(time.Hour), Rate: 1000000},
+		{From: c, To: dst, Start: now.Add(time.Minute), End: now.Add(2 * time.Minute), Rate: 10},
+		{From: c, To: local, Start: now, End: now.Add(time.Hour), Rate: 1000000},
+	})
+
+	tests := []struct {
+		name   string
+		size   uint64
+		expiry time.Duration
+		route  []int
+	}{
+		{"earliest arrival", 100, time.Hour, []int{2, 3}},
+		{"exceeding volume", 1000, time.Hour, []int{0, 1}},
+		{"expiry before arrival", 100, 30 * time.Second, nil},
+		{"expiry after earliest arrival", 100, 5 * time.Minute, []int{2, 3}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			route, _ := cgr.route(dst, test.size, now, now.Add(test.expiry))
+			if len(route) != len(test.route) {
+				t.Fatalf("Route %v differs from %v", route, test.route)
+			}
+			for i := range route {
+				if route[i] != test.route[i] {
+					t.Fatalf("Route %v differs from %v", route, test.route)
+				}
+			}
+		})
+	}
+}
+
+func TestCGRSenderForBundle(t *testing.T) {
+	registerGobTypes()
+
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := storage.NewStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	c := &Core{
+		NodeId:     bundle.MustNewEndpointID("dtn://local/"),
+		store:      store,
+		claManager: cla.NewManager(),
+	}
+	defer c.claManager.Close()
+
+	c.claManager.Register(&mtuConvSender{})
+	for i := 0; len(c.claManager.Sender()) == 0; i++ {
+		if i == 100 {
+			t.Fatal("CLA was not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	planFile := filepath.Join(dir, "contacts.toml")
+	plan := `
+[[contact]]
+from = "dtn://local/"
+to = "dtn://peer/"
+start = ` + now.Add(-time.Minute).Format(time.RFC3339) + `
+end = ` + now.Add(time.Hour).Format(time.RFC3339) + `
+rate = 1000
+
+[[contact]]
+from = "dtn://peer/"
+to = "dtn://dst/"
+start = ` + now.Add(time.Minute).Format(time.RFC3339) + `
+end = ` + now.Add(2*time.Minute).Format(time.RFC3339) + `
+rate = 10
+owlt = "1s"
+`
+	if err := ioutil.WriteFile(planFile, []byte(plan), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cgr, err := NewCGR(c, CGRConfig{ContactPlan: planFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bndl, err := bundle.Builder().
+		BundleCtrlFlags(0).
+		Source("dtn://local/").
+		Destination("dtn://dst/app").
+		CreationTimestampNow().
+		Lifetime("1h").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bp := NewBundlePackFromBundle(bndl, store)
+
+	if css, del := cgr.SenderForBundle(bp); len(css) != 1 || !del {
+		t.Fatalf("CGR selected %d senders, delete: %t", len(css), del)
+	} else if peer := css[0].GetPeerEndpointID(); peer != bundle.MustNewEndpointID("dtn://peer/") {
+		t.Fatalf("CGR selected %v", peer)
+	}
+
+	// Routing the same bundle again releases its previous reservation.
+	if css, _ := cgr.SenderForBundle(bp); len(css) != 1 {
+		t.Fatal("CGR did not route the same bundle again")
+	} else if len(cgr.bookings) != 1 {
+		t.Fatalf("CGR has %d bookings", len(cgr.bookings))
+	}
+
+	// The second contact's volume of 600 bytes only suffices for a few further bundles.
+	var routed int
+	for i := 0; i < 20; i++ {
+		b, err := bundle.Builder().
+			BundleCtrlFlags(0).
+			Source(fmt.Sprintf("dtn://local/%d", i)).
+			Destination("dtn://dst/app").
+			CreationTimestampNow().
+			Lifetime("1h").
+			PayloadBlock([]byte("hello world")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if css, _ := cgr.SenderForBundle(NewBundlePackFromBundle(b, store)); len(css) == 1 {
+			routed++
+		}
+	}
+	if routed == 0 || routed == 20 {
+		t.Fatalf("CGR routed %d of 20 bundles over a contact of limited volume", routed)
+	}
+
+	cgr.ReportFailure(bp, nil)
+	if _, ok := cgr.bookings[bp.ID()]; ok {
+		t.Fatal("Failed bundle's booking was not released")
+	}
+
+	cgr.SetContactPlan(ContactPlan{})
+	if css, _ := cgr.SenderForBundle(bp); len(css) != 0 {
+		t.Fatal("CGR routed a bundle without contacts")
+	}
+}
@@ -592,6 +592,92 @@ func (dtlsr *DTLSR) purgePeers() {
 	}
 }
 
+// dtlsrPeerDataState is the persisted form of a peerData.
+type dtlsrPeerDataState struct {
+	ID        bundle.EndpointID
+	Timestamp bundle.DtnTime
+	Peers     map[bundle.EndpointID]bundle.DtnTime
+}
+
+func newDTLSRPeerDataState(pd peerData) dtlsrPeerDataState {
+	return dtlsrPeerDataState{ID: pd.id, Timestamp: pd.timestamp, Peers: pd.peers}
+}
+
+func (pds dtlsrPeerDataState) peerData() peerData {
+	peers := pds.Peers
+	if peers == nil {
+		peers = make(map[bundle.EndpointID]bundle.DtnTime)
+	}
+	return peerData{id: pds.ID, timestamp: pds.Timestamp, peers: peers}
+}
+
+// dtlsrState is DTLSR's persisted state, compare RoutingStatePersister.
+type dtlsrState struct {
+	Peers        dtlsrPeerDataState
+	ReceivedData map[bundle.EndpointID]dtlsrPeerDataState
+}
+
+// MarshalState serializes our own and the received peer data.
+func (dtlsr *DTLSR) MarshalState() ([]byte, error) {
+	dtlsr.dataMutex.RLock()
+	defer dtlsr.dataMutex.RUnlock()
+
+	state := dtlsrState{
+		Peers:        newDTLSRPeerDataState(dtlsr.peers),
+		ReceivedData: make(map[bundle.EndpointID]dtlsrPeerDataState, len(dtlsr.receivedData)),
+	}
+	for id, data := range dtlsr.receivedData {
+		state.ReceivedData[id] = newDTLSRPeerDataState(data)
+	}
+
+	return marshalRoutingState(state)
+}
+
+// UnmarshalState restores the peer data and recomputes the routing table. Peers which were connected at the time of
+// persisting are treated as having disconnected back then, so that their links age over the downtime.
+func (dtlsr *DTLSR) UnmarshalState(data []byte, downtime time.Duration) error {
+	var state dtlsrState
+	if err := unmarshalRoutingState(data, &state); err != nil {
+		return err
+	}
+
+	disconnect := bundle.DtnTimeFromTime(time.Now().Add(-downtime))
+
+	dtlsr.dataMutex.Lock()
+
+	for peer, timestamp := range state.Peers.Peers {
+		if _, connected := dtlsr.peers.peers[peer]; connected {
+			continue
+		}
+
+		if timestamp == 0 {
+			timestamp = disconnect
+		}
+		dtlsr.newNode(peer)
+		dtlsr.peers.peers[peer] = timestamp
+	}
+
+	for id, peerState := range state.ReceivedData {
+		if stored, present := dtlsr.receivedData[id]; present && !peerState.peerData().isNewerThan(stored) {
+			continue
+		}
+
+		dtlsr.receivedData[id] = peerState.peerData()
+		dtlsr.newNode(id)
+		for node := range peerState.Peers {
+			dtlsr.newNode(node)
+		}
+	}
+
+	dtlsr.peerChange = true
+	dtlsr.computeRoutingTable()
+
+	dtlsr.dataMutex.Unlock()
+
+	dtlsr.purgePeers()
+	return nil
+}
+
 // DTLSRBlock contains routing metadata
 //
 // TODO: Turn this into an administrative record
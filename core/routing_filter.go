@@ -0,0 +1,476 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+// RoutingFilter restricts the ConvergenceSenders selected by a RoutingAlgorithm for a bundle.
+//
+// A filter might additionally implement the RoutingFilterNotifier or RoutingFilterFailureReporter interfaces to track
+// its own state for bundles.
+type RoutingFilter interface {
+	// Filter returns the remaining ConvergenceSenders for a bundle. The given slice must not be modified.
+	Filter(bp BundlePack, senders []cla.ConvergenceSender) []cla.ConvergenceSender
+}
+
+// RoutingFilterNotifier is an optional interface for a RoutingFilter to be notified about incoming bundles.
+type RoutingFilterNotifier interface {
+	NotifyIncoming(bp BundlePack)
+}
+
+// RoutingFilterFailureReporter is an optional interface for a RoutingFilter to be notified about failed
+// transmissions to previously selected ConvergenceSenders.
+type RoutingFilterFailureReporter interface {
+	ReportFailure(bp BundlePack, sender cla.ConvergenceSender)
+}
+
+// RoutingFilterConfig describes a RoutingFilter within a FilterChainConfig.
+type RoutingFilterConfig struct {
+	// Type of the filter, one of "exclude-peers", "max-copies", "no-previous-node", "prefer-cla", "bundle-size" or
+	// the name of a filter registered by RegisterRoutingFilter.
+	Type string
+
+	// Regex matches the Node IDs of excluded peers for "exclude-peers".
+	Regex string
+
+	// MaxCopies is the maximum amount of peers to receive a bundle for "max-copies".
+	MaxCopies uint `toml:"max-copies"`
+
+	// ClaTypes lists CLA types, e.g., "tcpcl" or "mtcp", in their order of preference for "prefer-cla" and the
+	// restricted CLA types for "bundle-size".
+	ClaTypes []string `toml:"cla-types"`
+
+	// MaxSize is the maximum size of a serialized bundle in bytes for "bundle-size".
+	MaxSize int `toml:"max-size"`
+
+	// Options might be used to configure registered third-party filters.
+	Options map[string]interface{}
+}
+
+// RoutingFilterFactory creates a RoutingFilter from its configuration.
+type RoutingFilterFactory func(c *Core, conf RoutingFilterConfig) (RoutingFilter, error)
+
+var (
+	routingFilterFactories      = make(map[string]RoutingFilterFactory)
+	routingFilterFactoriesMutex sync.RWMutex
+)
+
+// RegisterRoutingFilter makes a RoutingFilter available for the FilterChainConfig by its type name. This might be
+// used to register third-party filters, e.g., within an init function.
+func RegisterRoutingFilter(name string, factory RoutingFilterFactory) error {
+	routingFilterFactoriesMutex.Lock()
+	defer routingFilterFactoriesMutex.Unlock()
+
+	if _, exists := routingFilterFactories[name]; exists {
+		return fmt.Errorf("routing filter %s is already registered", name)
+	}
+
+	routingFilterFactories[name] = factory
+	return nil
+}
+
+// NewRoutingFilter creates a RoutingFilter based on its configuration's type.
+func NewRoutingFilter(c *Core, conf RoutingFilterConfig) (RoutingFilter, error) {
+	routingFilterFactoriesMutex.RLock()
+	factory, ok := routingFilterFactories[conf.Type]
+	routingFilterFactoriesMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown routing filter %s", conf.Type)
+	}
+	return factory(c, conf)
+}
+
+func init() {
+	_ = RegisterRoutingFilter("exclude-peers", newExcludePeersFilter)
+	_ = RegisterRoutingFilter("max-copies", newMaxCopiesFilter)
+	_ = RegisterRoutingFilter("no-previous-node", newNoPreviousNodeFilter)
+	_ = RegisterRoutingFilter("prefer-cla", newPreferClaFilter)
+	_ = RegisterRoutingFilter("bundle-size", newBundleSizeFilter)
+}
+
+// FilterChainConfig describes a FilterChain.
+type FilterChainConfig struct {
+	Algorithm *RoutingConf          `toml:"routing"`
+	Filters   []RoutingFilterConfig `toml:"filter"`
+}
+
+// FilterChain is a proxy routing algorithm, applying a chain of RoutingFilters to the ConvergenceSenders selected by
+// an underlying algorithm. The filters are applied in their order. Each removed ConvergenceSender is reported as a
+// failure to the underlying algorithm, as this bundle was not sent to it.
+type FilterChain struct {
+	algorithm RoutingAlgorithm
+	filters   []RoutingFilter
+}
+
+// NewFilterChain based on an underlying algorithm and the filters to be applied in this order.
+func NewFilterChain(algorithm RoutingAlgorithm, filters []RoutingFilter) *FilterChain {
+	return &FilterChain{
+		algorithm: algorithm,
+		filters:   filters,
+	}
+}
+
+// NewFilterChainFromConfig creates a FilterChain and its underlying algorithm from its configuration.
+func NewFilterChainFromConfig(c *Core, conf FilterChainConfig) (*FilterChain, error) {
+	if conf.Algorithm == nil {
+		return nil, fmt.Errorf("filter chain misses its underlying routing algorithm")
+	}
+
+	algorithm, err := conf.Algorithm.RoutingAlgorithm(c)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := make([]RoutingFilter, 0, len(conf.Filters))
+	for i, filterConf := range conf.Filters {
+		if filter, err := NewRoutingFilter(c, filterConf); err != nil {
+			return nil, fmt.Errorf("routing filter %d: %v", i, err)
+		} else {
+			filters = append(filters, filter)
+		}
+	}
+
+	return NewFilterChain(algorithm, filters), nil
+}
+
+// NotifyIncoming bundle, which will be handled by the underlying algorithm and all interested filters.
+func (fc *FilterChain) NotifyIncoming(bp BundlePack) {
+	fc.algorithm.NotifyIncoming(bp)
+
+	for _, filter := range fc.filters {
+		if notifier, ok := filter.(RoutingFilterNotifier); ok {
+			notifier.NotifyIncoming(bp)
+		}
+	}
+}
+
+// DispatchingAllowed if the underlying algorithm says so.
+func (fc *FilterChain) DispatchingAllowed(bp BundlePack) bool {
+	return fc.algorithm.DispatchingAllowed(bp)
+}
+
+// SenderForBundle queries the underlying algorithm and applies each filter to its result.
+func (fc *FilterChain) SenderForBundle(bp BundlePack) (sender []cla.ConvergenceSender, delete bool) {
+	sender, delete = fc.algorithm.SenderForBundle(bp)
+
+	for _, filter := range fc.filters {
+		if len(sender) == 0 {
+			break
+		}
+
+		filtered := filter.Filter(bp, sender)
+
+		for _, cs := range sender {
+			if !containsSender(filtered, cs) {
+				log.WithFields(log.Fields{
+					"bundle":             bp.ID(),
+					"convergence-sender": cs,
+					"filter":             fmt.Sprintf("%T", filter),
+				}).Debug("Routing filter excludes Convergence Sender")
+
+				fc.algorithm.ReportFailure(bp, cs)
+			}
+		}
+
+		sender = filtered
+	}
+
+	// As with the SensorNetworkMuleRouting, the delete flag is reset if no sender remains.
+	if delete && len(sender) == 0 {
+		delete = false
+	}
+
+	return
+}
+
+// ReportFailure back to the underlying algorithm and all interested filters.
+func (fc *FilterChain) ReportFailure(bp BundlePack, sender cla.ConvergenceSender) {
+	fc.algorithm.ReportFailure(bp, sender)
+
+	for _, filter := range fc.filters {
+		if reporter, ok := filter.(RoutingFilterFailureReporter); ok {
+			reporter.ReportFailure(bp, sender)
+		}
+	}
+}
+
+// ReportPeerAppeared to the underlying algorithm.
+func (fc *FilterChain) ReportPeerAppeared(peer cla.Convergence) {
+	fc.algorithm.ReportPeerAppeared(peer)
+}
+
+// ReportPeerDisappeared to the underlying algorithm.
+func (fc *FilterChain) ReportPeerDisappeared(peer cla.Convergence) {
+	fc.algorithm.ReportPeerDisappeared(peer)
+}
+
+// PrioritizeBundles by the underlying algorithm, if it is a BundlePrioritizer.
+func (fc *FilterChain) PrioritizeBundles(bis []storage.BundleItem) {
+	if prioritizer, ok := fc.algorithm.(BundlePrioritizer); ok {
+		prioritizer.PrioritizeBundles(bis)
+	}
+}
+
+// MarshalState of the underlying algorithm, if it is a RoutingStatePersister.
+func (fc *FilterChain) MarshalState() ([]byte, error) {
+	if persister, ok := fc.algorithm.(RoutingStatePersister); ok {
+		return persister.MarshalState()
+	}
+	return nil, nil
+}
+
+// UnmarshalState of the underlying algorithm, if it is a RoutingStatePersister.
+func (fc *FilterChain) UnmarshalState(data []byte, downtime time.Duration) error {
+	if persister, ok := fc.algorithm.(RoutingStatePersister); ok {
+		return persister.UnmarshalState(data, downtime)
+	}
+	return nil
+}
+
+func (fc *FilterChain) String() string {
+	return fmt.Sprintf("filter chain overlaying %v", fc.algorithm)
+}
+
+// containsSender checks if a ConvergenceSender is part of a slice.
+func containsSender(senders []cla.ConvergenceSender, cs cla.ConvergenceSender) bool {
+	for _, s := range senders {
+		if s == cs {
+			return true
+		}
+	}
+	return false
+}
+
+// excludePeersFilter removes peers whose Node ID matches a regex, unless the bundle is addressed to them.
+type excludePeersFilter struct {
+	peers *regexp.Regexp
+}
+
+func newExcludePeersFilter(_ *Core, conf RoutingFilterConfig) (RoutingFilter, error) {
+	if conf.Regex == "" {
+		return nil, fmt.Errorf("exclude-peers filter requires a regex")
+	}
+
+	peers, err := regexp.Compile(conf.Regex)
+	if err != nil {
+		return nil, err
+	}
+	return &excludePeersFilter{peers: peers}, nil
+}
+
+func (f *excludePeersFilter) Filter(bp BundlePack, senders []cla.ConvergenceSender) (filtered []cla.ConvergenceSender) {
+	destination := bp.MustBundle().PrimaryBlock.Destination
+
+	for _, cs := range senders {
+		peer := cs.GetPeerEndpointID()
+		if !f.peers.MatchString(peer.String()) || peer.SameNode(destination) {
+			filtered = append(filtered, cs)
+		}
+	}
+	return
+}
+
+// maxCopiesFilter limits the amount of peers to receive a bundle, tracked as "routing/filter/copies".
+type maxCopiesFilter struct {
+	c         *Core
+	maxCopies int
+}
+
+func newMaxCopiesFilter(c *Core, conf RoutingFilterConfig) (RoutingFilter, error) {
+	if conf.MaxCopies == 0 {
+		return nil, fmt.Errorf("max-copies filter requires a positive max-copies value")
+	}
+	return &maxCopiesFilter{c: c, maxCopies: int(conf.MaxCopies)}, nil
+}
+
+func (f *maxCopiesFilter) Filter(bp BundlePack, senders []cla.ConvergenceSender) (filtered []cla.ConvergenceSender) {
+	bi, err := f.c.store.QueryId(bp.Id)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bundle": bp.ID(),
+			"error":  err,
+		}).Warn("Failed to proceed a non-stored Bundle")
+		return
+	}
+
+	copies, _ := bi.Properties["routing/filter/copies"].([]bundle.EndpointID)
+	for _, cs := range senders {
+		if len(copies) >= f.maxCopies {
+			break
+		}
+
+		filtered = append(filtered, cs)
+		copies = append(copies, cs.GetPeerEndpointID())
+	}
+
+	bi.Properties["routing/filter/copies"] = copies
+	if err := f.c.store.Update(bi); err != nil {
+		log.WithFields(log.Fields{
+			"bundle": bp.ID(),
+			"error":  err,
+		}).Warn("Updating BundleItem failed")
+	}
+	return
+}
+
+// ReportFailure releases the copy of a failed transmission.
+func (f *maxCopiesFilter) ReportFailure(bp BundlePack, sender cla.ConvergenceSender) {
+	bi, err := f.c.store.QueryId(bp.Id)
+	if err != nil {
+		return
+	}
+
+	copies, _ := bi.Properties["routing/filter/copies"].([]bundle.EndpointID)
+	for i := range copies {
+		if copies[i] == sender.GetPeerEndpointID() {
+			bi.Properties["routing/filter/copies"] = append(copies[:i], copies[i+1:]...)
+			if err := f.c.store.Update(bi); err != nil {
+				log.WithFields(log.Fields{
+					"bundle": bp.ID(),
+					"error":  err,
+				}).Warn("Updating BundleItem failed")
+			}
+			return
+		}
+	}
+}
+
+// noPreviousNodeFilter prevents sending a bundle back to the node it was received from.
+//
+// As the PreviousNodeBlock is replaced before forwarding, the previous node is stored as "routing/filter/previous-node"
+// on reception.
+type noPreviousNodeFilter struct {
+	c *Core
+}
+
+func newNoPreviousNodeFilter(c *Core, _ RoutingFilterConfig) (RoutingFilter, error) {
+	return &noPreviousNodeFilter{c: c}, nil
+}
+
+func (f *noPreviousNodeFilter) NotifyIncoming(bp BundlePack) {
+	pnBlock, err := bp.MustBundle().ExtensionBlock(bundle.ExtBlockTypePreviousNodeBlock)
+	if err != nil {
+		return
+	}
+
+	bi, err := f.c.store.QueryId(bp.Id)
+	if err != nil {
+		return
+	}
+
+	bi.Properties["routing/filter/previous-node"] = pnBlock.Value.(*bundle.PreviousNodeBlock).Endpoint()
+	if err := f.c.store.Update(bi); err != nil {
+		log.WithFields(log.Fields{
+			"bundle": bp.ID(),
+			"error":  err,
+		}).Warn("Updating BundleItem failed")
+	}
+}
+
+func (f *noPreviousNodeFilter) Filter(bp BundlePack, senders []cla.ConvergenceSender) (filtered []cla.ConvergenceSender) {
+	bi, err := f.c.store.QueryId(bp.Id)
+	if err != nil {
+		return senders
+	}
+
+	prevNode, ok := bi.Properties["routing/filter/previous-node"].(bundle.EndpointID)
+	if !ok {
+		return senders
+	}
+
+	for _, cs := range senders {
+		if !cs.GetPeerEndpointID().SameNode(prevNode) {
+			filtered = append(filtered, cs)
+		}
+	}
+	return
+}
+
+// preferClaFilter keeps only the most preferred ConvergenceSender for peers reachable by multiple CLA types.
+type preferClaFilter struct {
+	claTypes []string
+}
+
+func newPreferClaFilter(_ *Core, conf RoutingFilterConfig) (RoutingFilter, error) {
+	if len(conf.ClaTypes) == 0 {
+		return nil, fmt.Errorf("prefer-cla filter requires cla-types")
+	}
+	return &preferClaFilter{claTypes: conf.ClaTypes}, nil
+}
+
+// rank of a ConvergenceSender's type; lower is better and unlisted types are ranked last.
+func (f *preferClaFilter) rank(cs cla.ConvergenceSender) int {
+	claType := convergenceType(cs)
+	for i, preferred := range f.claTypes {
+		if claType == preferred {
+			return i
+		}
+	}
+	return len(f.claTypes)
+}
+
+func (f *preferClaFilter) Filter(_ BundlePack, senders []cla.ConvergenceSender) (filtered []cla.ConvergenceSender) {
+	for _, cs := range senders {
+		best := true
+		for _, other := range senders {
+			if other != cs && other.GetPeerEndpointID().SameNode(cs.GetPeerEndpointID()) && f.rank(other) < f.rank(cs) {
+				best = false
+				break
+			}
+		}
+
+		if best {
+			filtered = append(filtered, cs)
+		}
+	}
+	return
+}
+
+// bundleSizeFilter removes ConvergenceSenders of the restricted CLA types, or all if none are given, for bundles
+// exceeding the maximum size.
+type bundleSizeFilter struct {
+	maxSize  int
+	claTypes []string
+}
+
+func newBundleSizeFilter(_ *Core, conf RoutingFilterConfig) (RoutingFilter, error) {
+	if conf.MaxSize <= 0 {
+		return nil, fmt.Errorf("bundle-size filter requires a positive max-size value")
+	}
+	return &bundleSizeFilter{maxSize: conf.MaxSize, claTypes: conf.ClaTypes}, nil
+}
+
+func (f *bundleSizeFilter) Filter(bp BundlePack, senders []cla.ConvergenceSender) (filtered []cla.ConvergenceSender) {
+	if int(storage.BundleSize(*bp.MustBundle())) <= f.maxSize {
+		return senders
+	}
+
+	for _, cs := range senders {
+		restricted := len(f.claTypes) == 0
+		for _, claType := range f.claTypes {
+			if convergenceType(cs) == claType {
+				restricted = true
+				break
+			}
+		}
+
+		if !restricted {
+			filtered = append(filtered, cs)
+		}
+	}
+	return
+}
@@ -0,0 +1,188 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+	"github.com/dtn7/dtn7-go/cla/mtcp"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+// peerConvSender is a mtuConvSender for a specific peer.
+type peerConvSender struct {
+	mtuConvSender
+	peer bundle.EndpointID
+}
+
+func (p *peerConvSender) GetPeerEndpointID() bundle.EndpointID { return p.peer }
+
+// fixedRouting is a RoutingAlgorithm always selecting the same ConvergenceSenders, recording reported failures.
+type fixedRouting struct {
+	senders  []cla.ConvergenceSender
+	failures []cla.ConvergenceSender
+}
+
+func (_ *fixedRouting) NotifyIncoming(_ BundlePack)             {}
+func (_ *fixedRouting) DispatchingAllowed(_ BundlePack) bool    { return true }
+func (_ *fixedRouting) ReportPeerAppeared(_ cla.Convergence)    {}
+func (_ *fixedRouting) ReportPeerDisappeared(_ cla.Convergence) {}
+
+func (fr *fixedRouting) SenderForBundle(_ BundlePack) ([]cla.ConvergenceSender, bool) {
+	return append([]cla.ConvergenceSender(nil), fr.senders...), true
+}
+
+func (fr *fixedRouting) ReportFailure(_ BundlePack, sender cla.ConvergenceSender) {
+	fr.failures = append(fr.failures, sender)
+}
+
+func TestFilterChain(t *testing.T) {
+	registerGobTypes()
+
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := storage.NewStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	c := &Core{NodeId: bundle.MustNewEndpointID("dtn://local/"), store: store}
+
+	sensor := &peerConvSender{peer: bundle.MustNewEndpointID("dtn://tree.sensor/")}
+	prevNode := &peerConvSender{peer: bundle.MustNewEndpointID("dtn://prev/")}
+	peer := &peerConvSender{peer: bundle.MustNewEndpointID("dtn://peer/")}
+	peerMtcp := mtcp.NewMTCPClient("localhost:35037", bundle.MustNewEndpointID("dtn://peer/"), false)
+	other := &peerConvSender{peer: bundle.MustNewEndpointID("dtn://other/")}
+
+	algorithm := &fixedRouting{senders: []cla.ConvergenceSender{sensor, prevNode, peer, peerMtcp, other}}
+
+	var filters []RoutingFilter
+	for _, conf := range []RoutingFilterConfig{
+		{Type: "exclude-peers", Regex: "^dtn://[^/]+\\.sensor/.*$"},
+		{Type: "no-previous-node"},
+		{Type: "prefer-cla", ClaTypes: []string{"mtcp"}},
+		{Type: "max-copies", MaxCopies: 1},
+	} {
+		if filter, err := NewRoutingFilter(c, conf); err != nil {
+			t.Fatal(err)
+		} else {
+			filters = append(filters, filter)
+		}
+	}
+
+	fc := NewFilterChain(algorithm, filters)
+
+	b, err := bundle.Builder().
+		BundleCtrlFlags(0).
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("1h").
+		Canonical(bundle.NewPreviousNodeBlock(bundle.MustNewEndpointID("dtn://prev/"))).
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bp := NewBundlePackFromBundle(b, store)
+	fc.NotifyIncoming(bp)
+
+	if css, del := fc.SenderForBundle(bp); len(css) != 1 || css[0] != peerMtcp || !del {
+		t.Fatalf("Filter chain selected %v, delete: %t", css, del)
+	}
+
+	for _, excluded := range []cla.ConvergenceSender{sensor, prevNode, peer, other} {
+		if !containsSender(algorithm.failures, excluded) {
+			t.Fatalf("Excluded %v was not reported", excluded)
+		}
+	}
+
+	if css, del := fc.SenderForBundle(bp); len(css) != 0 || del {
+		t.Fatalf("Filter chain exceeded its copies: %v, delete: %t", css, del)
+	}
+
+	fc.ReportFailure(bp, peerMtcp)
+	if css, _ := fc.SenderForBundle(bp); len(css) != 1 {
+		t.Fatalf("Failed copy was not released: %v", css)
+	}
+}
+
+func TestBundleSizeFilter(t *testing.T) {
+	filter, err := NewRoutingFilter(nil, RoutingFilterConfig{Type: "bundle-size", MaxSize: 512, ClaTypes: []string{"core"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restricted := &peerConvSender{peer: bundle.MustNewEndpointID("dtn://lora/")}
+	unrestricted := mtcp.NewMTCPClient("localhost:35037", bundle.MustNewEndpointID("dtn://wifi/"), false)
+	senders := []cla.ConvergenceSender{restricted, unrestricted}
+
+	for _, size := range []int{64, 1024} {
+		b, err := bundle.Builder().
+			BundleCtrlFlags(0).
+			Source("dtn://src/").
+			Destination("dtn://dst/").
+			CreationTimestampNow().
+			Lifetime("1h").
+			PayloadBlock(make([]byte, size)).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		filtered := filter.Filter(BundlePack{bndl: &b}, senders)
+		if large := size > 512; containsSender(filtered, restricted) == large || !containsSender(filtered, unrestricted) {
+			t.Fatalf("Bundle of %d bytes was filtered to %v", size, filtered)
+		}
+	}
+}
+
+// dropAllFilter is a third-party RoutingFilter, removing all ConvergenceSenders.
+type dropAllFilter struct{}
+
+func (_ dropAllFilter) Filter(_ BundlePack, _ []cla.ConvergenceSender) []cla.ConvergenceSender {
+	return nil
+}
+
+func TestRegisterRoutingFilter(t *testing.T) {
+	factory := func(_ *Core, _ RoutingFilterConfig) (RoutingFilter, error) { return dropAllFilter{}, nil }
+
+	if err := RegisterRoutingFilter("max-copies", factory); err == nil {
+		t.Fatal("Built-in routing filter was replaced")
+	}
+
+	// Ignore the error of an already registered filter for repeated test runs.
+	_ = RegisterRoutingFilter("test-drop-all", factory)
+
+	conf := RoutingConf{
+		Algorithm: "filter-chain",
+		FilterChainConf: FilterChainConfig{
+			Algorithm: &RoutingConf{Algorithm: "epidemic"},
+			Filters:   []RoutingFilterConfig{{Type: "test-drop-all"}},
+		},
+	}
+
+	if ra, err := conf.RoutingAlgorithm(&Core{}); err != nil {
+		t.Fatal(err)
+	} else if fc, ok := ra.(*FilterChain); !ok || len(fc.filters) != 1 {
+		t.Fatalf("Routing algorithm is %v", ra)
+	}
+
+	conf.FilterChainConf.Filters = []RoutingFilterConfig{{Type: "unknown"}}
+	if _, err := conf.RoutingAlgorithm(&Core{}); err == nil {
+		t.Fatal("Unknown routing filter did not error")
+	}
+}
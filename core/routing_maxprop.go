@@ -0,0 +1,669 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/cboring"
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+// MaxPropConfig contains the configuration for MaxProp.
+type MaxPropConfig struct {
+	// HopThreshold prioritizes bundles which have traversed fewer hops. Those are sent first, ordered by their hop
+	// count, followed by all other bundles, ordered by their delivery cost. Zero orders all bundles by their cost.
+	HopThreshold uint64 `toml:"hop-threshold"`
+}
+
+// maxPropVector is a node's meeting probability vector.
+type maxPropVector struct {
+	// Encounters is the node's amount of encounters, identifying the vector's most recent version.
+	Encounters uint64
+
+	// Probabilities to meet other nodes, summing up to one.
+	Probabilities map[bundle.EndpointID]float64
+}
+
+// MaxProp is an implementation of the MaxProp routing algorithm, as proposed by Burgess et al.
+//
+// Each node tracks the probabilities of meeting other nodes. These vectors are flooded through the network within
+// metadata bundles, allowing each node to calculate the cost of the most likely path to a bundle's destination.
+// Bundles are replicated to each encountered peer. On each contact, bundles which have traversed fewer hops than the
+// configured threshold are sent first, followed by all other bundles ordered by their delivery cost. Delivered
+// bundles are acknowledged by flooding their IDs, purging the remaining copies.
+type MaxProp struct {
+	c      *Core
+	config MaxPropConfig
+
+	// vectors are the meeting probability vectors of all known nodes, including this one
+	vectors map[bundle.EndpointID]maxPropVector
+	// acks maps the IDs of delivered bundles to their expiration
+	acks map[bundle.BundleID]time.Time
+	// dataMutex protects the vectors and acks
+	dataMutex sync.RWMutex
+}
+
+// NewMaxProp creates a new MaxProp RoutingAlgorithm interacting with the given Core.
+func NewMaxProp(c *Core, config MaxPropConfig) *MaxProp {
+	log.WithField("hop_threshold", config.HopThreshold).Info("Initialised MaxProp")
+
+	maxProp := &MaxProp{
+		c:       c,
+		config:  config,
+		vectors: make(map[bundle.EndpointID]maxPropVector),
+		acks:    make(map[bundle.BundleID]time.Time),
+	}
+
+	extensionBlockManager := bundle.GetExtensionBlockManager()
+	if !extensionBlockManager.IsKnown(bundle.ExtBlockTypeMaxPropBlock) {
+		_ = extensionBlockManager.Register(newMaxPropBlock(nil, nil))
+	}
+
+	return maxProp
+}
+
+// encounter increases the meeting probability for a peer by one and normalizes the vector afterwards.
+// The dataMutex must be held.
+func (mp *MaxProp) encounter(peer bundle.EndpointID) {
+	vector := mp.vectors[mp.c.NodeId]
+	if vector.Probabilities == nil {
+		vector.Probabilities = make(map[bundle.EndpointID]float64)
+	}
+
+	vector.Encounters++
+	vector.Probabilities[peer] += 1
+
+	var sum float64
+	for _, p := range vector.Probabilities {
+		sum += p
+	}
+	for node := range vector.Probabilities {
+		vector.Probabilities[node] /= sum
+	}
+
+	mp.vectors[mp.c.NodeId] = vector
+
+	log.WithFields(log.Fields{
+		"peer":        peer,
+		"probability": vector.Probabilities[peer],
+	}).Debug("MaxProp updated meeting probability via encounter")
+}
+
+// costs calculates the cost of the most likely path to each known node, based on the meeting probability vectors.
+// A path's cost is the sum of each hop's probability of not meeting. The dataMutex must be held.
+func (mp *MaxProp) costs() map[bundle.EndpointID]float64 {
+	costs := map[bundle.EndpointID]float64{mp.c.NodeId: 0}
+	visited := make(map[bundle.EndpointID]bool)
+
+	for {
+		var node bundle.EndpointID
+		var nodeCost = math.Inf(1)
+		for n, cost := range costs {
+			if !visited[n] && cost < nodeCost {
+				node, nodeCost = n, cost
+			}
+		}
+
+		if math.IsInf(nodeCost, 1) {
+			return costs
+		}
+		visited[node] = true
+
+		for next, p := range mp.vectors[node].Probabilities {
+			if oldCost, ok := costs[next]; !ok || nodeCost+(1-p) < oldCost {
+				costs[next] = nodeCost + (1 - p)
+			}
+		}
+	}
+}
+
+// cost returns the delivery cost for a destination, based on the given path costs.
+func (_ *MaxProp) cost(costs map[bundle.EndpointID]float64, destination bundle.EndpointID) float64 {
+	cost := math.Inf(1)
+	for node, nodeCost := range costs {
+		if node.SameNode(destination) && nodeCost < cost {
+			cost = nodeCost
+		}
+	}
+	return cost
+}
+
+// isAcked checks if a bundle's delivery was acknowledged.
+func (mp *MaxProp) isAcked(bid bundle.BundleID) bool {
+	mp.dataMutex.RLock()
+	defer mp.dataMutex.RUnlock()
+
+	_, ok := mp.acks[bid.Scrub()]
+	return ok
+}
+
+// expireAcks removes the acknowledgements of expired bundles. The dataMutex must be held.
+func (mp *MaxProp) expireAcks(now time.Time) {
+	for bid, expires := range mp.acks {
+		if now.After(expires) {
+			delete(mp.acks, bid)
+		}
+	}
+}
+
+// merge a peer's vectors and acknowledgements. The newly acknowledged bundle IDs are returned.
+func (mp *MaxProp) merge(block *MaxPropBlock) (newAcks []bundle.BundleID) {
+	mp.dataMutex.Lock()
+	defer mp.dataMutex.Unlock()
+
+	for node, vector := range block.vectors {
+		if node == mp.c.NodeId {
+			continue
+		}
+
+		if known, ok := mp.vectors[node]; !ok || vector.Encounters > known.Encounters {
+			mp.vectors[node] = vector
+		}
+	}
+
+	now := time.Now()
+	mp.expireAcks(now)
+
+	for bid, expires := range block.acks {
+		if _, ok := mp.acks[bid]; ok || now.After(expires) {
+			continue
+		}
+
+		mp.acks[bid] = expires
+		newAcks = append(newAcks, bid)
+	}
+
+	return
+}
+
+// acknowledge the delivery of a bundle to this node. True is returned if this acknowledgement is new.
+func (mp *MaxProp) acknowledge(bp BundlePack) bool {
+	mp.dataMutex.Lock()
+	defer mp.dataMutex.Unlock()
+
+	bid := bp.Id.Scrub()
+	if _, ok := mp.acks[bid]; ok {
+		return false
+	}
+
+	mp.acks[bid] = bp.ExpirationTime()
+	return true
+}
+
+// purge the stored copy of an acknowledged bundle, unless it is addressed to this node.
+func (mp *MaxProp) purge(bid bundle.BundleID) {
+	bi, err := mp.c.store.QueryId(bid)
+	if err != nil || !bi.Pending {
+		return
+	}
+
+	bp := NewBundlePack(bi.BId, mp.c.store)
+	if bndl, err := bp.Bundle(); err != nil || mp.c.HasEndpoint(bndl.PrimaryBlock.Destination) {
+		return
+	}
+
+	log.WithField("bundle", bp.ID()).Info("MaxProp purges acknowledged bundle")
+
+	mp.c.bundleDeletion(bp, bundle.NoInformation)
+}
+
+// sendMetadata sends our known vectors and acknowledgements to a peer.
+func (mp *MaxProp) sendMetadata(destination bundle.EndpointID) {
+	mp.dataMutex.Lock()
+	mp.expireAcks(time.Now())
+	metadataBlock := newMaxPropBlock(mp.vectors, mp.acks)
+	mp.dataMutex.Unlock()
+
+	if err := sendMetadataBundle(mp.c, mp.c.NodeId, destination, metadataBlock); err != nil {
+		log.WithFields(log.Fields{
+			"peer":  destination,
+			"error": err,
+		}).Warn("MaxProp was unable to send metadata bundle")
+	}
+}
+
+// floodMetadata sends our metadata to all connected peers, except the excluded one.
+func (mp *MaxProp) floodMetadata(exclude bundle.EndpointID) {
+	for _, cs := range mp.c.claManager.Sender() {
+		if peer := cs.GetPeerEndpointID(); peer != (bundle.EndpointID{}) && !peer.SameNode(exclude) {
+			mp.sendMetadata(peer)
+		}
+	}
+}
+
+// NotifyIncoming handles received metadata and records the hop count, destination and previous node of other
+// bundles. Bundles addressed to this node will be acknowledged.
+func (mp *MaxProp) NotifyIncoming(bp BundlePack) {
+	bndl := bp.MustBundle()
+
+	if metadataBlock, err := bndl.ExtensionBlock(bundle.ExtBlockTypeMaxPropBlock); err == nil {
+		if bndl.PrimaryBlock.Destination != mp.c.NodeId {
+			return
+		}
+
+		peer := bndl.PrimaryBlock.SourceNode
+		newAcks := mp.merge(metadataBlock.Value.(*MaxPropBlock))
+
+		log.WithFields(log.Fields{
+			"peer": peer,
+			"acks": len(newAcks),
+		}).Debug("MaxProp received metadata")
+
+		for _, bid := range newAcks {
+			mp.purge(bid)
+		}
+		if len(newAcks) > 0 {
+			mp.floodMetadata(peer)
+		}
+		return
+	}
+
+	bi, err := mp.c.store.QueryId(bp.Id)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bundle": bp.ID(),
+			"error":  err,
+		}).Warn("Failed to proceed a non-stored Bundle")
+		return
+	}
+
+	var hops uint64
+	if hcBlock, err := bndl.ExtensionBlock(bundle.ExtBlockTypeHopCountBlock); err == nil {
+		hops = uint64(hcBlock.Value.(*bundle.HopCountBlock).Count)
+	}
+	bi.Properties["routing/maxprop/hops"] = hops
+	bi.Properties["routing/maxprop/destination"] = bndl.PrimaryBlock.Destination
+
+	if pnBlock, err := bndl.ExtensionBlock(bundle.ExtBlockTypePreviousNodeBlock); err == nil {
+		prevNode := pnBlock.Value.(*bundle.PreviousNodeBlock).Endpoint()
+
+		sentEids, _ := bi.Properties["routing/maxprop/sent"].([]bundle.EndpointID)
+		known := false
+		for _, eid := range sentEids {
+			if eid == prevNode {
+				known = true
+				break
+			}
+		}
+		if !known {
+			bi.Properties["routing/maxprop/sent"] = append(sentEids, prevNode)
+		}
+	}
+
+	if err := mp.c.store.Update(bi); err != nil {
+		log.WithFields(log.Fields{
+			"bundle": bp.ID(),
+			"error":  err,
+		}).Warn("Updating BundleItem failed")
+	}
+
+	if mp.c.HasEndpoint(bndl.PrimaryBlock.Destination) && mp.acknowledge(bp) {
+		log.WithField("bundle", bp.ID()).Debug("MaxProp floods acknowledgement of delivered bundle")
+
+		mp.floodMetadata(bundle.EndpointID{})
+	}
+}
+
+// DispatchingAllowed purges bundles whose delivery was already acknowledged.
+func (mp *MaxProp) DispatchingAllowed(bp BundlePack) bool {
+	if !mp.isAcked(bp.Id) || mp.c.HasEndpoint(bp.MustBundle().PrimaryBlock.Destination) {
+		return true
+	}
+
+	log.WithField("bundle", bp.ID()).Info("MaxProp drops bundle, whose delivery was acknowledged")
+
+	mp.c.bundleDeletion(bp, bundle.NoInformation)
+	return false
+}
+
+// SenderForBundle replicates a bundle to each connected peer, which has not received it yet.
+func (mp *MaxProp) SenderForBundle(bp BundlePack) (css []cla.ConvergenceSender, del bool) {
+	if _, err := bp.MustBundle().ExtensionBlock(bundle.ExtBlockTypeMaxPropBlock); err == nil {
+		// Metadata is only sent directly to peers.
+		return nil, true
+	}
+
+	bi, err := mp.c.store.QueryId(bp.Id)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bundle": bp.ID(),
+			"error":  err,
+		}).Warn("Failed to proceed a non-stored Bundle")
+		return
+	}
+
+	css, sentEids := filterCLAs(bi, mp.c.claManager.Sender(), "maxprop")
+
+	bi.Properties["routing/maxprop/sent"] = sentEids
+	if err := mp.c.store.Update(bi); err != nil {
+		log.WithFields(log.Fields{
+			"bundle": bp.ID(),
+			"error":  err,
+		}).Warn("Updating BundleItem failed")
+	}
+
+	log.WithFields(log.Fields{
+		"bundle":              bp.ID(),
+		"sent":                sentEids,
+		"convergence-senders": css,
+	}).Debug("MaxProp selected Convergence Senders for an outgoing bundle")
+
+	return css, false
+}
+
+// PrioritizeBundles orders the pending bundles for the next transmissions. Bundles below the hop threshold come
+// first, ordered by their hop count, followed by all others, ordered by their delivery cost.
+func (mp *MaxProp) PrioritizeBundles(bis []storage.BundleItem) {
+	mp.dataMutex.RLock()
+	costs := mp.costs()
+	mp.dataMutex.RUnlock()
+
+	type priority struct {
+		hops uint64
+		cost float64
+	}
+
+	priorities := make(map[string]priority, len(bis))
+	for _, bi := range bis {
+		var prio = priority{cost: math.Inf(1)}
+		if hops, ok := bi.Properties["routing/maxprop/hops"].(uint64); ok {
+			prio.hops = hops
+		}
+		if destination, ok := bi.Properties["routing/maxprop/destination"].(bundle.EndpointID); ok {
+			prio.cost = mp.cost(costs, destination)
+		}
+		priorities[bi.Id] = prio
+	}
+
+	sort.SliceStable(bis, func(i, j int) bool {
+		pi, pj := priorities[bis[i].Id], priorities[bis[j].Id]
+		iBelow, jBelow := pi.hops < mp.config.HopThreshold, pj.hops < mp.config.HopThreshold
+
+		switch {
+		case iBelow && jBelow:
+			return pi.hops < pj.hops
+		case iBelow != jBelow:
+			return iBelow
+		default:
+			return pi.cost < pj.cost
+		}
+	})
+}
+
+// ReportFailure removes the failed peer from the bundle's sent list, allowing a later retransmission.
+func (mp *MaxProp) ReportFailure(bp BundlePack, sender cla.ConvergenceSender) {
+	bi, err := mp.c.store.QueryId(bp.Id)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bundle": bp.ID(),
+			"error":  err,
+		}).Warn("Failed to proceed a non-stored Bundle")
+		return
+	}
+
+	sentEids, _ := bi.Properties["routing/maxprop/sent"].([]bundle.EndpointID)
+	for i := 0; i < len(sentEids); i++ {
+		if sentEids[i] == sender.GetPeerEndpointID() {
+			sentEids = append(sentEids[:i], sentEids[i+1:]...)
+			break
+		}
+	}
+
+	bi.Properties["routing/maxprop/sent"] = sentEids
+	if err := mp.c.store.Update(bi); err != nil {
+		log.WithFields(log.Fields{
+			"bundle": bp.ID(),
+			"error":  err,
+		}).Warn("Updating BundleItem failed")
+	}
+}
+
+// ReportPeerAppeared updates the meeting probabilities and sends our metadata to the new peer.
+func (mp *MaxProp) ReportPeerAppeared(peer cla.Convergence) {
+	cs, ok := peer.(cla.ConvergenceSender)
+	if !ok {
+		return
+	}
+
+	peerID := cs.GetPeerEndpointID()
+	if peerID == (bundle.EndpointID{}) {
+		return
+	}
+
+	mp.dataMutex.Lock()
+	mp.encounter(peerID)
+	mp.dataMutex.Unlock()
+
+	mp.sendMetadata(peerID)
+}
+
+func (_ *MaxProp) ReportPeerDisappeared(_ cla.Convergence) {}
+
+// maxPropState is MaxProp's persisted state, compare RoutingStatePersister.
+type maxPropState struct {
+	Vectors map[bundle.EndpointID]maxPropVector
+	Acks    map[bundle.BundleID]time.Time
+}
+
+// MarshalState serializes the meeting probability vectors and acknowledgements.
+func (mp *MaxProp) MarshalState() ([]byte, error) {
+	mp.dataMutex.RLock()
+	defer mp.dataMutex.RUnlock()
+
+	return marshalRoutingState(maxPropState{Vectors: mp.vectors, Acks: mp.acks})
+}
+
+// UnmarshalState restores the vectors and all acknowledgements of not yet expired bundles.
+func (mp *MaxProp) UnmarshalState(data []byte, _ time.Duration) error {
+	var state maxPropState
+	if err := unmarshalRoutingState(data, &state); err != nil {
+		return err
+	}
+
+	mp.dataMutex.Lock()
+	defer mp.dataMutex.Unlock()
+
+	for node, vector := range state.Vectors {
+		if known, ok := mp.vectors[node]; !ok || vector.Encounters > known.Encounters {
+			mp.vectors[node] = vector
+		}
+	}
+	for bid, expires := range state.Acks {
+		mp.acks[bid] = expires
+	}
+	mp.expireAcks(time.Now())
+
+	return nil
+}
+
+func (_ *MaxProp) String() string {
+	return "maxprop"
+}
+
+// MaxPropBlock contains MaxProp's meeting probability vectors and acknowledgements of delivered bundles.
+type MaxPropBlock struct {
+	vectors map[bundle.EndpointID]maxPropVector
+	acks    map[bundle.BundleID]time.Time
+}
+
+// newMaxPropBlock creates a new MaxPropBlock containing copies of the given vectors and acknowledgements.
+func newMaxPropBlock(vectors map[bundle.EndpointID]maxPropVector, acks map[bundle.BundleID]time.Time) *MaxPropBlock {
+	block := &MaxPropBlock{
+		vectors: make(map[bundle.EndpointID]maxPropVector, len(vectors)),
+		acks:    make(map[bundle.BundleID]time.Time, len(acks)),
+	}
+
+	for node, vector := range vectors {
+		probabilities := make(map[bundle.EndpointID]float64, len(vector.Probabilities))
+		for peer, p := range vector.Probabilities {
+			probabilities[peer] = p
+		}
+		block.vectors[node] = maxPropVector{Encounters: vector.Encounters, Probabilities: probabilities}
+	}
+	for bid, expires := range acks {
+		block.acks[bid] = expires
+	}
+
+	return block
+}
+
+func (mpb *MaxPropBlock) BlockTypeCode() uint64 {
+	return bundle.ExtBlockTypeMaxPropBlock
+}
+
+func (mpb *MaxPropBlock) CheckValid() error {
+	return nil
+}
+
+// MarshalCbor writes the vectors as a map of each node to its encounters and probabilities, followed by an array of
+// acknowledgements, each consisting of the bundle's source, creation timestamp and expiration.
+func (mpb *MaxPropBlock) MarshalCbor(w io.Writer) error {
+	if err := cboring.WriteArrayLength(2, w); err != nil {
+		return err
+	}
+
+	if err := cboring.WriteMapPairLength(uint64(len(mpb.vectors)), w); err != nil {
+		return err
+	}
+	for node, vector := range mpb.vectors {
+		if err := cboring.Marshal(&node, w); err != nil {
+			return err
+		}
+
+		if err := cboring.WriteArrayLength(2, w); err != nil {
+			return err
+		}
+		if err := cboring.WriteUInt(vector.Encounters, w); err != nil {
+			return err
+		}
+		if err := cboring.WriteMapPairLength(uint64(len(vector.Probabilities)), w); err != nil {
+			return err
+		}
+		for peer, p := range vector.Probabilities {
+			if err := cboring.Marshal(&peer, w); err != nil {
+				return err
+			}
+			if err := cboring.WriteFloat64(p, w); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := cboring.WriteArrayLength(uint64(len(mpb.acks)), w); err != nil {
+		return err
+	}
+	for bid, expires := range mpb.acks {
+		if err := cboring.WriteArrayLength(3, w); err != nil {
+			return err
+		}
+		if err := cboring.Marshal(&bid.SourceNode, w); err != nil {
+			return err
+		}
+		if err := cboring.Marshal(&bid.Timestamp, w); err != nil {
+			return err
+		}
+		if err := cboring.WriteUInt(uint64(expires.Unix()), w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (mpb *MaxPropBlock) UnmarshalCbor(r io.Reader) error {
+	if l, err := cboring.ReadArrayLength(r); err != nil {
+		return err
+	} else if l != 2 {
+		return fmt.Errorf("expected 2 fields, got %d", l)
+	}
+
+	vectorsLen, err := cboring.ReadMapPairLength(r)
+	if err != nil {
+		return err
+	}
+
+	vectors := make(map[bundle.EndpointID]maxPropVector, vectorsLen)
+	for i := uint64(0); i < vectorsLen; i++ {
+		var node bundle.EndpointID
+		if err := cboring.Unmarshal(&node, r); err != nil {
+			return err
+		}
+
+		if l, err := cboring.ReadArrayLength(r); err != nil {
+			return err
+		} else if l != 2 {
+			return fmt.Errorf("expected 2 vector fields, got %d", l)
+		}
+
+		var vector maxPropVector
+		if vector.Encounters, err = cboring.ReadUInt(r); err != nil {
+			return err
+		}
+
+		probabilitiesLen, err := cboring.ReadMapPairLength(r)
+		if err != nil {
+			return err
+		}
+
+		vector.Probabilities = make(map[bundle.EndpointID]float64, probabilitiesLen)
+		for j := uint64(0); j < probabilitiesLen; j++ {
+			var peer bundle.EndpointID
+			if err := cboring.Unmarshal(&peer, r); err != nil {
+				return err
+			}
+
+			p, err := cboring.ReadFloat64(r)
+			if err != nil {
+				return err
+			}
+			vector.Probabilities[peer] = p
+		}
+
+		vectors[node] = vector
+	}
+
+	acksLen, err := cboring.ReadArrayLength(r)
+	if err != nil {
+		return err
+	}
+
+	acks := make(map[bundle.BundleID]time.Time, acksLen)
+	for i := uint64(0); i < acksLen; i++ {
+		if l, err := cboring.ReadArrayLength(r); err != nil {
+			return err
+		} else if l != 3 {
+			return fmt.Errorf("expected 3 acknowledgement fields, got %d", l)
+		}
+
+		var bid bundle.BundleID
+		if err := cboring.Unmarshal(&bid.SourceNode, r); err != nil {
+			return err
+		}
+		if err := cboring.Unmarshal(&bid.Timestamp, r); err != nil {
+			return err
+		}
+
+		expires, err := cboring.ReadUInt(r)
+		if err != nil {
+			return err
+		}
+		acks[bid] = time.Unix(int64(expires), 0)
+	}
+
+	mpb.vectors = vectors
+	mpb.acks = acks
+
+	return nil
+}
@@ -0,0 +1,236 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+func TestMaxPropBlockCbor(t *testing.T) {
+	_ = NewMaxProp(&Core{NodeId: bundle.MustNewEndpointID("dtn://a/")}, MaxPropConfig{})
+
+	vectors := map[bundle.EndpointID]maxPropVector{
+		bundle.MustNewEndpointID("dtn://a/"): {
+			Encounters: 3,
+			Probabilities: map[bundle.EndpointID]float64{
+				bundle.MustNewEndpointID("dtn://b/"): 0.25,
+				bundle.MustNewEndpointID("dtn://c/"): 0.75,
+			},
+		},
+	}
+	acks := map[bundle.BundleID]time.Time{
+		{
+			SourceNode: bundle.MustNewEndpointID("dtn://src/"),
+			Timestamp:  bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 23),
+		}: time.Unix(time.Now().Add(time.Hour).Unix(), 0),
+	}
+
+	b, err := bundle.Builder().
+		BundleCtrlFlags(0).
+		Source("dtn://a/").
+		Destination("dtn://b/").
+		CreationTimestampNow().
+		Lifetime("1m").
+		Canonical(newMaxPropBlock(vectors, acks)).
+		PayloadBlock(byte(1)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buff bytes.Buffer
+	if err := b.MarshalCbor(&buff); err != nil {
+		t.Fatal(err)
+	}
+
+	b2, err := bundle.ParseBundle(&buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cb, err := b2.ExtensionBlock(bundle.ExtBlockTypeMaxPropBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := cb.Value.(*MaxPropBlock)
+	if !reflect.DeepEqual(block.vectors, vectors) {
+		t.Fatalf("Vectors differ: %v != %v", block.vectors, vectors)
+	} else if !reflect.DeepEqual(block.acks, acks) {
+		t.Fatalf("Acknowledgements differ: %v != %v", block.acks, acks)
+	}
+}
+
+func TestMaxPropCosts(t *testing.T) {
+	eid := bundle.MustNewEndpointID
+
+	mp := NewMaxProp(&Core{NodeId: eid("dtn://a/")}, MaxPropConfig{})
+	mp.encounter(eid("dtn://b/"))
+	mp.encounter(eid("dtn://b/"))
+	mp.encounter(eid("dtn://c/"))
+
+	if p := mp.vectors[eid("dtn://a/")].Probabilities; p[eid("dtn://b/")] != 0.5 || p[eid("dtn://c/")] != 0.5 {
+		t.Fatalf("Meeting probabilities are %v", p)
+	}
+
+	mp.merge(newMaxPropBlock(map[bundle.EndpointID]maxPropVector{
+		eid("dtn://a/"): {Encounters: 23, Probabilities: map[bundle.EndpointID]float64{eid("dtn://z/"): 1}},
+		eid("dtn://c/"): {Encounters: 1, Probabilities: map[bundle.EndpointID]float64{eid("dtn://d/"): 1}},
+	}, nil))
+
+	costs := mp.costs()
+
+	tests := []struct {
+		destination string
+		cost        float64
+	}{
+		{"dtn://b/", 0.5},
+		{"dtn://d/inbox", 0.5},
+		{"dtn://z/", math.Inf(1)},
+	}
+	for _, test := range tests {
+		if cost := mp.cost(costs, eid(test.destination)); cost != test.cost {
+			t.Fatalf("Cost for %s is %f, expected %f", test.destination, cost, test.cost)
+		}
+	}
+}
+
+func TestMaxPropPrioritizeBundles(t *testing.T) {
+	eid := bundle.MustNewEndpointID
+
+	mp := NewMaxProp(&Core{NodeId: eid("dtn://a/")}, MaxPropConfig{HopThreshold: 2})
+	mp.encounter(eid("dtn://b/"))
+	mp.encounter(eid("dtn://c/"))
+	mp.encounter(eid("dtn://c/"))
+
+	item := func(id string, hops uint64, destination string) storage.BundleItem {
+		return storage.BundleItem{Id: id, Properties: map[string]interface{}{
+			"routing/maxprop/hops":        hops,
+			"routing/maxprop/destination": eid(destination),
+		}}
+	}
+
+	bis := []storage.BundleItem{
+		item("unknown", 5, "dtn://unknown/"),
+		item("far-b", 3, "dtn://b/"),
+		item("near-1", 1, "dtn://unknown/"),
+		item("far-c", 4, "dtn://c/"),
+		item("near-0", 0, "dtn://unknown/"),
+	}
+	mp.PrioritizeBundles(bis)
+
+	var order []string
+	for _, bi := range bis {
+		order = append(order, bi.Id)
+	}
+
+	if expected := []string{"near-0", "near-1", "far-c", "far-b", "unknown"}; !reflect.DeepEqual(order, expected) {
+		t.Fatalf("Bundles are ordered %v, expected %v", order, expected)
+	}
+}
+
+func TestMaxPropAcknowledgements(t *testing.T) {
+	registerGobTypes()
+
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := storage.NewStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	c := &Core{
+		NodeId:     bundle.MustNewEndpointID("dtn://local/"),
+		store:      store,
+		claManager: cla.NewManager(),
+	}
+	defer c.claManager.Close()
+
+	c.agentManager = NewAgentManager(c)
+	defer c.agentManager.Close()
+
+	c.claManager.Register(&mtuConvSender{})
+	for i := 0; len(c.claManager.Sender()) == 0; i++ {
+		if i == 100 {
+			t.Fatal("CLA was not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mp := NewMaxProp(c, MaxPropConfig{})
+	c.routing = mp
+
+	b, err := bundle.Builder().
+		BundleCtrlFlags(0).
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("1h").
+		HopCountBlock(64).
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bp := NewBundlePackFromBundle(b, store)
+	bp.AddConstraint(ForwardPending)
+	_ = bp.Sync()
+
+	mp.NotifyIncoming(bp)
+
+	if bi, err := store.QueryId(bp.Id); err != nil {
+		t.Fatal(err)
+	} else if hops, ok := bi.Properties["routing/maxprop/hops"].(uint64); !ok || hops != 0 {
+		t.Fatalf("Stored hop count is %v", bi.Properties["routing/maxprop/hops"])
+	}
+
+	if css, del := mp.SenderForBundle(bp); len(css) != 1 || del {
+		t.Fatalf("Bundle was routed to %d CLAs, delete: %t", len(css), del)
+	} else if css, _ := mp.SenderForBundle(bp); len(css) != 0 {
+		t.Fatal("Bundle was routed twice to the same peer")
+	}
+
+	metadata, err := bundle.Builder().
+		BundleCtrlFlags(0).
+		Source("dtn://peer/").
+		Destination("dtn://local/").
+		CreationTimestampNow().
+		Lifetime("1m").
+		Canonical(newMaxPropBlock(nil, map[bundle.BundleID]time.Time{b.ID(): time.Now().Add(time.Hour)})).
+		PayloadBlock(byte(1)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mp.NotifyIncoming(NewBundlePackFromBundle(metadata, store))
+
+	if !mp.isAcked(b.ID()) {
+		t.Fatal("Bundle was not acknowledged")
+	} else if bi, err := store.QueryId(bp.Id); err == nil && bi.Pending {
+		t.Fatal("Acknowledged bundle is still pending")
+	}
+
+	if mp.DispatchingAllowed(NewBundlePackFromBundle(b, store)) {
+		t.Fatal("Acknowledged bundle is allowed to be dispatched")
+	}
+}
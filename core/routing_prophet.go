@@ -6,7 +6,9 @@
 package core
 
 import (
+	"fmt"
 	"io"
+	"math"
 	"sync"
 	"time"
 
@@ -457,6 +459,56 @@ func (prophet *Prophet) ReportPeerDisappeared(peer cla.Convergence) {
 	// there really isn't anything to do upon a peer's disappearance
 }
 
+// prophetState is Prophet's persisted state, compare RoutingStatePersister.
+type prophetState struct {
+	Predictabilities     map[bundle.EndpointID]float64
+	PeerPredictabilities map[bundle.EndpointID]map[bundle.EndpointID]float64
+}
+
+// MarshalState serializes the predictabilities.
+func (prophet *Prophet) MarshalState() ([]byte, error) {
+	prophet.dataMutex.RLock()
+	defer prophet.dataMutex.RUnlock()
+
+	return marshalRoutingState(prophetState{
+		Predictabilities:     prophet.predictabilities,
+		PeerPredictabilities: prophet.peerPredictabilities,
+	})
+}
+
+// UnmarshalState restores the predictabilities, which are aged for each passed AgeInterval of the downtime.
+func (prophet *Prophet) UnmarshalState(data []byte, downtime time.Duration) error {
+	var state prophetState
+	if err := unmarshalRoutingState(data, &state); err != nil {
+		return err
+	}
+
+	ageInterval, err := time.ParseDuration(prophet.config.AgeInterval)
+	if err != nil {
+		return err
+	} else if ageInterval <= 0 {
+		return fmt.Errorf("age interval %v is not positive", ageInterval)
+	}
+	ageing := math.Pow(prophet.config.Gamma, float64(downtime/ageInterval))
+
+	prophet.dataMutex.Lock()
+	defer prophet.dataMutex.Unlock()
+
+	for peer, pred := range state.Predictabilities {
+		prophet.predictabilities[peer] = pred * ageing
+	}
+	for peer, preds := range state.PeerPredictabilities {
+		prophet.peerPredictabilities[peer] = preds
+	}
+
+	log.WithFields(log.Fields{
+		"downtime": downtime,
+		"ageing":   ageing,
+	}).Debug("Prophet restored and aged predictabilities")
+
+	return nil
+}
+
 // ProphetBlock contains routing metadata
 //
 // TODO: Turn this into an administrative record
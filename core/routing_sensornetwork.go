@@ -7,10 +7,12 @@ package core
 import (
 	"fmt"
 	"regexp"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/dtn7/dtn7-go/cla"
+	"github.com/dtn7/dtn7-go/storage"
 )
 
 // SensorNetworkMuleRouting is a simple proxy routing algorithm for data mules in specific sensor networks.
@@ -110,6 +112,29 @@ func (snm *SensorNetworkMuleRouting) ReportPeerDisappeared(peer cla.Convergence)
 	snm.algorithm.ReportPeerDisappeared(peer)
 }
 
+// PrioritizeBundles by the underlying algorithm, if it is a BundlePrioritizer.
+func (snm *SensorNetworkMuleRouting) PrioritizeBundles(bis []storage.BundleItem) {
+	if prioritizer, ok := snm.algorithm.(BundlePrioritizer); ok {
+		prioritizer.PrioritizeBundles(bis)
+	}
+}
+
+// MarshalState of the underlying algorithm, if it is a RoutingStatePersister.
+func (snm *SensorNetworkMuleRouting) MarshalState() ([]byte, error) {
+	if persister, ok := snm.algorithm.(RoutingStatePersister); ok {
+		return persister.MarshalState()
+	}
+	return nil, nil
+}
+
+// UnmarshalState of the underlying algorithm, if it is a RoutingStatePersister.
+func (snm *SensorNetworkMuleRouting) UnmarshalState(data []byte, downtime time.Duration) error {
+	if persister, ok := snm.algorithm.(RoutingStatePersister); ok {
+		return persister.UnmarshalState(data, downtime)
+	}
+	return nil
+}
+
 func (snm *SensorNetworkMuleRouting) String() string {
 	return fmt.Sprintf("sensor mule overlaying %v", snm.algorithm)
 }
@@ -52,6 +52,37 @@ func cleanupMetaData(c *Core, metadata *map[bundle.BundleID]sprayMetaData) {
 	}
 }
 
+// sprayMetaDataState is the persisted form of a sprayMetaData.
+type sprayMetaDataState struct {
+	Sent            []bundle.EndpointID
+	RemainingCopies uint64
+}
+
+// marshalSprayMetaData serializes the metadata of both SprayAndWait and BinarySpray.
+func marshalSprayMetaData(metadata map[bundle.BundleID]sprayMetaData) ([]byte, error) {
+	state := make(map[bundle.BundleID]sprayMetaDataState, len(metadata))
+	for bundleId, data := range metadata {
+		state[bundleId] = sprayMetaDataState{Sent: data.sent, RemainingCopies: data.remainingCopies}
+	}
+	return marshalRoutingState(state)
+}
+
+// unmarshalSprayMetaData restores serialized metadata, skipping those of bundles which are no longer stored.
+func unmarshalSprayMetaData(c *Core, data []byte, metadata map[bundle.BundleID]sprayMetaData) error {
+	var state map[bundle.BundleID]sprayMetaDataState
+	if err := unmarshalRoutingState(data, &state); err != nil {
+		return err
+	}
+
+	for bundleId, s := range state {
+		if _, present := metadata[bundleId]; present || !c.store.KnowsBundle(bundleId) {
+			continue
+		}
+		metadata[bundleId] = sprayMetaData{sent: s.Sent, remainingCopies: s.RemainingCopies}
+	}
+	return nil
+}
+
 // NewSprayAndWait creates new instance of SprayAndWait
 func NewSprayAndWait(c *Core, config SprayConfig) *SprayAndWait {
 	log.WithFields(log.Fields{
@@ -215,6 +246,23 @@ func (_ *SprayAndWait) ReportPeerAppeared(_ cla.Convergence) {}
 
 func (_ *SprayAndWait) ReportPeerDisappeared(_ cla.Convergence) {}
 
+// MarshalState serializes the bundles' metadata.
+func (sw *SprayAndWait) MarshalState() ([]byte, error) {
+	sw.dataMutex.RLock()
+	defer sw.dataMutex.RUnlock()
+
+	return marshalSprayMetaData(sw.bundleData)
+}
+
+// UnmarshalState restores the metadata of all still stored bundles. The downtime is not relevant, as expired bundles
+// are already removed from the store.
+func (sw *SprayAndWait) UnmarshalState(data []byte, _ time.Duration) error {
+	sw.dataMutex.Lock()
+	defer sw.dataMutex.Unlock()
+
+	return unmarshalSprayMetaData(sw.c, data, sw.bundleData)
+}
+
 // BinarySpray implements the binary Spray and Wait routing protocol
 // In this case, each node hands over floor(copies/2) during the spray phase
 type BinarySpray struct {
@@ -422,6 +470,23 @@ func (_ *BinarySpray) ReportPeerAppeared(_ cla.Convergence) {}
 
 func (_ *BinarySpray) ReportPeerDisappeared(_ cla.Convergence) {}
 
+// MarshalState serializes the bundles' metadata.
+func (bs *BinarySpray) MarshalState() ([]byte, error) {
+	bs.dataMutex.RLock()
+	defer bs.dataMutex.RUnlock()
+
+	return marshalSprayMetaData(bs.bundleData)
+}
+
+// UnmarshalState restores the metadata of all still stored bundles. The downtime is not relevant, as expired bundles
+// are already removed from the store.
+func (bs *BinarySpray) UnmarshalState(data []byte, _ time.Duration) error {
+	bs.dataMutex.Lock()
+	defer bs.dataMutex.Unlock()
+
+	return unmarshalSprayMetaData(bs.c, data, bs.bundleData)
+}
+
 // BinarySprayBlock contains metadata to let the next forwarder know their remaining copies
 type BinarySprayBlock uint64
 
@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// routingStateInterval is the interval to persist the routing algorithm's state, in addition to the Core's shutdown.
+const routingStateInterval = time.Minute
+
+// RoutingStatePersister is an optional interface for a RoutingAlgorithm to persist its learned state, e.g., about
+// encounters, within the store. The state is restored when the Core is started again.
+type RoutingStatePersister interface {
+	// MarshalState serializes the current state. A nil slice indicates that there is nothing to persist.
+	MarshalState() ([]byte, error)
+
+	// UnmarshalState restores a previously persisted state. The downtime is the duration since the state was
+	// persisted and should be used to age the state.
+	UnmarshalState(data []byte, downtime time.Duration) error
+}
+
+// routingStateName identifies the routing algorithm's state within the store.
+func (c *Core) routingStateName() string {
+	return "routing/" + c.routingName()
+}
+
+// restoreRoutingState restores the routing algorithm's persisted state, if any.
+func (c *Core) restoreRoutingState() {
+	persister, ok := c.routing.(RoutingStatePersister)
+	if !ok {
+		return
+	}
+
+	logger := log.WithField("state", c.routingStateName())
+
+	si, err := c.store.QueryState(c.routingStateName())
+	if err != nil {
+		logger.Debug("No persisted routing state was found")
+		return
+	}
+
+	downtime := time.Since(si.Stored)
+	if downtime < 0 {
+		downtime = 0
+	}
+
+	if err := persister.UnmarshalState(si.Data, downtime); err != nil {
+		logger.WithError(err).Warn("Restoring persisted routing state failed")
+	} else {
+		logger.WithField("downtime", downtime).Info("Restored persisted routing state")
+	}
+}
+
+// persistRoutingState stores the routing algorithm's current state.
+func (c *Core) persistRoutingState() {
+	persister, ok := c.routing.(RoutingStatePersister)
+	if !ok {
+		return
+	}
+
+	logger := log.WithField("state", c.routingStateName())
+
+	data, err := persister.MarshalState()
+	if err != nil {
+		logger.WithError(err).Warn("Serializing routing state failed")
+		return
+	} else if data == nil {
+		return
+	}
+
+	if err := c.store.StoreState(c.routingStateName(), data); err != nil {
+		logger.WithError(err).Warn("Persisting routing state failed")
+	} else {
+		logger.Debug("Persisted routing state")
+	}
+}
+
+// marshalRoutingState serializes a routing algorithm's state struct by gob.
+func marshalRoutingState(state interface{}) ([]byte, error) {
+	var buff bytes.Buffer
+	if err := gob.NewEncoder(&buff).Encode(state); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+// unmarshalRoutingState deserializes a routing algorithm's state struct, serialized by marshalRoutingState.
+func unmarshalRoutingState(data []byte, state interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(state)
+}
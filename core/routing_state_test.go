@@ -0,0 +1,135 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+func TestRoutingStateProphet(t *testing.T) {
+	registerGobTypes()
+
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := storage.NewStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	conf := ProphetConfig{PInit: 0.75, Beta: 0.25, Gamma: 0.5, AgeInterval: "1h"}
+	peer := bundle.MustNewEndpointID("dtn://peer/")
+
+	c1 := &Core{NodeId: bundle.MustNewEndpointID("dtn://local/"), store: store, cron: NewCron()}
+	defer c1.cron.Stop()
+
+	p1 := NewProphet(c1, conf)
+	c1.routing = p1
+	p1.encounter(peer)
+	c1.persistRoutingState()
+
+	c2 := &Core{NodeId: bundle.MustNewEndpointID("dtn://local/"), store: store, cron: NewCron()}
+	defer c2.cron.Stop()
+
+	p2 := NewProphet(c2, conf)
+	c2.routing = p2
+	c2.restoreRoutingState()
+
+	if pred := p2.predictabilities[peer]; math.Abs(pred-conf.PInit) > 0.001 {
+		t.Fatalf("Restored predictability is %f, expected %f", pred, conf.PInit)
+	}
+
+	data, err := p1.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p3 := NewProphet(c2, conf)
+	if err := p3.UnmarshalState(data, 2*time.Hour+time.Minute); err != nil {
+		t.Fatal(err)
+	} else if pred, expected := p3.predictabilities[peer], conf.PInit*0.25; pred != expected {
+		t.Fatalf("Aged predictability is %f, expected %f", pred, expected)
+	}
+}
+
+func TestRoutingStateSpray(t *testing.T) {
+	registerGobTypes()
+
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := storage.NewStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	c := &Core{NodeId: bundle.MustNewEndpointID("dtn://local/"), store: store, cron: NewCron()}
+	defer c.cron.Stop()
+
+	var bids []bundle.BundleID
+	for i := 0; i < 2; i++ {
+		b, err := bundle.Builder().
+			BundleCtrlFlags(0).
+			Source("dtn://src/").
+			Destination("dtn://dst/").
+			CreationTimestampNow().
+			Lifetime("1h").
+			PayloadBlock([]byte("hello world")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Only the first bundle is still known after the restart.
+		if i == 0 {
+			bp := NewBundlePackFromBundle(b, store)
+			bp.AddConstraint(ForwardPending)
+			_ = bp.Sync()
+		}
+		bids = append(bids, b.ID())
+
+		time.Sleep(time.Millisecond)
+	}
+
+	sw1 := NewSprayAndWait(c, SprayConfig{Multiplicity: 10})
+	for _, bid := range bids {
+		sw1.bundleData[bid] = sprayMetaData{
+			sent:            []bundle.EndpointID{bundle.MustNewEndpointID("dtn://peer/")},
+			remainingCopies: 7,
+		}
+	}
+
+	data, err := sw1.MarshalState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sw2 := NewSprayAndWait(c, SprayConfig{Multiplicity: 10})
+	if err := sw2.UnmarshalState(data, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sw2.bundleData) != 1 {
+		t.Fatalf("Restored metadata has %d entries, expected 1", len(sw2.bundleData))
+	} else if md, ok := sw2.bundleData[bids[0]]; !ok || md.remainingCopies != 7 || len(md.sent) != 1 {
+		t.Fatalf("Restored metadata is %v", md)
+	}
+}
@@ -0,0 +1,298 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/BurntSushi/toml"
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+)
+
+// StaticRouteConfig describes a StaticRoute. A rule without prefix and regex matches all destinations.
+type StaticRouteConfig struct {
+	// Prefix matches destination endpoints starting with this string, e.g., "dtn://sensor".
+	Prefix string
+	// Regex matches destination endpoints by a regular expression, e.g., "^dtn://[^/]+\\.sensor/.*$".
+	Regex string
+
+	// NextHop is the node ID to forward matching bundles to.
+	NextHop string `toml:"next-hop"`
+	// Fallback is an optional node ID, used if the next hop is not connected.
+	Fallback string
+}
+
+// StaticRoutingConfig contains data to initialize "static".
+type StaticRoutingConfig struct {
+	// Routes are the ordered rules of the routing table.
+	Routes []StaticRouteConfig `toml:"route"`
+
+	// RoutesFile is an optional TOML file of further [[route]] tables, appended to Routes.
+	RoutesFile string `toml:"routes-file"`
+
+	// ReloadTime is the optional interval to check the RoutesFile for modifications and reload the routing table.
+	// Note: Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h".
+	ReloadTime string `toml:"reload-time"`
+}
+
+// StaticRoute is a rule of the StaticRouting's table.
+type StaticRoute struct {
+	prefix string
+	regex  *regexp.Regexp
+
+	NextHop  bundle.EndpointID
+	Fallback bundle.EndpointID
+}
+
+// NewStaticRoute creates a StaticRoute from its configuration.
+func NewStaticRoute(conf StaticRouteConfig) (route StaticRoute, err error) {
+	if conf.Prefix != "" && conf.Regex != "" {
+		err = fmt.Errorf("static route must not have both a prefix and a regex")
+		return
+	}
+
+	route.prefix = conf.Prefix
+	if conf.Regex != "" {
+		if route.regex, err = regexp.Compile(conf.Regex); err != nil {
+			return
+		}
+	}
+
+	if route.NextHop, err = bundle.NewEndpointID(conf.NextHop); err != nil {
+		err = fmt.Errorf("static route's next hop is invalid: %v", err)
+		return
+	}
+
+	if conf.Fallback != "" {
+		if route.Fallback, err = bundle.NewEndpointID(conf.Fallback); err != nil {
+			err = fmt.Errorf("static route's fallback is invalid: %v", err)
+			return
+		}
+	}
+
+	return
+}
+
+// Matches checks if a destination is matched by this rule.
+func (route StaticRoute) Matches(destination bundle.EndpointID) bool {
+	switch {
+	case route.regex != nil:
+		return route.regex.MatchString(destination.String())
+	default:
+		return strings.HasPrefix(destination.String(), route.prefix)
+	}
+}
+
+func (route StaticRoute) String() string {
+	var pattern string
+	switch {
+	case route.regex != nil:
+		pattern = route.regex.String()
+	case route.prefix != "":
+		pattern = route.prefix + "*"
+	default:
+		pattern = "*"
+	}
+
+	if route.Fallback == (bundle.EndpointID{}) {
+		return fmt.Sprintf("%s -> %v", pattern, route.NextHop)
+	}
+	return fmt.Sprintf("%s -> %v (fallback %v)", pattern, route.NextHop, route.Fallback)
+}
+
+// StaticRouting forwards bundles based on an ordered table of StaticRoutes.
+//
+// The first rule matching a bundle's destination names its next hop. A bundle will be sent to the ConvergenceSender
+// currently connected to this next hop or otherwise to the rule's fallback. If neither is connected, the bundle stays
+// pending until being retried. Bundles without a matching rule are not forwarded.
+type StaticRouting struct {
+	c *Core
+
+	routes      []StaticRoute
+	routesMutex sync.RWMutex
+
+	// conf and routesModTime are used for reloading the routing table.
+	conf          StaticRoutingConfig
+	routesModTime time.Time
+}
+
+// NewStaticRouting creates a new StaticRouting RoutingAlgorithm interacting with the given Core.
+func NewStaticRouting(c *Core, config StaticRoutingConfig) (*StaticRouting, error) {
+	log.WithField("config", config).Debug("Initialising static routing")
+
+	sr := &StaticRouting{c: c, conf: config}
+	if err := sr.reloadRoutes(); err != nil {
+		return nil, err
+	}
+
+	if config.ReloadTime != "" {
+		if config.RoutesFile == "" {
+			return nil, fmt.Errorf("static routing's reload time requires a routes file")
+		}
+
+		reloadTime, err := time.ParseDuration(config.ReloadTime)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.cron.Register("static_reload", sr.reloadCron, reloadTime); err != nil {
+			log.WithError(err).Warn("Could not register static routing reload job")
+		}
+	}
+
+	return sr, nil
+}
+
+// SetRoutes replaces the routing table at runtime.
+func (sr *StaticRouting) SetRoutes(routes []StaticRoute) {
+	sr.routesMutex.Lock()
+	defer sr.routesMutex.Unlock()
+
+	sr.routes = append([]StaticRoute(nil), routes...)
+
+	log.WithField("routes", sr.routes).Info("Static routing loaded routing table")
+}
+
+// Routes returns a copy of the current routing table.
+func (sr *StaticRouting) Routes() []StaticRoute {
+	sr.routesMutex.RLock()
+	defer sr.routesMutex.RUnlock()
+
+	return append([]StaticRoute(nil), sr.routes...)
+}
+
+// reloadRoutes creates the routing table from the configured routes and the routes file.
+func (sr *StaticRouting) reloadRoutes() error {
+	confs := sr.conf.Routes
+
+	var modTime time.Time
+	if sr.conf.RoutesFile != "" {
+		fi, err := os.Stat(sr.conf.RoutesFile)
+		if err != nil {
+			return err
+		}
+		modTime = fi.ModTime()
+
+		var routesFile struct {
+			Route []StaticRouteConfig
+		}
+		if _, err := toml.DecodeFile(sr.conf.RoutesFile, &routesFile); err != nil {
+			return err
+		}
+		confs = append(append([]StaticRouteConfig(nil), confs...), routesFile.Route...)
+	}
+
+	routes := make([]StaticRoute, 0, len(confs))
+	for i, conf := range confs {
+		if route, err := NewStaticRoute(conf); err != nil {
+			return fmt.Errorf("static route %d: %v", i, err)
+		} else {
+			routes = append(routes, route)
+		}
+	}
+
+	sr.SetRoutes(routes)
+
+	sr.routesMutex.Lock()
+	sr.routesModTime = modTime
+	sr.routesMutex.Unlock()
+
+	return nil
+}
+
+// reloadCron reloads a modified routes file.
+func (sr *StaticRouting) reloadCron() {
+	fi, err := os.Stat(sr.conf.RoutesFile)
+	if err != nil {
+		log.WithField("file", sr.conf.RoutesFile).WithError(err).Warn("Static routing failed to inspect routes file")
+		return
+	}
+
+	sr.routesMutex.RLock()
+	modified := !fi.ModTime().Equal(sr.routesModTime)
+	sr.routesMutex.RUnlock()
+
+	if !modified {
+		return
+	}
+
+	if err := sr.reloadRoutes(); err != nil {
+		log.WithField("file", sr.conf.RoutesFile).WithError(err).Warn("Static routing failed to reload routes file")
+	}
+}
+
+// route returns the first StaticRoute matching a destination.
+func (sr *StaticRouting) route(destination bundle.EndpointID) (StaticRoute, bool) {
+	sr.routesMutex.RLock()
+	defer sr.routesMutex.RUnlock()
+
+	for _, route := range sr.routes {
+		if route.Matches(destination) {
+			return route, true
+		}
+	}
+	return StaticRoute{}, false
+}
+
+// NotifyIncoming is ignored by the StaticRouting.
+func (_ *StaticRouting) NotifyIncoming(_ BundlePack) {}
+
+// DispatchingAllowed is always true for the StaticRouting.
+func (_ *StaticRouting) DispatchingAllowed(_ BundlePack) bool {
+	return true
+}
+
+// SenderForBundle returns the ConvergenceSender of the next hop named by the first matching rule, or its fallback.
+func (sr *StaticRouting) SenderForBundle(bp BundlePack) (css []cla.ConvergenceSender, del bool) {
+	destination := bp.MustBundle().PrimaryBlock.Destination
+	logger := log.WithFields(log.Fields{
+		"bundle":      bp.ID(),
+		"destination": destination,
+	})
+
+	route, ok := sr.route(destination)
+	if !ok {
+		logger.Info("Static routing has no route for bundle")
+		return nil, false
+	}
+
+	for _, hop := range []bundle.EndpointID{route.NextHop, route.Fallback} {
+		if hop == (bundle.EndpointID{}) {
+			continue
+		}
+
+		for _, cs := range sr.c.claManager.Sender() {
+			if cs.GetPeerEndpointID().SameNode(hop) {
+				logger.WithFields(log.Fields{
+					"route":    route,
+					"next_hop": hop,
+				}).Debug("Static routing selected next hop")
+
+				return []cla.ConvergenceSender{cs}, true
+			}
+		}
+	}
+
+	logger.WithField("route", route).Info("Static routing's next hop for bundle is not connected")
+	return nil, false
+}
+
+func (_ *StaticRouting) ReportFailure(_ BundlePack, _ cla.ConvergenceSender) {}
+
+func (_ *StaticRouting) ReportPeerAppeared(_ cla.Convergence) {}
+
+func (_ *StaticRouting) ReportPeerDisappeared(_ cla.Convergence) {}
+
+func (_ *StaticRouting) String() string {
+	return "static"
+}
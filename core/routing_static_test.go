@@ -0,0 +1,156 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+func TestStaticRouteMatches(t *testing.T) {
+	tests := []struct {
+		conf    StaticRouteConfig
+		eid     string
+		matches bool
+	}{
+		{StaticRouteConfig{Prefix: "dtn://sensor", NextHop: "dtn://gw/"}, "dtn://sensor23/", true},
+		{StaticRouteConfig{Prefix: "dtn://sensor", NextHop: "dtn://gw/"}, "dtn://server/", false},
+		{StaticRouteConfig{Regex: "^dtn://[^/]+\\.sensor/.*$", NextHop: "dtn://gw/"}, "dtn://tree.sensor/", true},
+		{StaticRouteConfig{Regex: "^dtn://[^/]+\\.sensor/.*$", NextHop: "dtn://gw/"}, "dtn://tree/", false},
+		{StaticRouteConfig{NextHop: "dtn://gw/"}, "ipn:23.42", true},
+	}
+
+	for _, test := range tests {
+		route, err := NewStaticRoute(test.conf)
+		if err != nil {
+			t.Fatal(err)
+		} else if matches := route.Matches(bundle.MustNewEndpointID(test.eid)); matches != test.matches {
+			t.Fatalf("Route %v matches %s: %t", route, test.eid, matches)
+		}
+	}
+
+	invalidConfs := []StaticRouteConfig{
+		{Prefix: "dtn://", Regex: "^dtn://", NextHop: "dtn://gw/"},
+		{Regex: "(", NextHop: "dtn://gw/"},
+		{NextHop: "nope"},
+		{NextHop: "dtn://gw/", Fallback: "nope"},
+	}
+	for _, conf := range invalidConfs {
+		if _, err := NewStaticRoute(conf); err == nil {
+			t.Fatalf("Invalid route %v was created", conf)
+		}
+	}
+}
+
+func TestStaticRouting(t *testing.T) {
+	registerGobTypes()
+
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := storage.NewStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	c := &Core{
+		NodeId:     bundle.MustNewEndpointID("dtn://local/"),
+		store:      store,
+		claManager: cla.NewManager(),
+	}
+	defer c.claManager.Close()
+
+	c.claManager.Register(&mtuConvSender{})
+	for i := 0; len(c.claManager.Sender()) == 0; i++ {
+		if i == 100 {
+			t.Fatal("CLA was not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	routesFile := filepath.Join(dir, "routes.toml")
+	writeRoutes := func(routes string, modTime time.Time) {
+		if err := ioutil.WriteFile(routesFile, []byte(routes), 0600); err != nil {
+			t.Fatal(err)
+		} else if err := os.Chtimes(routesFile, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeRoutes(`
+[[route]]
+regex = "^dtn://[^/]+\\.sensor/.*$"
+next-hop = "dtn://gateway/"
+fallback = "dtn://peer/"
+`, time.Now().Add(-time.Hour))
+
+	sr, err := NewStaticRouting(c, StaticRoutingConfig{
+		Routes: []StaticRouteConfig{
+			{Prefix: "dtn://server/", NextHop: "dtn://peer/"},
+			{Prefix: "dtn://unreachable/", NextHop: "dtn://nowhere/"},
+		},
+		RoutesFile: routesFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	senders := func(destination string) []cla.ConvergenceSender {
+		b, err := bundle.Builder().
+			BundleCtrlFlags(0).
+			Source("dtn://local/").
+			Destination(destination).
+			CreationTimestampNow().
+			Lifetime("1h").
+			PayloadBlock([]byte("hello world")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		css, _ := sr.SenderForBundle(NewBundlePackFromBundle(b, store))
+		return css
+	}
+
+	tests := []struct {
+		destination string
+		routed      bool
+	}{
+		{"dtn://server/inbox", true},
+		{"dtn://unreachable/", false},
+		{"dtn://tree.sensor/", true},
+		{"dtn://unknown/", false},
+	}
+	for _, test := range tests {
+		if css := senders(test.destination); (len(css) == 1) != test.routed {
+			t.Fatalf("Bundle for %s was routed to %d CLAs", test.destination, len(css))
+		}
+	}
+
+	writeRoutes(`
+[[route]]
+next-hop = "dtn://peer/"
+`, time.Now())
+	sr.reloadCron()
+
+	if l := len(sr.Routes()); l != 3 {
+		t.Fatalf("Reloaded routing table has %d routes", l)
+	} else if css := senders("dtn://unknown/"); len(css) != 1 {
+		t.Fatal("Reloaded default route was not used")
+	} else if css := senders("dtn://unreachable/"); len(css) != 0 {
+		t.Fatal("Configured routes lost their order")
+	}
+}
@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+const routingConfToml = `
+[routing]
+algorithm = "sensor-mule"
+
+[routing.sensor-mule-conf]
+sensor-node-regex = "^dtn://sensor/$"
+
+[routing.sensor-mule-conf.routing]
+algorithm = "test-third-party"
+
+[routing.sensor-mule-conf.routing.third-party-conf]
+name = "foo"
+peers = ["dtn://a/", "dtn://b/"]
+
+[routing.maxprop-conf]
+hop-threshold = 3
+`
+
+// thirdPartyRouting is a RoutingAlgorithm with its own configuration section.
+type thirdPartyRouting struct {
+	fixedRouting
+
+	Name  string
+	Peers []string
+}
+
+func TestRegisterRoutingAlgorithm(t *testing.T) {
+	factory := func(_ *Core, conf RoutingConf) (RoutingAlgorithm, error) {
+		ra := &thirdPartyRouting{}
+		if err := conf.DecodeSection("third-party-conf", ra); err != nil {
+			return nil, err
+		}
+		return ra, nil
+	}
+
+	if err := RegisterRoutingAlgorithm("epidemic", factory); err == nil {
+		t.Fatal("Built-in routing algorithm was replaced")
+	}
+
+	// Ignore the error of an already registered algorithm for repeated test runs.
+	_ = RegisterRoutingAlgorithm("test-third-party", factory)
+
+	var conf struct {
+		Routing RoutingConf
+	}
+	if _, err := toml.Decode(routingConfToml, &conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Routing.MaxPropConf.HopThreshold != 3 {
+		t.Fatalf("Built-in section was not decoded: %v", conf.Routing.MaxPropConf)
+	}
+
+	ra, err := conf.Routing.RoutingAlgorithm(&Core{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snm, ok := ra.(*SensorNetworkMuleRouting)
+	if !ok {
+		t.Fatalf("Routing algorithm is %v", ra)
+	}
+
+	if tpr, ok := snm.algorithm.(*thirdPartyRouting); !ok {
+		t.Fatalf("Underlying routing algorithm is %v", snm.algorithm)
+	} else if tpr.Name != "foo" || len(tpr.Peers) != 2 {
+		t.Fatalf("Third-party section was decoded to %v", tpr)
+	}
+
+	conf.Routing.Algorithm = "unknown"
+	if _, err := conf.Routing.RoutingAlgorithm(&Core{}); err == nil {
+		t.Fatal("Unknown routing algorithm did not error")
+	}
+}
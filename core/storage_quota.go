@@ -0,0 +1,149 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"regexp"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+// StorageQuotaConf limits the store's size and configures the eviction of bundles, compare storage.Quota.
+type StorageQuotaConf struct {
+	// MaxBytes is the maximum size of all stored bundles in bytes. Zero disables this limit.
+	MaxBytes uint64 `toml:"max-bytes"`
+
+	// MaxBundles is the maximum amount of stored bundles. Zero disables this limit.
+	MaxBundles uint64 `toml:"max-bundles"`
+
+	// Policy is one of "oldest", "soonest-expiring", "largest" or "priority", as described for the
+	// storage.EvictionPolicy. Defaults to "oldest".
+	Policy string
+
+	// Priorities are rules to assign priorities to bundles for the "priority" policy.
+	Priorities []StoragePriorityConf `toml:"priority"`
+}
+
+// StoragePriorityConf assigns a priority to bundles matching both the source and destination regex. A missing regex
+// matches all bundles. The first matching rule is used; bundles without a matching rule have a priority of zero.
+type StoragePriorityConf struct {
+	Source      string
+	Destination string
+	Priority    int
+}
+
+// storagePriority is a parsed StoragePriorityConf.
+type storagePriority struct {
+	source      *regexp.Regexp
+	destination *regexp.Regexp
+	priority    int
+}
+
+// matches checks if this storagePriority applies to a bundle.
+func (sp storagePriority) matches(b bundle.Bundle) bool {
+	if sp.source != nil && !sp.source.MatchString(b.PrimaryBlock.SourceNode.String()) {
+		return false
+	}
+	if sp.destination != nil && !sp.destination.MatchString(b.PrimaryBlock.Destination.String()) {
+		return false
+	}
+	return true
+}
+
+// SetStorageQuotaConf limits the store's size. Without being called, the store is unlimited.
+//
+// If the quota is reached, other bundles are evicted based on the policy. Evicted bundles are deleted, sending a
+// status report with the "Depleted storage" reason if requested. Received bundles which do not fit are refused.
+func (c *Core) SetStorageQuotaConf(conf StorageQuotaConf) error {
+	policy, err := storage.ParseEvictionPolicy(conf.Policy)
+	if err != nil {
+		return err
+	}
+
+	var priorities []storagePriority
+	for _, prioConf := range conf.Priorities {
+		prio := storagePriority{priority: prioConf.Priority}
+
+		if prioConf.Source != "" {
+			if prio.source, err = regexp.Compile(prioConf.Source); err != nil {
+				return err
+			}
+		}
+		if prioConf.Destination != "" {
+			if prio.destination, err = regexp.Compile(prioConf.Destination); err != nil {
+				return err
+			}
+		}
+
+		priorities = append(priorities, prio)
+	}
+
+	c.store.SetQuota(storage.Quota{
+		MaxBytes:   conf.MaxBytes,
+		MaxBundles: conf.MaxBundles,
+		Policy:     policy,
+		Priority: func(b bundle.Bundle) int {
+			for _, prio := range priorities {
+				if prio.matches(b) {
+					return prio.priority
+				}
+			}
+			return 0
+		},
+		Originated: func(b bundle.Bundle) bool {
+			return c.HasEndpoint(b.PrimaryBlock.SourceNode)
+		},
+		Evict: c.evictBundle,
+	})
+
+	return nil
+}
+
+// evictBundle deletes a bundle evicted by the store due to its quota.
+//
+// This is called from within the store's Push. As the requested status report must be stored itself, possibly
+// evicting further bundles, it is queued and sent afterwards by the Core's handler, compare reportEvictions.
+func (c *Core) evictBundle(bi storage.BundleItem) {
+	bp := NewBundlePack(bi.BId, c.store)
+	bndl, err := bp.Bundle()
+	if err != nil {
+		log.WithField("bundle", bi.Id).WithError(err).Warn("Failed to load evicted bundle")
+		return
+	}
+
+	log.WithField("bundle", bp.ID()).Info("Bundle is evicted due to depleted storage")
+
+	if bndl.PrimaryBlock.BundleControlFlags.Has(bundle.StatusRequestDeletion) {
+		c.evictedMutex.Lock()
+		c.evicted = append(c.evicted, bp)
+		c.evictedMutex.Unlock()
+
+		select {
+		case c.evictedSyn <- struct{}{}:
+		default:
+		}
+	}
+
+	c.bundleDeletion(bp, bundle.DepletedStorage)
+}
+
+// reportEvictions sends the deferred status reports of the evicted bundles.
+func (c *Core) reportEvictions() {
+	for {
+		c.evictedMutex.Lock()
+		if len(c.evicted) == 0 {
+			c.evictedMutex.Unlock()
+			return
+		}
+		bp := c.evicted[0]
+		c.evicted = c.evicted[1:]
+		c.evictedMutex.Unlock()
+
+		c.SendStatusReport(bp, bundle.DeletedBundle, bundle.DepletedStorage)
+	}
+}
@@ -0,0 +1,197 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/agent"
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+func TestStorageQuotaEviction(t *testing.T) {
+	registerGobTypes()
+
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := storage.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	c := &Core{
+		NodeId:     bundle.MustNewEndpointID("dtn://node/"),
+		store:      store,
+		claManager: cla.NewManager(),
+	}
+	c.agentManager = NewAgentManager(c)
+
+	err = c.SetStorageQuotaConf(StorageQuotaConf{
+		MaxBundles: 2,
+		Policy:     "priority",
+		Priorities: []StoragePriorityConf{{Source: "^dtn://important/", Priority: 10}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, unsubscribe := c.SubscribeEvents(agent.BundleEventFilter{
+		Types: []agent.BundleEventType{agent.BundleDeleted},
+	})
+	defer unsubscribe()
+
+	var bps []BundlePack
+	for _, src := range []string{"dtn://important/", "dtn://other/", "dtn://other/"} {
+		b, err := bundle.Builder().
+			BundleCtrlFlags(0).
+			Source(src).
+			Destination("dtn://dst/").
+			CreationTimestampNow().
+			Lifetime("1h").
+			PayloadBlock([]byte("hello world")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bp := NewBundlePackFromBundle(b, store)
+		bp.AddConstraint(ForwardPending)
+		if err := bp.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		bps = append(bps, bp)
+
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	select {
+	case event := <-events:
+		if event.Bundle != bps[1].Id {
+			t.Fatalf("Evicted bundle %v instead of %v", event.Bundle, bps[1].Id)
+		} else if event.Reason != bundle.DepletedStorage {
+			t.Fatalf("Event's reason is %v", event.Reason)
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("Waiting for a BundleDeleted event timed out")
+	}
+
+	for i, known := range []bool{true, false, true} {
+		if store.KnowsBundle(bps[i].Id) != known {
+			t.Fatalf("Bundle %v: known is %t, expected %t", bps[i].Id, !known, known)
+		}
+	}
+}
+
+func TestStorageQuotaEvictionReport(t *testing.T) {
+	store := storage.NewMemoryStore()
+	c, err := NewCore(store, bundle.MustNewEndpointID("dtn://node/"), false, RoutingConf{Algorithm: "epidemic"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.SetStorageQuotaConf(StorageQuotaConf{MaxBundles: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	events, unsubscribe := c.SubscribeEvents(agent.BundleEventFilter{
+		Types: []agent.BundleEventType{agent.BundleDeleted},
+	})
+	defer unsubscribe()
+
+	var bps []BundlePack
+	for _, flags := range []bundle.BundleControlFlags{bundle.StatusRequestDeletion, 0, 0} {
+		b, err := bundle.Builder().
+			BundleCtrlFlags(flags).
+			Source("dtn://other/").
+			Destination("dtn://dst/").
+			ReportTo("dtn://report/").
+			CreationTimestampNow().
+			Lifetime("1h").
+			PayloadBlock([]byte("hello world")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bp := NewBundlePackFromBundle(b, store)
+		bp.AddConstraint(ForwardPending)
+		if err := bp.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		bps = append(bps, bp)
+
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	// The oldest bundle is evicted. Its status report is stored afterwards, evicting the next oldest bundle.
+	for _, bp := range bps[:2] {
+		select {
+		case event := <-events:
+			if event.Bundle != bp.Id {
+				t.Fatalf("Evicted bundle %v instead of %v", event.Bundle, bp.Id)
+			} else if event.Reason != bundle.DepletedStorage {
+				t.Fatalf("Event's reason is %v", event.Reason)
+			}
+
+		case <-time.After(5 * time.Second):
+			t.Fatal("Waiting for a BundleDeleted event timed out")
+		}
+	}
+
+	reports := func() (n int) {
+		bis, err := store.QueryAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, bi := range bis {
+			if bi.Destination.String() == "dtn://report/" && bi.Originated {
+				n++
+			}
+		}
+		return
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for reports() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Status report was not stored")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := reports(); n != 1 {
+		t.Fatalf("Store contains %d status reports", n)
+	} else if !store.KnowsBundle(bps[2].Id) {
+		t.Fatal("Newest bundle was evicted")
+	}
+}
+
+func TestStorageQuotaConfInvalid(t *testing.T) {
+	tests := []StorageQuotaConf{
+		{Policy: "newest"},
+		{Priorities: []StoragePriorityConf{{Source: "("}}},
+		{Priorities: []StoragePriorityConf{{Destination: "["}}},
+	}
+
+	for _, conf := range tests {
+		c := &Core{}
+		if err := c.SetStorageQuotaConf(conf); err == nil {
+			t.Fatalf("Invalid configuration %v was accepted", conf)
+		}
+	}
+}
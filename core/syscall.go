@@ -0,0 +1,212 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"sync"
+
+	"github.com/dtn7/cboring"
+	"github.com/dtn7/dtn7-go/cla"
+)
+
+// SyscallHandler answers a syscall for a Core by writing its CBOR encoded response.
+type SyscallHandler func(c *Core, w io.Writer) error
+
+// SyscallManager keeps a book of the syscalls, which ApplicationAgents might request by a SyscallRequestMessage.
+//
+// The default syscalls are:
+//
+//   node_id:   the Node ID as a text string
+//   endpoints: the registered endpoints as an array of text strings
+//   peers:     the current peers as an array of maps, each with the text strings "endpoint", "address", and "cla"
+//   store:     the store's statistics as a map of text strings to unsigned integers
+//   routing:   the routing algorithm's name as a text string
+//   version:   the Version as a text string
+type SyscallManager struct {
+	syscalls map[string]SyscallHandler
+	sync.Mutex
+}
+
+// NewSyscallManager creates an empty SyscallManager. To use the default syscalls, see GetSyscallManager.
+func NewSyscallManager() *SyscallManager {
+	return &SyscallManager{
+		syscalls: make(map[string]SyscallHandler),
+	}
+}
+
+// Register a SyscallHandler for a syscall's name. An error is returned if this name is already registered.
+func (sm *SyscallManager) Register(name string, handler SyscallHandler) error {
+	sm.Lock()
+	defer sm.Unlock()
+
+	if _, exists := sm.syscalls[name]; exists {
+		return fmt.Errorf("syscall %s is already registered", name)
+	}
+
+	sm.syscalls[name] = handler
+	return nil
+}
+
+// Unregister a syscall by its name.
+func (sm *SyscallManager) Unregister(name string) {
+	sm.Lock()
+	defer sm.Unlock()
+
+	delete(sm.syscalls, name)
+}
+
+// IsKnown returns true if a syscall of this name is registered.
+func (sm *SyscallManager) IsKnown(name string) bool {
+	sm.Lock()
+	defer sm.Unlock()
+
+	_, known := sm.syscalls[name]
+	return known
+}
+
+// Syscall answers a syscall for a Core, returning its CBOR encoded response.
+func (sm *SyscallManager) Syscall(c *Core, name string) ([]byte, error) {
+	sm.Lock()
+	handler, known := sm.syscalls[name]
+	sm.Unlock()
+
+	if !known {
+		return nil, fmt.Errorf("syscall %s is unknown", name)
+	}
+
+	var buff bytes.Buffer
+	if err := handler(c, &buff); err != nil {
+		return nil, fmt.Errorf("syscall %s errored: %v", name, err)
+	}
+	return buff.Bytes(), nil
+}
+
+var (
+	syscallManager      *SyscallManager
+	syscallManagerMutex sync.Mutex
+)
+
+// GetSyscallManager returns the singleton SyscallManager. If none exists, a new one will be generated with the
+// default syscalls.
+func GetSyscallManager() *SyscallManager {
+	syscallManagerMutex.Lock()
+	defer syscallManagerMutex.Unlock()
+
+	if syscallManager == nil {
+		syscallManager = NewSyscallManager()
+
+		_ = syscallManager.Register("node_id", syscallNodeId)
+		_ = syscallManager.Register("endpoints", syscallEndpoints)
+		_ = syscallManager.Register("peers", syscallPeers)
+		_ = syscallManager.Register("store", syscallStore)
+		_ = syscallManager.Register("routing", syscallRouting)
+		_ = syscallManager.Register("version", syscallVersion)
+	}
+
+	return syscallManager
+}
+
+func syscallNodeId(c *Core, w io.Writer) error {
+	return cboring.WriteTextString(c.NodeId.String(), w)
+}
+
+func syscallEndpoints(c *Core, w io.Writer) error {
+	eids := c.agentManager.mux.Endpoints()
+
+	if err := cboring.WriteArrayLength(uint64(len(eids)), w); err != nil {
+		return err
+	}
+	for _, eid := range eids {
+		if err := cboring.WriteTextString(eid.String(), w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func syscallPeers(c *Core, w io.Writer) error {
+	senders := c.claManager.Sender()
+
+	if err := cboring.WriteArrayLength(uint64(len(senders)), w); err != nil {
+		return err
+	}
+	for _, cs := range senders {
+		fields := [][2]string{
+			{"endpoint", cs.GetPeerEndpointID().String()},
+			{"address", cs.Address()},
+			{"cla", convergenceType(cs)},
+		}
+		if err := writeSyscallTextMap(fields, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func syscallStore(c *Core, w io.Writer) error {
+	stats, err := c.store.Stats()
+	if err != nil {
+		return err
+	}
+
+	fields := []struct {
+		key   string
+		value int
+	}{
+		{"bundles", stats.Bundles},
+		{"pending", stats.Pending},
+		{"local", stats.Local},
+		{"fragmented", stats.Fragmented},
+	}
+
+	if err := cboring.WriteMapPairLength(uint64(len(fields)), w); err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if err := cboring.WriteTextString(field.key, w); err != nil {
+			return err
+		} else if err := cboring.WriteUInt(uint64(field.value), w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func syscallRouting(c *Core, w io.Writer) error {
+	return cboring.WriteTextString(c.routingConf.Algorithm, w)
+}
+
+func syscallVersion(_ *Core, w io.Writer) error {
+	return cboring.WriteTextString(Version, w)
+}
+
+// convergenceType names a CLA's type after its package, e.g., "tcpcl" or "mtcp".
+func convergenceType(conv cla.Convergable) string {
+	claType := reflect.TypeOf(conv)
+	if claType.Kind() == reflect.Ptr {
+		claType = claType.Elem()
+	}
+	return path.Base(claType.PkgPath())
+}
+
+// writeSyscallTextMap writes a CBOR map of text strings to text strings.
+func writeSyscallTextMap(fields [][2]string, w io.Writer) error {
+	if err := cboring.WriteMapPairLength(uint64(len(fields)), w); err != nil {
+		return err
+	}
+	for _, field := range fields {
+		for _, s := range field {
+			if err := cboring.WriteTextString(s, w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dtn7/cboring"
+
+	"github.com/dtn7/dtn7-go/agent"
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+// syscallAgent is an ApplicationAgent for a single endpoint, passing all received syscall responses to its inbox.
+type syscallAgent struct {
+	endpoint bundle.EndpointID
+	receiver chan agent.Message
+	sender   chan agent.Message
+	inbox    chan agent.SyscallResponseMessage
+}
+
+func newSyscallAgent(endpoint bundle.EndpointID) *syscallAgent {
+	sa := &syscallAgent{
+		endpoint: endpoint,
+		receiver: make(chan agent.Message),
+		sender:   make(chan agent.Message),
+		inbox:    make(chan agent.SyscallResponseMessage, 16),
+	}
+
+	go func() {
+		for msg := range sa.receiver {
+			if srm, ok := msg.(agent.SyscallResponseMessage); ok {
+				sa.inbox <- srm
+			}
+		}
+	}()
+
+	return sa
+}
+
+func (sa *syscallAgent) Endpoints() []bundle.EndpointID      { return []bundle.EndpointID{sa.endpoint} }
+func (sa *syscallAgent) MessageReceiver() chan agent.Message { return sa.receiver }
+func (sa *syscallAgent) MessageSender() chan agent.Message   { return sa.sender }
+
+func (sa *syscallAgent) syscall(t *testing.T, request string) []byte {
+	sa.sender <- agent.SyscallRequestMessage{Sender: sa.endpoint, Request: request}
+
+	select {
+	case srm := <-sa.inbox:
+		if srm.Request != request {
+			t.Fatalf("Received response for %q instead of %q", srm.Request, request)
+		}
+		return srm.Response
+
+	case <-time.After(time.Second):
+		t.Fatalf("Syscall %q timed out", request)
+		return nil
+	}
+}
+
+func TestAgentManagerSyscall(t *testing.T) {
+	registerGobTypes()
+
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := storage.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	c := &Core{
+		NodeId:      bundle.MustNewEndpointID("dtn://node/"),
+		store:       store,
+		claManager:  cla.NewManager(),
+		routingConf: RoutingConf{Algorithm: "epidemic"},
+	}
+	c.agentManager = NewAgentManager(c)
+
+	sa := newSyscallAgent(bundle.MustNewEndpointID("dtn://node/app"))
+	c.agentManager.Register(sa)
+
+	textTests := map[string]string{
+		"node_id": "dtn://node/",
+		"routing": "epidemic",
+		"version": Version,
+	}
+	for request, expected := range textTests {
+		if s, err := cboring.ReadTextString(bytes.NewBuffer(sa.syscall(t, request))); err != nil {
+			t.Fatal(err)
+		} else if s != expected {
+			t.Fatalf("Syscall %q returned %q instead of %q", request, s, expected)
+		}
+	}
+
+	endpoints := bytes.NewBuffer(sa.syscall(t, "endpoints"))
+	if n, err := cboring.ReadArrayLength(endpoints); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf("Syscall returned %d endpoints instead of one", n)
+	} else if eid, err := cboring.ReadTextString(endpoints); err != nil {
+		t.Fatal(err)
+	} else if eid != "dtn://node/app" {
+		t.Fatalf("Syscall returned endpoint %q", eid)
+	}
+
+	if n, err := cboring.ReadArrayLength(bytes.NewBuffer(sa.syscall(t, "peers"))); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("Syscall returned %d peers instead of none", n)
+	}
+
+	if n, err := cboring.ReadMapPairLength(bytes.NewBuffer(sa.syscall(t, "store"))); err != nil {
+		t.Fatal(err)
+	} else if n != 4 {
+		t.Fatalf("Syscall returned %d store statistics", n)
+	}
+
+	if response := sa.syscall(t, "unknown"); len(response) != 0 {
+		t.Fatalf("Unknown syscall returned %x", response)
+	}
+
+	// Register a further syscall
+	if err := GetSyscallManager().Register("answer", func(_ *Core, w io.Writer) error {
+		return cboring.WriteUInt(42, w)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer GetSyscallManager().Unregister("answer")
+
+	if err := GetSyscallManager().Register("answer", nil); err == nil {
+		t.Fatal("Registering a syscall twice did not error")
+	}
+
+	if n, err := cboring.ReadUInt(bytes.NewBuffer(sa.syscall(t, "answer"))); err != nil {
+		t.Fatal(err)
+	} else if n != 42 {
+		t.Fatalf("Syscall returned %d", n)
+	}
+}
@@ -0,0 +1,330 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"bytes"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+)
+
+// transmissionWorkers bounds the number of concurrent transmissions to ConvergenceSenders.
+const transmissionWorkers = 8
+
+// transmission of a forwarded bundle to the ConvergenceSenders selected by the routing decision.
+type transmission struct {
+	bp               BundlePack
+	deleteAfterwards bool
+
+	// mutex protects the bundle while preparing a copy for each ConvergenceSender and the following fields.
+	mutex   sync.Mutex
+	pending int
+	sent    bool
+}
+
+// bundle returns a copy of the transmission's bundle with an updated Bundle Age block, if present.
+func (tr *transmission) bundle() (bundle.Bundle, error) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	if age, reset, err := tr.bp.UpdateBundleAge(); err == nil {
+		defer reset()
+
+		log.WithFields(log.Fields{
+			"bundle": tr.bp.ID(),
+			"age":    age,
+		}).Debug("Bundle's age block was updated")
+	}
+
+	return copyBundle(tr.bp.MustBundle())
+}
+
+// done marks one ConvergenceSender's transmission as finished and reports if this was the last one.
+func (tr *transmission) done(sent bool) bool {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	tr.pending--
+	tr.sent = tr.sent || sent
+	return tr.pending == 0
+}
+
+// copyBundle creates a deep copy of a bundle by serializing it.
+func copyBundle(b *bundle.Bundle) (bndl bundle.Bundle, err error) {
+	var buff bytes.Buffer
+	if err = b.MarshalCbor(&buff); err != nil {
+		return
+	}
+
+	err = bndl.UnmarshalCbor(&buff)
+	return
+}
+
+// senderQueue is the queue of outstanding transmissions for one ConvergenceSender. Its transmissions are sent one
+// after another, in the order of their routing decisions.
+type senderQueue struct {
+	sender cla.ConvergenceSender
+	jobs   []*transmission
+
+	// scheduled is true while this queue is either listed as ready or being processed by a worker.
+	scheduled bool
+}
+
+// keptTransmission is a routing decision for a disappeared peer, kept to be resumed on this peer's reappearance.
+type keptTransmission struct {
+	bid              bundle.BundleID
+	deleteAfterwards bool
+}
+
+// transmissionQueues are the per-ConvergenceSender queues of forwarded bundles, processed by a bounded pool of
+// workers. The queues are served in turns, one transmission at a time, so that a slow peer cannot stall the
+// transmissions to all other peers.
+type transmissionQueues struct {
+	core *Core
+
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	queues map[cla.ConvergenceSender]*senderQueue
+	ready  []*senderQueue
+
+	// bundles are the scrubbed IDs of all bundles with an unfinished transmission.
+	bundles map[string]bool
+
+	// kept are the dropped transmissions of disappeared peers, by their endpoint ID.
+	kept map[string][]keptTransmission
+
+	closed  bool
+	workers sync.WaitGroup
+}
+
+// newTransmissionQueues creates transmissionQueues for a Core and starts their workers.
+func newTransmissionQueues(c *Core, workers int) *transmissionQueues {
+	tq := &transmissionQueues{
+		core:    c,
+		queues:  make(map[cla.ConvergenceSender]*senderQueue),
+		bundles: make(map[string]bool),
+		kept:    make(map[string][]keptTransmission),
+	}
+	tq.cond = sync.NewCond(&tq.mutex)
+
+	tq.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go tq.worker()
+	}
+
+	return tq
+}
+
+// enqueue a bundle's transmission for each ConvergenceSender. False is returned if this bundle is already being
+// transmitted or the queues are closed; the bundle was not enqueued in this case.
+func (tq *transmissionQueues) enqueue(tr *transmission, senders []cla.ConvergenceSender) bool {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+
+	bid := tr.bp.Id.Scrub().String()
+	if tq.closed || tq.bundles[bid] {
+		return false
+	}
+	tq.bundles[bid] = true
+
+	tr.pending = len(senders)
+
+	for _, sender := range senders {
+		sq, ok := tq.queues[sender]
+		if !ok {
+			sq = &senderQueue{sender: sender}
+			tq.queues[sender] = sq
+		}
+
+		sq.jobs = append(sq.jobs, tr)
+		tq.schedule(sq)
+	}
+
+	return true
+}
+
+// schedule a non-empty senderQueue for a worker, if it is not already scheduled. The mutex must be held.
+func (tq *transmissionQueues) schedule(sq *senderQueue) {
+	if sq.scheduled || len(sq.jobs) == 0 {
+		return
+	}
+
+	sq.scheduled = true
+	tq.ready = append(tq.ready, sq)
+	tq.cond.Signal()
+}
+
+// transmitting checks if a bundle has an unfinished transmission.
+func (tq *transmissionQueues) transmitting(bid bundle.BundleID) bool {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+
+	return tq.bundles[bid.Scrub().String()]
+}
+
+// length returns the number of outstanding transmissions for a ConvergenceSender.
+func (tq *transmissionQueues) length(sender cla.ConvergenceSender) int {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+
+	if sq, ok := tq.queues[sender]; ok {
+		return len(sq.jobs)
+	}
+	return 0
+}
+
+// drop all outstanding transmissions for a ConvergenceSender, e.g., after its peer has disappeared. Each dropped
+// transmission is reported as a failure and kept for the peer's endpoint, to be resumed on its reappearance.
+func (tq *transmissionQueues) drop(sender cla.ConvergenceSender) {
+	tq.mutex.Lock()
+	sq, ok := tq.queues[sender]
+	if !ok {
+		tq.mutex.Unlock()
+		return
+	}
+
+	jobs := sq.jobs
+	sq.jobs = nil
+	if !sq.scheduled {
+		delete(tq.queues, sender)
+	}
+
+	if peer := sender.GetPeerEndpointID(); peer != bundle.DtnNone() {
+		for _, tr := range jobs {
+			tq.keep(peer.String(), keptTransmission{bid: tr.bp.Id, deleteAfterwards: tr.deleteAfterwards})
+		}
+	}
+	tq.mutex.Unlock()
+
+	if len(jobs) > 0 {
+		log.WithFields(log.Fields{
+			"cla":     sender,
+			"bundles": len(jobs),
+		}).Info("Dropping queued transmissions for a disappeared peer")
+	}
+
+	for _, tr := range jobs {
+		tq.core.routing.ReportFailure(tr.bp, sender)
+		tq.finish(tr, false)
+	}
+}
+
+// keep a dropped transmission for a peer's endpoint, unless this bundle is already kept. The mutex must be held.
+func (tq *transmissionQueues) keep(peer string, kt keptTransmission) {
+	for _, other := range tq.kept[peer] {
+		if other.bid.Scrub() == kt.bid.Scrub() {
+			return
+		}
+	}
+
+	tq.kept[peer] = append(tq.kept[peer], kt)
+}
+
+// resume returns and forgets the kept transmissions for a reappeared peer's endpoint.
+func (tq *transmissionQueues) resume(peer bundle.EndpointID) []keptTransmission {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+
+	kts := tq.kept[peer.String()]
+	delete(tq.kept, peer.String())
+	return kts
+}
+
+// prune the kept transmissions of bundles which are no longer known, e.g., for peers which never reappeared.
+func (tq *transmissionQueues) prune(knows func(bundle.BundleID) bool) {
+	tq.mutex.Lock()
+	defer tq.mutex.Unlock()
+
+	for peer, kts := range tq.kept {
+		var remaining []keptTransmission
+		for _, kt := range kts {
+			if knows(kt.bid) {
+				remaining = append(remaining, kt)
+			}
+		}
+
+		if len(remaining) > 0 {
+			tq.kept[peer] = remaining
+		} else {
+			delete(tq.kept, peer)
+		}
+	}
+}
+
+// worker processes the ready senderQueues, one transmission at a time, until the queues are closed.
+func (tq *transmissionQueues) worker() {
+	defer tq.workers.Done()
+
+	for {
+		tq.mutex.Lock()
+		for len(tq.ready) == 0 && !tq.closed {
+			tq.cond.Wait()
+		}
+		if tq.closed {
+			tq.mutex.Unlock()
+			return
+		}
+
+		sq := tq.ready[0]
+		tq.ready = tq.ready[1:]
+
+		if len(sq.jobs) == 0 {
+			sq.scheduled = false
+			delete(tq.queues, sq.sender)
+			tq.mutex.Unlock()
+			continue
+		}
+
+		tr := sq.jobs[0]
+		sq.jobs = sq.jobs[1:]
+		tq.mutex.Unlock()
+
+		sent := tq.core.sendTransmission(tr, sq.sender)
+
+		tq.mutex.Lock()
+		sq.scheduled = false
+		if len(sq.jobs) > 0 {
+			tq.schedule(sq)
+		} else {
+			delete(tq.queues, sq.sender)
+		}
+		tq.mutex.Unlock()
+
+		tq.finish(tr, sent)
+	}
+}
+
+// finish one ConvergenceSender's transmission. After the last one, the bundle's forwarding is completed.
+func (tq *transmissionQueues) finish(tr *transmission, sent bool) {
+	if !tr.done(sent) {
+		return
+	}
+
+	tq.core.bundlesMutex.Lock()
+	tq.core.forwarded(tr)
+	tq.core.bundlesMutex.Unlock()
+
+	tq.mutex.Lock()
+	delete(tq.bundles, tr.bp.Id.Scrub().String())
+	tq.mutex.Unlock()
+}
+
+// close stops the workers after their current transmission. Outstanding transmissions are discarded; their bundles
+// remain pending within the store.
+func (tq *transmissionQueues) close() {
+	tq.mutex.Lock()
+	tq.closed = true
+	tq.ready = nil
+	tq.queues = make(map[cla.ConvergenceSender]*senderQueue)
+	tq.kept = make(map[string][]keptTransmission)
+	tq.cond.Broadcast()
+	tq.mutex.Unlock()
+
+	tq.workers.Wait()
+}
@@ -0,0 +1,275 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+	"github.com/dtn7/dtn7-go/storage"
+)
+
+// gateConvSender is a ConvergenceSender whose transmissions block until its gate is opened, at the latest by Close.
+type gateConvSender struct {
+	address  string
+	peer     bundle.EndpointID
+	gate     chan struct{}
+	gateOnce sync.Once
+	sent     chan bundle.Bundle
+	chnl     chan cla.ConvergenceStatus
+}
+
+func newGateConvSender(address, peer string, open bool) *gateConvSender {
+	gcs := &gateConvSender{
+		address: address,
+		peer:    bundle.MustNewEndpointID(peer),
+		gate:    make(chan struct{}),
+		sent:    make(chan bundle.Bundle, 64),
+		chnl:    make(chan cla.ConvergenceStatus),
+	}
+	if open {
+		gcs.open()
+	}
+	return gcs
+}
+
+func (gcs *gateConvSender) open() {
+	gcs.gateOnce.Do(func() { close(gcs.gate) })
+}
+
+func (gcs *gateConvSender) Start() (error, bool) {
+	go func() { gcs.chnl <- cla.NewConvergencePeerAppeared(gcs, gcs.peer) }()
+	return nil, false
+}
+
+func (gcs *gateConvSender) Close()                               { gcs.open() }
+func (gcs *gateConvSender) Channel() chan cla.ConvergenceStatus  { return gcs.chnl }
+func (gcs *gateConvSender) Address() string                      { return gcs.address }
+func (gcs *gateConvSender) IsPermanent() bool                    { return false }
+func (gcs *gateConvSender) GetPeerEndpointID() bundle.EndpointID { return gcs.peer }
+
+func (gcs *gateConvSender) String() string { return gcs.address }
+
+func (gcs *gateConvSender) Send(bndl *bundle.Bundle) error {
+	<-gcs.gate
+	gcs.sent <- *bndl
+	return nil
+}
+
+func transmissionTestCore(t *testing.T) (*Core, storage.Store) {
+	store := storage.NewMemoryStore()
+	c, err := NewCore(store, bundle.MustNewEndpointID("dtn://node/"), false, RoutingConf{Algorithm: "epidemic"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, store
+}
+
+func transmissionTestBundle(t *testing.T, c *Core) bundle.Bundle {
+	b, err := bundle.Builder().
+		Source("dtn://node/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("1h").
+		HopCountBlock(64).
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.SendBundle(&b)
+	return b
+}
+
+func waitSent(t *testing.T, gcs *gateConvSender, n int) []bundle.Bundle {
+	var bndls []bundle.Bundle
+	for len(bndls) < n {
+		select {
+		case b := <-gcs.sent:
+			bndls = append(bndls, b)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s sent %d bundles instead of %d", gcs.address, len(bndls), n)
+		}
+	}
+	return bndls
+}
+
+func TestTransmissionQueuesContact(t *testing.T) {
+	c, store := transmissionTestCore(t)
+	defer c.Close()
+
+	var bndls []bundle.Bundle
+	for i := 0; i < 3; i++ {
+		bndls = append(bndls, transmissionTestBundle(t, c))
+	}
+
+	if bis, err := store.QueryPending(); err != nil {
+		t.Fatal(err)
+	} else if len(bis) != len(bndls) {
+		t.Fatalf("Store contains %d pending bundles", len(bis))
+	}
+
+	gcs := newGateConvSender("gate://peer", "dtn://peer/", true)
+	c.RegisterConvergable(gcs)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(c.claManager.Sender()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Sender was not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The periodic check of the pending bundles enqueues them for the new peer.
+	c.checkPendingBundles()
+
+	sent := waitSent(t, gcs, len(bndls))
+	for _, b := range sent {
+		if hcBlock, err := b.ExtensionBlock(bundle.ExtBlockTypeHopCountBlock); err != nil {
+			t.Fatal(err)
+		} else if hc := hcBlock.Value.(*bundle.HopCountBlock); hc.Count != 1 {
+			t.Fatalf("Sent bundle's hop count is %d", hc.Count)
+		}
+	}
+}
+
+func TestTransmissionQueuesSlowPeer(t *testing.T) {
+	c, _ := transmissionTestCore(t)
+	defer c.Close()
+
+	slow := newGateConvSender("gate://slow", "dtn://slow/", false)
+	fast := newGateConvSender("gate://fast", "dtn://fast/", true)
+	c.RegisterConvergable(slow)
+	c.RegisterConvergable(fast)
+
+	b := transmissionTestBundle(t, c)
+
+	// The fast peer receives its bundles while the slow one is still sending.
+	waitSent(t, fast, 1)
+
+	b2 := transmissionTestBundle(t, c)
+	waitSent(t, fast, 1)
+
+	if !c.transmissions.transmitting(b.ID()) || !c.transmissions.transmitting(b2.ID()) {
+		t.Fatal("Bundles are not being transmitted")
+	}
+
+	// Retrying the pending bundles does not enqueue those being transmitted again.
+	c.checkPendingBundles()
+	if l := c.transmissions.length(slow); l != 1 {
+		t.Fatalf("Slow peer's queue has %d transmissions", l)
+	}
+
+	slow.open()
+	waitSent(t, slow, 2)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.transmissions.transmitting(b.ID()) || c.transmissions.transmitting(b2.ID()) {
+		if time.Now().After(deadline) {
+			t.Fatal("Transmissions did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	bp := NewBundlePack(b.ID(), c.store)
+	if !bp.HasConstraint(Contraindicated) {
+		t.Fatalf("Forwarded bundle has constraints %v", bp.Constraints)
+	}
+}
+
+func TestTransmissionQueuesDrop(t *testing.T) {
+	c, _ := transmissionTestCore(t)
+	defer c.Close()
+
+	slow := newGateConvSender("gate://slow", "dtn://slow/", false)
+	c.RegisterConvergable(slow)
+
+	// Wait for the contact, as no bundle can be queued before.
+	deadline := time.Now().Add(5 * time.Second)
+	for len(c.claManager.Sender()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Sender was not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	b1 := transmissionTestBundle(t, c)
+	for c.transmissions.length(slow) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Bundle was not taken from the queue")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	b2 := transmissionTestBundle(t, c)
+
+	if l := c.transmissions.length(slow); l != 1 {
+		t.Fatalf("Slow peer's queue has %d transmissions", l)
+	}
+
+	c.transmissions.drop(slow)
+	if l := c.transmissions.length(slow); l != 0 {
+		t.Fatalf("Dropped queue has %d transmissions", l)
+	} else if c.transmissions.transmitting(b2.ID()) {
+		t.Fatal("Dropped bundle is still being transmitted")
+	} else if !c.transmissions.transmitting(b1.ID()) {
+		t.Fatal("Bundle being sent is not transmitted anymore")
+	}
+
+	if bp := NewBundlePack(b2.ID(), c.store); !bp.HasConstraint(Contraindicated) {
+		t.Fatalf("Dropped bundle has constraints %v", bp.Constraints)
+	}
+
+	slow.open()
+	waitSent(t, slow, 1)
+
+	// The dropped transmission is kept for the peer's endpoint and resumed on its reappearance, without checking the
+	// pending bundles.
+	back := newGateConvSender("gate://slow-again", "dtn://slow/", true)
+	c.RegisterConvergable(back)
+
+	sent := waitSent(t, back, 1)
+	if sent[0].ID() != b2.ID() {
+		t.Fatalf("Reappeared peer received %v instead of %v", sent[0].ID(), b2.ID())
+	} else if hcBlock, err := sent[0].ExtensionBlock(bundle.ExtBlockTypeHopCountBlock); err != nil {
+		t.Fatal(err)
+	} else if hc := hcBlock.Value.(*bundle.HopCountBlock); hc.Count != 1 {
+		t.Fatalf("Resumed bundle's hop count is %d", hc.Count)
+	}
+
+	if kts := c.transmissions.resume(back.GetPeerEndpointID()); len(kts) != 0 {
+		t.Fatalf("Resumed transmissions are still kept: %v", kts)
+	}
+}
+
+func TestTransmissionQueuesPrune(t *testing.T) {
+	c, _ := transmissionTestCore(t)
+	defer c.Close()
+
+	peer := bundle.MustNewEndpointID("dtn://peer/")
+	b1 := transmissionTestBundle(t, c)
+	b2 := transmissionTestBundle(t, c)
+
+	c.transmissions.mutex.Lock()
+	c.transmissions.keep(peer.String(), keptTransmission{bid: b1.ID()})
+	c.transmissions.keep(peer.String(), keptTransmission{bid: b1.ID()})
+	c.transmissions.keep(peer.String(), keptTransmission{bid: b2.ID()})
+	c.transmissions.mutex.Unlock()
+
+	if err := c.store.Delete(b2.ID()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The periodic check forgets the kept transmissions of deleted bundles.
+	c.checkPendingBundles()
+
+	if kts := c.transmissions.resume(peer); len(kts) != 1 || kts[0].bid != b1.ID() {
+		t.Fatalf("Kept transmissions are %v", kts)
+	}
+}
@@ -0,0 +1,433 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/bundle"
+)
+
+// TrustPolicy defines how the integrity protection of received bundles is handled.
+type TrustPolicy int
+
+const (
+	// TrustIgnore does not verify any received bundle.
+	TrustIgnore TrustPolicy = iota
+
+	// TrustVerify verifies the integrity protection of received bundles, if present.
+	TrustVerify
+
+	// TrustRequire requires an integrity protection for each received bundle, which will be verified.
+	TrustRequire
+)
+
+func (tp TrustPolicy) String() string {
+	switch tp {
+	case TrustIgnore:
+		return "ignore"
+	case TrustVerify:
+		return "verify"
+	case TrustRequire:
+		return "require"
+	default:
+		return "unknown"
+	}
+}
+
+// parseTrustPolicy from its string representation, as returned by TrustPolicy.String. Defaults to TrustVerify.
+func parseTrustPolicy(policy string) (TrustPolicy, error) {
+	switch policy {
+	case "ignore":
+		return TrustIgnore, nil
+	case "", "verify":
+		return TrustVerify, nil
+	case "require":
+		return TrustRequire, nil
+	default:
+		return TrustIgnore, fmt.Errorf("unknown trust policy %s", policy)
+	}
+}
+
+// TrustStore maps node IDs to their trusted ed25519 public keys.
+type TrustStore struct {
+	keys  map[string]ed25519.PublicKey
+	mutex sync.RWMutex
+}
+
+// NewTrustStore creates an empty TrustStore.
+func NewTrustStore() *TrustStore {
+	return &TrustStore{keys: make(map[string]ed25519.PublicKey)}
+}
+
+// trustStoreKey is the map key for an EndpointID, identifying its node.
+func trustStoreKey(eid bundle.EndpointID) string {
+	if eid.EndpointType == nil {
+		eid = bundle.DtnNone()
+	}
+	return eid.EndpointType.SchemeName() + ":" + eid.Authority()
+}
+
+// Add a trusted ed25519 public key for the node of the given EndpointID.
+func (ts *TrustStore) Add(eid bundle.EndpointID, pub ed25519.PublicKey) error {
+	if l := len(pub); l != ed25519.PublicKeySize {
+		return fmt.Errorf("ed25519 public key's length is %d, not %d", l, ed25519.PublicKeySize)
+	}
+
+	ts.mutex.Lock()
+	ts.keys[trustStoreKey(eid)] = pub
+	ts.mutex.Unlock()
+
+	return nil
+}
+
+// AddHex adds a trusted hex encoded ed25519 public key for the node of the given EndpointID string.
+func (ts *TrustStore) AddHex(eid, pub string) error {
+	endpoint, err := bundle.NewEndpointID(eid)
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := hex.DecodeString(pub)
+	if err != nil {
+		return fmt.Errorf("decoding public key of %s errored: %v", eid, err)
+	}
+
+	return ts.Add(endpoint, pubKey)
+}
+
+// Lookup the trusted ed25519 public key for the node of the given EndpointID.
+func (ts *TrustStore) Lookup(eid bundle.EndpointID) (pub ed25519.PublicKey, ok bool) {
+	ts.mutex.RLock()
+	pub, ok = ts.keys[trustStoreKey(eid)]
+	ts.mutex.RUnlock()
+
+	return
+}
+
+// LoadDirectory adds the trusted keys of all files within a directory.
+//
+// Each line of such a file must contain a node ID and its hex encoded ed25519 public key, separated by whitespace.
+// Empty lines and lines starting with a # are ignored.
+func (ts *TrustStore) LoadDirectory(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+
+		filename := filepath.Join(dir, file.Name())
+		if err := ts.loadFile(filename); err != nil {
+			return fmt.Errorf("loading trusted keys from %s errored: %v", filename, err)
+		}
+	}
+
+	return nil
+}
+
+// loadFile adds the trusted keys of a single file, as described for LoadDirectory.
+func (ts *TrustStore) loadFile(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("line %d has %d instead of 2 fields", lineNo, len(fields))
+		}
+
+		if err := ts.AddHex(fields[0], fields[1]); err != nil {
+			return fmt.Errorf("line %d: %v", lineNo, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// TrustConf configures the verification of received bundles' integrity protection.
+type TrustConf struct {
+	// Policy is one of "require", "verify" or "ignore", as described for the TrustPolicy. Defaults to "verify".
+	Policy string
+
+	// Directory of files containing trusted keys, as described for TrustStore.LoadDirectory.
+	Directory string
+
+	// Keys maps node IDs to their hex encoded ed25519 public keys.
+	Keys map[string]string
+
+	// Gateways maps source node IDs to the node IDs of gateways, which are trusted to integrity protect bundles of
+	// this source. Otherwise, a Block Integrity Block's security source must be the bundle's source node.
+	Gateways map[string][]string
+}
+
+// trustPolicy is the parsed TrustConf, used by the Core.
+type trustPolicy struct {
+	policy TrustPolicy
+	store  *TrustStore
+
+	// gateways maps the trustStoreKey of a source node to its trusted gateways' trustStoreKeys.
+	gateways map[string]map[string]bool
+
+	// failures counts the bundles which failed verification; accessed atomically.
+	failures uint64
+}
+
+// SetTrustConf configures the verification of received bundles. Without being called, no bundle will be verified.
+//
+// Block Integrity Blocks of the ed25519 security context and the deprecated SignatureBlocks are verified against
+// the trusted keys of their security source. This node's own key is always trusted. Block Integrity Blocks of the
+// BIB-HMAC-SHA2 security context are verified against the configured HMAC key of the IntegrityConf. A Block
+// Integrity Block's security source must be the bundle's source node or one of its configured gateways.
+func (c *Core) SetTrustConf(conf TrustConf) error {
+	policy, err := parseTrustPolicy(conf.Policy)
+	if err != nil {
+		return err
+	}
+
+	ts := NewTrustStore()
+	if c.signPriv != nil {
+		if err := ts.Add(c.NodeId, c.signPriv.Public().(ed25519.PublicKey)); err != nil {
+			return err
+		}
+	}
+
+	if conf.Directory != "" {
+		if err := ts.LoadDirectory(conf.Directory); err != nil {
+			return err
+		}
+	}
+
+	for eid, pub := range conf.Keys {
+		if err := ts.AddHex(eid, pub); err != nil {
+			return err
+		}
+	}
+
+	gateways := make(map[string]map[string]bool)
+	for src, gws := range conf.Gateways {
+		srcEid, err := bundle.NewEndpointID(src)
+		if err != nil {
+			return err
+		}
+
+		srcKey := trustStoreKey(srcEid)
+		if gateways[srcKey] == nil {
+			gateways[srcKey] = make(map[string]bool)
+		}
+
+		for _, gw := range gws {
+			gwEid, err := bundle.NewEndpointID(gw)
+			if err != nil {
+				return fmt.Errorf("gateway of %s: %v", src, err)
+			}
+			gateways[srcKey][trustStoreKey(gwEid)] = true
+		}
+	}
+
+	if policy != TrustIgnore {
+		if err := registerSignatureBlock(); err != nil {
+			return err
+		}
+	}
+
+	c.trust = &trustPolicy{policy: policy, store: ts, gateways: gateways}
+	return nil
+}
+
+// trustedSource checks if a security source is allowed to integrity protect bundles of a source node, being either
+// this node itself or one of its configured gateways.
+func (tp *trustPolicy) trustedSource(securitySource, sourceNode bundle.EndpointID) bool {
+	srcKey := trustStoreKey(sourceNode)
+	secKey := trustStoreKey(securitySource)
+
+	return secKey == srcKey || tp.gateways[srcKey][secKey]
+}
+
+// IntegrityFailures returns the amount of received bundles which failed the verification of their integrity.
+func (c *Core) IntegrityFailures() uint64 {
+	if c.trust == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&c.trust.failures)
+}
+
+// registerSignatureBlock makes the deprecated SignatureBlock known, allowing to inspect bundles from older nodes.
+func registerSignatureBlock() error {
+	if !bundle.GetExtensionBlockManager().IsKnown(bundle.ExtBlockTypeSignatureBlock) {
+		if err := bundle.GetExtensionBlockManager().Register(&bundle.SignatureBlock{}); err != nil {
+			return fmt.Errorf("SignatureBlock registration errored: %v", err)
+		}
+	}
+	return nil
+}
+
+// missingKeyError reports that an integrity protection cannot be verified because this node lacks the required key.
+type missingKeyError struct {
+	msg string
+}
+
+func (mke *missingKeyError) Error() string {
+	return mke.msg
+}
+
+// verifyBlockIntegrityBlock against the trusted key of its security context, after checking its security source.
+func (c *Core) verifyBlockIntegrityBlock(bndl *bundle.Bundle, bib *bundle.BlockIntegrityBlock) (bundle.StatusReportReason, error) {
+	if src := bndl.PrimaryBlock.SourceNode; !c.trust.trustedSource(bib.SecuritySource, src) {
+		return bundle.FailedSecurityOperation, fmt.Errorf("security source %v is not trusted for source %v",
+			bib.SecuritySource, src)
+	}
+
+	var key []byte
+
+	switch bib.SecurityContextId {
+	case bundle.SecConIdBIBEd25519:
+		trusted, ok := c.trust.store.Lookup(bib.SecuritySource)
+		if !ok {
+			return bundle.FailedSecurityOperation, &missingKeyError{fmt.Sprintf("no trusted key for %v", bib.SecuritySource)}
+		}
+		if pub, ok := bib.Ed25519PublicKey(); ok && !bytes.Equal(pub, trusted) {
+			return bundle.FailedSecurityOperation, fmt.Errorf("attached key differs from trusted key for %v", bib.SecuritySource)
+		}
+		key = trusted
+
+	case bundle.SecConIdBIBHMACSHA2:
+		if c.integrity == nil || c.integrity.hmacKey == nil {
+			return bundle.UnknownSecurityOperation, &missingKeyError{"no HMAC key is configured"}
+		}
+		key = c.integrity.hmacKey
+
+	default:
+		return bundle.UnknownSecurityOperation, &missingKeyError{fmt.Sprintf("unsupported security context %d", bib.SecurityContextId)}
+	}
+
+	if err := bib.Verify(*bndl, key); err != nil {
+		return bundle.FailedSecurityOperation, err
+	}
+	return bundle.NoInformation, nil
+}
+
+// verifySignatureBlock against the trusted key of the bundle's source.
+func (c *Core) verifySignatureBlock(bndl *bundle.Bundle, sb *bundle.SignatureBlock) (bundle.StatusReportReason, error) {
+	src := bndl.PrimaryBlock.SourceNode
+	if trusted, ok := c.trust.store.Lookup(src); !ok {
+		return bundle.FailedSecurityOperation, &missingKeyError{fmt.Sprintf("no trusted key for %v", src)}
+	} else if !bytes.Equal(sb.PublicKey, trusted) {
+		return bundle.FailedSecurityOperation, fmt.Errorf("attached key differs from trusted key for %v", src)
+	} else if !sb.Verify(*bndl) {
+		return bundle.FailedSecurityOperation, fmt.Errorf("invalid signature")
+	}
+	return bundle.NoInformation, nil
+}
+
+// verifyIntegrity checks a bundle's integrity protection against the TrustPolicy.
+//
+// Encrypted Block Integrity Blocks and those of fragments cannot be verified at this point. Their verification is
+// deferred until the bundle's local delivery. Thus, intermediate nodes accept such bundles. Intermediate nodes also
+// forward bundles whose integrity protection they lack the key for; only the bundle's destination rejects them.
+func (c *Core) verifyIntegrity(bp BundlePack) (bundle.StatusReportReason, error) {
+	if c.trust == nil || c.trust.policy == TrustIgnore {
+		return bundle.NoInformation, nil
+	}
+
+	bndl := bp.MustBundle()
+	isFragment := bndl.PrimaryBlock.BundleControlFlags.Has(bundle.IsFragment)
+	isDestination := c.HasEndpoint(bndl.PrimaryBlock.Destination)
+
+	var found, verified bool
+	for i := range bndl.CanonicalBlocks {
+		cb := &bndl.CanonicalBlocks[i]
+
+		var reason bundle.StatusReportReason
+		var err error
+
+		switch eb := cb.Value.(type) {
+		case *bundle.BlockIntegrityBlock:
+			found = true
+			if isFragment {
+				continue
+			}
+			reason, err = c.verifyBlockIntegrityBlock(bndl, eb)
+
+		case *bundle.SignatureBlock:
+			found = true
+			if isFragment {
+				continue
+			}
+			reason, err = c.verifySignatureBlock(bndl, eb)
+
+		case *bundle.GenericExtensionBlock:
+			if cb.TypeCode() == bundle.ExtBlockTypeBlockIntegrityBlock {
+				found = true
+			}
+			continue
+
+		default:
+			continue
+		}
+
+		var mke *missingKeyError
+		if err != nil && errors.As(err, &mke) && !isDestination {
+			log.WithField("bundle", bp.ID()).WithError(err).Debug(
+				"Forwarding bundle without verifying its integrity protection")
+			continue
+		} else if err != nil {
+			return reason, err
+		}
+		verified = true
+	}
+
+	if !found && c.trust.policy == TrustRequire {
+		return bundle.MissingSecurityOperation, fmt.Errorf("bundle has no integrity protection")
+	}
+
+	if verified {
+		log.WithField("bundle", bp.ID()).Debug("Verified bundle's integrity protection")
+	}
+	return bundle.NoInformation, nil
+}
+
+// checkIntegrity verifies a bundle's integrity protection and logs and counts failures.
+func (c *Core) checkIntegrity(bp BundlePack) (bool, bundle.StatusReportReason) {
+	reason, err := c.verifyIntegrity(bp)
+	if err == nil {
+		return true, reason
+	}
+
+	atomic.AddUint64(&c.trust.failures, 1)
+
+	log.WithFields(log.Fields{
+		"bundle": bp.ID(),
+		"policy": c.trust.policy,
+		"reason": reason,
+	}).WithError(err).Warn("Bundle failed integrity verification")
+
+	return false, reason
+}
@@ -0,0 +1,214 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/dtn7/dtn7-go/cla"
+)
+
+func TestTrustStoreLoadDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pub, _, _ := ed25519.GenerateKey(nil)
+	content := fmt.Sprintf("# trusted nodes\n\ndtn://foo/ %x\n", pub)
+	if err := ioutil.WriteFile(filepath.Join(dir, "foo"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := NewTrustStore()
+	if err := ts.LoadDirectory(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if trusted, ok := ts.Lookup(bundle.MustNewEndpointID("dtn://foo/bar")); !ok {
+		t.Fatal("Key for dtn://foo/ is unknown")
+	} else if !trusted.Equal(pub) {
+		t.Fatalf("Key %x differs from %x", trusted, pub)
+	}
+
+	if _, ok := ts.Lookup(bundle.MustNewEndpointID("dtn://bar/")); ok {
+		t.Fatal("Key for dtn://bar/ is known")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "invalid"), []byte("dtn://bar/\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.LoadDirectory(dir); err == nil {
+		t.Fatal("Loading an invalid file did not error")
+	}
+}
+
+func TestCoreVerifyIntegrity(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	_, forgedPriv, _ := ed25519.GenerateKey(nil)
+	kek := "000102030405060708090a0b0c0d0e0f"
+	hmacKey := "0f0e0d0c0b0a09080706050403020100"
+
+	newBundle := func(t *testing.T, priv ed25519.PrivateKey, hmac, encrypt bool) *bundle.Bundle {
+		b, err := bundle.Builder().
+			Source("dtn://src/").
+			Destination("dtn://dst/").
+			CreationTimestampNow().
+			Lifetime("1h").
+			PayloadBlock([]byte("hello world")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if priv != nil {
+			src := &Core{NodeId: b.PrimaryBlock.SourceNode, signPriv: priv}
+			if err := src.SetIntegrityConf(IntegrityConf{}); err != nil {
+				t.Fatal(err)
+			}
+			src.sendBundleAttachIntegrity(&b)
+		}
+
+		if hmac {
+			src := &Core{NodeId: b.PrimaryBlock.SourceNode}
+			if err := src.SetIntegrityConf(IntegrityConf{HMACKey: hmacKey}); err != nil {
+				t.Fatal(err)
+			}
+			src.sendBundleAttachIntegrity(&b)
+		}
+
+		if encrypt {
+			src := &Core{NodeId: b.PrimaryBlock.SourceNode}
+			if err := src.SetConfidentialityConf([]ConfidentialityConf{{Destination: "dtn://dst/", Key: kek}}); err != nil {
+				t.Fatal(err)
+			}
+			src.sendBundleEncrypt(&b)
+		}
+
+		return &b
+	}
+
+	tests := []struct {
+		name    string
+		node    string
+		keys    bool
+		policy  string
+		priv    ed25519.PrivateKey
+		hmac    bool
+		encrypt bool
+		reason  bundle.StatusReportReason
+		valid   bool
+	}{
+		{"ignore forged", "dtn://dst/", true, "ignore", forgedPriv, false, false, bundle.NoInformation, true},
+		{"verify unsigned", "dtn://dst/", true, "verify", nil, false, false, bundle.NoInformation, true},
+		{"verify signed", "dtn://dst/", true, "verify", priv, false, false, bundle.NoInformation, true},
+		{"verify forged", "dtn://dst/", true, "verify", forgedPriv, false, false, bundle.FailedSecurityOperation, false},
+		{"verify encrypted forged", "dtn://dst/", true, "verify", forgedPriv, false, true, bundle.NoInformation, true},
+		{"verify unknown key", "dtn://dst/", false, "verify", priv, false, false, bundle.FailedSecurityOperation, false},
+		{"verify hmac without key", "dtn://dst/", true, "verify", nil, true, false, bundle.UnknownSecurityOperation, false},
+		{"require unsigned", "dtn://dst/", true, "require", nil, false, false, bundle.MissingSecurityOperation, false},
+		{"require signed", "dtn://dst/", true, "require", priv, false, false, bundle.NoInformation, true},
+		{"relay signed", "dtn://relay/", true, "verify", priv, false, false, bundle.NoInformation, true},
+		{"relay forged", "dtn://relay/", true, "verify", forgedPriv, false, false, bundle.FailedSecurityOperation, false},
+		{"relay unknown key", "dtn://relay/", false, "verify", priv, false, false, bundle.NoInformation, true},
+		{"relay hmac without key", "dtn://relay/", true, "require", nil, true, false, bundle.NoInformation, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Core{NodeId: bundle.MustNewEndpointID(test.node), claManager: cla.NewManager()}
+			c.agentManager = NewAgentManager(c)
+
+			keys := map[string]string{}
+			if test.keys {
+				keys["dtn://src/"] = hex.EncodeToString(pub)
+			}
+			if err := c.SetTrustConf(TrustConf{Policy: test.policy, Keys: keys}); err != nil {
+				t.Fatal(err)
+			}
+
+			b := newBundle(t, test.priv, test.hmac, test.encrypt)
+			bp := BundlePack{Id: b.ID(), bndl: b}
+
+			valid, reason := c.checkIntegrity(bp)
+			if valid != test.valid || reason != test.reason {
+				t.Fatalf("Verification resulted in %t, %v; expected %t, %v", valid, reason, test.valid, test.reason)
+			}
+
+			if failures := c.IntegrityFailures(); (failures == 0) != test.valid {
+				t.Fatalf("Core counted %d failures", failures)
+			}
+		})
+	}
+}
+
+func TestCoreVerifyIntegritySecuritySource(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+
+	// A bundle claiming dtn://src/ as its source, but being signed by the trusted dtn://gw/.
+	b, err := bundle.Builder().
+		Source("dtn://src/").
+		Destination("dtn://dst/").
+		CreationTimestampNow().
+		Lifetime("1h").
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gw := &Core{NodeId: bundle.MustNewEndpointID("dtn://gw/"), signPriv: priv}
+	if err := gw.SetIntegrityConf(IntegrityConf{}); err != nil {
+		t.Fatal(err)
+	}
+	gw.sendBundleAttachIntegrity(&b)
+
+	tests := []struct {
+		name     string
+		node     string
+		gateways map[string][]string
+		valid    bool
+	}{
+		{"destination without gateway", "dtn://dst/", nil, false},
+		{"relay without gateway", "dtn://relay/", nil, false},
+		{"other gateway", "dtn://dst/", map[string][]string{"dtn://src/": {"dtn://other/"}}, false},
+		{"gateway of other source", "dtn://dst/", map[string][]string{"dtn://other/": {"dtn://gw/"}}, false},
+		{"destination with gateway", "dtn://dst/", map[string][]string{"dtn://src/": {"dtn://gw/"}}, true},
+		{"relay with gateway", "dtn://relay/", map[string][]string{"dtn://src/": {"dtn://gw/"}}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Core{NodeId: bundle.MustNewEndpointID(test.node), claManager: cla.NewManager()}
+			c.agentManager = NewAgentManager(c)
+
+			conf := TrustConf{
+				Policy:   "verify",
+				Keys:     map[string]string{"dtn://gw/": hex.EncodeToString(pub)},
+				Gateways: test.gateways,
+			}
+			if err := c.SetTrustConf(conf); err != nil {
+				t.Fatal(err)
+			}
+
+			bp := BundlePack{Id: b.ID(), bndl: &b}
+
+			valid, reason := c.checkIntegrity(bp)
+			if valid != test.valid {
+				t.Fatalf("Verification resulted in %t, %v; expected %t", valid, reason, test.valid)
+			} else if !valid && reason != bundle.FailedSecurityOperation {
+				t.Fatalf("Verification failed with reason %v", reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package core
+
+// Version of this dtn7-go build, e.g., answered by the "version" syscall. A release build might set it by the linker,
+// `go build -ldflags "-X github.com/dtn7/dtn7-go/core.Version=v0.9.0"`.
+var Version = "devel"
@@ -0,0 +1,266 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package metrics provides counters, gauges, and summaries, exposed in the Prometheus text format.
+//
+// Each metric might have labels, whose values must be passed in the same order for each update. Metrics are
+// registered at a Registry, which writes all metrics on an HTTP request. The DefaultRegistry is used by dtn7-go's
+// packages.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Collector is a metric to be written by a Registry.
+type Collector interface {
+	// Name of this metric, unique within a Registry.
+	Name() string
+
+	// Collect writes this metric in the Prometheus text format.
+	Collect(w io.Writer) error
+}
+
+// desc describes a metric by its name, help text, type, and label names.
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d desc) Name() string {
+	return d.name
+}
+
+// writeHeader writes the HELP and TYPE lines.
+func (d desc) writeHeader(w io.Writer) error {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help)
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, help, d.name, d.typ)
+	return err
+}
+
+// labelKey joins label values to a map key.
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// writeSample writes a sample line for a metric's name, label names and values, and its value.
+func writeSample(w io.Writer, name string, labels, values []string, value float64) error {
+	var b strings.Builder
+	b.WriteString(name)
+
+	if len(labels) > 0 {
+		escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+		b.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			_, _ = fmt.Fprintf(&b, "%s=\"%s\"", label, escape.Replace(values[i]))
+		}
+		b.WriteByte('}')
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(formatValue(value))
+	b.WriteByte('\n')
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// formatValue in the Prometheus text format.
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// vec stores a value for each combination of label values.
+type vec struct {
+	desc
+
+	mutex  sync.Mutex
+	values map[string]*vecValue
+}
+
+type vecValue struct {
+	labelValues []string
+	value       float64
+	count       uint64
+}
+
+func newVec(name, help, typ string, labels []string) vec {
+	return vec{
+		desc:   desc{name: name, help: help, typ: typ, labels: labels},
+		values: make(map[string]*vecValue),
+	}
+}
+
+// update the value for the label values by a function, which also receives the amount of previous updates.
+func (v *vec) update(labelValues []string, f func(value float64) float64) {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	key := labelKey(labelValues)
+	vv, ok := v.values[key]
+	if !ok {
+		vv = &vecValue{labelValues: append([]string(nil), labelValues...)}
+		v.values[key] = vv
+	}
+
+	vv.value = f(vv.value)
+	vv.count++
+}
+
+// sorted returns a copy of all values, sorted by their label values.
+func (v *vec) sorted() []vecValue {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	vvs := make([]vecValue, 0, len(v.values))
+	for _, vv := range v.values {
+		vvs = append(vvs, *vv)
+	}
+	sort.Slice(vvs, func(i, j int) bool { return labelKey(vvs[i].labelValues) < labelKey(vvs[j].labelValues) })
+	return vvs
+}
+
+// Counter is a monotonically increasing metric.
+type Counter struct {
+	vec
+}
+
+// NewCounter creates a new Counter with optional label names.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{newVec(name, help, "counter", labels)}
+}
+
+// Add a non-negative value to the counter for the label values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("counter %s cannot be decreased", c.name))
+	}
+	c.update(labelValues, func(value float64) float64 { return value + v })
+}
+
+// Inc increments the counter for the label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Collect(w io.Writer) error {
+	if err := c.writeHeader(w); err != nil {
+		return err
+	}
+	for _, vv := range c.sorted() {
+		if err := writeSample(w, c.name, c.labels, vv.labelValues, vv.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Gauge is a metric which might be set to any value.
+type Gauge struct {
+	vec
+}
+
+// NewGauge creates a new Gauge with optional label names.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{newVec(name, help, "gauge", labels)}
+}
+
+// Set the gauge for the label values.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.update(labelValues, func(float64) float64 { return v })
+}
+
+// Add a value, which might be negative, to the gauge for the label values.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.update(labelValues, func(value float64) float64 { return value + v })
+}
+
+func (g *Gauge) Collect(w io.Writer) error {
+	if err := g.writeHeader(w); err != nil {
+		return err
+	}
+	for _, vv := range g.sorted() {
+		if err := writeSample(w, g.name, g.labels, vv.labelValues, vv.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Summary observes values, e.g., durations, exposing their sum and count.
+type Summary struct {
+	vec
+}
+
+// NewSummary creates a new Summary with optional label names.
+func NewSummary(name, help string, labels ...string) *Summary {
+	return &Summary{newVec(name, help, "summary", labels)}
+}
+
+// Observe a value for the label values.
+func (s *Summary) Observe(v float64, labelValues ...string) {
+	s.update(labelValues, func(value float64) float64 { return value + v })
+}
+
+func (s *Summary) Collect(w io.Writer) error {
+	if err := s.writeHeader(w); err != nil {
+		return err
+	}
+	for _, vv := range s.sorted() {
+		if err := writeSample(w, s.name+"_sum", s.labels, vv.labelValues, vv.value); err != nil {
+			return err
+		} else if err := writeSample(w, s.name+"_count", s.labels, vv.labelValues, float64(vv.count)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Func is a metric without labels, whose value is requested from a function on each collection.
+type Func struct {
+	desc
+	f func() float64
+}
+
+// NewGaugeFunc creates a gauge whose value is requested from a function.
+func NewGaugeFunc(name, help string, f func() float64) *Func {
+	return &Func{desc: desc{name: name, help: help, typ: "gauge"}, f: f}
+}
+
+// NewCounterFunc creates a counter whose value is requested from a function.
+func NewCounterFunc(name, help string, f func() float64) *Func {
+	return &Func{desc: desc{name: name, help: help, typ: "counter"}, f: f}
+}
+
+func (f *Func) Collect(w io.Writer) error {
+	if err := f.writeHeader(w); err != nil {
+		return err
+	}
+	return writeSample(w, f.name, nil, nil, f.f())
+}
@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+
+	c := NewCounter("test_bundles_total", "Test bundles.", "reason")
+	c.Inc("no information")
+	c.Add(2, "hop \"limit\"")
+	r.Register(c)
+
+	g := NewGauge("test_pending", "Pending\nbundles.")
+	g.Set(5)
+	g.Add(-2)
+	r.Register(g)
+
+	s := NewSummary("test_duration_seconds", "Test durations.", "job")
+	s.Observe(0.5, "cron")
+	s.Observe(1.5, "cron")
+	r.Register(s)
+
+	r.Register(NewGaugeFunc("test_func", "Test function.", func() float64 { return 23 }))
+
+	expected := `# HELP test_bundles_total Test bundles.
+# TYPE test_bundles_total counter
+test_bundles_total{reason="hop \"limit\""} 2
+test_bundles_total{reason="no information"} 1
+# HELP test_duration_seconds Test durations.
+# TYPE test_duration_seconds summary
+test_duration_seconds_sum{job="cron"} 2
+test_duration_seconds_count{job="cron"} 2
+# HELP test_func Test function.
+# TYPE test_func gauge
+test_func 23
+# HELP test_pending Pending\nbundles.
+# TYPE test_pending gauge
+test_pending 3
+`
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if body, err := ioutil.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	} else if string(body) != expected {
+		t.Fatalf("Metrics differ:\n%s\nexpected:\n%s", body, expected)
+	}
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("Content type is %q", ct)
+	}
+
+	r.Unregister("test_func")
+	var b strings.Builder
+	if err := r.Collect(&b); err != nil {
+		t.Fatal(err)
+	} else if strings.Contains(b.String(), "test_func") {
+		t.Fatal("Unregistered metric was collected")
+	}
+}
+
+func TestCounterLabelValues(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Wrong amount of label values did not panic")
+		}
+	}()
+
+	NewCounter("test_total", "Test.", "a", "b").Inc("a")
+}
@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Registry of Collectors, which might be exposed via HTTP.
+type Registry struct {
+	mutex      sync.Mutex
+	collectors map[string]Collector
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// Register a Collector. A previously registered Collector of the same name will be replaced.
+func (r *Registry) Register(c Collector) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.collectors[c.Name()] = c
+}
+
+// Unregister a Collector by its name.
+func (r *Registry) Unregister(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.collectors, name)
+}
+
+// Collect all registered metrics, sorted by their names, in the Prometheus text format.
+func (r *Registry) Collect(w io.Writer) error {
+	r.mutex.Lock()
+	collectors := make([]Collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		collectors = append(collectors, c)
+	}
+	r.mutex.Unlock()
+
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].Name() < collectors[j].Name() })
+
+	for _, c := range collectors {
+		if err := c.Collect(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ServeHTTP writes all metrics, making the Registry a http.Handler.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	var buff bytes.Buffer
+	if err := r.Collect(&buff); err != nil {
+		log.WithError(err).Warn("Collecting metrics errored")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := buff.WriteTo(w); err != nil {
+		log.WithError(err).Debug("Writing metrics errored")
+	}
+}
+
+// DefaultRegistry is used by dtn7-go's packages.
+var DefaultRegistry = NewRegistry()
+
+// Register a Collector at the DefaultRegistry.
+func Register(c Collector) {
+	DefaultRegistry.Register(c)
+}
+
+// Unregister a Collector by its name from the DefaultRegistry.
+func Unregister(name string) {
+	DefaultRegistry.Unregister(name)
+}
@@ -5,6 +5,7 @@
 package storage
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"fmt"
 	"os"
@@ -21,18 +22,35 @@ type BundleItem struct {
 	BId bundle.BundleID
 
 	Pending bool      `badgerholdIndex:"Pending"`
+	Local   bool      `badgerholdIndex:"Local"`
 	Expires time.Time `badgerholdIndex:"Expires"`
 
+	// Destination of the Bundle, available without reading the Bundle. Unset for Bundles stored by older versions.
+	Destination bundle.EndpointID
+
 	Fragmented bool
 	Parts      []BundlePart
 
+	// Size of all serialized BundleParts in bytes.
+	Size uint64
+	// Stored is the time of this BundleItem's insertion.
+	Stored time.Time
+	// Priority is used by the EvictPriority policy.
+	Priority int
+	// Originated marks Bundles which were created at this node.
+	Originated bool
+	// Contraindicated marks Bundles which are currently not forwardable; those are evicted first.
+	Contraindicated bool
+
 	Properties map[string]interface{}
 }
 
 // BundlePart links a BundleItem to a Bundle with possible information
-// regarding fragmentations.
+// regarding fragmentations. The Bundle is either stored in a file or, for
+// the MemoryStore, serialized within the Data field.
 type BundlePart struct {
 	Filename string
+	Data     []byte
 
 	FragmentOffset  uint64
 	TotalDataLength uint64
@@ -52,9 +70,20 @@ func (bp BundlePart) deleteBundle() error {
 	return os.Remove(bp.Filename)
 }
 
-// Load the Bundle struct from the disk.
+// fileSize returns the size of the serialized Bundle on the disk.
+func (bp BundlePart) fileSize() uint64 {
+	if fi, err := os.Stat(bp.Filename); err != nil {
+		return 0
+	} else {
+		return uint64(fi.Size())
+	}
+}
+
+// Load the Bundle struct from the disk or from its Data.
 func (bp BundlePart) Load() (b bundle.Bundle, err error) {
-	if f, fErr := os.Open(bp.Filename); fErr != nil {
+	if bp.Data != nil {
+		b, err = bundle.ParseBundle(bytes.NewReader(bp.Data))
+	} else if f, fErr := os.Open(bp.Filename); fErr != nil {
 		err = fErr
 	} else {
 		b, err = bundle.ParseBundle(f)
@@ -62,11 +91,19 @@ func (bp BundlePart) Load() (b bundle.Bundle, err error) {
 	return
 }
 
-// calcExpirationDate for a Bundle.
-func calcExpirationDate(b bundle.Bundle) time.Time {
-	// TODO: check Bundle Age Block
-	return b.PrimaryBlock.CreationTimestamp.DtnTime().Time().Add(
-		time.Duration(b.PrimaryBlock.Lifetime) * time.Millisecond)
+// BundleSize returns the size of a serialized Bundle in bytes.
+func BundleSize(b bundle.Bundle) uint64 {
+	var cw countingWriter
+	_ = b.MarshalCbor(&cw)
+	return uint64(cw)
+}
+
+// countingWriter is an io.Writer which only counts the written bytes.
+type countingWriter uint64
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	*cw += countingWriter(len(p))
+	return len(p), nil
 }
 
 // bundlePartPath returns a path for a Bundle.
@@ -78,16 +115,23 @@ func bundlePartPath(id bundle.BundleID, storagePath string) string {
 // newBundleItem creates a new BundleItem for a Bundle.
 func newBundleItem(b bundle.Bundle, storagePath string) (bi BundleItem) {
 	bid := b.ID()
+	now := time.Now()
 
 	bi = BundleItem{
 		Id:  bid.Scrub().String(),
 		BId: bid.Scrub(),
 
 		Pending: false,
-		Expires: calcExpirationDate(b),
+		Local:   false,
+		Expires: b.ExpirationTime(now),
+
+		Destination: b.PrimaryBlock.Destination,
 
 		Fragmented: b.PrimaryBlock.HasFragmentation(),
 
+		Size:   BundleSize(b),
+		Stored: now,
+
 		Properties: make(map[string]interface{}),
 	}
 
@@ -0,0 +1,385 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/bundle"
+)
+
+// ErrQuotaExceeded is returned by the Store's Push if a Bundle does not fit into the Quota, even after evicting
+// other Bundles.
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// EvictionPolicy defines which Bundles are evicted first if the Store's Quota is reached.
+//
+// Independent of the policy, contraindicated Bundles are evicted first, followed by all other relayed Bundles.
+// Bundles which were originated at this node or are awaiting their local delivery are evicted last.
+type EvictionPolicy int
+
+const (
+	// EvictOldest evicts the Bundles which are stored the longest.
+	EvictOldest EvictionPolicy = iota
+
+	// EvictSoonestExpiring evicts the Bundles which will expire next.
+	EvictSoonestExpiring
+
+	// EvictLargest evicts the largest Bundles.
+	EvictLargest
+
+	// EvictPriority evicts the Bundles with the lowest priority, the oldest of them first.
+	EvictPriority
+)
+
+func (ep EvictionPolicy) String() string {
+	switch ep {
+	case EvictOldest:
+		return "oldest"
+	case EvictSoonestExpiring:
+		return "soonest-expiring"
+	case EvictLargest:
+		return "largest"
+	case EvictPriority:
+		return "priority"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseEvictionPolicy from its string representation, as returned by EvictionPolicy.String. Defaults to EvictOldest.
+func ParseEvictionPolicy(policy string) (EvictionPolicy, error) {
+	switch policy {
+	case "", "oldest":
+		return EvictOldest, nil
+	case "soonest-expiring":
+		return EvictSoonestExpiring, nil
+	case "largest":
+		return EvictLargest, nil
+	case "priority":
+		return EvictPriority, nil
+	default:
+		return EvictOldest, fmt.Errorf("unknown eviction policy %s", policy)
+	}
+}
+
+// Quota limits the Store's size. If a new Bundle would exceed the Quota, other Bundles are evicted based on the
+// EvictionPolicy. A Bundle is refused if it does not fit without evicting itself.
+type Quota struct {
+	// MaxBytes is the maximum size of all stored Bundles in bytes. Zero disables this limit.
+	MaxBytes uint64
+
+	// MaxBundles is the maximum amount of stored Bundles. Zero disables this limit.
+	MaxBundles uint64
+
+	// Policy to select the evicted Bundles.
+	Policy EvictionPolicy
+
+	// Priority of a new Bundle, used by EvictPriority. Might be nil, resulting in an equal priority.
+	Priority func(b bundle.Bundle) int
+
+	// Originated checks if a new Bundle was created at this node. Might be nil.
+	Originated func(b bundle.Bundle) bool
+
+	// Evict is called for each evicted BundleItem, lacking its Parts and Properties, e.g., to clean up further state.
+	// It might delete the BundleItem from the Store; otherwise, the Store deletes evicted BundleItems itself. Evict is
+	// called from within the Store's Push and must not push further Bundles itself.
+	Evict func(bi BundleItem)
+}
+
+// isLimited checks if this Quota limits the Store at all.
+func (q Quota) isLimited() bool {
+	return q.MaxBytes > 0 || q.MaxBundles > 0
+}
+
+// evictionClass orders BundleItems before applying the EvictionPolicy; lower classes are evicted first.
+func (_ Quota) evictionClass(bi BundleItem) int {
+	switch {
+	case bi.Contraindicated:
+		return 0
+	case bi.Originated || bi.Local:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// evictsBefore checks if BundleItem a should be evicted before BundleItem b.
+func (q Quota) evictsBefore(a, b BundleItem) bool {
+	if classA, classB := q.evictionClass(a), q.evictionClass(b); classA != classB {
+		return classA < classB
+	}
+
+	switch q.Policy {
+	case EvictSoonestExpiring:
+		if !a.Expires.Equal(b.Expires) {
+			return a.Expires.Before(b.Expires)
+		}
+
+	case EvictLargest:
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+
+	case EvictPriority:
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+	}
+
+	return a.Stored.Before(b.Stored)
+}
+
+// quotaKeeper enforces a Quota for a Store and tracks its usage. It is embedded by the Store implementations,
+// providing their SetQuota, Usage and Admits methods.
+type quotaKeeper struct {
+	store Store
+
+	// quota limits the stored Bundles; usedBytes and usedBundles track the current usage, excluding those BundleItems
+	// which are currently evicted. candidates are the stored BundleItems, except those currently evicted, ordered by
+	// their eviction; their Parts and Properties are omitted. All fields are protected by the mutex.
+	quota       Quota
+	usedBytes   uint64
+	usedBundles uint64
+	candidates  []BundleItem
+	evicting    map[string]bool
+	mutex       sync.Mutex
+}
+
+// newQuotaKeeper for a Store without any stored BundleItems being tracked.
+func newQuotaKeeper(store Store) *quotaKeeper {
+	return &quotaKeeper{
+		store:    store,
+		evicting: make(map[string]bool),
+	}
+}
+
+// SetQuota limits the Store's size. Bundles exceeding the new Quota will be evicted with the next new Bundle.
+func (qk *quotaKeeper) SetQuota(q Quota) {
+	qk.mutex.Lock()
+	qk.quota = q
+	sort.SliceStable(qk.candidates, func(i, j int) bool {
+		return qk.quota.evictsBefore(qk.candidates[i], qk.candidates[j])
+	})
+	qk.mutex.Unlock()
+
+	log.WithFields(log.Fields{
+		"max_bytes":   q.MaxBytes,
+		"max_bundles": q.MaxBundles,
+		"policy":      q.Policy,
+	}).Info("Store's quota was set")
+}
+
+// Usage returns the size of all stored Bundles in bytes and their amount.
+func (qk *quotaKeeper) Usage() (bytes, bundles uint64) {
+	qk.mutex.Lock()
+	defer qk.mutex.Unlock()
+
+	return qk.usedBytes, qk.usedBundles
+}
+
+// Admits checks if a new relayed Bundle of the given size in bytes would fit into the Quota, possibly after evicting
+// other Bundles. This should be used to refuse Bundles before receiving them.
+func (qk *quotaKeeper) Admits(size uint64) bool {
+	qk.mutex.Lock()
+	defer qk.mutex.Unlock()
+
+	incoming := BundleItem{Size: size, Stored: time.Now(), Expires: time.Unix(math.MaxInt32, 0)}
+	_, err := qk.evictionVictims(incoming, size, 1)
+	return err == nil
+}
+
+// track an already stored BundleItem, e.g., when opening an existing Store.
+func (qk *quotaKeeper) track(bi BundleItem) {
+	qk.mutex.Lock()
+	qk.usedBytes += bi.Size
+	qk.usedBundles++
+	qk.index(bi)
+	qk.mutex.Unlock()
+}
+
+// stored updates the eviction order for a BundleItem after its insertion or modification, whose space is already
+// reserved or tracked.
+func (qk *quotaKeeper) stored(bi BundleItem) {
+	qk.mutex.Lock()
+	qk.index(bi)
+	qk.mutex.Unlock()
+}
+
+// index inserts or replaces a BundleItem within the ordered candidates, unless it is currently evicted.
+// The mutex must be held.
+func (qk *quotaKeeper) index(bi BundleItem) {
+	qk.unindex(bi.Id)
+	if qk.evicting[bi.Id] {
+		return
+	}
+
+	bi.Parts = nil
+	bi.Properties = nil
+
+	i := sort.Search(len(qk.candidates), func(i int) bool {
+		return qk.quota.evictsBefore(bi, qk.candidates[i])
+	})
+
+	qk.candidates = append(qk.candidates, BundleItem{})
+	copy(qk.candidates[i+1:], qk.candidates[i:])
+	qk.candidates[i] = bi
+}
+
+// unindex removes a BundleItem from the ordered candidates. The mutex must be held.
+func (qk *quotaKeeper) unindex(id string) {
+	for i := range qk.candidates {
+		if qk.candidates[i].Id == id {
+			qk.candidates = append(qk.candidates[:i], qk.candidates[i+1:]...)
+			return
+		}
+	}
+}
+
+// prepare a new BundleItem by setting its Priority and Originated fields based on the Quota.
+func (qk *quotaKeeper) prepare(b bundle.Bundle, bi *BundleItem) {
+	qk.mutex.Lock()
+	priority, originated := qk.quota.Priority, qk.quota.Originated
+	qk.mutex.Unlock()
+
+	if priority != nil {
+		bi.Priority = priority(b)
+	}
+	if originated != nil {
+		bi.Originated = originated(b)
+	}
+}
+
+// fits checks if additional bytes and Bundles fit into the Quota after freeing some.
+// The mutex must be held.
+func (qk *quotaKeeper) fits(addBytes, addBundles, freedBytes, freedBundles uint64) bool {
+	remaining := func(used, freed uint64) uint64 {
+		if freed > used {
+			return 0
+		}
+		return used - freed
+	}
+
+	bytesOk := qk.quota.MaxBytes == 0 || remaining(qk.usedBytes, freedBytes)+addBytes <= qk.quota.MaxBytes
+	bundlesOk := qk.quota.MaxBundles == 0 || remaining(qk.usedBundles, freedBundles)+addBundles <= qk.quota.MaxBundles
+	return bytesOk && bundlesOk
+}
+
+// evictionVictims selects the BundleItems to be evicted for the incoming BundleItem, adding the given amount of
+// bytes and Bundles. An ErrQuotaExceeded is returned if the incoming BundleItem itself would be evicted.
+// The mutex must be held.
+func (qk *quotaKeeper) evictionVictims(incoming BundleItem, addBytes, addBundles uint64) (victims []BundleItem, err error) {
+	if !qk.quota.isLimited() || qk.fits(addBytes, addBundles, 0, 0) {
+		return
+	}
+
+	if qk.quota.MaxBytes > 0 && addBytes > qk.quota.MaxBytes {
+		err = ErrQuotaExceeded
+		return
+	}
+
+	var freedBytes, freedBundles uint64
+	for _, bi := range qk.candidates {
+		if !qk.quota.evictsBefore(bi, incoming) {
+			break
+		} else if bi.Id == incoming.Id {
+			continue
+		}
+
+		victims = append(victims, bi)
+		freedBytes += bi.Size
+		freedBundles++
+
+		if qk.fits(addBytes, addBundles, freedBytes, freedBundles) {
+			return
+		}
+	}
+
+	victims = nil
+	err = ErrQuotaExceeded
+	return
+}
+
+// reserve space for a new BundleItem or a new part of it, evicting other BundleItems if necessary.
+func (qk *quotaKeeper) reserve(incoming BundleItem, addBytes, addBundles uint64) error {
+	qk.mutex.Lock()
+
+	victims, err := qk.evictionVictims(incoming, addBytes, addBundles)
+	if err != nil {
+		qk.mutex.Unlock()
+		return err
+	}
+
+	for _, victim := range victims {
+		qk.evicting[victim.Id] = true
+		qk.release(victim.Size, 1)
+		qk.unindex(victim.Id)
+	}
+	qk.usedBytes += addBytes
+	qk.usedBundles += addBundles
+
+	evict := qk.quota.Evict
+	qk.mutex.Unlock()
+
+	for _, victim := range victims {
+		log.WithFields(log.Fields{
+			"bundle": victim.Id,
+			"size":   victim.Size,
+		}).Info("Store evicts BundleItem to comply with its quota")
+
+		if evict != nil {
+			evict(victim)
+		}
+
+		if qk.store.KnowsBundle(victim.BId) {
+			if err := qk.store.Delete(victim.BId); err != nil {
+				log.WithField("bundle", victim.Id).WithError(err).Warn("Failed to delete evicted BundleItem")
+			}
+		}
+
+		qk.mutex.Lock()
+		delete(qk.evicting, victim.Id)
+		qk.mutex.Unlock()
+	}
+
+	return nil
+}
+
+// unreserve previously reserved space, e.g., after a failed insertion.
+func (qk *quotaKeeper) unreserve(bytes, bundles uint64) {
+	qk.mutex.Lock()
+	qk.release(bytes, bundles)
+	qk.mutex.Unlock()
+}
+
+// deleted releases the space of a deleted BundleItem, unless it is currently evicted and already released.
+func (qk *quotaKeeper) deleted(bi BundleItem) {
+	qk.mutex.Lock()
+	if !qk.evicting[bi.Id] {
+		qk.release(bi.Size, 1)
+		qk.unindex(bi.Id)
+	}
+	qk.mutex.Unlock()
+}
+
+// release subtracts bytes and Bundles from the current usage. The mutex must be held.
+func (qk *quotaKeeper) release(bytes, bundles uint64) {
+	if bytes > qk.usedBytes {
+		bytes = qk.usedBytes
+	}
+	if bundles > qk.usedBundles {
+		bundles = qk.usedBundles
+	}
+
+	qk.usedBytes -= bytes
+	qk.usedBundles -= bundles
+}
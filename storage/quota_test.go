@@ -0,0 +1,215 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package storage
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dtn7/dtn7-go/bundle"
+)
+
+// quotaBundle creates a Bundle from the given node with a lifetime and payload size.
+func quotaBundle(t *testing.T, node, lifetime string, size int) bundle.Bundle {
+	b, err := bundle.Builder().
+		Source("dtn://" + node + "/").
+		Destination("dtn://dest/").
+		CreationTimestampNow().
+		Lifetime(lifetime).
+		PayloadBlock(make([]byte, size)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ensure distinct creation timestamps and storage times.
+	time.Sleep(2 * time.Millisecond)
+	return b
+}
+
+func TestStoreQuotaPolicies(t *testing.T) {
+	tests := []struct {
+		policy  EvictionPolicy
+		evicted string
+	}{
+		{EvictOldest, "a"},
+		{EvictSoonestExpiring, "b"},
+		{EvictLargest, "c"},
+		{EvictPriority, "d"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			dir := setupStoreDir(t)
+			defer os.RemoveAll(dir)
+
+			store, err := NewStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			var evicted []BundleItem
+			store.SetQuota(Quota{
+				MaxBundles: 4,
+				Policy:     test.policy,
+				Priority: func(b bundle.Bundle) int {
+					if b.PrimaryBlock.SourceNode.Authority() == "d" {
+						return -1
+					}
+					return 3
+				},
+				Evict: func(bi BundleItem) {
+					evicted = append(evicted, bi)
+					_ = store.Delete(bi.BId)
+				},
+			})
+
+			bndls := map[string]bundle.Bundle{
+				"a": quotaBundle(t, "a", "1h", 10),
+				"b": quotaBundle(t, "b", "10m", 10),
+				"c": quotaBundle(t, "c", "2h", 1000),
+				"d": quotaBundle(t, "d", "3h", 10),
+				"e": quotaBundle(t, "e", "4h", 10),
+			}
+			for _, node := range []string{"a", "b", "c", "d", "e"} {
+				if err := store.Push(bndls[node]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if len(evicted) != 1 || evicted[0].BId != bndls[test.evicted].ID() {
+				t.Fatalf("Evicted %v, expected bundle from %s", evicted, test.evicted)
+			} else if store.KnowsBundle(bndls[test.evicted].ID()) {
+				t.Fatal("Evicted bundle is still known")
+			} else if _, bundles := store.Usage(); bundles != 4 {
+				t.Fatalf("Store contains %d bundles", bundles)
+			}
+		})
+	}
+}
+
+func TestStoreQuotaClasses(t *testing.T) {
+	dir := setupStoreDir(t)
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.SetQuota(Quota{
+		MaxBundles: 3,
+		Originated: func(b bundle.Bundle) bool {
+			return b.PrimaryBlock.SourceNode.Authority() == "local"
+		},
+	})
+
+	local := quotaBundle(t, "local", "1h", 10)
+	relayed := quotaBundle(t, "relayed", "1h", 10)
+	contraindicated := quotaBundle(t, "contraindicated", "1h", 10)
+
+	for _, b := range []bundle.Bundle{local, relayed, contraindicated} {
+		if err := store.Push(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if bi, err := store.QueryId(contraindicated.ID()); err != nil {
+		t.Fatal(err)
+	} else {
+		bi.Contraindicated = true
+		if err := store.Update(bi); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, expected := range []bundle.BundleID{contraindicated.ID(), relayed.ID()} {
+		if err := store.Push(quotaBundle(t, "local", "1h", 10)); err != nil {
+			t.Fatal(err)
+		} else if store.KnowsBundle(expected) {
+			t.Fatalf("Bundle %v was not evicted", expected)
+		} else if !store.KnowsBundle(local.ID()) {
+			t.Fatal("Locally originated bundle was evicted")
+		}
+	}
+
+	// Only locally originated bundles are left, which are evicted after relayed bundles.
+	if store.Admits(10) {
+		t.Fatal("Store admits a relayed bundle")
+	} else if err := store.Push(quotaBundle(t, "relayed", "1h", 10)); err != ErrQuotaExceeded {
+		t.Fatalf("Pushing a relayed bundle resulted in %v", err)
+	}
+
+	bytes, bundles := store.Usage()
+	if bundles != 3 {
+		t.Fatalf("Store contains %d bundles", bundles)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if bytes2, bundles2 := store.Usage(); bytes2 != bytes || bundles2 != bundles {
+		t.Fatalf("Reopened store's usage is %d bytes and %d bundles, expected %d and %d",
+			bytes2, bundles2, bytes, bundles)
+	}
+
+	// The reopened store's eviction order is restored from its stored bundles.
+	store.SetQuota(Quota{
+		MaxBundles: 3,
+		Originated: func(b bundle.Bundle) bool {
+			return b.PrimaryBlock.SourceNode.Authority() == "local"
+		},
+	})
+	if err := store.Push(quotaBundle(t, "local", "1h", 10)); err != nil {
+		t.Fatal(err)
+	} else if store.KnowsBundle(local.ID()) {
+		t.Fatal("Oldest bundle of the reopened store was not evicted")
+	} else if _, bundles := store.Usage(); bundles != 3 {
+		t.Fatalf("Reopened store contains %d bundles", bundles)
+	}
+}
+
+func TestStoreQuotaMaxBytes(t *testing.T) {
+	dir := setupStoreDir(t)
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	store.SetQuota(Quota{MaxBytes: 512})
+
+	if store.Admits(1024) {
+		t.Fatal("Store admits a bundle larger than its quota")
+	} else if err := store.Push(quotaBundle(t, "large", "1h", 1024)); err != ErrQuotaExceeded {
+		t.Fatalf("Pushing a too large bundle resulted in %v", err)
+	}
+
+	small := quotaBundle(t, "small", "1h", 128)
+	if err := store.Push(small); err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes, _ := store.Usage(); bytes == 0 || bytes > 512 {
+		t.Fatalf("Store's usage is %d bytes", bytes)
+	}
+
+	if err := store.Push(quotaBundle(t, "medium", "1h", 384)); err != nil {
+		t.Fatal(err)
+	} else if store.KnowsBundle(small.ID()) {
+		t.Fatal("Oldest bundle was not evicted")
+	}
+}
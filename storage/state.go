@@ -0,0 +1,17 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package storage
+
+import (
+	"time"
+)
+
+// StateItem is a named and serialized state, e.g., of a routing algorithm, which is stored next to the BundleItems to
+// survive restarts.
+type StateItem struct {
+	Name   string `badgerhold:"key"`
+	Data   []byte
+	Stored time.Time
+}
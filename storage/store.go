@@ -5,81 +5,124 @@
 package storage
 
 import (
-	"os"
-	"path"
+	"errors"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/dtn7/dtn7-go/bundle"
-	"github.com/timshannon/badgerhold"
 )
 
-const (
-	dirBadger string = "db"
-	dirBundle string = "bndl"
-)
+// ErrNotFound is returned by a Store's queries if the requested item is unknown.
+var ErrNotFound = errors.New("no such item in the store")
 
 // Store implements a storage for Bundles together with meta data.
-type Store struct {
-	bh *badgerhold.Store
+//
+// Two implementations exist: the BadgerStore persists Bundles on the disk and the MemoryStore keeps them in memory,
+// e.g., for tests, simulations or embedded nodes without persistent storage.
+type Store interface {
+	// Close the Store. It must not be used afterwards.
+	Close() error
 
-	badgerDir string
-	bundleDir string
-}
+	// Push a new/received Bundle to the Store.
+	Push(b bundle.Bundle) error
 
-// NewStore creates a new Store or opens an existing Store from the given path.
-func NewStore(dir string) (s *Store, err error) {
-	badgerDir := path.Join(dir, dirBadger)
-	bundleDir := path.Join(dir, dirBundle)
+	// Update an existing BundleItem.
+	Update(bi BundleItem) error
 
-	opts := badgerhold.DefaultOptions
-	opts.Dir = badgerDir
-	opts.ValueDir = badgerDir
-	opts.Logger = log.StandardLogger()
-	opts.Options.ValueLogFileSize = 1<<28 - 1
+	// Delete a BundleItem, represented by the "scrubed" BundleID.
+	Delete(bid bundle.BundleID) error
 
-	if dirErr := os.MkdirAll(badgerDir, 0700); dirErr != nil {
-		err = dirErr
-		return
-	}
-	if dirErr := os.MkdirAll(bundleDir, 0700); dirErr != nil {
-		err = dirErr
-		return
-	}
+	// DeleteExpired removes all expired Bundles.
+	DeleteExpired()
 
-	if bh, bhErr := badgerhold.Open(opts); bhErr != nil {
-		err = bhErr
-	} else {
-		s = &Store{
-			bh: bh,
+	// KnowsBundle checks if such a Bundle is known.
+	KnowsBundle(bid bundle.BundleID) bool
 
-			badgerDir: badgerDir,
-			bundleDir: bundleDir,
-		}
-	}
-	return
+	// QueryId fetches the BundleItem for the requested BundleID or returns an ErrNotFound.
+	QueryId(bid bundle.BundleID) (BundleItem, error)
+
+	// QueryPending fetches all pending Bundles.
+	QueryPending() ([]BundleItem, error)
+
+	// QueryLocal fetches all Bundles which are stored for a local endpoint until their delivery.
+	QueryLocal() ([]BundleItem, error)
+
+	// QueryExpired fetches all Bundles which expire before the given time.
+	QueryExpired(t time.Time) ([]BundleItem, error)
+
+	// QueryAll fetches all Bundles.
+	QueryAll() ([]BundleItem, error)
+
+	// Stats summarizes the stored Bundles.
+	Stats() (StoreStats, error)
+
+	// StoreState inserts or replaces the state of the given name.
+	StoreState(name string, data []byte) error
+
+	// QueryState fetches the StateItem of the given name or returns an ErrNotFound.
+	QueryState(name string) (StateItem, error)
+
+	// DeleteState removes the state of the given name.
+	DeleteState(name string) error
+
+	// SetQuota limits the Store's size. Bundles exceeding the new Quota will be evicted with the next new Bundle.
+	SetQuota(q Quota)
+
+	// Usage returns the size of all stored Bundles in bytes and their amount.
+	Usage() (bytes, bundles uint64)
+
+	// Admits checks if a new relayed Bundle of the given size in bytes would fit into the Quota.
+	Admits(size uint64) bool
 }
 
-// Close the Store. It must not be used afterwards.
-func (s *Store) Close() error {
-	return s.bh.Close()
+// StoreStats summarizes a Store's content.
+type StoreStats struct {
+	Bundles    int
+	Pending    int
+	Local      int
+	Fragmented int
 }
 
-// Push a new/received Bundle to the Store.
-func (s *Store) Push(b bundle.Bundle) error {
-	bi := newBundleItem(b, s.bundleDir)
+// itemStore is the implementation specific part of a Store, which is used by pushBundle to store BundleItems and
+// the Bundles of their BundleParts.
+type itemStore interface {
+	QueryId(bid bundle.BundleID) (BundleItem, error)
+
+	// insertItem stores the Bundle of the BundleItem's only BundlePart and inserts the new BundleItem.
+	insertItem(bi BundleItem, b bundle.Bundle) error
 
-	if biStore, err := s.QueryId(b.ID()); err != nil {
+	// appendPart stores the Bundle of a new BundlePart and updates the BundleItem to contain this part.
+	appendPart(bi BundleItem, part BundlePart, b bundle.Bundle) error
+}
+
+// pushBundle inserts a new Bundle or a new fragment of a known Bundle, represented by a new BundleItem, into a Store
+// while enforcing its Quota.
+func pushBundle(is itemStore, qk *quotaKeeper, b bundle.Bundle, bi BundleItem) error {
+	if biStore, err := is.QueryId(b.ID()); err != nil {
 		log.WithFields(log.Fields{
 			"bundle": b.ID().String(),
 		}).Info("Bundle ID is unknown, inserting BundleItem")
 
-		if err := bi.Parts[0].storeBundle(b); err != nil {
+		qk.prepare(b, &bi)
+
+		if err := qk.reserve(bi, bi.Size, 1); err != nil {
+			log.WithFields(log.Fields{
+				"bundle": b.ID().String(),
+				"size":   bi.Size,
+				"error":  err,
+			}).Info("Bundle does not fit into the Store's quota")
+
+			return err
+		}
+
+		if err := is.insertItem(bi, b); err != nil {
+			qk.unreserve(bi.Size, 1)
 			return err
 		}
 
-		return s.bh.Insert(bi.Id, bi)
+		qk.stored(bi)
+		return nil
 	} else if bi.Fragmented {
 		if !biStore.Fragmented {
 			log.WithFields(log.Fields{
@@ -88,33 +131,33 @@ func (s *Store) Push(b bundle.Bundle) error {
 			return nil
 		}
 
-		knownFragment := false
 		compPart := bi.Parts[0]
 		for _, part := range biStore.Parts {
 			if part.FragmentOffset == compPart.FragmentOffset &&
 				part.TotalDataLength == compPart.TotalDataLength {
-				knownFragment = true
-				break
+				log.WithFields(log.Fields{
+					"bundle": b.ID().String(),
+				}).Debug("Received bundle fragment, which is already stored")
+				return nil
 			}
 		}
 
-		if knownFragment {
-			log.WithFields(log.Fields{
-				"bundle": b.ID().String(),
-			}).Debug("Received bundle fragment, which is already stored")
-			return nil
-		} else {
-			log.WithFields(log.Fields{
-				"bundle": b.ID().String(),
-			}).Info("Received new bundle fragment, updating BundleItem")
+		log.WithFields(log.Fields{
+			"bundle": b.ID().String(),
+		}).Info("Received new bundle fragment, updating BundleItem")
 
-			if err := compPart.storeBundle(b); err != nil {
-				return err
-			}
+		if err := qk.reserve(biStore, bi.Size, 0); err != nil {
+			return err
+		}
 
-			biStore.Parts = append(biStore.Parts, compPart)
-			return s.bh.Update(biStore.Id, biStore)
+		biStore.Size += bi.Size
+		if err := is.appendPart(biStore, compPart, b); err != nil {
+			qk.unreserve(bi.Size, 0)
+			return err
 		}
+
+		qk.stored(biStore)
+		return nil
 	} else {
 		log.WithFields(log.Fields{
 			"bundle": b.ID().String(),
@@ -124,42 +167,10 @@ func (s *Store) Push(b bundle.Bundle) error {
 	}
 }
 
-// Update an existing BundleItem.
-func (s *Store) Update(bi BundleItem) error {
-	log.WithFields(log.Fields{
-		"bundle": bi.Id,
-	}).Debug("Store updates BundleItem")
-
-	return s.bh.Update(bi.Id, bi)
-}
-
-// Delete a BundleItem, represented by the "scrubed" BundleID.
-func (s *Store) Delete(bid bundle.BundleID) error {
-	if bi, err := s.QueryId(bid); err == nil {
-		log.WithFields(log.Fields{
-			"bundle": bid,
-		}).Info("Store deletes BundleItem")
-
-		for _, bp := range bi.Parts {
-			if err := bp.deleteBundle(); err != nil {
-				log.WithFields(log.Fields{
-					"bundle": bid,
-					"file":   bp.Filename,
-					"error":  err,
-				}).Warn("Failed to delete BundlePart")
-			}
-		}
-
-		return s.bh.Delete(bi.Id, BundleItem{})
-	}
-
-	return nil
-}
-
-// DeleteExpired removes all expired Bundles.
-func (s *Store) DeleteExpired() {
-	var bis []BundleItem
-	if err := s.bh.Find(&bis, badgerhold.Where("Expires").Lt(time.Now())); err != nil {
+// deleteExpired removes all expired Bundles from a Store.
+func deleteExpired(s Store) {
+	bis, err := s.QueryExpired(time.Now())
+	if err != nil {
 		log.WithError(err).Warn("Failed to get expired Bundles")
 		return
 	}
@@ -174,20 +185,24 @@ func (s *Store) DeleteExpired() {
 	}
 }
 
-// QueryId fetches the BundleItem for the requested BundleID.
-func (s *Store) QueryId(bid bundle.BundleID) (bi BundleItem, err error) {
-	err = s.bh.Get(bid.Scrub().String(), &bi)
-	return
-}
+// storeStats summarizes the Bundles of a Store.
+func storeStats(s Store) (stats StoreStats, err error) {
+	bis, err := s.QueryAll()
+	if err != nil {
+		return
+	}
 
-// QueryPending fetches all pending Bundles.
-func (s *Store) QueryPending() (bis []BundleItem, err error) {
-	err = s.bh.Find(&bis, badgerhold.Where("Pending").Eq(true))
+	stats.Bundles = len(bis)
+	for _, bi := range bis {
+		if bi.Pending {
+			stats.Pending++
+		}
+		if bi.Local {
+			stats.Local++
+		}
+		if bi.Fragmented {
+			stats.Fragmented++
+		}
+	}
 	return
 }
-
-// KnowsBundle checks if such a Bundle is known.
-func (s *Store) KnowsBundle(bid bundle.BundleID) bool {
-	_, err := s.QueryId(bid)
-	return err != badgerhold.ErrNotFound
-}
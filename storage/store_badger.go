@@ -0,0 +1,240 @@
+// SPDX-FileCopyrightText: 2019, 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package storage
+
+import (
+	"os"
+	"path"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/bundle"
+	"github.com/timshannon/badgerhold"
+)
+
+const (
+	dirBadger string = "db"
+	dirBundle string = "bndl"
+)
+
+// badgerErr replaces badgerhold's ErrNotFound by the Store's ErrNotFound.
+func badgerErr(err error) error {
+	if err == badgerhold.ErrNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+// BadgerStore is a Store which persists the BundleItems in a badgerhold database and the Bundles as files.
+type BadgerStore struct {
+	*quotaKeeper
+
+	bh *badgerhold.Store
+
+	badgerDir string
+	bundleDir string
+}
+
+// NewStore creates a new BadgerStore or opens an existing BadgerStore from the given path.
+func NewStore(dir string) (s *BadgerStore, err error) {
+	badgerDir := path.Join(dir, dirBadger)
+	bundleDir := path.Join(dir, dirBundle)
+
+	opts := badgerhold.DefaultOptions
+	opts.Dir = badgerDir
+	opts.ValueDir = badgerDir
+	opts.Logger = log.StandardLogger()
+	opts.Options.ValueLogFileSize = 1<<28 - 1
+
+	if dirErr := os.MkdirAll(badgerDir, 0700); dirErr != nil {
+		err = dirErr
+		return
+	}
+	if dirErr := os.MkdirAll(bundleDir, 0700); dirErr != nil {
+		err = dirErr
+		return
+	}
+
+	if bh, bhErr := badgerhold.Open(opts); bhErr != nil {
+		err = bhErr
+	} else {
+		s = &BadgerStore{
+			bh: bh,
+
+			badgerDir: badgerDir,
+			bundleDir: bundleDir,
+		}
+		s.quotaKeeper = newQuotaKeeper(s)
+
+		err = s.calcUsage()
+	}
+	return
+}
+
+// calcUsage sums up the sizes of all stored BundleItems. The size of BundleItems stored before its introduction is
+// determined from their files.
+func (s *BadgerStore) calcUsage() error {
+	bis, err := s.QueryAll()
+	if err != nil {
+		return err
+	}
+
+	for _, bi := range bis {
+		if bi.Size == 0 {
+			for _, bp := range bi.Parts {
+				bi.Size += bp.fileSize()
+			}
+
+			if err := s.bh.Update(bi.Id, bi); err != nil {
+				return err
+			}
+		}
+
+		s.track(bi)
+	}
+
+	return nil
+}
+
+// Close the BadgerStore. It must not be used afterwards.
+func (s *BadgerStore) Close() error {
+	return s.bh.Close()
+}
+
+// Push a new/received Bundle to the BadgerStore.
+func (s *BadgerStore) Push(b bundle.Bundle) error {
+	return pushBundle(s, s.quotaKeeper, b, newBundleItem(b, s.bundleDir))
+}
+
+func (s *BadgerStore) insertItem(bi BundleItem, b bundle.Bundle) error {
+	if err := bi.Parts[0].storeBundle(b); err != nil {
+		return err
+	}
+
+	if err := s.bh.Insert(bi.Id, bi); err != nil {
+		_ = bi.Parts[0].deleteBundle()
+		return err
+	}
+
+	return nil
+}
+
+func (s *BadgerStore) appendPart(bi BundleItem, part BundlePart, b bundle.Bundle) error {
+	if err := part.storeBundle(b); err != nil {
+		return err
+	}
+
+	bi.Parts = append(bi.Parts, part)
+	return s.bh.Update(bi.Id, bi)
+}
+
+// Update an existing BundleItem.
+func (s *BadgerStore) Update(bi BundleItem) error {
+	log.WithFields(log.Fields{
+		"bundle": bi.Id,
+	}).Debug("Store updates BundleItem")
+
+	if err := badgerErr(s.bh.Update(bi.Id, bi)); err != nil {
+		return err
+	}
+
+	s.stored(bi)
+	return nil
+}
+
+// Delete a BundleItem, represented by the "scrubed" BundleID.
+func (s *BadgerStore) Delete(bid bundle.BundleID) error {
+	if bi, err := s.QueryId(bid); err == nil {
+		log.WithFields(log.Fields{
+			"bundle": bid,
+		}).Info("Store deletes BundleItem")
+
+		for _, bp := range bi.Parts {
+			if err := bp.deleteBundle(); err != nil {
+				log.WithFields(log.Fields{
+					"bundle": bid,
+					"file":   bp.Filename,
+					"error":  err,
+				}).Warn("Failed to delete BundlePart")
+			}
+		}
+
+		if err := s.bh.Delete(bi.Id, BundleItem{}); err != nil {
+			return err
+		}
+
+		s.deleted(bi)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes all expired Bundles.
+func (s *BadgerStore) DeleteExpired() {
+	deleteExpired(s)
+}
+
+// QueryId fetches the BundleItem for the requested BundleID.
+func (s *BadgerStore) QueryId(bid bundle.BundleID) (bi BundleItem, err error) {
+	err = badgerErr(s.bh.Get(bid.Scrub().String(), &bi))
+	return
+}
+
+// QueryPending fetches all pending Bundles.
+func (s *BadgerStore) QueryPending() (bis []BundleItem, err error) {
+	err = s.bh.Find(&bis, badgerhold.Where("Pending").Eq(true))
+	return
+}
+
+// QueryLocal fetches all Bundles which are stored for a local endpoint until their delivery.
+func (s *BadgerStore) QueryLocal() (bis []BundleItem, err error) {
+	err = s.bh.Find(&bis, badgerhold.Where("Local").Eq(true))
+	return
+}
+
+// QueryExpired fetches all Bundles which expire before the given time.
+func (s *BadgerStore) QueryExpired(t time.Time) (bis []BundleItem, err error) {
+	err = s.bh.Find(&bis, badgerhold.Where("Expires").Lt(t))
+	return
+}
+
+// QueryAll fetches all Bundles.
+func (s *BadgerStore) QueryAll() (bis []BundleItem, err error) {
+	err = s.bh.Find(&bis, nil)
+	return
+}
+
+// Stats summarizes the stored Bundles.
+func (s *BadgerStore) Stats() (StoreStats, error) {
+	return storeStats(s)
+}
+
+// KnowsBundle checks if such a Bundle is known.
+func (s *BadgerStore) KnowsBundle(bid bundle.BundleID) bool {
+	_, err := s.QueryId(bid)
+	return err != ErrNotFound
+}
+
+// StoreState inserts or replaces the state of the given name.
+func (s *BadgerStore) StoreState(name string, data []byte) error {
+	log.WithFields(log.Fields{
+		"state": name,
+		"size":  len(data),
+	}).Debug("Store persists state")
+
+	return s.bh.Upsert(name, StateItem{Name: name, Data: data, Stored: time.Now()})
+}
+
+// QueryState fetches the StateItem of the given name.
+func (s *BadgerStore) QueryState(name string) (si StateItem, err error) {
+	err = badgerErr(s.bh.Get(name, &si))
+	return
+}
+
+// DeleteState removes the state of the given name.
+func (s *BadgerStore) DeleteState(name string) error {
+	return badgerErr(s.bh.Delete(name, StateItem{}))
+}
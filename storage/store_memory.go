@@ -0,0 +1,257 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package storage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/dtn7/dtn7-go/bundle"
+)
+
+// MemoryStore is a Store which keeps everything in memory and loses its content when being closed, e.g., for tests,
+// simulations or embedded nodes without persistent storage.
+//
+// BundleItems are kept serialized, just like in the BadgerStore. Thus, modifications of a queried BundleItem do not
+// affect the stored one until being updated and its Properties must be registered for gob encoding.
+type MemoryStore struct {
+	*quotaKeeper
+
+	items  map[string][]byte
+	states map[string]StateItem
+	mutex  sync.RWMutex
+}
+
+// NewMemoryStore creates a new and empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{
+		items:  make(map[string][]byte),
+		states: make(map[string]StateItem),
+	}
+	s.quotaKeeper = newQuotaKeeper(s)
+
+	return s
+}
+
+// encodeItem serializes a BundleItem for the items map.
+func encodeItem(bi BundleItem) ([]byte, error) {
+	var buff bytes.Buffer
+	if err := gob.NewEncoder(&buff).Encode(bi); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+// decodeItem deserializes a BundleItem from the items map.
+func decodeItem(data []byte) (bi BundleItem, err error) {
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&bi)
+	return
+}
+
+// partData serializes a Bundle into a BundlePart's Data field.
+func partData(part *BundlePart, b bundle.Bundle) error {
+	var buff bytes.Buffer
+	if err := b.WriteBundle(&buff); err != nil {
+		return err
+	}
+
+	part.Filename = ""
+	part.Data = buff.Bytes()
+	return nil
+}
+
+// put inserts or replaces a BundleItem.
+func (s *MemoryStore) put(bi BundleItem) error {
+	data, err := encodeItem(bi)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.items[bi.Id] = data
+	s.mutex.Unlock()
+
+	return nil
+}
+
+// find all BundleItems matching the predicate.
+func (s *MemoryStore) find(predicate func(bi BundleItem) bool) (bis []BundleItem, err error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, data := range s.items {
+		if bi, decErr := decodeItem(data); decErr != nil {
+			return nil, decErr
+		} else if predicate(bi) {
+			bis = append(bis, bi)
+		}
+	}
+	return
+}
+
+// Close the MemoryStore, dropping its content.
+func (s *MemoryStore) Close() error {
+	s.mutex.Lock()
+	s.items = make(map[string][]byte)
+	s.states = make(map[string]StateItem)
+	s.mutex.Unlock()
+
+	return nil
+}
+
+// Push a new/received Bundle to the MemoryStore.
+func (s *MemoryStore) Push(b bundle.Bundle) error {
+	return pushBundle(s, s.quotaKeeper, b, newBundleItem(b, ""))
+}
+
+func (s *MemoryStore) insertItem(bi BundleItem, b bundle.Bundle) error {
+	if err := partData(&bi.Parts[0], b); err != nil {
+		return err
+	}
+
+	return s.put(bi)
+}
+
+func (s *MemoryStore) appendPart(bi BundleItem, part BundlePart, b bundle.Bundle) error {
+	if err := partData(&part, b); err != nil {
+		return err
+	}
+
+	bi.Parts = append(bi.Parts, part)
+	return s.put(bi)
+}
+
+// Update an existing BundleItem.
+func (s *MemoryStore) Update(bi BundleItem) error {
+	log.WithFields(log.Fields{
+		"bundle": bi.Id,
+	}).Debug("Store updates BundleItem")
+
+	if !s.knowsItem(bi.Id) {
+		return ErrNotFound
+	}
+	if err := s.put(bi); err != nil {
+		return err
+	}
+
+	s.stored(bi)
+	return nil
+}
+
+// Delete a BundleItem, represented by the "scrubed" BundleID.
+func (s *MemoryStore) Delete(bid bundle.BundleID) error {
+	if bi, err := s.QueryId(bid); err == nil {
+		log.WithFields(log.Fields{
+			"bundle": bid,
+		}).Info("Store deletes BundleItem")
+
+		s.mutex.Lock()
+		delete(s.items, bi.Id)
+		s.mutex.Unlock()
+
+		s.deleted(bi)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes all expired Bundles.
+func (s *MemoryStore) DeleteExpired() {
+	deleteExpired(s)
+}
+
+// knowsItem checks if a BundleItem of this Id is stored.
+func (s *MemoryStore) knowsItem(id string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, ok := s.items[id]
+	return ok
+}
+
+// KnowsBundle checks if such a Bundle is known.
+func (s *MemoryStore) KnowsBundle(bid bundle.BundleID) bool {
+	return s.knowsItem(bid.Scrub().String())
+}
+
+// QueryId fetches the BundleItem for the requested BundleID.
+func (s *MemoryStore) QueryId(bid bundle.BundleID) (bi BundleItem, err error) {
+	s.mutex.RLock()
+	data, ok := s.items[bid.Scrub().String()]
+	s.mutex.RUnlock()
+
+	if !ok {
+		err = ErrNotFound
+		return
+	}
+	return decodeItem(data)
+}
+
+// QueryPending fetches all pending Bundles.
+func (s *MemoryStore) QueryPending() ([]BundleItem, error) {
+	return s.find(func(bi BundleItem) bool { return bi.Pending })
+}
+
+// QueryLocal fetches all Bundles which are stored for a local endpoint until their delivery.
+func (s *MemoryStore) QueryLocal() ([]BundleItem, error) {
+	return s.find(func(bi BundleItem) bool { return bi.Local })
+}
+
+// QueryExpired fetches all Bundles which expire before the given time.
+func (s *MemoryStore) QueryExpired(t time.Time) ([]BundleItem, error) {
+	return s.find(func(bi BundleItem) bool { return bi.Expires.Before(t) })
+}
+
+// QueryAll fetches all Bundles.
+func (s *MemoryStore) QueryAll() ([]BundleItem, error) {
+	return s.find(func(_ BundleItem) bool { return true })
+}
+
+// Stats summarizes the stored Bundles.
+func (s *MemoryStore) Stats() (StoreStats, error) {
+	return storeStats(s)
+}
+
+// StoreState inserts or replaces the state of the given name.
+func (s *MemoryStore) StoreState(name string, data []byte) error {
+	log.WithFields(log.Fields{
+		"state": name,
+		"size":  len(data),
+	}).Debug("Store persists state")
+
+	s.mutex.Lock()
+	s.states[name] = StateItem{Name: name, Data: append([]byte(nil), data...), Stored: time.Now()}
+	s.mutex.Unlock()
+
+	return nil
+}
+
+// QueryState fetches the StateItem of the given name.
+func (s *MemoryStore) QueryState(name string) (si StateItem, err error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	si, ok := s.states[name]
+	if !ok {
+		err = ErrNotFound
+	}
+	return
+}
+
+// DeleteState removes the state of the given name.
+func (s *MemoryStore) DeleteState(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.states[name]; !ok {
+		return ErrNotFound
+	}
+	delete(s.states, name)
+	return nil
+}
@@ -0,0 +1,139 @@
+// SPDX-FileCopyrightText: 2020 Alvar Penning
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dtn7/dtn7-go/bundle"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestMemoryStoreItemCopies(t *testing.T) {
+	store := NewMemoryStore()
+	defer store.Close()
+
+	b := quotaBundle(t, "src", "10m", 64)
+	if err := store.Push(b); err != nil {
+		t.Fatal(err)
+	}
+
+	bi, err := store.QueryId(b.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bi.Pending = true
+	bi.Properties["test"] = "modified"
+
+	if bi2, err := store.QueryId(b.ID()); err != nil {
+		t.Fatal(err)
+	} else if bi2.Pending || len(bi2.Properties) != 0 {
+		t.Fatalf("Stored BundleItem was modified without an update: %v", bi2)
+	}
+
+	if err := store.Update(bi); err != nil {
+		t.Fatal(err)
+	} else if bi2, err := store.QueryId(b.ID()); err != nil {
+		t.Fatal(err)
+	} else if !bi2.Pending || bi2.Properties["test"] != "modified" {
+		t.Fatalf("Updated BundleItem is %v", bi2)
+	}
+
+	unknown := quotaBundle(t, "unknown", "10m", 64)
+	if _, err := store.QueryId(unknown.ID()); err != ErrNotFound {
+		t.Fatalf("Querying an unknown bundle resulted in %v", err)
+	} else if err := store.Update(newBundleItem(unknown, "")); err != ErrNotFound {
+		t.Fatalf("Updating an unknown bundle resulted in %v", err)
+	}
+}
+
+func TestMemoryStoreFragments(t *testing.T) {
+	store := NewMemoryStore()
+	defer store.Close()
+
+	b := quotaBundle(t, "src", "10m", 512)
+	frags, err := b.Fragment(256)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(frags) < 2 {
+		t.Fatalf("Bundle was split into %d fragments", len(frags))
+	}
+
+	for _, frag := range frags {
+		if err := store.Push(frag); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bi, err := store.QueryId(frags[0].ID())
+	if err != nil {
+		t.Fatal(err)
+	} else if !bi.Fragmented || len(bi.Parts) != len(frags) {
+		t.Fatalf("BundleItem has %d parts, instead of %d", len(bi.Parts), len(frags))
+	}
+
+	var loaded []bundle.Bundle
+	for _, part := range bi.Parts {
+		if part.Filename != "" {
+			t.Fatalf("BundlePart refers to file %s", part.Filename)
+		} else if frag, err := part.Load(); err != nil {
+			t.Fatal(err)
+		} else {
+			loaded = append(loaded, frag)
+		}
+	}
+
+	b2, err := bundle.ReassembleFragments(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buff1, buff2 bytes.Buffer
+	if err := b.MarshalCbor(&buff1); err != nil {
+		t.Fatal(err)
+	} else if err := b2.MarshalCbor(&buff2); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(buff1.Bytes(), buff2.Bytes()) {
+		t.Fatal("Reassembled bundle differs")
+	}
+
+	if _, bundles := store.Usage(); bundles != 1 {
+		t.Fatalf("Store's usage lists %d bundles", bundles)
+	}
+}
+
+func TestMemoryStoreState(t *testing.T) {
+	store := NewMemoryStore()
+
+	if _, err := store.QueryState("routing/test"); err != ErrNotFound {
+		t.Fatalf("Querying an unknown state resulted in %v", err)
+	}
+
+	if err := store.StoreState("routing/test", []byte("hello")); err != nil {
+		t.Fatal(err)
+	} else if si, err := store.QueryState("routing/test"); err != nil {
+		t.Fatal(err)
+	} else if string(si.Data) != "hello" {
+		t.Fatalf("State is %s", si.Data)
+	}
+
+	if err := store.DeleteState("routing/test"); err != nil {
+		t.Fatal(err)
+	} else if err := store.DeleteState("routing/test"); err != ErrNotFound {
+		t.Fatalf("Deleting an unknown state resulted in %v", err)
+	}
+
+	if err := store.StoreState("routing/test", []byte("hello")); err != nil {
+		t.Fatal(err)
+	} else if err := store.Close(); err != nil {
+		t.Fatal(err)
+	} else if _, err := store.QueryState("routing/test"); err != ErrNotFound {
+		t.Fatal("State survived closing the MemoryStore")
+	}
+}
@@ -35,6 +35,11 @@ func TestStore(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	testStore(t, store)
+}
+
+// testStore checks the basic operations of an empty Store implementation and closes it afterwards.
+func testStore(t *testing.T, store Store) {
 	b, bErr := bundle.Builder().
 		Source("dtn://src/").
 		Destination("dtn://dest/").
@@ -85,6 +90,33 @@ func TestStore(t *testing.T) {
 		t.Fatalf("Found %d pending BundleItem, instead of 1", l)
 	}
 
+	if bil, err := store.QueryLocal(); err != nil {
+		t.Fatal(err)
+	} else if l := len(bil); l != 0 {
+		t.Fatalf("Found %d local BundleItem, instead of 0", l)
+	}
+
+	if bi, err := store.QueryId(b.ID()); err != nil {
+		t.Fatal(err)
+	} else {
+		bi.Local = true
+		if err := store.Update(bi); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if bil, err := store.QueryLocal(); err != nil {
+		t.Fatal(err)
+	} else if l := len(bil); l != 1 {
+		t.Fatalf("Found %d local BundleItem, instead of 1", l)
+	}
+
+	if stats, err := store.Stats(); err != nil {
+		t.Fatal(err)
+	} else if expected := (StoreStats{Bundles: 1, Pending: 1, Local: 1}); stats != expected {
+		t.Fatalf("Store's stats are %v, instead of %v", stats, expected)
+	}
+
 	if bi, err := store.QueryId(b.ID()); err != nil {
 		t.Fatal(err)
 	} else {
@@ -96,11 +128,93 @@ func TestStore(t *testing.T) {
 
 	store.DeleteExpired()
 
-	if bi, err := store.QueryId(b.ID()); err == nil {
-		t.Fatalf("Deleted expired BundleItem was found: %v", bi)
+	if bi, err := store.QueryId(b.ID()); err != ErrNotFound {
+		t.Fatalf("Deleted expired BundleItem was found: %v, %v", bi, err)
+	} else if store.KnowsBundle(b.ID()) {
+		t.Fatal("Deleted expired BundleItem is known")
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreState(t *testing.T) {
+	dir := setupStoreDir(t)
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.QueryState("routing/test"); err == nil {
+		t.Fatal("Unknown state was found")
+	}
+
+	for _, data := range [][]byte{[]byte("hello"), []byte("world")} {
+		if err := store.StoreState("routing/test", data); err != nil {
+			t.Fatal(err)
+		}
 	}
 
 	if err := store.Close(); err != nil {
 		t.Fatal(err)
 	}
+
+	store, err = NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if si, err := store.QueryState("routing/test"); err != nil {
+		t.Fatal(err)
+	} else if string(si.Data) != "world" {
+		t.Fatalf("State is %s", si.Data)
+	} else if time.Since(si.Stored) > time.Minute {
+		t.Fatalf("State's timestamp is %v", si.Stored)
+	}
+
+	if bis, err := store.QueryAll(); err != nil {
+		t.Fatal(err)
+	} else if len(bis) != 0 {
+		t.Fatalf("State is listed as %d BundleItems", len(bis))
+	}
+
+	if err := store.DeleteState("routing/test"); err != nil {
+		t.Fatal(err)
+	} else if _, err := store.QueryState("routing/test"); err == nil {
+		t.Fatal("Deleted state was found")
+	}
+}
+
+func TestStoreExpiresBundleAge(t *testing.T) {
+	store := NewMemoryStore()
+	defer store.Close()
+
+	// A bundle from a node without a synchronized clock, being ten minutes old at its reception.
+	b, err := bundle.Builder().
+		Source("dtn://sensor/").
+		Destination("dtn://dest/").
+		CreationTimestampEpoch().
+		Lifetime("30m").
+		BundleAgeBlock(uint64(10 * time.Minute / time.Millisecond)).
+		PayloadBlock([]byte("hello world")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Push(b); err != nil {
+		t.Fatal(err)
+	}
+
+	store.DeleteExpired()
+
+	if bi, err := store.QueryId(b.ID()); err != nil {
+		t.Fatal(err)
+	} else if remaining := time.Until(bi.Expires); remaining < 19*time.Minute || remaining > 20*time.Minute {
+		t.Fatalf("BundleItem expires in %v", remaining)
+	}
 }